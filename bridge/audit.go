@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// auditMaxSize is the size at which the audit file is rotated to <file>.1
+const auditMaxSize = 1 << 20
+
+const auditFileMode = 0600
+
+// auditRecord is an executed command as written to the audit file and <device_name>/audit/last.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	Entity  string    `json:"entity"`
+	Payload string    `json:"payload"`
+	Source  string    `json:"source,omitempty"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// auditMu keeps records of commands finishing at the same time from interleaving in the file
+var auditMu sync.Mutex
+
+// subscribeAudit records the executed commands on the event bus with audit.enabled. The returned function stops it.
+func subscribeAudit(client mqttclient.Client) func() {
+	appConf := appconfig.RequireConfig()
+	if !appConf.Audit.Enabled {
+		return func() {}
+	}
+
+	path := appConf.Audit.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(appconfig.ConfigDir(), path)
+	}
+	topic := appConf.DeviceName + "/audit/last"
+	if err := appstate.RecordRetainedTopics([]string{topic}); err != nil {
+		logger.Warn("Failed to record retained topics", "err", err)
+	}
+
+	return events.Subscribe(func(event events.Event) {
+		record := auditRecord{Time: event.Time, Payload: event.Payload, Source: event.Source, Success: event.Err == nil}
+		if event.Entity != nil {
+			record.Entity = event.Entity.GetDiscoveryConfig().UniqueId
+		}
+		if event.Err != nil {
+			record.Error = event.Err.Error()
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			logger.Error("Error marshaling audit record", "err", err)
+			return
+		}
+
+		if err := appendAudit(path, line); err != nil {
+			logger.Error("Failed to write audit file", "path", path, "err", err)
+		}
+		qos := byte(appConf.Mqtt.Qos)
+		if err := publishOrQueue(client, topic, qos, true, string(line)); err != nil {
+			logger.Error("Error publishing audit record", "topic", topic, "err", err)
+		}
+	}, events.CommandFinished)
+}
+
+// appendAudit appends line to the audit file at path, after rotating a full file to <path>.1.
+func appendAudit(path string, line []byte) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(line)) > auditMaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, auditFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,757 @@
+// Package bridge runs pc2mqtt. It connects to the MQTT broker, publishes the Home Assistant
+// discovery configs, states and availability of all entities and executes their commands.
+//
+// Programs embedding pc2mqtt add their own entities with entities.Register before calling Run.
+// Entities registered or unregistered while Run is running are announced to or removed from Home Assistant.
+package bridge
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+	"github.com/leonlatsch/pc2mqtt/internal/logging"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+)
+
+const payloadHaOnline = "online"
+
+var (
+	logger        = logging.For(appconfig.LogModuleBridge)
+	commandLogger = logging.For(appconfig.LogModuleCommands)
+)
+
+var (
+	connectionLost        = make(chan struct{}, 1)
+	connectionEstablished = make(chan struct{}, 1)
+	initialConnectionDone = false
+)
+
+// Options select the config the bridge runs with.
+type Options struct {
+	// ConfigPath is a config file or a directory holding config.json and one
+	// <profile>.json per profile. Defaults to config.json.
+	ConfigPath string
+	// Profile names the profile merged over the base config. Empty loads the base config only.
+	Profile string
+	// BrokerUrl replaces the configured brokers, TLS and proxy, eg. with an embedded broker.
+	BrokerUrl string
+	// Simulate leaves the PC alone, eg. for a run against an embedded broker: the local state, like cooldowns
+	// and discovery hashes, and the audit file are kept in a temporary directory, the offline queue isn't
+	// persisted and actions print the commands they would run to stdout.
+	Simulate bool
+}
+
+// ErrRestart is returned by Run after an update was installed. The caller should start
+// the new binary, eg. by exiting under a service manager that restarts pc2mqtt.
+var ErrRestart = errors.New("pc2mqtt was updated and needs to restart")
+
+// Run loads the config, connects to the broker and serves all built-in and registered
+// entities until ctx is canceled. Then it lets running publishes and actions finish,
+// reports the device offline and disconnects.
+func Run(ctx context.Context, opts Options) error {
+	logger.Info("Starting application")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	openBackground()
+	clearPanics()
+
+	if err := appconfig.LoadConfig(appconfig.LoadOptions{Path: opts.ConfigPath, Profile: opts.Profile}); err != nil {
+		return err
+	}
+	appConf := appconfig.RequireConfig()
+	if opts.BrokerUrl != "" {
+		appConf.Mqtt.Url = opts.BrokerUrl
+		appConf.Mqtt.Tls = appconfig.TLSAppConfig{}
+		appConf.Mqtt.Proxy = ""
+	}
+	if opts.Simulate {
+		stateDir, err := os.MkdirTemp("", "pc2mqtt-simulate")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(stateDir)
+		appstate.SetPath(filepath.Join(stateDir, "pc2mqtt-state.json"))
+		defer appstate.SetPath("")
+		appConf.OfflineQueue.Persist = false
+		appConf.Audit.File = filepath.Join(stateDir, "pc2mqtt-audit.jsonl")
+		system.SetDryRun(os.Stdout)
+		defer system.SetDryRun(nil)
+	}
+	if err := logging.Setup(appConf.Logging, appConf.DebugMode); err != nil {
+		return err
+	}
+	system.SetPowerOptions(appConf.Commands.PowerOptions())
+	if mode := appConf.Commands.OsSleepMode(); !system.SleepModeSupported(mode) {
+		logger.Warn("This PC can't sleep with the sleep mode, the sleep button is hidden", "mode", mode)
+	}
+
+	loadOfflineQueue()
+
+	deadline := networkDeadline(time.Now())
+	waitForNetwork(ctx, deadline)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	conn, err := createClient()
+	if err != nil {
+		return err
+	}
+	client := mqttclient.FromPaho(conn)
+	outbox = startPublisher(client)
+	defer outbox.stop()
+	// The last will only reaches the broker once it notices the lost connection
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.Error("Panic, reporting offline before exiting", "panic", recovered)
+			publishOfflineStatus()
+			panic(recovered)
+		}
+	}()
+	scheduler = startScheduler(ctx)
+	// States and command results reach the broker through the event bus
+	stopOutput := subscribeMqttOutput(client)
+	defer stopOutput()
+	stopHomieOutput := subscribeHomieOutput(client)
+	defer stopHomieOutput()
+	stopExecutor := subscribeCommandExecutor()
+	defer stopExecutor()
+	stopWebhooks := subscribeWebhooks(ctx)
+	defer stopWebhooks()
+	stopAudit := subscribeAudit(client)
+	defer stopAudit()
+	startLogMirror(ctx, client)
+
+	// Connect to MQTT broker, retrying until the network deadline
+	if err := connect(ctx, conn, deadline, 0); err != nil {
+		if ctx.Err() != nil {
+			conn.Disconnect(0)
+			return nil
+		}
+		return fmt.Errorf("Failed to connect to MQTT broker: %w", err)
+	}
+
+	// Wait for initial connection
+	select {
+	case <-connectionEstablished:
+		logger.Info("Initial connection established")
+	case <-time.After(initialConnectTimeout):
+		conn.Disconnect(0)
+		return errors.New("Timeout waiting for initial MQTT connection")
+	case <-ctx.Done():
+		conn.Disconnect(0)
+		return nil
+	}
+
+	entityList := entities.GetEntities()
+	entitiesWithCommands := entities.FilterEntitiesWithCommands(entityList)
+	logger.Info("Loaded entities", "entities", len(entityList), "commands", len(entitiesWithCommands))
+
+	if err := appstate.RecordRetainedTopics(entities.RetainedTopics(entityList)); err != nil {
+		logger.Warn("Failed to record retained topics for cleanup", "err", err)
+	}
+
+	stopWatching := watchRegistry(ctx, client)
+	defer stopWatching()
+
+	healthServer, err := startHealthServer(client)
+	if err != nil {
+		logger.Error("Failed to start health endpoint", "err", err)
+	}
+
+	if appConf.UpdateCheck {
+		goTask(ctx, "update check", func() { runUpdateCheck(ctx) })
+	}
+
+	goTask(ctx, "reconnect", func() { runReconnect(ctx, conn) })
+	goTask(ctx, "heartbeat", func() { runHeartbeat(ctx, client) })
+	goTask(ctx, "sensor updates", func() { runSensorUpdates(ctx, client) })
+	goTask(ctx, "schedules", func() { runSchedules(ctx, client) })
+	goTask(ctx, "host checks", func() { runHostChecks(ctx, client) })
+	goTask(ctx, "power state", func() { runPowerStateUpdates(ctx) })
+	goTask(ctx, "session watch", func() { runSessionWatch(ctx) })
+	goTask(ctx, "desktop watch", func() { runDesktopWatch(ctx, client) })
+	goTask(ctx, "notification clicks", func() { runNotificationClicks(ctx, client) })
+	goTask(ctx, "sleep watch", func() { runSleepWatch(ctx, client) })
+	goTask(ctx, "system events", func() { runSystemEvents(ctx) })
+
+	// Wait for shutdown, a restart into an installed update or a panic
+	var result error
+	select {
+	case <-ctx.Done():
+		logger.Info("Application shutting down")
+	case <-update.Installed():
+		logger.Info("Update installed, restarting")
+		result = ErrRestart
+	case err := <-panics:
+		logger.Error("Shutting down after panic", "err", err)
+		result = err
+	}
+	cancel()
+	shutdown(conn, healthServer)
+	return result
+}
+
+// publishAutoDiscoveryConfigs publishes the discovery configs of entityList that changed since they were last
+// published, or all of them with force, eg. once Home Assistant restarted.
+func publishAutoDiscoveryConfigs(client mqttclient.Client, entityList []entities.Entity, force bool) {
+	switch appconfig.RequireConfig().Mqtt.DiscoveryMode {
+	case appconfig.DiscoveryModeNone:
+		return
+	case appconfig.DiscoveryModeDevice:
+		publishDeviceDiscoveryConfig(client, entityList, force)
+		return
+	}
+
+	logger.Info("Publishing auto-discovery configs", "entities", len(entityList), "force", force)
+	var messages []discoveryMessage
+	for i, ety := range entityList {
+		configJson, err := json.Marshal(ety.GetDiscoveryConfig())
+		if err != nil {
+			logger.Error("Error marshaling discovery config", "entity", i, "err", err)
+			continue
+		}
+
+		for _, topic := range entities.DiscoveryTopics(ety.GetDiscoveryTopic()) {
+			messages = append(messages, discoveryMessage{topic: topic, payload: configJson})
+		}
+	}
+
+	published := publishDiscoveryMessages(client, messages, force)
+	logger.Info("Auto-discovery configs published successfully", "published", published, "unchanged", len(messages)-published)
+}
+
+// publishDeviceDiscoveryConfig publishes the entities of every device as components of a single device discovery
+// message, like publishAutoDiscoveryConfigs only if it changed or with force.
+func publishDeviceDiscoveryConfig(client mqttclient.Client, entityList []entities.Entity, force bool) {
+	logger.Info("Publishing device discovery configs", "components", len(entityList), "force", force)
+	configs, err := entities.GetDeviceDiscoveryConfigs(entityList)
+	if err != nil {
+		logger.Error("Error building device discovery config", "err", err)
+		return
+	}
+
+	var messages []discoveryMessage
+	for deviceTopic, config := range configs {
+		configJson, err := json.Marshal(config)
+		if err != nil {
+			logger.Error("Error marshaling device discovery config", "err", err)
+			continue
+		}
+
+		for _, topic := range entities.DiscoveryTopics(deviceTopic) {
+			messages = append(messages, discoveryMessage{topic: topic, payload: configJson})
+		}
+	}
+
+	published := publishDiscoveryMessages(client, messages, force)
+	logger.Info("Device discovery configs published", "published", published, "unchanged", len(messages)-published)
+}
+
+// publishDiscoveryConfig publishes a retained discovery config and waits for the broker.
+func publishDiscoveryConfig(client mqttclient.Client, topic string, configJson []byte) error {
+	return outbox.publish(topic, byte(appconfig.RequireConfig().Mqtt.Qos), true, configJson)
+}
+
+func publishAvailability(client mqttclient.Client, entityList []entities.Entity) {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	logger.Info("Publishing availability", "entities", len(entityList))
+	for topic, payload := range entities.AvailabilityPayloads(entityList) {
+		if err := publishOrQueue(client, topic, byte(mqttConf.Qos), mqttConf.Retain, payload); err != nil {
+			logger.Error("Error publishing availability", "topic", topic, "err", err)
+			continue
+		}
+		logger.Debug("Published availability", "topic", topic, "payload", payload)
+	}
+
+	logger.Info("Availability messages published successfully")
+}
+
+// publishEntityAvailability publishes the availability topics of entities that can go
+// unavailable on their own, without touching the device availability.
+func publishEntityAvailability(client mqttclient.Client, entityList []entities.Entity) {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	deviceTopic := entities.GetDeviceAvailability().Topic
+	var checked []entities.Entity
+	for _, ety := range entityList {
+		if _, ok := ety.(entities.EntityWithAvailability); ok {
+			checked = append(checked, ety)
+		}
+	}
+
+	for topic, payload := range entities.AvailabilityPayloads(checked) {
+		if topic == deviceTopic {
+			continue
+		}
+		if err := publishOrQueue(client, topic, byte(mqttConf.Qos), mqttConf.Retain, payload); err != nil {
+			logger.Error("Error publishing availability", "topic", topic, "err", err)
+			continue
+		}
+		logger.Debug("Published availability", "topic", topic, "payload", payload)
+	}
+}
+
+func publishSensorStates(client mqttclient.Client, entityList []entities.Entity) {
+	var sensors []entities.BinarySensor
+	valueSensors := 0
+	for _, entity := range entityList {
+		switch v := entity.(type) {
+		case entities.BinarySensor:
+			sensors = append(sensors, v)
+		case entities.Sensor, entities.Switch, entities.Select, entities.Update, entities.DeviceTracker:
+			valueSensors++
+		}
+	}
+
+	if len(sensors)+valueSensors == 0 {
+		logger.Debug("No sensors to publish")
+		return
+	}
+
+	logger.Info("Publishing sensor states", "binary_sensors", len(sensors), "sensors", valueSensors)
+	err := publishConcurrently(len(sensors), func(i int) error {
+		sensor := sensors[i]
+		if !sensor.IsAvailable() {
+			return nil
+		}
+		topic := sensor.GetDiscoveryConfig().StateTopic
+		if err := publishOrQueue(client, topic, byte(sensor.DiscoveryConfig.Qos), sensor.Retain, sensor.Payload()); err != nil {
+			return fmt.Errorf("%s: %w", topic, err)
+		}
+		logger.Debug("Published sensor state", "topic", topic)
+		return nil
+	})
+	if err != nil {
+		logger.Error("Error publishing sensor states", "err", err)
+	}
+	publishSensorValues(entityList)
+
+	logger.Info("Sensor states published successfully")
+}
+
+// publishSensorValues publishes the current value of every switch, select, update and device tracker in entityList
+// on the event bus. Its sensors are polled right away by the scheduler.
+func publishSensorValues(entityList []entities.Entity) {
+	scheduler.pollNow(entityList)
+	var stateEvents []events.Event
+	for _, entity := range entityList {
+		event := events.Event{Kind: events.StateUpdated, Entity: entity}
+		switch v := entity.(type) {
+		case entities.Switch:
+			event.Payload, event.Retain = v.Payload(), v.Retain
+		case entities.Select:
+			event.Payload, event.Retain = v.Payload(), v.Retain
+		case entities.Update:
+			event.Payload, event.Retain = v.Payload(), v.Retain
+		case entities.DeviceTracker:
+			event.Payload, event.Retain = v.Payload(), v.Retain
+		default:
+			continue
+		}
+		stateEvents = append(stateEvents, event)
+	}
+	// The outputs log their own errors
+	publishConcurrently(len(stateEvents), func(i int) error {
+		events.Publish(stateEvents[i])
+		return nil
+	})
+}
+
+// runSensorUpdates periodically republishes the entity availability and the switch and update states.
+// Sensors are polled by the scheduler, which picks up changed sensors here.
+func runSensorUpdates(ctx context.Context, client mqttclient.Client) {
+	appConf := appconfig.RequireConfig()
+	if appConf.Diagnostics.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(appConf.Diagnostics.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			entityList := entities.GetEntities()
+			scheduler.sync(entityList)
+			publishEntityAvailability(client, entityList)
+
+			var values []entities.Entity
+			for _, entity := range entityList {
+				if _, ok := entity.(entities.Sensor); !ok {
+					values = append(values, entity)
+				}
+			}
+			publishSensorValues(values)
+		}
+	}
+}
+
+// runHeartbeat periodically republishes availability and binary sensor states,
+// so a stale retained "online" cannot hide a machine that died without a last will.
+func runHeartbeat(ctx context.Context, client mqttclient.Client) {
+	appConf := appconfig.RequireConfig()
+	if appConf.Heartbeat.Interval <= 0 {
+		return
+	}
+
+	interval := time.Duration(appConf.Heartbeat.Interval) * time.Second
+	logger.Info("Publishing heartbeat", "interval", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			publishHeartbeat(client, entities.GetEntities(), appConf.Heartbeat.Retain)
+		}
+	}
+}
+
+func publishHeartbeat(client mqttclient.Client, entityList []entities.Entity, retain bool) {
+	qos := byte(appconfig.RequireConfig().Mqtt.Qos)
+	payloads := entities.AvailabilityPayloads(entityList)
+	for _, ety := range entityList {
+		if sensor, ok := ety.(entities.BinarySensor); ok {
+			payloads[sensor.DiscoveryConfig.StateTopic] = sensor.Payload()
+		}
+	}
+
+	for topic, payload := range payloads {
+		if err := publishOrQueue(client, topic, qos, retain, payload); err != nil {
+			logger.Error("Error publishing heartbeat", "topic", topic, "err", err)
+			continue
+		}
+	}
+	logger.Debug("Published heartbeat", "topics", len(payloads))
+}
+
+func subscribeToCommandTopics(client mqttclient.Client, entitiesWithCommands []entities.EntityWithCommand) {
+	if appconfig.RequireConfig().ReadOnly {
+		commandLogger.Info("Read-only, not subscribing to command topics")
+		return
+	}
+	if len(entitiesWithCommands) == 0 {
+		commandLogger.Info("No command topics to subscribe to")
+		return
+	}
+
+	commandLogger.Info("Subscribing to command topics", "topics", len(entitiesWithCommands))
+
+	commandsConf := appconfig.RequireConfig().Commands
+	gracePeriod := time.Duration(commandsConf.StartupGracePeriod) * time.Second
+	subscribedAt := time.Now()
+
+	// Create a message handler
+	var messageCount int
+	handler := func(msg mqttclient.Message) {
+		defer recoverEvent("command handler")
+		messageCount++
+		topic := msg.Topic
+		payload := string(msg.Payload)
+
+		commandLogger.Info("Received command", "number", messageCount, "topic", topic, "payload", payload)
+
+		if msg.Retained && commandsConf.IgnoreRetained {
+			commandLogger.Warn("Ignoring retained command. Stale retained commands would execute on every start", "topic", topic)
+			return
+		}
+		if time.Since(subscribedAt) < gracePeriod {
+			commandLogger.Warn("Ignoring command received within the startup grace period", "topic", topic, "grace_period", gracePeriod)
+			return
+		}
+
+		matched := false
+		for _, entity := range entitiesWithCommands {
+			if entity.GetDiscoveryConfig().CommandTopic == topic {
+				matched = true
+				events.Publish(events.Event{Kind: events.CommandReceived, Entity: entity, Payload: payload, Source: topic})
+				break
+			}
+		}
+
+		if !matched {
+			commandLogger.Warn("Received message on unhandled topic", "topic", topic)
+		}
+	}
+
+	// Subscribe to all command topics
+	filters := make(map[string]byte)
+	for _, ety := range entitiesWithCommands {
+		topic := ety.GetDiscoveryConfig().CommandTopic
+		filters[topic] = byte(ety.GetDiscoveryConfig().Qos)
+		commandLogger.Debug("Subscribing to topic", "topic", topic)
+	}
+
+	token := client.Subscribe(filters, handler)
+	if token.Wait() && token.Error() != nil {
+		commandLogger.Error("Failed to subscribe to command topics", "err", token.Error())
+		return
+	}
+
+	commandLogger.Info("Ready to receive commands")
+	commandLogger.Debug("Subscribed to command topics", "topics", len(entitiesWithCommands))
+}
+
+// publishCommandResult reports the outcome of an entity's action on its result topic.
+func publishCommandResult(client mqttclient.Client, entity entities.EntityWithCommand, err error) {
+	topic := entity.GetResultTopic()
+	if topic == "" {
+		return
+	}
+
+	resultJson, marshalErr := json.Marshal(entities.NewCommandResult(err))
+	if marshalErr != nil {
+		commandLogger.Error("Error marshaling command result", "err", marshalErr)
+		return
+	}
+
+	if err := outbox.publish(topic, byte(entity.GetDiscoveryConfig().Qos), false, resultJson); err != nil {
+		commandLogger.Error("Error publishing command result", "topic", topic, "err", err)
+		return
+	}
+	commandLogger.Debug("Published command result", "topic", topic)
+}
+
+// subscribeToHomeAssistantStatus republishes discovery configs, availability and
+// states whenever Home Assistant announces it is online again after a restart.
+func subscribeToHomeAssistantStatus(client mqttclient.Client) {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	topics := []string{mqttConf.HaStatusTopic}
+	if mqttConf.HaStatusTopic == "" {
+		topics = nil
+		for _, prefix := range mqttConf.DiscoveryPrefixes() {
+			topics = append(topics, prefix+"/status")
+		}
+	}
+
+	handler := func(msg mqttclient.Message) {
+		defer recoverEvent("Home Assistant status handler")
+		if string(msg.Payload) != payloadHaOnline {
+			logger.Debug("Home Assistant status changed", "status", string(msg.Payload))
+			return
+		}
+
+		logger.Info("Home Assistant is online, republishing discovery configs and states")
+		// Publishing waits for tokens, which must not happen on the message handler goroutine
+		goBackground(func() {
+			defer recoverEvent("Home Assistant status handler")
+			entityList := entities.GetEntities()
+			publishAutoDiscoveryConfigs(client, entityList, true)
+			publishAvailability(client, entityList)
+			publishSensorStates(client, entityList)
+		})
+	}
+
+	for _, topic := range topics {
+		token := client.Subscribe(map[string]byte{topic: byte(mqttConf.Qos)}, handler)
+		if token.Wait() && token.Error() != nil {
+			logger.Error("Failed to subscribe to Home Assistant status topic", "topic", topic, "err", token.Error())
+			continue
+		}
+		logger.Debug("Subscribed to Home Assistant status topic", "topic", topic)
+	}
+}
+
+// NewClientOptions returns the broker, credential and connection options shared by all clients.
+// The config must be loaded.
+func NewClientOptions(clientId string) (*mqtt.ClientOptions, error) {
+	appConf := appconfig.RequireConfig()
+	brokers := brokerUrls(appConf.Mqtt)
+
+	logger.Info("Creating MQTT client", "client_id", clientId, "brokers", strings.Join(brokers, ", "))
+
+	opts := mqtt.NewClientOptions()
+	for _, broker := range brokers {
+		opts.AddBroker(broker)
+	}
+	opts.SetClientID(clientId)
+	opts.SetUsername(appConf.Mqtt.Username)
+	opts.SetPassword(appConf.Mqtt.Password)
+	opts.SetCleanSession(appConf.Mqtt.CleanSession)
+	opts.SetResumeSubs(appConf.Mqtt.ResumeSubs)
+	// The bridge reconnects itself with the configured backoff and jitter, see runReconnect
+	opts.SetAutoReconnect(false)
+	opts.SetConnectRetry(false)
+	opts.SetKeepAlive(time.Duration(appConf.Mqtt.KeepAlive) * time.Second)
+	opts.SetPingTimeout(time.Duration(appConf.Mqtt.PingTimeout) * time.Second)
+	opts.SetConnectTimeout(time.Duration(appConf.Mqtt.ConnectTimeout) * time.Second)
+
+	if appConf.Mqtt.UsesTls() {
+		tlsConfig, err := createTLSConfig(appConf.Mqtt.Tls)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create TLS config: %w", err)
+		}
+		if tlsConfig.InsecureSkipVerify {
+			logger.Warn("TLS certificate verification is disabled")
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	if appConf.Mqtt.Proxy != "" {
+		if err := setupProxy(opts, appConf.Mqtt.Proxy, appConf.Mqtt.UsesWebsocket()); err != nil {
+			return nil, fmt.Errorf("Failed to set up proxy: %w", err)
+		}
+		logger.Info("Connecting through proxy", "proxy", redactUrl(appConf.Mqtt.Proxy))
+	}
+
+	return opts, nil
+}
+
+// ClientId returns the configured MQTT client id or pc2mqtt-<device_name>.
+func ClientId() string {
+	appConf := appconfig.RequireConfig()
+	if appConf.Mqtt.ClientId != "" {
+		return appConf.Mqtt.ClientId
+	}
+	return "pc2mqtt-" + appConf.DeviceName
+}
+
+func createClient() (mqtt.Client, error) {
+	appConf := appconfig.RequireConfig()
+	opts, err := NewClientOptions(ClientId())
+	if err != nil {
+		return nil, err
+	}
+
+	// Set Last Will and Testament. Only one is possible, so Homie gets it only without Home Assistant discovery.
+	if appConf.Homie.Enabled && appConf.Mqtt.DiscoveryMode == appconfig.DiscoveryModeNone {
+		opts.SetWill(entities.HomieStateTopic(), entities.HomieStateLost, homieQos, true)
+	} else {
+		availability := entities.GetDeviceAvailability()
+		opts.SetWill(availability.Topic, availability.PayloadNotAvailable, byte(appConf.Mqtt.Qos), appConf.Mqtt.Retain)
+	}
+
+	// Paho tries the brokers in order, the last one attempted is the one connected to
+	var attempted atomic.Pointer[url.URL]
+	opts.SetConnectionAttemptHandler(func(broker *url.URL, tlsConfig *tls.Config) *tls.Config {
+		attempted.Store(broker)
+		return tlsConfig
+	})
+
+	// Connection callback
+	opts.SetOnConnectHandler(func(conn mqtt.Client) {
+		defer recoverEvent("connect handler")
+		client := mqttclient.FromPaho(conn)
+		if broker := attempted.Load(); broker != nil {
+			logger.Info("Connected", "broker", broker.Redacted())
+		} else {
+			logger.Info("Connected")
+		}
+
+		// Signal connection established
+		select {
+		case connectionEstablished <- struct{}{}:
+		default:
+		}
+
+		// Publish configuration and subscribe (on both initial and reconnection)
+		goBackground(func() {
+			defer recoverEvent("connect handler")
+			entityList := entities.GetEntities()
+
+			flushOfflineQueue(client)
+
+			if !initialConnectionDone {
+				// Only publish auto-discovery configs on initial connection, those unchanged since the last run are
+				// still retained
+				publishAutoDiscoveryConfigs(client, entityList, false)
+				initialConnectionDone = true
+			} else {
+				diagnostics.RecordReconnect()
+			}
+			publishOnlineState(client, entityList)
+		})
+	})
+
+	// Connection lost callback
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		logger.Warn("Connection lost", "err", err)
+		diagnostics.RecordError(err)
+		events.Publish(events.Event{Kind: events.ConnectionLost, Err: err})
+
+		select {
+		case connectionLost <- struct{}{}:
+		default:
+		}
+	})
+
+	client := mqtt.NewClient(opts)
+	logger.Info("MQTT client created successfully")
+	return client, nil
+}
+
+// publishOnlineState publishes the availability and states of entityList and subscribes to the command topics,
+// after every connect and after the PC woke up.
+func publishOnlineState(client mqttclient.Client, entityList []entities.Entity) {
+	appConf := appconfig.RequireConfig()
+	if appConf.Homie.Enabled {
+		publishHomieDevice(client, entityList)
+	}
+
+	publishAvailability(client, entityList)
+	publishSensorStates(client, entityList)
+	subscribeToCommandTopics(client, entities.FilterEntitiesWithCommands(entityList))
+	subscribeToHomeAssistantStatus(client)
+	if appConf.Homie.Enabled {
+		subscribeToHomieCommands(client)
+	}
+}
+
+// brokerUrls returns the brokers from mqtt.url, or builds one from host, port, transport and tls.
+func brokerUrls(conf appconfig.MqttAppConfig) []string {
+	if conf.Url != "" {
+		return conf.BrokerUrls()
+	}
+
+	switch conf.Transport {
+	case appconfig.TransportWebsocket:
+		scheme := "ws"
+		if conf.Tls.Enabled {
+			scheme = "wss"
+		}
+		path := "/" + strings.TrimPrefix(conf.WebsocketPath, "/")
+		return []string{fmt.Sprintf("%v://%v:%v%v", scheme, conf.Host, conf.Port, path)}
+	default:
+		scheme := "tcp"
+		if conf.Tls.Enabled {
+			scheme = "mqtts"
+		}
+		return []string{fmt.Sprintf("%v://%v:%v", scheme, conf.Host, conf.Port)}
+	}
+}
+
+func publishOfflineStatus() {
+	logger.Info("Publishing offline status before shutdown")
+	if appconfig.RequireConfig().Homie.Enabled {
+		publishHomieState(entities.HomieStateDisconnected)
+	}
+	mqttConf := appconfig.RequireConfig().Mqtt
+	availability := entities.GetDeviceAvailability()
+	payload := availability.PayloadNotAvailable
+
+	if err := outbox.publish(availability.Topic, byte(mqttConf.Qos), mqttConf.Retain, payload); err != nil {
+		logger.Error("Failed to publish offline status", "err", err)
+		return
+	}
+	logger.Info("Offline status published successfully")
+}
@@ -0,0 +1,236 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/broker"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// loadTestConfig loads a config of the device box from a temporary directory, which also keeps the local state.
+func loadTestConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"device_id": "dev1", "device_name": "box", "mqtt": {"host": "localhost"}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appconfig.LoadConfig(appconfig.LoadOptions{Path: path}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTestOutbox publishes through client until the test ends.
+func startTestOutbox(t *testing.T, client mqttclient.Client) {
+	t.Helper()
+	outbox = startPublisher(client)
+	t.Cleanup(func() {
+		outbox.stop()
+		outbox = nil
+	})
+}
+
+func testButton(name string) entities.Button {
+	return entities.Button{
+		DiscoveryConfig: &entities.DiscoveryConfig{
+			UniqueId:     "box_button_" + name,
+			CommandTopic: "box/button/" + name + "/command",
+		},
+		Action: func(context.Context) error { return nil },
+	}
+}
+
+func published(fake *mqttclient.Fake) map[string]string {
+	payloads := make(map[string]string)
+	for _, msg := range fake.Published() {
+		payloads[msg.Topic] = string(msg.Payload)
+	}
+	return payloads
+}
+
+func TestCommandRouting(t *testing.T) {
+	loadTestConfig(t)
+	fake := mqttclient.NewFake()
+	shutdown, reboot := testButton("shutdown"), testButton("reboot")
+	subscribeToCommandTopics(fake, []entities.EntityWithCommand{shutdown, reboot})
+
+	received := make(chan events.Event, 4)
+	defer events.Subscribe(func(event events.Event) { received <- event }, events.CommandReceived)()
+
+	fake.Deliver(mqttclient.Message{Topic: "box/button/reboot/command", Payload: []byte("PRESS")})
+	select {
+	case event := <-received:
+		if event.Entity.GetDiscoveryConfig().UniqueId != "box_button_reboot" || event.Payload != "PRESS" {
+			t.Errorf("Routed to %s with %q, want box_button_reboot with PRESS", event.Entity.GetDiscoveryConfig().UniqueId, event.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Command was not routed")
+	}
+
+	// Retained commands are stale and ignored by default
+	fake.Deliver(mqttclient.Message{Topic: "box/button/shutdown/command", Payload: []byte("PRESS"), Retained: true})
+	select {
+	case event := <-received:
+		t.Errorf("Retained command was routed to %s", event.Entity.GetDiscoveryConfig().UniqueId)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	loadTestConfig(t)
+	fake := mqttclient.NewFake()
+	startTestOutbox(t, fake)
+
+	device := entities.GetDeviceAvailability()
+	sensor := entities.Sensor{
+		DiscoveryConfig: &entities.DiscoveryConfig{
+			UniqueId: "box_sensor_gpu",
+			Availability: []entities.Availability{
+				device,
+				{Topic: "box/sensor/gpu/availability", PayloadAvailable: "online", PayloadNotAvailable: "offline"},
+			},
+		},
+		Value:     func() string { return "" },
+		Available: func() bool { return false },
+	}
+	publishAvailability(fake, []entities.Entity{sensor})
+
+	payloads := published(fake)
+	if payloads[device.Topic] != "online" {
+		t.Errorf("Device availability is %q, want online", payloads[device.Topic])
+	}
+	if payloads["box/sensor/gpu/availability"] != "offline" {
+		t.Errorf("Sensor availability is %q, want offline", payloads["box/sensor/gpu/availability"])
+	}
+}
+
+func TestOfflineQueue(t *testing.T) {
+	loadTestConfig(t)
+	fake := mqttclient.NewFake()
+	startTestOutbox(t, fake)
+
+	fake.SetConnected(false)
+	for _, payload := range []string{"1", "2"} {
+		if err := publishOrQueue(fake, "box/sensor/cpu/state", 1, true, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(fake.Published()) != 0 {
+		t.Fatalf("Published %d messages while disconnected", len(fake.Published()))
+	}
+
+	fake.SetConnected(true)
+	flushOfflineQueue(fake)
+	messages := fake.Published()
+	if len(messages) != 1 || string(messages[0].Payload) != "2" || !messages[0].Retained {
+		t.Errorf("Flushed %v, want only the latest retained state 2", messages)
+	}
+}
+
+// failingTopic fails every publish to topic.
+type failingTopic struct {
+	*mqttclient.Fake
+	topic string
+}
+
+func (client failingTopic) Publish(topic string, qos byte, retain bool, payload any) mqttclient.Token {
+	// The publisher publishes from a single goroutine
+	if topic == client.topic {
+		client.Fake.FailPublishes(errors.New("Not authorized"))
+		defer client.Fake.FailPublishes(nil)
+	}
+	return client.Fake.Publish(topic, qos, retain, payload)
+}
+
+func TestPublisherRetriesWithoutBlocking(t *testing.T) {
+	loadTestConfig(t)
+	fake := mqttclient.NewFake()
+	startTestOutbox(t, failingTopic{Fake: fake, topic: "box/denied"})
+
+	failed := make(chan error, 1)
+	go func() { failed <- outbox.publish("box/denied", 1, false, "x") }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if err := outbox.publish("box/state", 1, false, "online"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > publishInitialBackoff {
+		t.Errorf("Publish waited %s for the retries of another topic", elapsed)
+	}
+	if err := <-failed; err == nil {
+		t.Error("Publish to the failing topic succeeded")
+	}
+}
+
+func TestRunWithEmbeddedBroker(t *testing.T) {
+	// Forget the debounce of commands of earlier runs
+	commandLimiter.Lock()
+	clear(commandLimiter.lastExecution)
+	commandLimiter.Unlock()
+
+	embedded, err := broker.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer embedded.Close()
+	published := make(chan broker.Message, 1024)
+	embedded.OnPublish = func(msg broker.Message) { published <- msg }
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"device_id": "dev1", "device_name": "box", "debug_mode": true, "mqtt": {"host": "localhost"}, "diagnostics": {"enabled": false}}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- Run(ctx, Options{ConfigPath: path, BrokerUrl: embedded.Url(), Simulate: true})
+	}()
+
+	// waitFor returns the payload of the next message published to topic
+	waitFor := func(topic string) string {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case msg := <-published:
+				if msg.Topic == topic {
+					return string(msg.Payload)
+				}
+			case <-timeout:
+				t.Fatalf("Nothing was published to %s", topic)
+			}
+		}
+	}
+
+	if payload := waitFor("box/state"); payload != "online" {
+		t.Errorf("Availability is %q, want online", payload)
+	}
+	if err := embedded.WaitSubscribed(ctx, "box/button/test/command"); err != nil {
+		t.Fatal("The test button was not subscribed")
+	}
+	embedded.Publish("box/button/test/command", []byte("PRESS"), false)
+	if payload := waitFor("box/button/test/result"); !strings.Contains(payload, `"success":true`) {
+		t.Errorf("Result is %s, want success", payload)
+	}
+
+	cancel()
+	if payload := waitFor("box/state"); payload != "offline" {
+		t.Errorf("Availability after stopping is %q, want offline", payload)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+)
+
+const rateLimitWindow = time.Minute
+
+// commandLimiter drops repeated commands for the same entity within its debounce
+// window and caps the number of executed actions across all entities.
+var commandLimiter = struct {
+	sync.Mutex
+	lastExecution map[string]time.Time
+	executions    []time.Time
+}{lastExecution: make(map[string]time.Time)}
+
+// allowCommand records an execution for topic and returns nil, or returns
+// the reason the command has to be dropped.
+func allowCommand(topic string, debounce time.Duration) error {
+	commandLimiter.Lock()
+	defer commandLimiter.Unlock()
+
+	now := time.Now()
+	if last, ok := commandLimiter.lastExecution[topic]; ok && now.Sub(last) < debounce {
+		return fmt.Errorf("Ignoring command on %q, last execution was %v ago (debounce %v)", topic, now.Sub(last).Round(time.Millisecond), debounce)
+	}
+
+	maxActions := appconfig.RequireConfig().Commands.MaxActionsPerMinute
+	if maxActions > 0 {
+		recent := commandLimiter.executions[:0]
+		for _, execution := range commandLimiter.executions {
+			if now.Sub(execution) < rateLimitWindow {
+				recent = append(recent, execution)
+			}
+		}
+		commandLimiter.executions = recent
+
+		if len(recent) >= maxActions {
+			return fmt.Errorf("Ignoring command on %q, rate limit of %d actions per minute reached", topic, maxActions)
+		}
+	}
+
+	commandLimiter.lastExecution[topic] = now
+	commandLimiter.executions = append(commandLimiter.executions, now)
+	return nil
+}
+
+// checkCooldown returns the reason the command for entity has to be rejected while its action is in
+// cooldown, or nil. The last executions are kept in the state file, so a reboot doesn't end the cooldown.
+func checkCooldown(entity entities.EntityWithCommand) error {
+	withCooldown, ok := entity.(entities.EntityWithCooldown)
+	if !ok || withCooldown.GetCooldown() <= 0 {
+		return nil
+	}
+
+	last, err := appstate.LastExecution(entity.GetDiscoveryConfig().UniqueId)
+	if err != nil {
+		// Not knowing the last execution must not block the action for good
+		commandLogger.Warn("Failed to read the last execution", "topic", entity.GetDiscoveryConfig().CommandTopic, "err", err)
+		return nil
+	}
+	if since := time.Since(last); since >= 0 && since < withCooldown.GetCooldown() {
+		return fmt.Errorf("Ignoring command on %q, last execution was %v ago (cooldown %v)", entity.GetDiscoveryConfig().CommandTopic, since.Round(time.Second), withCooldown.GetCooldown())
+	}
+	return nil
+}
+
+// recordCooldown stores the execution of the action of entity, if it has a cooldown.
+func recordCooldown(entity entities.EntityWithCommand) {
+	withCooldown, ok := entity.(entities.EntityWithCooldown)
+	if !ok || withCooldown.GetCooldown() <= 0 {
+		return
+	}
+	if err := appstate.RecordExecution(entity.GetDiscoveryConfig().UniqueId, time.Now()); err != nil {
+		commandLogger.Warn("Failed to record the execution", "topic", entity.GetDiscoveryConfig().CommandTopic, "err", err)
+	}
+}
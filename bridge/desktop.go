@@ -0,0 +1,84 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// desktopReadTimeout bounds reading the desktop state
+const desktopReadTimeout = 5 * time.Second
+
+// runDesktopWatch reads the Linux desktop every desktop.interval until ctx is done and publishes
+// logon, lock and display changes right away. The idle time sensor is polled by the scheduler.
+func runDesktopWatch(ctx context.Context, client mqttclient.Client) {
+	conf := appconfig.RequireConfig().Desktop
+	if !conf.Enabled {
+		return
+	}
+	logger.Info("Watching the desktop", "backend", conf.Backend, "interval", conf.Interval)
+
+	var previous system.Desktop
+	var lastErr string
+	first := true
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		timer.Reset(time.Duration(conf.Interval) * time.Second)
+
+		readCtx, cancel := context.WithTimeout(ctx, desktopReadTimeout)
+		current, err := system.ReadDesktop(readCtx, conf.Backend)
+		cancel()
+		if err != nil {
+			// Log once, the desktop stays unreadable eg. without logind
+			if err.Error() != lastErr {
+				logger.Warn("Failed to read the desktop", "err", err)
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+		entities.SetDesktop(current)
+
+		if first || current.LoggedIn != previous.LoggedIn {
+			change := system.SessionLogoff
+			if current.LoggedIn {
+				change = system.SessionLogon
+			}
+			publishSessionChange(change, !first)
+		}
+		if first || current.Locked != previous.Locked {
+			change := system.SessionUnlock
+			if current.Locked {
+				change = system.SessionLock
+			}
+			publishSessionChange(change, !first)
+		}
+		if first || displayChanged(previous.DisplayOn, current.DisplayOn) {
+			if sensor, ok := entities.DesktopDisplaySensor(entities.GetEntities()); ok {
+				if sensor.IsAvailable() {
+					events.Publish(events.Event{Kind: events.StateUpdated, Entity: sensor, Payload: sensor.Payload(), Retain: sensor.Retain})
+				}
+				publishEntityAvailability(client, []entities.Entity{sensor})
+			}
+		}
+		previous, first = current, false
+	}
+}
+
+func displayChanged(previous *bool, current *bool) bool {
+	if previous == nil || current == nil {
+		return previous != current
+	}
+	return *previous != *current
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// discoveryMessage is a retained discovery config. An empty payload removes the config from Home Assistant.
+type discoveryMessage struct {
+	topic   string
+	payload []byte
+}
+
+// publishDiscoveryMessages publishes messages and records their hashes in the local state. Without force
+// configs published with the same content before, also by earlier runs, are skipped, so Home Assistant doesn't
+// reprocess every config on each start. It returns the number of published messages.
+func publishDiscoveryMessages(client mqttclient.Client, messages []discoveryMessage, force bool) int {
+	broker := discoveryBroker()
+	var known map[string]string
+	if !force {
+		var err error
+		if known, err = appstate.DiscoveryHashes(broker); err != nil {
+			logger.Warn("Failed to read the hashes of published discovery configs, publishing all", "err", err)
+		}
+	}
+
+	var changed []discoveryMessage
+	var changedHashes []string
+	for _, message := range messages {
+		hash := discoveryHash(message.payload)
+		if known[message.topic] == hash && hash != "" {
+			continue
+		}
+		changed = append(changed, message)
+		changedHashes = append(changedHashes, hash)
+	}
+
+	published := make([]bool, len(changed))
+	err := publishConcurrently(len(changed), func(i int) error {
+		message := changed[i]
+		if err := publishDiscoveryConfig(client, message.topic, message.payload); err != nil {
+			return fmt.Errorf("%s: %w", message.topic, err)
+		}
+		logger.Debug("Published discovery config", "topic", message.topic, "removed", len(message.payload) == 0)
+		published[i] = true
+		return nil
+	})
+	if err != nil {
+		logger.Error("Error publishing discovery configs", "err", err)
+	}
+
+	hashes := make(map[string]string)
+	for i, message := range changed {
+		if published[i] {
+			hashes[message.topic] = changedHashes[i]
+		}
+	}
+
+	if len(hashes) > 0 {
+		if err := appstate.RecordDiscoveryHashes(broker, hashes); err != nil {
+			logger.Warn("Failed to record the hashes of published discovery configs", "err", err)
+		}
+	}
+	if skipped := len(messages) - len(changed); skipped > 0 {
+		logger.Debug("Skipped unchanged discovery configs", "configs", skipped)
+	}
+	return len(hashes)
+}
+
+// discoveryBroker identifies the brokers the hashes are recorded for, so another broker, eg. the embedded one of
+// simulate, gets every config.
+func discoveryBroker() string {
+	return strings.Join(brokerUrls(appconfig.RequireConfig().Mqtt), ",")
+}
+
+// discoveryHash returns the SHA-256 of a discovery payload, empty for a removed config.
+func discoveryHash(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
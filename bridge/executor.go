@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"errors"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+)
+
+// subscribeCommandExecutor runs the actions of received commands and reports their outcome
+// on the event bus. The returned function stops it.
+func subscribeCommandExecutor() func() {
+	return events.Subscribe(executeCommand, events.CommandReceived)
+}
+
+func executeCommand(event events.Event) {
+	entity, ok := event.Entity.(entities.EntityWithCommand)
+	if !ok {
+		return
+	}
+
+	topic := entity.GetDiscoveryConfig().CommandTopic
+	// Schedules still pass commands
+	if appconfig.RequireConfig().ReadOnly {
+		commandLogger.Warn("Command rejected", "topic", topic, "err", "read_only is set")
+		return
+	}
+	// Before the debounce, a rejected command must not block the next one
+	if err := entities.CheckCommandPayload(entity, event.Payload); err != nil {
+		commandLogger.Warn("Command rejected", "topic", topic, "err", err)
+		return
+	}
+	if err := checkQuietHours(entity, event); err != nil {
+		if errors.Is(err, errDeferred) {
+			commandLogger.Info("Command deferred", "topic", topic, "reason", err)
+		} else {
+			commandLogger.Warn("Command rejected", "topic", topic, "err", err)
+		}
+		publishRejected(entity, event, err)
+		return
+	}
+	if err := checkCooldown(entity); err != nil {
+		commandLogger.Warn("Command rejected", "topic", topic, "err", err)
+		publishRejected(entity, event, err)
+		return
+	}
+	if err := allowCommand(topic, entity.GetDebounce()); err != nil {
+		commandLogger.Warn("Command rejected", "topic", topic, "err", err)
+		return
+	}
+	recordCooldown(entity)
+
+	if !beginBackground() {
+		commandLogger.Warn("Ignoring command received during shutdown", "topic", topic)
+		return
+	}
+
+	commandLogger.Info("Executing command", "topic", topic)
+	entity.QueueAction(event.Payload, func(err error) {
+		defer endBackground()
+		if err != nil {
+			commandLogger.Error("Command failed", "topic", topic, "err", err)
+			diagnostics.RecordError(err)
+		}
+
+		events.Publish(events.Event{Kind: events.CommandFinished, Entity: entity, Payload: event.Payload, Source: event.Source, Err: err})
+		switch entity.(type) {
+		case entities.Switch, entities.Select:
+			publishSensorValues([]entities.Entity{entity})
+		}
+	})
+}
+
+// publishRejected reports the rejected command of event in the background. Commands arrive on the message handler
+// goroutine, which must not wait for the result to be published.
+func publishRejected(entity entities.EntityWithCommand, event events.Event, err error) {
+	goBackground(func() {
+		events.Publish(events.Event{Kind: events.CommandRejected, Entity: entity, Payload: event.Payload, Source: event.Source, Err: err})
+	})
+}
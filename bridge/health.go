@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+	"github.com/leonlatsch/pc2mqtt/internal/logging"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+var healthLogger = logging.For(appconfig.LogModuleHealth)
+
+type healthStatus struct {
+	Status          string     `json:"status"`
+	Connected       bool       `json:"connected"`
+	LastPublish     *time.Time `json:"last_publish"`
+	PublishFailures uint64     `json:"publish_failures"`
+	PublishRetries  uint64     `json:"publish_retries"`
+	Uptime          int64      `json:"uptime"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// startHealthServer serves /healthz on health.listen. It returns nil without listening when no address is set.
+func startHealthServer(client mqttclient.Client) (*http.Server, error) {
+	healthConf := appconfig.RequireConfig().Health
+	if healthConf.Listen == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", healthConf.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := checkHealth(client, time.Duration(healthConf.MaxPublishAge)*time.Second)
+		w.Header().Set("Content-Type", "application/json")
+		if status.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			healthLogger.Error("Health endpoint stopped", "err", err)
+		}
+	}()
+
+	healthLogger.Info("Serving health endpoint", "url", "http://"+listener.Addr().String()+"/healthz")
+	return server, nil
+}
+
+// checkHealth reports unhealthy while the broker connection is down or, with maxPublishAge set,
+// when the last successful publish is older than maxPublishAge.
+func checkHealth(client mqttclient.Client, maxPublishAge time.Duration) healthStatus {
+	status := healthStatus{
+		Status:          "ok",
+		Connected:       client.IsConnectionOpen(),
+		PublishFailures: diagnostics.PublishFailures(),
+		PublishRetries:  diagnostics.PublishRetries(),
+		Uptime:          int64(diagnostics.Uptime().Seconds()),
+	}
+	if lastPublish := diagnostics.LastPublish(); !lastPublish.IsZero() {
+		status.LastPublish = &lastPublish
+	}
+
+	switch {
+	case !status.Connected:
+		status.Status = "unhealthy"
+		status.Error = "Not connected to the MQTT broker"
+	case maxPublishAge > 0 && (status.LastPublish == nil || time.Since(*status.LastPublish) > maxPublishAge):
+		status.Status = "unhealthy"
+		status.Error = "Nothing published for more than " + maxPublishAge.String()
+	}
+
+	return status
+}
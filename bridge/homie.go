@@ -0,0 +1,107 @@
+package bridge
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// Homie requires QoS 1 for retained messages and commands
+const homieQos = 1
+
+// publishHomieDevice announces the entities of entityList as Homie device. The device is in the
+// init state while its attributes change and ready afterwards.
+func publishHomieDevice(client mqttclient.Client, entityList []entities.Entity) {
+	nodes := entities.HomieNodes(entityList)
+	logger.Info("Publishing Homie device", "topic", entities.HomieDeviceTopic(), "nodes", len(nodes))
+	publishHomieState(entities.HomieStateInit)
+
+	attributes := entities.HomieAttributes(nodes)
+	for _, topic := range slices.Sorted(maps.Keys(attributes)) {
+		payload := attributes[topic]
+		if err := outbox.publish(topic, homieQos, true, payload); err != nil {
+			logger.Error("Error publishing Homie attribute", "topic", topic, "err", err)
+		}
+	}
+	// Binary sensors are not polled
+	for _, node := range nodes {
+		if sensor, ok := node.Entity.(entities.BinarySensor); ok {
+			publishHomieValue(client, node, sensor.Payload())
+		}
+	}
+
+	publishHomieState(entities.HomieStateReady)
+}
+
+func publishHomieState(state string) {
+	if err := outbox.publish(entities.HomieStateTopic(), homieQos, true, state); err != nil {
+		logger.Error("Error publishing Homie state", "state", state, "err", err)
+	}
+}
+
+func publishHomieValue(client mqttclient.Client, node entities.HomieNode, payload string) {
+	topic := node.Topic()
+	if err := publishOrQueue(client, topic, homieQos, node.Property.Retained, node.Value(payload)); err != nil {
+		logger.Error("Error publishing Homie value", "topic", topic, "err", err)
+	}
+}
+
+// subscribeHomieOutput publishes the states on the event bus to the Homie properties.
+// The returned function stops it.
+func subscribeHomieOutput(client mqttclient.Client) func() {
+	if !appconfig.RequireConfig().Homie.Enabled {
+		return func() {}
+	}
+
+	return events.Subscribe(func(event events.Event) {
+		for _, node := range entities.HomieNodes([]entities.Entity{event.Entity}) {
+			publishHomieValue(client, node, event.Payload)
+		}
+	}, events.StateUpdated)
+}
+
+// subscribeToHomieCommands receives values set on settable Homie properties and passes
+// them as commands of their entities to the event bus.
+func subscribeToHomieCommands(client mqttclient.Client) {
+	if appconfig.RequireConfig().ReadOnly {
+		return
+	}
+	commandsConf := appconfig.RequireConfig().Commands
+	deviceTopic := entities.HomieDeviceTopic()
+	handler := func(msg mqttclient.Message) {
+		defer recoverEvent("Homie command handler")
+		if msg.Retained && commandsConf.IgnoreRetained {
+			commandLogger.Warn("Ignoring retained command. Stale retained commands would execute on every start", "topic", msg.Topic)
+			return
+		}
+
+		// <base topic>/<device>/<node>/<property>/set
+		nodeId, _, _ := strings.Cut(strings.TrimPrefix(msg.Topic, deviceTopic+"/"), "/")
+		node, ok := entities.FindHomieNode(entities.HomieNodes(entities.GetEntities()), nodeId)
+		if !ok {
+			commandLogger.Warn("Received message on unhandled topic", "topic", msg.Topic)
+			return
+		}
+
+		payload, err := node.CommandPayload(string(msg.Payload))
+		if err != nil {
+			commandLogger.Warn("Command rejected", "topic", msg.Topic, "err", err)
+			return
+		}
+		commandLogger.Info("Received Homie command", "topic", msg.Topic, "payload", string(msg.Payload))
+		events.Publish(events.Event{Kind: events.CommandReceived, Entity: node.Entity, Payload: payload, Source: msg.Topic})
+	}
+
+	topic := deviceTopic + "/+/+/set"
+	token := client.Subscribe(map[string]byte{topic: homieQos}, handler)
+	if token.Wait() && token.Error() != nil {
+		commandLogger.Error("Failed to subscribe to Homie command topics", "topic", topic, "err", token.Error())
+		return
+	}
+	commandLogger.Debug("Subscribed to Homie command topics", "topic", topic)
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+	"github.com/leonlatsch/pc2mqtt/internal/remote"
+)
+
+// hostCheckInterval is how often the hosts controlled over SSH are checked for being up
+const hostCheckInterval = 30 * time.Second
+
+// runHostChecks checks whether the configured hosts are up until ctx is done and publishes
+// their power state and availability whenever it changes.
+func runHostChecks(ctx context.Context, client mqttclient.Client) {
+	hosts := entities.GetHosts()
+	if len(hosts) == 0 {
+		return
+	}
+	logger.Info("Checking hosts", "hosts", len(hosts), "interval", hostCheckInterval)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		for _, host := range hosts {
+			checkHost(ctx, client, host)
+		}
+		timer.Reset(hostCheckInterval)
+	}
+}
+
+func checkHost(ctx context.Context, client mqttclient.Client, host remote.Host) {
+	online := host.Reachable(ctx)
+	if ctx.Err() != nil || !remote.SetOnline(host.Name, online) {
+		return
+	}
+	if online {
+		logger.Info("Host is up", "host", host.Name)
+	} else {
+		logger.Info("Host is down", "host", host.Name)
+	}
+
+	entityList := entities.HostEntities(entities.GetEntities(), host)
+	for _, ety := range entityList {
+		if sensor, ok := ety.(entities.BinarySensor); ok {
+			events.Publish(events.Event{Kind: events.StateUpdated, Entity: sensor, Payload: sensor.Payload(), Retain: sensor.Retain})
+		}
+	}
+	publishEntityAvailability(client, entityList)
+	if online {
+		scheduler.pollNow(entityList)
+	}
+}
@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/logging"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// logQueueSize bounds the log records waiting to be published, further records are dropped
+const logQueueSize = 100
+
+// mqttLogWriter hands the log records written by the logging mirror to the publishing goroutine
+// without blocking the caller.
+type mqttLogWriter chan []byte
+
+func (w mqttLogWriter) Write(record []byte) (int, error) {
+	select {
+	case w <- bytes.Clone(bytes.TrimSuffix(record, []byte("\n"))):
+	default:
+	}
+	return len(record), nil
+}
+
+// startLogMirror publishes the log at logging.mqtt_level to <device_name>/log until ctx is done.
+// The records bypass the publish queue and are dropped while disconnected, so publishing them
+// never logs again itself.
+func startLogMirror(ctx context.Context, client mqttclient.Client) {
+	appConf := appconfig.RequireConfig()
+	if appConf.Logging.MqttLevel == "" {
+		return
+	}
+	level, err := logging.ParseLevel(appConf.Logging.MqttLevel)
+	if err != nil {
+		logger.Error("Failed to mirror log to MQTT", "err", err)
+		return
+	}
+
+	topic := appConf.DeviceName + "/log"
+	writer := make(mqttLogWriter, logQueueSize)
+	stop := logging.Mirror(writer, level)
+	goTask(ctx, "log mirror", func() {
+		for {
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case record := <-writer:
+				if client.IsConnectionOpen() {
+					client.Publish(topic, 0, false, record)
+				}
+			}
+		}
+	})
+	logger.Debug("Mirroring log to MQTT", "topic", topic, "level", level)
+}
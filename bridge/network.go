@@ -0,0 +1,92 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// initialConnectTimeout is how long startup waits for the first connection without mqtt.wait_for_network
+const initialConnectTimeout = 10 * time.Second
+
+// networkDeadline returns until when startup waits for the network and the first connection.
+func networkDeadline(start time.Time) time.Time {
+	wait := time.Duration(appconfig.RequireConfig().Mqtt.WaitForNetwork) * time.Second
+	return start.Add(max(wait, initialConnectTimeout))
+}
+
+// waitForNetwork waits until the host name of a broker, or of the proxy, resolves, retrying every
+// mqtt.connect_retry_interval until deadline. On boot DNS may fail until Wi-Fi is connected. Once
+// deadline passes, connecting is tried anyway.
+func waitForNetwork(ctx context.Context, deadline time.Time) {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	if mqttConf.WaitForNetwork <= 0 {
+		return
+	}
+	hosts := networkHosts(mqttConf)
+	if len(hosts) == 0 {
+		return
+	}
+
+	retryInterval := time.Duration(mqttConf.ConnectRetryInterval) * time.Second
+	waiting := false
+	for {
+		err := resolveAny(ctx, hosts)
+		if err == nil {
+			if waiting {
+				logger.Info("Network is ready")
+			}
+			return
+		}
+		if !time.Now().Add(retryInterval).Before(deadline) {
+			logger.Warn("Network still not ready, connecting anyway", "err", err)
+			return
+		}
+		if !waiting {
+			logger.Info("Waiting for the network", "hosts", hosts, "err", err, "max_wait", time.Until(deadline).Round(time.Second))
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// networkHosts returns the host names startup resolves: the proxy's, which resolves the brokers itself, or those
+// of the brokers. IP addresses need no DNS and are left out.
+func networkHosts(conf appconfig.MqttAppConfig) []string {
+	urls := brokerUrls(conf)
+	if conf.Proxy != "" {
+		urls = []string{conf.Proxy}
+	}
+
+	var hosts []string
+	for _, rawUrl := range urls {
+		parsed, err := url.Parse(rawUrl)
+		if err != nil || parsed.Hostname() == "" || net.ParseIP(parsed.Hostname()) != nil {
+			continue
+		}
+		hosts = append(hosts, parsed.Hostname())
+	}
+	return hosts
+}
+
+// resolveAny returns nil once one of hosts resolves, the last error otherwise.
+func resolveAny(ctx context.Context, hosts []string) error {
+	var err error
+	for _, host := range hosts {
+		lookupCtx, cancel := context.WithTimeout(ctx, initialConnectTimeout)
+		_, err = net.DefaultResolver.LookupHost(lookupCtx, host)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// runNotificationClicks publishes the actions clicked in notifications to the action topic of their
+// notify entity until ctx is done, eg. yes to my-pc/notify/notify/action. Closed full-screen messages are
+// published alike to their closed topic, eg. dismissed to my-pc/notify/message/closed.
+func runNotificationClicks(ctx context.Context, client mqttclient.Client) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case click := <-entities.NotificationClicks():
+			topic := entities.NotificationActionTopic(click.Notify)
+			logger.Info("Notification action clicked", "action", click.Action, "topic", topic)
+			qos := byte(appconfig.RequireConfig().Mqtt.Qos)
+			if err := publishOrQueue(client, topic, qos, false, click.Action); err != nil {
+				logger.Error("Error publishing notification action", "topic", topic, "err", err)
+			}
+		case closed := <-entities.MessageClosures():
+			topic := entities.MessageClosedTopic(closed.Notify)
+			logger.Info("Full-screen message closed", "reason", closed.Reason, "topic", topic)
+			qos := byte(appconfig.RequireConfig().Mqtt.Qos)
+			if err := publishOrQueue(client, topic, qos, false, closed.Reason); err != nil {
+				logger.Error("Error publishing closed message", "topic", topic, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+	"github.com/leonlatsch/pc2mqtt/internal/logging"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+var queueLogger = logging.For(appconfig.LogModuleQueue)
+
+// offlineQueue keeps the latest state message per topic while the broker is unreachable.
+var offlineQueue = struct {
+	sync.Mutex
+	messages map[string]appstate.PendingMessage
+}{messages: make(map[string]appstate.PendingMessage)}
+
+// publishOrQueue publishes a state message, or queues it when the connection is down
+// and the offline queue is enabled. Queued messages are flushed after reconnecting.
+func publishOrQueue(client mqttclient.Client, topic string, qos byte, retain bool, payload string) error {
+	if !client.IsConnectionOpen() {
+		if !appconfig.RequireConfig().OfflineQueue.Enabled {
+			return mqtt.ErrNotConnected
+		}
+
+		enqueueOfflineMessage(appstate.PendingMessage{
+			Topic:   topic,
+			Qos:     qos,
+			Retain:  retain,
+			Payload: payload,
+		})
+		return nil
+	}
+
+	err := outbox.publish(topic, qos, retain, payload)
+	if err != nil && !client.IsConnectionOpen() && appconfig.RequireConfig().OfflineQueue.Enabled {
+		// The connection dropped while publishing, send it after reconnecting
+		enqueueOfflineMessage(appstate.PendingMessage{
+			Topic:   topic,
+			Qos:     qos,
+			Retain:  retain,
+			Payload: payload,
+		})
+		return nil
+	}
+	return err
+}
+
+func enqueueOfflineMessage(message appstate.PendingMessage) {
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+
+	offlineQueue.messages[message.Topic] = message
+	queueLogger.Debug("Queued message until the broker is reachable again", "topic", message.Topic)
+	persistOfflineQueue()
+}
+
+// persistOfflineQueue must be called with the offline queue locked.
+func persistOfflineQueue() {
+	if !appconfig.RequireConfig().OfflineQueue.Persist {
+		return
+	}
+
+	messages := make([]appstate.PendingMessage, 0, len(offlineQueue.messages))
+	for _, message := range offlineQueue.messages {
+		messages = append(messages, message)
+	}
+	if err := appstate.SavePendingMessages(messages); err != nil {
+		queueLogger.Error("Failed to persist offline queue", "err", err)
+	}
+}
+
+// loadOfflineQueue restores messages queued before the last shutdown.
+func loadOfflineQueue() {
+	if !appconfig.RequireConfig().OfflineQueue.Persist {
+		return
+	}
+
+	messages, err := appstate.PendingMessages()
+	if err != nil {
+		queueLogger.Error("Failed to load persisted offline queue", "err", err)
+		return
+	}
+
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+	for _, message := range messages {
+		offlineQueue.messages[message.Topic] = message
+	}
+	if len(messages) > 0 {
+		queueLogger.Info("Loaded queued messages from previous run", "messages", len(messages))
+	}
+}
+
+// flushOfflineQueue publishes all queued messages. Messages failing again stay queued.
+func flushOfflineQueue(client mqttclient.Client) {
+	offlineQueue.Lock()
+	defer offlineQueue.Unlock()
+
+	if len(offlineQueue.messages) == 0 {
+		return
+	}
+
+	queueLogger.Info("Flushing queued messages", "messages", len(offlineQueue.messages))
+	for topic, message := range offlineQueue.messages {
+		if err := outbox.publish(message.Topic, message.Qos, message.Retain, message.Payload); err != nil {
+			queueLogger.Error("Error publishing queued message", "topic", topic, "err", err)
+			continue
+		}
+		delete(offlineQueue.messages, topic)
+	}
+	persistOfflineQueue()
+}
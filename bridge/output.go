@@ -0,0 +1,32 @@
+package bridge
+
+import (
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// subscribeMqttOutput publishes the states and the results of finished and rejected commands on the event
+// bus to the broker.
+// The returned function stops it.
+func subscribeMqttOutput(client mqttclient.Client) func() {
+	return events.Subscribe(func(event events.Event) {
+		switch event.Kind {
+		case events.StateUpdated:
+			publishState(client, event.Entity, event.Payload, event.Retain)
+		case events.CommandFinished, events.CommandRejected:
+			if entity, ok := event.Entity.(entities.EntityWithCommand); ok {
+				publishCommandResult(client, entity, event.Err)
+			}
+		}
+	}, events.StateUpdated, events.CommandFinished, events.CommandRejected)
+}
+
+func publishState(client mqttclient.Client, entity entities.Entity, payload string, retain bool) {
+	config := entity.GetDiscoveryConfig()
+	if err := publishOrQueue(client, config.StateTopic, byte(config.Qos), retain, payload); err != nil {
+		logger.Error("Error publishing sensor state", "topic", config.StateTopic, "err", err)
+		return
+	}
+	logger.Debug("Published sensor state", "topic", config.StateTopic)
+}
@@ -0,0 +1,37 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+)
+
+// countdownInterval is how often the shutdown countdown is published while a warned about power action is pending
+const countdownInterval = 5 * time.Second
+
+// runPowerStateUpdates publishes the blocked and pending power action sensors as soon as a power action
+// starts or stops waiting for inhibitors, instead of on their next poll. The shutdown countdown is
+// published every few seconds until the action runs or is cancelled.
+func runPowerStateUpdates(ctx context.Context) {
+	countdown := time.NewTicker(countdownInterval)
+	countdown.Stop()
+	defer countdown.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-countdown.C:
+			scheduler.pollNow(entities.CountdownSensors(entities.GetEntities()))
+			continue
+		case <-entities.PowerStateChanges():
+		}
+
+		scheduler.pollNow(entities.PowerStateSensors(entities.GetEntities()))
+		if entities.PowerCountdownRunning() {
+			countdown.Reset(countdownInterval)
+		} else {
+			countdown.Stop()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package bridge
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/net/proxy"
+)
+
+// setupProxy routes the broker connection through a SOCKS5 or HTTP CONNECT proxy.
+func setupProxy(opts *mqtt.ClientOptions, proxyAddress string, websocket bool) error {
+	proxyUrl, err := url.Parse(proxyAddress)
+	if err != nil {
+		return err
+	}
+
+	switch proxyUrl.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return errors.New("Unsupported proxy scheme " + proxyUrl.Scheme + ". Use socks5 or http")
+	}
+
+	// Websockets are dialed by the websocket library, which supports both proxy types itself
+	if websocket {
+		opts.SetWebsocketOptions(&mqtt.WebsocketOptions{
+			Proxy: http.ProxyURL(proxyUrl),
+		})
+		return nil
+	}
+
+	opts.SetCustomOpenConnectionFn(func(uri *url.URL, options mqtt.ClientOptions) (net.Conn, error) {
+		dialer := &net.Dialer{Timeout: options.ConnectTimeout}
+		conn, err := dialProxy(proxyUrl, dialer, uri.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		switch uri.Scheme {
+		case "ssl", "tls", "mqtts", "mqtt+ssl", "tcps":
+			tlsConfig := &tls.Config{}
+			if options.TLSConfig != nil {
+				tlsConfig = options.TLSConfig.Clone()
+			}
+			if tlsConfig.ServerName == "" {
+				tlsConfig.ServerName = uri.Hostname()
+			}
+
+			tlsConn := tls.Client(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+
+		return conn, nil
+	})
+
+	return nil
+}
+
+func dialProxy(proxyUrl *url.URL, dialer *net.Dialer, address string) (net.Conn, error) {
+	if proxyUrl.Scheme == "http" {
+		return dialHttpConnect(proxyUrl, dialer, address)
+	}
+
+	socksDialer, err := proxy.FromURL(proxyUrl, dialer)
+	if err != nil {
+		return nil, err
+	}
+	return socksDialer.Dial("tcp", address)
+}
+
+// dialHttpConnect opens a tunnel to address with an HTTP CONNECT request.
+func dialHttpConnect(proxyUrl *url.URL, dialer *net.Dialer, address string) (net.Conn, error) {
+	conn, err := dialer.Dial("tcp", proxyUrl.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyUrl.User != nil {
+		password, _ := proxyUrl.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyUrl.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// Reading through a buffer is safe, the broker never sends before the client's CONNECT packet
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("Proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+
+	return conn, nil
+}
+
+// redactUrl hides the password of a URL for logging.
+func redactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	return u.Redacted()
+}
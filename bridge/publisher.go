@@ -0,0 +1,206 @@
+package bridge
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+const (
+	publishQueueSize      = 256
+	publishBatchSize      = 64
+	publishTimeout        = 10 * time.Second
+	publishMaxAttempts    = 4
+	publishInitialBackoff = 500 * time.Millisecond
+	publishMaxBackoff     = 5 * time.Second
+	// publishWorkers bounds the messages publishConcurrently waits for at once
+	publishWorkers = 16
+)
+
+var (
+	errPublishTimeout   = errors.New("Timeout waiting for the broker to acknowledge the publish")
+	errPublisherStopped = errors.New("Publisher stopped")
+)
+
+// publisher sends all messages through a single goroutine. Messages queued at the same
+// time are published as a batch without waiting in between, failed ones are retried with
+// exponential backoff while the connection is up. Messages waiting for a retry don't hold up the others.
+type publisher struct {
+	client   mqttclient.Client
+	requests chan publishRequest
+	done     chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type publishRequest struct {
+	topic   string
+	qos     byte
+	retain  bool
+	payload any
+	result  chan error
+	// attempts made so far and when the next one is due
+	attempts int
+	retryAt  time.Time
+}
+
+// outbox is the publisher of the running bridge.
+var outbox *publisher
+
+func startPublisher(client mqttclient.Client) *publisher {
+	p := &publisher{
+		client:   client,
+		requests: make(chan publishRequest, publishQueueSize),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// publish queues a message and waits until the broker acknowledged it or all attempts failed.
+func (p *publisher) publish(topic string, qos byte, retain bool, payload any) error {
+	request := publishRequest{
+		topic:   topic,
+		qos:     qos,
+		retain:  retain,
+		payload: payload,
+		result:  make(chan error, 1),
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return errPublisherStopped
+	}
+	p.requests <- request
+	p.mu.Unlock()
+
+	return <-request.result
+}
+
+// stop publishes the queued messages and ends the publisher.
+func (p *publisher) stop() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.requests)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
+
+func (p *publisher) run() {
+	defer close(p.done)
+	requests := p.requests
+	var retries []publishRequest
+	retryTimer := time.NewTimer(publishMaxBackoff)
+	retryTimer.Stop()
+	// After stop the queued messages and the retries are still sent
+	for requests != nil || len(retries) > 0 {
+		select {
+		case request, ok := <-requests:
+			if !ok {
+				requests = nil
+				continue
+			}
+			retries = append(retries, p.send(p.collect(request))...)
+		case now := <-retryTimer.C:
+			var due []publishRequest
+			retries = slices.DeleteFunc(retries, func(request publishRequest) bool {
+				if request.retryAt.After(now) {
+					return false
+				}
+				due = append(due, request)
+				return true
+			})
+			retries = append(retries, p.send(due)...)
+		}
+
+		if len(retries) > 0 {
+			next := slices.MinFunc(retries, func(a, b publishRequest) int { return a.retryAt.Compare(b.retryAt) })
+			retryTimer.Reset(time.Until(next.retryAt))
+		}
+	}
+}
+
+// collect adds the messages queued right now to request, up to publishBatchSize.
+func (p *publisher) collect(request publishRequest) []publishRequest {
+	batch := []publishRequest{request}
+	for len(batch) < publishBatchSize {
+		select {
+		case request, ok := <-p.requests:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, request)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// send publishes batch and returns the failed messages to retry, with the time of their next attempt, until
+// publishMaxAttempts is reached.
+func (p *publisher) send(batch []publishRequest) []publishRequest {
+	tokens := make([]mqttclient.Token, len(batch))
+	for i, request := range batch {
+		tokens[i] = p.client.Publish(request.topic, request.qos, request.retain, request.payload)
+	}
+
+	var failed []publishRequest
+	for i, request := range batch {
+		request.attempts++
+		err := waitToken(tokens[i])
+		switch {
+		case err == nil:
+			diagnostics.RecordPublish()
+			request.result <- nil
+		case request.attempts < publishMaxAttempts && p.client.IsConnectionOpen():
+			backoff := min(publishInitialBackoff<<(request.attempts-1), publishMaxBackoff)
+			request.retryAt = time.Now().Add(backoff)
+			failed = append(failed, request)
+		default:
+			diagnostics.RecordPublishFailure()
+			diagnostics.RecordError(err)
+			request.result <- err
+		}
+	}
+	if len(failed) > 0 {
+		diagnostics.RecordPublishRetries(len(failed))
+		logger.Warn("Retrying failed publishes", "messages", len(failed), "attempt", failed[0].attempts+1, "backoff", time.Until(failed[0].retryAt).Round(time.Millisecond))
+	}
+	return failed
+}
+
+func waitToken(token mqttclient.Token) error {
+	if !token.WaitTimeout(publishTimeout) {
+		return errPublishTimeout
+	}
+	return token.Error()
+}
+
+// publishConcurrently calls publish for the indexes up to count on at most publishWorkers goroutines, so the
+// publisher batches the messages instead of waiting for the broker to acknowledge them one by one. It returns
+// the errors of all calls joined.
+func publishConcurrently(count int, publish func(i int) error) error {
+	workers := make(chan struct{}, publishWorkers)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := range count {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			errs[i] = publish(i)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// deferredCommands holds the timers of the commands deferred by quiet hours by command topic. A later command
+// for the same entity replaces the deferred one, so it runs at most once.
+var deferredCommands = struct {
+	sync.Mutex
+	timers map[string]*time.Timer
+}{timers: make(map[string]*time.Timer)}
+
+// errDeferred marks the reasons of commands that run once the quiet hours end
+var errDeferred = errors.New("Deferred")
+
+// checkQuietHours returns the reason the command of event is held back by quiet hours, or nil. Deferred
+// commands run once the window ends.
+func checkQuietHours(entity entities.EntityWithCommand, event events.Event) error {
+	windows := appconfig.RequireConfig().Commands.QuietHours
+	if len(windows) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	entityList := entities.GetEntities()
+	for _, window := range windows {
+		end, quiet := window.End(now)
+		if !quiet || !quietHoursCover(window, entityList, entity) {
+			continue
+		}
+
+		topic := entity.GetDiscoveryConfig().CommandTopic
+		if window.Mode != appconfig.QuietHoursDefer {
+			return fmt.Errorf("Rejecting command on %q, quiet hours from %s to %s", topic, window.From, window.To)
+		}
+		deferCommand(topic, end, event)
+		return fmt.Errorf("%w command on %q until %s, quiet hours from %s to %s", errDeferred, topic, end.Format(time.DateTime), window.From, window.To)
+	}
+	return nil
+}
+
+// quietHoursCover reports whether entity is one of the entities of window.
+func quietHoursCover(window appconfig.QuietHoursAppConfig, entityList []entities.Entity, entity entities.EntityWithCommand) bool {
+	return slices.ContainsFunc(window.EntityNames(), func(name string) bool {
+		// Entities of this OS only, eg. no sleep button on a PC that can't sleep
+		match, err := entities.FindEntityWithCommand(entityList, name)
+		return err == nil && match.GetDiscoveryConfig().UniqueId == entity.GetDiscoveryConfig().UniqueId
+	})
+}
+
+// deferCommand runs the command of event again at end, replacing a command deferred before for topic.
+func deferCommand(topic string, end time.Time, event events.Event) {
+	deferredCommands.Lock()
+	defer deferredCommands.Unlock()
+	if timer, ok := deferredCommands.timers[topic]; ok {
+		timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(end), func() {
+		deferredCommands.Lock()
+		if deferredCommands.timers[topic] != timer {
+			// Replaced meanwhile
+			deferredCommands.Unlock()
+			return
+		}
+		delete(deferredCommands.timers, topic)
+		deferredCommands.Unlock()
+
+		commandLogger.Info("Quiet hours ended, running deferred command", "topic", topic)
+		executeCommand(event)
+	})
+	deferredCommands.timers[topic] = timer
+}
@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+)
+
+// connect connects conn and retries with the reconnect backoff, starting after failures earlier attempts, until
+// it succeeds, ctx is done or the next attempt would start after deadline. A zero deadline retries forever.
+func connect(ctx context.Context, conn mqtt.Client, deadline time.Time, failures int) error {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	for ; ; failures++ {
+		token := conn.Connect()
+		select {
+		case <-token.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		err := token.Error()
+		if err == nil {
+			return nil
+		}
+		diagnostics.RecordFailedConnect()
+		diagnostics.RecordError(err)
+
+		delay := reconnectDelay(mqttConf, failures)
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		logger.Warn("Connecting to MQTT broker failed, retrying", "err", err, "retry_in", delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runReconnect reconnects conn with the reconnect backoff whenever the connection is lost, until ctx is done.
+func runReconnect(ctx context.Context, conn mqtt.Client) {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-connectionLost:
+		}
+
+		// Also the first attempt waits, a restarted broker would otherwise get all its clients at once
+		delay := reconnectDelay(mqttConf, 0)
+		logger.Info("Attempting to reconnect to MQTT broker", "in", delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := connect(ctx, conn, time.Time{}, 1); err != nil {
+			return
+		}
+	}
+}
+
+// reconnectDelay returns the time to wait after failures failed connection attempts: mqtt.connect_retry_interval,
+// with the exponential backoff doubled for every failure up to mqtt.max_reconnect_interval, spread randomly by
+// mqtt.reconnect_jitter.
+func reconnectDelay(conf appconfig.MqttAppConfig, failures int) time.Duration {
+	delay := time.Duration(conf.ConnectRetryInterval) * time.Second
+	if conf.ReconnectBackoff == appconfig.ReconnectBackoffExponential {
+		maxDelay := max(time.Duration(conf.MaxReconnectInterval)*time.Second, delay)
+		for i := 0; i < failures && delay < maxDelay; i++ {
+			delay = min(delay*2, maxDelay)
+		}
+	}
+
+	spread := int64(delay) * int64(conf.ReconnectJitter) / 100
+	if spread > 0 {
+		delay += time.Duration(rand.Int64N(2*spread+1) - spread)
+	}
+	return delay
+}
@@ -0,0 +1,86 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+)
+
+// restartDelay is how long a panicked task waits before it starts again, so a task panicking
+// on every run doesn't spin
+const restartDelay = 5 * time.Second
+
+// panics receives the panic stopping the bridge, unless on_panic restarts the failed task.
+var panics = make(chan error, 1)
+
+// panicError logs a recovered panic of task with its stack and records it as the last error.
+func panicError(task string, recovered any) error {
+	err := fmt.Errorf("Panic in %s: %v", task, recovered)
+	logger.Error("Recovered panic", "task", task, "panic", recovered, "stack", string(debug.Stack()))
+	diagnostics.RecordError(err)
+	return err
+}
+
+// handlePanic stops the bridge after a panic, unless on_panic is restart. It reports whether
+// the failed task may continue.
+func handlePanic(err error) bool {
+	if appconfig.RequireConfig().OnPanic == appconfig.OnPanicRestart {
+		return true
+	}
+	select {
+	case panics <- err:
+	default:
+	}
+	return false
+}
+
+// recoverEvent recovers a panic while handling a single event, eg. a message, when deferred.
+// With on_panic restart the next event is handled as usual.
+func recoverEvent(task string) {
+	if recovered := recover(); recovered != nil {
+		handlePanic(panicError(task, recovered))
+	}
+}
+
+// guard runs fn and returns a panic in it as error.
+func guard(task string, fn func()) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = panicError(task, recovered)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// goTask runs the long running fn in a tracked goroutine until it returns. After a panic fn is
+// started again with on_panic restart, otherwise the bridge stops.
+func goTask(ctx context.Context, task string, fn func()) bool {
+	return goBackground(func() {
+		for {
+			err := guard(task, fn)
+			if err == nil || ctx.Err() != nil || !handlePanic(err) {
+				return
+			}
+
+			logger.Warn("Restarting after panic", "task", task, "delay", restartDelay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(restartDelay):
+			}
+		}
+	})
+}
+
+// clearPanics forgets a panic of a previous run.
+func clearPanics() {
+	select {
+	case <-panics:
+	default:
+	}
+}
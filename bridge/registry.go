@@ -0,0 +1,126 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// registryChangeQueueSize bounds the registry changes waiting to be published
+const registryChangeQueueSize = 16
+
+// watchRegistry announces entities registered while running and removes unregistered ones
+// from Home Assistant, until ctx is done. The returned function stops watching.
+func watchRegistry(ctx context.Context, client mqttclient.Client) func() {
+	changes := make(chan entities.Change, registryChangeQueueSize)
+	stop := entities.Watch(func(change entities.Change) {
+		select {
+		case changes <- change:
+		case <-ctx.Done():
+		}
+	})
+
+	goTask(ctx, "registry watcher", func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change := <-changes:
+				applyRegistryChange(client, change)
+			}
+		}
+	})
+	return stop
+}
+
+func applyRegistryChange(client mqttclient.Client, change entities.Change) {
+	logger.Info("Entities changed", "added", len(change.Added), "removed", len(change.Removed))
+
+	unsubscribeFromCommandTopics(client, entities.FilterEntitiesWithCommands(change.Removed))
+	scheduler.remove(change.Removed)
+	publishDiscoveryChange(client, change)
+	if appconfig.RequireConfig().Homie.Enabled {
+		publishHomieDevice(client, entities.GetEntities())
+	}
+
+	if len(change.Added) == 0 {
+		return
+	}
+	if err := appstate.RecordRetainedTopics(entities.RetainedTopics(change.Added)); err != nil {
+		logger.Warn("Failed to record retained topics for cleanup", "err", err)
+	}
+	publishAvailability(client, change.Added)
+	publishSensorStates(client, change.Added)
+	subscribeToCommandTopics(client, entities.FilterEntitiesWithCommands(change.Added))
+}
+
+// publishDiscoveryChange publishes the discovery configs of added entities and empty
+// retained configs for removed ones, which removes them from Home Assistant.
+func publishDiscoveryChange(client mqttclient.Client, change entities.Change) {
+	switch appconfig.RequireConfig().Mqtt.DiscoveryMode {
+	case appconfig.DiscoveryModeNone:
+		return
+	case appconfig.DiscoveryModeDevice:
+		publishDeviceDiscoveryChange(client, change)
+		return
+	}
+
+	if len(change.Added) > 0 {
+		publishAutoDiscoveryConfigs(client, change.Added, false)
+	}
+	var removals []discoveryMessage
+	for _, ety := range change.Removed {
+		for _, topic := range entities.DiscoveryTopics(ety.GetDiscoveryTopic()) {
+			removals = append(removals, discoveryMessage{topic: topic, payload: []byte{}})
+		}
+	}
+	publishDiscoveryMessages(client, removals, true)
+}
+
+// publishDeviceDiscoveryChange republishes the device discovery messages of the devices with changed components.
+func publishDeviceDiscoveryChange(client mqttclient.Client, change entities.Change) {
+	updates, err := entities.GetDeviceDiscoveryUpdates(entities.GetEntities(), change)
+	if err != nil {
+		logger.Error("Error building device discovery config", "err", err)
+		return
+	}
+
+	var messages []discoveryMessage
+	for deviceTopic, config := range updates {
+		configJson := []byte{}
+		if config != nil {
+			if configJson, err = json.Marshal(config); err != nil {
+				logger.Error("Error marshaling device discovery config", "err", err)
+				continue
+			}
+		}
+
+		for _, topic := range entities.DiscoveryTopics(deviceTopic) {
+			messages = append(messages, discoveryMessage{topic: topic, payload: configJson})
+		}
+	}
+	published := publishDiscoveryMessages(client, messages, true)
+	logger.Info("Device discovery configs updated", "published", published)
+}
+
+func unsubscribeFromCommandTopics(client mqttclient.Client, entitiesWithCommands []entities.EntityWithCommand) {
+	if len(entitiesWithCommands) == 0 {
+		return
+	}
+
+	var topics []string
+	for _, ety := range entitiesWithCommands {
+		topics = append(topics, ety.GetDiscoveryConfig().CommandTopic)
+	}
+
+	token := client.Unsubscribe(topics...)
+	if token.Wait() && token.Error() != nil {
+		commandLogger.Error("Failed to unsubscribe from command topics", "err", token.Error())
+		return
+	}
+	commandLogger.Debug("Unsubscribed from command topics", "topics", len(topics))
+}
@@ -0,0 +1,405 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+)
+
+// idleSchedulerWait is how long the scheduler sleeps when no sensor is due
+const idleSchedulerWait = time.Hour
+
+// pollScheduler reads every sensor at its own interval on a shared pool of workers and
+// publishes the state. Reads time out, and failing sensors are polled less often.
+// With changes_only, states equal to the last published ones are skipped until the forced refresh.
+type pollScheduler struct {
+	defaultInterval time.Duration
+	timeout         time.Duration
+	jitter          int
+	maxBackoff      time.Duration
+	changesOnly     bool
+	forceInterval   time.Duration
+	workers         chan struct{}
+	wake            chan struct{}
+
+	mu      sync.Mutex
+	sensors map[string]*polledSensor
+}
+
+type polledSensor struct {
+	sensor   entities.Sensor
+	interval time.Duration
+	// next is the time of the next poll, zero if the sensor is only polled on demand
+	next     time.Time
+	failures int
+	polling  bool
+	// again polls the sensor once more after the running poll, which was requested meanwhile
+	again bool
+	// published is the last published value, publishedAt when it was published
+	published   string
+	publishedAt time.Time
+	// force publishes the next value even if unchanged
+	force bool
+	// value is the last polled value, also if it was not published
+	value string
+	// samples are the last polled numeric values averaged for sensors with an Average
+	samples []float64
+}
+
+// scheduler is the sensor scheduler of the running bridge.
+var scheduler *pollScheduler
+
+func startScheduler(ctx context.Context) *pollScheduler {
+	appConf := appconfig.RequireConfig()
+	s := &pollScheduler{
+		defaultInterval: time.Duration(appConf.Diagnostics.Interval) * time.Second,
+		timeout:         time.Duration(appConf.Polling.Timeout) * time.Second,
+		jitter:          appConf.Polling.Jitter,
+		maxBackoff:      time.Duration(appConf.Polling.MaxBackoff) * time.Second,
+		changesOnly:     appConf.Polling.ChangesOnly,
+		forceInterval:   time.Duration(appConf.Polling.ForceInterval) * time.Second,
+		workers:         make(chan struct{}, appConf.Polling.Workers),
+		wake:            make(chan struct{}, 1),
+		sensors:         make(map[string]*polledSensor),
+	}
+	goTask(ctx, "poll scheduler", func() { s.run(ctx) })
+	return s
+}
+
+// pollNow polls and publishes the sensors of entityList right away, also unchanged states,
+// and schedules them from then on.
+func (s *pollScheduler) pollNow(entityList []entities.Entity) {
+	s.mu.Lock()
+	for _, sensor := range sensorsOf(entityList) {
+		polled := s.add(sensor)
+		polled.force = true
+		if polled.polling {
+			polled.again = true
+		} else {
+			polled.next = time.Now()
+		}
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// sync schedules the sensors of entityList and stops polling all others. New sensors are polled right away.
+func (s *pollScheduler) sync(entityList []entities.Entity) {
+	sensors := sensorsOf(entityList)
+	s.mu.Lock()
+	for topic := range s.sensors {
+		if _, ok := sensors[topic]; !ok {
+			delete(s.sensors, topic)
+		}
+	}
+	for _, sensor := range sensors {
+		s.add(sensor)
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+// remove stops polling the sensors of entityList.
+func (s *pollScheduler) remove(entityList []entities.Entity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for topic := range sensorsOf(entityList) {
+		delete(s.sensors, topic)
+	}
+}
+
+// add schedules sensor, or updates it if already scheduled. New sensors are due right away.
+func (s *pollScheduler) add(sensor entities.Sensor) *polledSensor {
+	interval := sensor.Interval
+	if interval == 0 {
+		interval = s.defaultInterval
+	}
+
+	topic := sensor.GetDiscoveryTopic()
+	polled, ok := s.sensors[topic]
+	if !ok {
+		polled = &polledSensor{next: time.Now()}
+		s.sensors[topic] = polled
+	}
+	polled.sensor = sensor
+	polled.interval = interval
+	return polled
+}
+
+func (s *pollScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *pollScheduler) run(ctx context.Context) {
+	timer := time.NewTimer(idleSchedulerWait)
+	defer timer.Stop()
+	for {
+		timer.Reset(s.dispatch(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// dispatch starts polling all due sensors and returns the time until the next one is due.
+func (s *pollScheduler) dispatch(ctx context.Context) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	wait := idleSchedulerWait
+	for _, polled := range s.sensors {
+		if polled.polling || polled.next.IsZero() {
+			continue
+		}
+		if polled.next.After(now) {
+			wait = min(wait, polled.next.Sub(now))
+			continue
+		}
+
+		polled.polling = true
+		sensor := polled.sensor
+		if !goBackground(func() { s.poll(ctx, polled, sensor) }) {
+			polled.polling = false
+		}
+	}
+	return wait
+}
+
+type pollResult struct {
+	payload string
+	err     error
+}
+
+// poll reads sensor on a worker and publishes its state. A read exceeding the timeout
+// counts as failed, but the sensor is not polled again until the read returned.
+func (s *pollScheduler) poll(ctx context.Context, polled *polledSensor, sensor entities.Sensor) {
+	defer recoverEvent("sensor poller")
+	select {
+	case s.workers <- struct{}{}:
+	case <-ctx.Done():
+		s.skip(polled, true)
+		return
+	}
+
+	if !sensor.IsAvailable() {
+		<-s.workers
+		s.skip(polled, true)
+		return
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	finished := make(chan pollResult, 1)
+	go func() {
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				finished <- pollResult{err: fmt.Errorf("Sensor panicked: %v", r)}
+			}
+		}()
+		payload, err := sensor.Read(readCtx)
+		finished <- pollResult{payload: payload, err: err}
+	}()
+
+	var result pollResult
+	returned := true
+	select {
+	case result = <-finished:
+	case <-readCtx.Done():
+		returned = false
+		result.err = fmt.Errorf("Sensor did not respond within %s", s.timeout)
+		go func() {
+			<-finished
+			s.mu.Lock()
+			polled.polling = false
+			s.mu.Unlock()
+			s.notify()
+		}()
+	}
+	<-s.workers
+
+	if ctx.Err() != nil {
+		// Shutting down, which is no failure of the sensor
+		s.skip(polled, returned)
+		return
+	}
+	if result.err == nil {
+		value := s.smooth(polled, sensor, result.payload)
+		s.checkThreshold(polled, sensor, value)
+		s.publish(polled, sensor, value)
+	}
+	s.finish(polled, result.err, returned)
+}
+
+// publish publishes the polled value of sensor on the event bus, unless only changes are published and it did not change.
+func (s *pollScheduler) publish(polled *polledSensor, sensor entities.Sensor, value string) {
+	topic := sensor.GetDiscoveryConfig().StateTopic
+	if !s.due(polled, sensor, value) {
+		logger.Debug("Sensor state unchanged", "topic", topic)
+		return
+	}
+
+	events.Publish(events.Event{
+		Kind:    events.StateUpdated,
+		Entity:  sensor,
+		Payload: sensor.Format.Apply(value),
+		Retain:  sensor.Retain,
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	polled.published = value
+	polled.publishedAt = time.Now()
+	if !polled.again {
+		polled.force = false
+	}
+}
+
+// smooth returns the moving average of the last sensor.Average numeric values of polled, with as many decimals
+// as value has. Non-numeric values and sensors without an average are returned as polled.
+func (s *pollScheduler) smooth(polled *polledSensor, sensor entities.Sensor, value string) string {
+	if sensor.Average <= 1 {
+		return value
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	s.mu.Lock()
+	polled.samples = append(polled.samples, number)
+	if len(polled.samples) > sensor.Average {
+		polled.samples = polled.samples[len(polled.samples)-sensor.Average:]
+	}
+	var sum float64
+	for _, sample := range polled.samples {
+		sum += sample
+	}
+	average := sum / float64(len(polled.samples))
+	s.mu.Unlock()
+
+	decimals := 0
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		decimals = len(value) - dot - 1
+	}
+	return strconv.FormatFloat(average, 'f', decimals, 64)
+}
+
+// checkThreshold publishes a threshold crossed event if value crossed the threshold of sensor since the last poll.
+func (s *pollScheduler) checkThreshold(polled *polledSensor, sensor entities.Sensor, value string) {
+	s.mu.Lock()
+	previous := polled.value
+	polled.value = value
+	s.mu.Unlock()
+
+	if previous == "" || !sensor.Crossed(previous, value) {
+		return
+	}
+	logger.Info("Sensor crossed threshold", "topic", sensor.GetDiscoveryConfig().StateTopic, "threshold", *sensor.Threshold, "value", value)
+	events.Publish(events.Event{Kind: events.ThresholdCrossed, Entity: sensor, Payload: value, Previous: previous})
+}
+
+// due reports whether value of polled is to be published. Sensors with a deadband only publish changes, also
+// without changes_only.
+func (s *pollScheduler) due(polled *polledSensor, sensor entities.Sensor, value string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changesOnly && sensor.Deadband <= 0 || polled.force || polled.publishedAt.IsZero() {
+		return true
+	}
+	if s.forceInterval > 0 && time.Since(polled.publishedAt) >= s.forceInterval {
+		return true
+	}
+	return sensor.Changed(polled.published, value)
+}
+
+// finish schedules the next poll of polled after a poll ended with err.
+func (s *pollScheduler) finish(polled *polledSensor, err error, returned bool) {
+	defer s.notify()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	topic := polled.sensor.GetDiscoveryConfig().StateTopic
+	if err != nil {
+		polled.failures++
+	} else if polled.failures > 0 {
+		logger.Info("Sensor recovered", "topic", topic, "failures", polled.failures)
+		polled.failures = 0
+	}
+	s.reschedule(polled, returned)
+
+	if err != nil {
+		if polled.failures == 1 {
+			logger.Warn("Failed to poll sensor", "topic", topic, "err", err)
+			diagnostics.RecordError(err)
+		} else {
+			logger.Debug("Failed to poll sensor", "topic", topic, "err", err, "failures", polled.failures)
+		}
+	}
+}
+
+// skip schedules the next poll of polled after a poll that neither succeeded nor failed,
+// eg. of an unavailable sensor.
+func (s *pollScheduler) skip(polled *polledSensor, returned bool) {
+	defer s.notify()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reschedule(polled, returned)
+}
+
+// reschedule sets the time of the next poll of polled. The caller holds the lock.
+func (s *pollScheduler) reschedule(polled *polledSensor, returned bool) {
+	switch {
+	case polled.again:
+		polled.next = time.Now()
+		polled.again = false
+	case polled.interval > 0:
+		polled.next = time.Now().Add(s.delay(polled.interval, polled.failures))
+	default:
+		polled.next = time.Time{}
+	}
+	if returned {
+		polled.polling = false
+	}
+}
+
+// delay returns the time until the next poll: the interval doubled for every failure up
+// to the maximum backoff, spread randomly by the configured jitter.
+func (s *pollScheduler) delay(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < s.maxBackoff; i++ {
+		delay = min(delay*2, s.maxBackoff)
+	}
+
+	spread := int64(delay) * int64(s.jitter) / 100
+	if spread > 0 {
+		delay += time.Duration(rand.Int64N(2*spread+1) - spread)
+	}
+	return delay
+}
+
+// sensorsOf returns the sensors of entityList by discovery topic.
+func sensorsOf(entityList []entities.Entity) map[string]entities.Sensor {
+	sensors := make(map[string]entities.Sensor)
+	for _, ety := range entityList {
+		if sensor, ok := ety.(entities.Sensor); ok {
+			sensors[sensor.GetDiscoveryTopic()] = sensor
+		}
+	}
+	return sensors
+}
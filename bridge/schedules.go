@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/cron"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// missedRunTolerance is how late a scheduled run may start. Runs missed by more, eg. while the PC
+// was asleep, are skipped, so a nightly shutdown doesn't run right after waking up in the morning.
+const missedRunTolerance = time.Minute
+
+// scheduleWakeLead is how long before a run the PC wakes up for it, so the schedules notice the run
+// in time although the timer only counts awake time
+const scheduleWakeLead = 2 * time.Minute
+
+// maxScheduleWait bounds the wait for the next run, as timers don't follow the wall clock across suspend
+const maxScheduleWait = time.Minute
+
+type scheduledRun struct {
+	name     string
+	config   appconfig.ScheduleAppConfig
+	schedule cron.Schedule
+	// next is the time of the next run, zero if the schedule never runs again
+	next time.Time
+}
+
+// runSchedules runs the actions and publishes of the configured schedules at their times until ctx is done.
+func runSchedules(ctx context.Context, client mqttclient.Client) {
+	var runs []*scheduledRun
+	now := time.Now()
+	for name, config := range appconfig.RequireConfig().Schedules {
+		schedule, err := cron.Parse(config.Cron)
+		if err != nil {
+			// The config was validated
+			continue
+		}
+		run := &scheduledRun{name: name, config: config, schedule: schedule, next: schedule.Next(now)}
+		scheduleWake(run)
+		runs = append(runs, run)
+	}
+	if len(runs) == 0 {
+		return
+	}
+	logger.Info("Running schedules", "schedules", len(runs))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		wait := maxScheduleWait
+		var ran []string
+		for _, run := range runs {
+			if run.next.IsZero() {
+				continue
+			}
+			if !run.next.After(now) {
+				if late := now.Sub(run.next); late > missedRunTolerance {
+					logger.Warn("Skipping missed scheduled run", "schedule", run.name, "due", run.next, "late", late.Round(time.Second))
+				} else {
+					runSchedule(client, run)
+				}
+				run.next = run.schedule.Next(now)
+				scheduleWake(run)
+				ran = append(ran, entities.ScheduleSensorId(run.name))
+			}
+			if !run.next.IsZero() {
+				wait = min(wait, time.Until(run.next))
+			}
+		}
+
+		if len(ran) > 0 {
+			publishScheduleSensors(ran)
+		}
+		timer.Reset(wait)
+	}
+}
+
+// runSchedule runs the action of the scheduled entity like a received command, or publishes the scheduled message.
+func runSchedule(client mqttclient.Client, run *scheduledRun) {
+	if run.config.Topic != "" {
+		logger.Info("Publishing scheduled message", "schedule", run.name, "topic", run.config.Topic)
+		qos := byte(appconfig.RequireConfig().Mqtt.Qos)
+		if err := publishOrQueue(client, run.config.Topic, qos, run.config.Retain, run.config.Payload); err != nil {
+			logger.Error("Error publishing scheduled message", "schedule", run.name, "topic", run.config.Topic, "err", err)
+		}
+		return
+	}
+
+	entity, err := entities.FindEntityWithCommand(entities.GetEntities(), run.config.Entity)
+	if err != nil {
+		logger.Error("Failed to run schedule", "schedule", run.name, "err", err)
+		diagnostics.RecordError(err)
+		return
+	}
+
+	payload := run.config.Payload
+	if payload == "" {
+		payload = entities.DefaultCommandPayload(entity)
+	}
+	logger.Info("Running scheduled action", "schedule", run.name, "entity", entity.GetDiscoveryConfig().UniqueId, "payload", payload)
+	events.Publish(events.Event{Kind: events.CommandReceived, Entity: entity, Payload: payload, Source: "schedule:" + run.name})
+}
+
+// scheduleWake wakes the PC from sleep shortly before the next run of schedules with wake.
+func scheduleWake(run *scheduledRun) {
+	if !run.config.Wake || run.next.IsZero() {
+		return
+	}
+	at := run.next.Add(-scheduleWakeLead)
+	if !at.After(time.Now()) {
+		return
+	}
+
+	cmd, err := system.GetScheduleWakeCommand(at)
+	if err == nil {
+		err = system.RunCommand(cmd)
+	}
+	if err != nil {
+		logger.Error("Failed to schedule wake", "schedule", run.name, "err", err)
+		diagnostics.RecordError(err)
+		return
+	}
+	logger.Info("Scheduled wake", "schedule", run.name, "at", at)
+}
+
+// publishScheduleSensors publishes the next runs of the schedule sensors with the given unique ids right away.
+func publishScheduleSensors(uniqueIds []string) {
+	var sensors []entities.Entity
+	for _, ety := range entities.GetEntities() {
+		if slices.Contains(uniqueIds, ety.GetDiscoveryConfig().UniqueId) {
+			sensors = append(sensors, ety)
+		}
+	}
+	scheduler.pollNow(sensors)
+}
@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// runSessionWatch publishes lock, unlock, logon and logoff of user sessions as soon as the system
+// reports them, as state of the locked and logged in sensors, as session_changed events and on the
+// system event entity.
+func runSessionWatch(ctx context.Context) {
+	if runtime.GOOS != system.WINDOWS {
+		return
+	}
+
+	session, err := system.CurrentSession()
+	if err != nil {
+		logger.Warn("Failed to read the current session", "err", err)
+	}
+	entities.SetSession(session)
+	publishSessionChange(system.SessionLogon, false)
+	publishSessionChange(system.SessionLock, false)
+
+	changes := make(chan string, 8)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- system.WatchSessions(ctx, changes) }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-watchErr:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to watch sessions", "err", err)
+			}
+			return
+		case change := <-changes:
+			session = session.Apply(change)
+			entities.SetSession(session)
+			publishSessionChange(change, true)
+		}
+	}
+}
+
+// publishSessionChange publishes the state of the session sensor changed by change and of the presence
+// tracker, and with event a session_changed event.
+func publishSessionChange(change string, event bool) {
+	entityList := entities.GetEntities()
+	if tracker, ok := entities.PresenceTracker(entityList); ok {
+		events.Publish(events.Event{Kind: events.StateUpdated, Entity: tracker, Payload: tracker.Payload(), Retain: tracker.Retain})
+	}
+	sensor, ok := entities.SessionSensor(entityList, change)
+	if !ok {
+		return
+	}
+	events.Publish(events.Event{Kind: events.StateUpdated, Entity: sensor, Payload: sensor.Payload(), Retain: sensor.Retain})
+	if event {
+		logger.Info("Session changed", "change", change)
+		events.Publish(events.Event{Kind: events.SessionChanged, Entity: sensor, Payload: change})
+		publishSystemEvent(change)
+	}
+}
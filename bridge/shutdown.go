@@ -0,0 +1,94 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// shutdownTimeout bounds how long shutdown waits for running publishes and actions.
+const shutdownTimeout = 10 * time.Second
+
+// background tracks goroutines that publish or run actions, so shutdown can let them
+// finish before the device is reported offline. Once closed, no new work is started.
+var background struct {
+	sync.Mutex
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// beginBackground registers running work. It returns false once shutdown started.
+// Every successful call must be followed by endBackground.
+func beginBackground() bool {
+	background.Lock()
+	defer background.Unlock()
+	if background.closed {
+		return false
+	}
+	background.wg.Add(1)
+	return true
+}
+
+func endBackground() {
+	background.wg.Done()
+}
+
+// goBackground runs fn in a tracked goroutine. It returns false once shutdown started.
+func goBackground(fn func()) bool {
+	if !beginBackground() {
+		return false
+	}
+	go func() {
+		defer endBackground()
+		fn()
+	}()
+	return true
+}
+
+func openBackground() {
+	background.Lock()
+	defer background.Unlock()
+	background.closed = false
+}
+
+// waitBackground stops new background work and waits for the running work until timeout.
+func waitBackground(timeout time.Duration) bool {
+	background.Lock()
+	background.closed = true
+	background.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		background.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// shutdown lets running publishes and actions finish, then reports the device offline and disconnects.
+// The context of the background loops must be canceled before.
+func shutdown(client mqtt.Client, healthServer *http.Server) {
+	if !waitBackground(shutdownTimeout) {
+		logger.Warn("Timeout waiting for running publishes and actions", "timeout", shutdownTimeout)
+	}
+
+	if healthServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		healthServer.Shutdown(ctx)
+		cancel()
+	}
+
+	publishOfflineStatus()
+	outbox.stop()
+	// Waits up to 2 seconds for in-flight messages to be sent
+	client.Disconnect(2000)
+}
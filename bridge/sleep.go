@@ -0,0 +1,43 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// runSleepWatch fires suspend and resume on the system event entity and republishes availability and sensor
+// states and resubscribes as soon as the PC woke up, until ctx is done. The broker may have sent the last will while the PC slept, or still holds states from before.
+func runSleepWatch(ctx context.Context, client mqttclient.Client) {
+	changes := make(chan string, 4)
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- system.WatchSleep(ctx, changes) }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-watchErr:
+			if err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to watch sleep", "err", err)
+			}
+			return
+		case change := <-changes:
+			publishSystemEvent(change)
+			if change == system.SleepSuspend {
+				logger.Info("System going to sleep")
+				continue
+			}
+			// A dead connection is noticed by the keep alive, the connect handler then publishes everything
+			if !client.IsConnectionOpen() {
+				logger.Info("System resumed, waiting for the reconnect")
+				continue
+			}
+			logger.Info("System resumed, republishing states")
+			publishOnlineState(client, entities.GetEntities())
+		}
+	}
+}
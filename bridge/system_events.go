@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// runSystemEvents fires AC power and lid changes and the events of entities, eg. a shutdown cancelled on
+// the PC, on the system event entity until ctx is done.
+func runSystemEvents(ctx context.Context) {
+	changes := make(chan string, 4)
+	go func() {
+		if err := system.WatchPowerSupply(ctx, changes); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to watch the power supply", "err", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-changes:
+			publishSystemEvent(change)
+		case eventType := <-entities.SystemEvents():
+			publishSystemEvent(eventType)
+		}
+	}
+}
+
+// publishSystemEvent fires eventType, eg. lock, on the system event entity.
+func publishSystemEvent(eventType string) {
+	event, ok := entities.SystemEventEntity(entities.GetEntities())
+	if !ok {
+		return
+	}
+	logger.Debug("Firing system event", "event_type", eventType)
+	events.Publish(events.Event{Kind: events.StateUpdated, Entity: event, Payload: event.Payload(eventType)})
+}
@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+const updateCheckInterval = 6 * time.Hour
+
+// runUpdateCheck periodically looks for a newer release, logs it once and
+// republishes the update entity with the result.
+func runUpdateCheck(ctx context.Context) {
+	notified := ""
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+	for {
+		release, err := update.Check(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				logger.Warn("Failed to check for updates", "err", err)
+			}
+		case !update.IsRelease(version.Get()):
+			logger.Debug("pc2mqtt is no release version, not comparing it to the latest release", "version", version.Get(), "latest", release.TagName)
+		case update.IsNewer(release.TagName, version.Get()):
+			if release.TagName != notified {
+				logger.Info("A newer pc2mqtt version is available", "version", release.TagName, "current", version.Get(), "url", release.HtmlUrl)
+				notified = release.TagName
+			}
+		default:
+			logger.Debug("pc2mqtt is up to date", "version", version.Get())
+		}
+
+		if err == nil {
+			publishUpdateStates()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func publishUpdateStates() {
+	var updates []entities.Entity
+	for _, entity := range entities.GetEntities() {
+		if _, ok := entity.(entities.Update); ok {
+			updates = append(updates, entity)
+		}
+	}
+	publishSensorValues(updates)
+}
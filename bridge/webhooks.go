@@ -0,0 +1,161 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/events"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+// webhookQueueSize bounds the messages waiting to be posted, further messages are dropped
+const webhookQueueSize = 100
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookMessage is the JSON body posted to webhooks.
+type webhookMessage struct {
+	Event    events.Kind `json:"event"`
+	Device   string      `json:"device"`
+	DeviceId string      `json:"device_id"`
+	Time     time.Time   `json:"time"`
+	Entity   string      `json:"entity,omitempty"`
+	Payload  string      `json:"payload,omitempty"`
+	// Source is set for commands, the topic they arrived on or schedule:<name>
+	Source string `json:"source,omitempty"`
+	// Previous and Threshold are set for threshold_crossed
+	Previous  string   `json:"previous,omitempty"`
+	Threshold *float64 `json:"threshold,omitempty"`
+	// Success is set for command_finished
+	Success *bool  `json:"success,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type webhookDelivery struct {
+	webhook appconfig.WebhookAppConfig
+	body    []byte
+}
+
+// subscribeWebhooks posts the events selected by the configured webhooks to them until ctx is done.
+// Events are posted one after another in the background. The returned function stops it.
+func subscribeWebhooks(ctx context.Context) func() {
+	webhooks := appconfig.RequireConfig().Webhooks
+	if len(webhooks) == 0 {
+		return func() {}
+	}
+
+	var kinds []events.Kind
+	for _, webhook := range webhooks {
+		for _, name := range webhookEvents(webhook) {
+			if kind := events.Kind(name); !slices.Contains(kinds, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	deliveries := make(chan webhookDelivery, webhookQueueSize)
+	goTask(ctx, "webhooks", func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case delivery := <-deliveries:
+				postWebhook(ctx, delivery)
+			}
+		}
+	})
+
+	return events.Subscribe(func(event events.Event) {
+		body, err := json.Marshal(newWebhookMessage(event))
+		if err != nil {
+			logger.Error("Error marshaling webhook message", "event", event.Kind, "err", err)
+			return
+		}
+
+		for _, webhook := range webhooks {
+			if !slices.Contains(webhookEvents(webhook), string(event.Kind)) {
+				continue
+			}
+			select {
+			case deliveries <- webhookDelivery{webhook: webhook, body: body}:
+			default:
+				logger.Warn("Too many pending webhook messages, dropping event", "url", redactUrl(webhook.Url), "event", event.Kind)
+			}
+		}
+	}, kinds...)
+}
+
+func webhookEvents(webhook appconfig.WebhookAppConfig) []string {
+	if len(webhook.Events) == 0 {
+		return appconfig.DefaultWebhookEvents
+	}
+	return webhook.Events
+}
+
+func newWebhookMessage(event events.Event) webhookMessage {
+	appConf := appconfig.RequireConfig()
+	message := webhookMessage{
+		Event:    event.Kind,
+		Device:   appConf.DeviceName,
+		DeviceId: appConf.DeviceId,
+		Time:     event.Time,
+		Payload:  event.Payload,
+		Source:   event.Source,
+		Previous: event.Previous,
+	}
+	if event.Entity != nil {
+		message.Entity = event.Entity.GetDiscoveryConfig().UniqueId
+	}
+	if event.Err != nil {
+		message.Error = event.Err.Error()
+	}
+
+	switch event.Kind {
+	case events.CommandFinished, events.CommandRejected:
+		success := event.Err == nil
+		message.Success = &success
+	case events.ThresholdCrossed:
+		if sensor, ok := event.Entity.(entities.Sensor); ok {
+			message.Threshold = sensor.Threshold
+		}
+	}
+	return message
+}
+
+func postWebhook(ctx context.Context, delivery webhookDelivery) {
+	if err := sendWebhook(ctx, delivery); err != nil {
+		logger.Warn("Failed to post webhook", "url", redactUrl(delivery.webhook.Url), "err", err)
+		return
+	}
+	logger.Debug("Posted webhook", "url", redactUrl(delivery.webhook.Url))
+}
+
+func sendWebhook(ctx context.Context, delivery webhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.webhook.Url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pc2mqtt/"+version.Get())
+	for name, value := range delivery.webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// loadOptions holds the config selection from the global flags.
+var loadOptions appconfig.LoadOptions
+
+var globalFlags = flag.NewFlagSet("pc2mqtt", flag.ExitOnError)
+
+func init() {
+	globalFlags.StringVar(&loadOptions.Path, "config", os.Getenv("PC2MQTT_CONFIG"), "Config file or profile directory (env PC2MQTT_CONFIG)")
+	globalFlags.StringVar(&loadOptions.Profile, "profile", os.Getenv("PC2MQTT_PROFILE"), "Config profile to apply (env PC2MQTT_PROFILE)")
+	globalFlags.Usage = printUsage
+}
+
+// parseGlobalFlags parses the flags in front of the subcommand and returns the remaining args.
+func parseGlobalFlags(args []string) []string {
+	globalFlags.Parse(args)
+	return globalFlags.Args()
+}
+
+type subcommand struct {
+	description string
+	run         func(args []string) error
+}
+
+var subcommands = map[string]subcommand{
+	"cleanup": {
+		description: "Remove all retained discovery, availability and state messages from the broker",
+		run:         runCleanup,
+	},
+	"credentials": {
+		description: "Store (set) or remove (delete) the MQTT password in the OS credential store, or encrypt a config value",
+		run:         runCredentials,
+	},
+	"entities": {
+		description: "List the entities of the config with their discovery, state and command topics and QoS",
+		run:         runEntities,
+	},
+	"init": {
+		description: "Write a commented default config file",
+		run:         runInit,
+	},
+	"service": {
+		description: "Run pc2mqtt as a service at boot: install, uninstall, restart or status",
+		run:         runService,
+	},
+	"simulate": {
+		description: "Run against an embedded broker and print every published message",
+		run:         runSimulate,
+	},
+	"trigger": {
+		description: "Run the action of an entity locally without MQTT, eg. trigger shutdown -dry-run",
+		run:         runTrigger,
+	},
+	"update": {
+		description: "Replace this binary with the latest release and restart the service",
+		run:         runUpdate,
+	},
+	"version": {
+		description: "Print version, commit and build date. -check looks for a newer release",
+		run:         runVersion,
+	},
+}
+
+// runSubcommand executes the subcommand named in args, if any.
+// It returns false when args do not name a subcommand and the bridge should run.
+func runSubcommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage()
+		return true
+	}
+
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		return false
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+func printUsage() {
+	fmt.Println("Usage: pc2mqtt [flags] [command]")
+	fmt.Println()
+	fmt.Println("Without a command pc2mqtt runs the MQTT bridge.")
+	fmt.Println()
+	fmt.Println("Commands:")
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, subcommands[name].description)
+	}
+
+	fmt.Println()
+	fmt.Println("Flags:")
+	globalFlags.SetOutput(os.Stdout)
+	globalFlags.PrintDefaults()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/leonlatsch/pc2mqtt/bridge"
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/appstate"
+)
+
+func runCleanup(args []string) error {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Only print the topics that would be cleared")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	topics, err := retainedTopics()
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, topic := range topics {
+			fmt.Println(topic)
+		}
+		return nil
+	}
+
+	return cleanupRetainedTopics(topics)
+}
+
+// retainedTopics loads the config and returns every topic pc2mqtt published retained messages to on this
+// machine, as recorded in the local state, and the ones of the current entities.
+func retainedTopics() ([]string, error) {
+	if err := appconfig.LoadConfig(loadOptions); err != nil {
+		return nil, err
+	}
+
+	topics, err := appstate.RetainedTopics()
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range entities.RetainedTopics(entities.GetEntities()) {
+		if !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics, nil
+}
+
+// cleanupRetainedTopics publishes empty retained payloads to topics, which removes the
+// retained messages from the broker and the entities from Home Assistant.
+func cleanupRetainedTopics(topics []string) error {
+	appConf := appconfig.RequireConfig()
+	opts, err := bridge.NewClientOptions(bridge.ClientId() + "-cleanup")
+	if err != nil {
+		return err
+	}
+	cleanupClient := mqtt.NewClient(opts)
+	token := cleanupClient.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		return errors.New("Timeout connecting to MQTT broker")
+	}
+	if token.Error() != nil {
+		return token.Error()
+	}
+	defer cleanupClient.Disconnect(2000)
+
+	failed := 0
+	for _, topic := range topics {
+		token := cleanupClient.Publish(topic, byte(appConf.Mqtt.Qos), true, []byte{})
+		if token.Wait() && token.Error() != nil {
+			fmt.Printf("Failed to clear %s: %v\n", topic, token.Error())
+			failed++
+			continue
+		}
+		fmt.Printf("Cleared %s\n", topic)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("Failed to clear %d of %d topics", failed, len(topics))
+	}
+
+	return appstate.ClearRetainedTopics()
+}
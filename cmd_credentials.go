@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/credentials"
+	"golang.org/x/term"
+)
+
+func runCredentials(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: pc2mqtt credentials <set|delete|encrypt>")
+	}
+
+	flags := flag.NewFlagSet("credentials "+args[0], flag.ContinueOnError)
+	passphrase := flags.Bool("passphrase", false, "encrypt: Prompt for a passphrase instead of using or creating the key file")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	opts := loadOptions
+	opts.SkipSecrets = true
+	if err := appconfig.LoadConfig(opts); err != nil {
+		return err
+	}
+	account := appconfig.KeychainAccount(*appconfig.RequireConfig())
+
+	switch args[0] {
+	case "set":
+		password, err := promptSecret(fmt.Sprintf("MQTT password for %s: ", account))
+		if err != nil {
+			return err
+		}
+
+		if err := credentials.Set(account, password); err != nil {
+			return err
+		}
+		fmt.Printf("Stored MQTT password for %s. Set \"use_keychain\": true in the mqtt config to use it\n", account)
+	case "delete":
+		if err := credentials.Delete(account); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted MQTT password for %s\n", account)
+	case "encrypt":
+		return encryptSecret(*passphrase)
+	default:
+		return errors.New("Unknown credentials command " + args[0])
+	}
+
+	return nil
+}
+
+// encryptSecret prints the encrypted config value of a secret entered on stdin. Without a passphrase the key
+// file is used and created with a random key if missing.
+func encryptSecret(passphrase bool) error {
+	key, err := appconfig.SecretKey()
+	switch {
+	case passphrase:
+		if key, err = promptSecret("Passphrase: "); err != nil {
+			return err
+		}
+		if key == "" {
+			return errors.New("The passphrase may not be empty")
+		}
+	case errors.Is(err, appconfig.ErrNoSecretKey):
+		key = appconfig.GenerateKey()
+		if err := appconfig.WriteKeyFile(key); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Created key file %s. Keep it next to the config, it decrypts the encrypted values\n", appconfig.KeyFilePath())
+	case err != nil:
+		return err
+	}
+
+	secret, err := promptSecret("Value to encrypt: ")
+	if err != nil {
+		return err
+	}
+	value, err := appconfig.EncryptSecret(key, secret)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// stdin is shared by all prompts, so lines piped in for several prompts aren't lost in a buffer
+var stdin = bufio.NewReader(os.Stdin)
+
+// promptSecret prints prompt to stderr and reads a line from stdin, without echoing it when stdin is a terminal.
+func promptSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(secret), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// entityInfo holds the topics of an entity, as printed by the entities command.
+type entityInfo struct {
+	Platform        string   `json:"platform"`
+	UniqueId        string   `json:"unique_id"`
+	Name            string   `json:"name"`
+	Qos             int      `json:"qos"`
+	DiscoveryTopics []string `json:"discovery_topics"`
+	StateTopic      string   `json:"state_topic,omitempty"`
+	CommandTopic    string   `json:"command_topic,omitempty"`
+}
+
+func runEntities(args []string) error {
+	flags := flag.NewFlagSet("entities", flag.ContinueOnError)
+	asJson := flags.Bool("json", false, "Print the entities as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Listing needs no connection, so the password is not read from the credential store
+	opts := loadOptions
+	opts.SkipSecrets = true
+	if err := appconfig.LoadConfig(opts); err != nil {
+		return err
+	}
+
+	discoveryMode := appconfig.RequireConfig().Mqtt.DiscoveryMode
+	var infos []entityInfo
+	for _, ety := range entities.GetEntities() {
+		config := ety.GetDiscoveryConfig()
+		discoveryTopic := ety.GetDiscoveryTopic()
+		if discoveryMode == appconfig.DiscoveryModeDevice {
+			discoveryTopic = entities.GetDeviceDiscoveryTopic(config.Device)
+		}
+		var discoveryTopics []string
+		if discoveryMode != appconfig.DiscoveryModeNone {
+			discoveryTopics = entities.DiscoveryTopics(discoveryTopic)
+		}
+		infos = append(infos, entityInfo{
+			Platform:        entities.GetPlatform(ety),
+			UniqueId:        config.UniqueId,
+			Name:            config.Name,
+			Qos:             config.Qos,
+			DiscoveryTopics: discoveryTopics,
+			StateTopic:      config.StateTopic,
+			CommandTopic:    config.CommandTopic,
+		})
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(infos)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PLATFORM\tUNIQUE ID\tQOS\tDISCOVERY TOPIC\tSTATE TOPIC\tCOMMAND TOPIC")
+	for _, info := range infos {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\n",
+			info.Platform, info.UniqueId, info.Qos, orDash(strings.Join(info.DiscoveryTopics, ",")),
+			orDash(info.StateTopic), orDash(info.CommandTopic))
+	}
+	return writer.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
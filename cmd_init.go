@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	defaultOutput := appconfig.DefaultConfigFileName
+	if loadOptions.Path != "" {
+		defaultOutput = loadOptions.Path
+	}
+
+	output := flags.String("output", defaultOutput, "Path of the config file to write")
+	force := flags.Bool("force", false, "Overwrite an existing config file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := appconfig.WriteDefaultConfig(*output, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote default config to %s\n", *output)
+	return nil
+}
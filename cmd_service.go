@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/leonlatsch/pc2mqtt/bridge"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/service"
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+)
+
+func runService(args []string) error {
+	if len(args) == 0 {
+		return errors.New("Usage: pc2mqtt service <install|uninstall|restart|status|run>")
+	}
+
+	flags := flag.NewFlagSet("service "+args[0], flag.ContinueOnError)
+	name := flags.String("name", "pc2mqtt", "Name of the service")
+	user := flags.Bool("user", false, "Install a service of the current user instead of a system wide one")
+	cleanup := flags.Bool("cleanup", false, "uninstall: Also remove the retained messages from the broker, like the cleanup command")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	conf := service.Config{
+		Name:        *name,
+		Description: "pc2mqtt - control this PC over MQTT",
+		User:        *user,
+	}
+
+	switch args[0] {
+	case "install":
+		if err := serviceCommandLine(&conf); err != nil {
+			return err
+		}
+		return service.Install(conf)
+	case "uninstall":
+		if !*cleanup {
+			return service.Uninstall(conf)
+		}
+		return uninstallWithCleanup(conf)
+	case "restart":
+		return service.Restart(conf)
+	case "status":
+		return service.Status(conf)
+	case "run":
+		if err := appconfig.LoadConfig(loadOptions); err != nil {
+			return err
+		}
+		conf.WorkingDir = appconfig.ConfigDir()
+		opts := bridge.Options{ConfigPath: loadOptions.Path, Profile: loadOptions.Profile}
+		return service.Run(conf, func(ctx context.Context) error {
+			return bridge.Run(ctx, opts)
+		})
+	default:
+		return errors.New("Unknown service command " + args[0])
+	}
+}
+
+// uninstallWithCleanup removes the service and its retained messages. The topics are collected first, so a
+// config that doesn't load keeps the service, and cleared once the service stopped, so it can't publish again.
+func uninstallWithCleanup(conf service.Config) error {
+	topics, err := retainedTopics()
+	if err != nil {
+		return err
+	}
+	if err := service.Uninstall(conf); err != nil {
+		return err
+	}
+	if err := cleanupRetainedTopics(topics); err != nil {
+		return fmt.Errorf("%w. Run pc2mqtt cleanup to retry", err)
+	}
+	return nil
+}
+
+// serviceCommandLine points the service at this binary and the selected config with absolute paths,
+// after making sure the config loads. The passphrase of encrypted values is asked for and stored in
+// the key file, since the service can't ask at boot.
+func serviceCommandLine(conf *service.Config) error {
+	err := appconfig.LoadConfig(loadOptions)
+	if errors.Is(err, appconfig.ErrNoSecretKey) {
+		err = storePassphrase()
+	}
+	if err != nil {
+		return err
+	}
+
+	executable, err := update.Executable()
+	if err != nil {
+		return err
+	}
+
+	configPath := loadOptions.Path
+	if configPath == "" {
+		configPath = appconfig.DefaultConfigFileName
+	}
+	if configPath, err = filepath.Abs(configPath); err != nil {
+		return err
+	}
+
+	conf.Executable = executable
+	conf.Args = []string{"-config", configPath}
+	if loadOptions.Profile != "" {
+		conf.Args = append(conf.Args, "-profile", loadOptions.Profile)
+	}
+
+	conf.WorkingDir, err = filepath.Abs(appconfig.ConfigDir())
+	return err
+}
+
+// storePassphrase writes the passphrase of the encrypted values to the key file, once the config decrypts
+// with it.
+func storePassphrase() error {
+	passphrase, err := promptSecret("Passphrase of the encrypted config values: ")
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return appconfig.ErrNoSecretKey
+	}
+
+	appconfig.SetPassphrase(passphrase)
+	defer appconfig.SetPassphrase("")
+	if err := appconfig.LoadConfig(loadOptions); err != nil {
+		return err
+	}
+	if err := appconfig.WriteKeyFile(passphrase); err != nil {
+		return err
+	}
+	fmt.Printf("Stored the passphrase in %s for the service\n", appconfig.KeyFilePath())
+	return nil
+}
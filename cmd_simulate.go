@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/leonlatsch/pc2mqtt/bridge"
+	"github.com/leonlatsch/pc2mqtt/internal/broker"
+)
+
+func runSimulate(args []string) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	duration := flags.Duration("duration", 0, "Stop after this time, eg. 30s. 0 runs until interrupted")
+	var commands []string
+	flags.Func("command", "Publish topic=payload once pc2mqtt subscribed to it. Can be repeated", func(value string) error {
+		if !strings.Contains(value, "=") {
+			return errors.New("Invalid command " + value + ". Use topic=payload")
+		}
+		commands = append(commands, value)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	embedded, err := broker.Listen("127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer embedded.Close()
+
+	var printMu sync.Mutex
+	embedded.OnPublish = func(msg broker.Message) {
+		printMu.Lock()
+		defer printMu.Unlock()
+		retained := ""
+		if msg.Retain {
+			retained = " (retained)"
+		}
+		fmt.Printf("%s%s %s\n", msg.Topic, retained, msg.Payload)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	// A second signal exits right away
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	go publishSimulatedCommands(ctx, embedded, commands)
+	go publishStdinCommands(embedded)
+
+	fmt.Fprintf(os.Stderr, "Simulating against embedded broker %s. Enter \"<topic> <payload>\" to publish\n", embedded.Url())
+	opts := bridge.Options{ConfigPath: loadOptions.Path, Profile: loadOptions.Profile, BrokerUrl: embedded.Url(), Simulate: true}
+	return bridge.Run(ctx, opts)
+}
+
+// publishSimulatedCommands publishes the -command values once a client subscribed to their topics.
+func publishSimulatedCommands(ctx context.Context, embedded *broker.Broker, commands []string) {
+	for _, command := range commands {
+		topic, payload, _ := strings.Cut(command, "=")
+		if err := embedded.WaitSubscribed(ctx, topic); err != nil {
+			return
+		}
+		embedded.Publish(topic, []byte(payload), false)
+	}
+}
+
+// publishStdinCommands publishes every "<topic> <payload>" line read from stdin.
+func publishStdinCommands(embedded *broker.Broker) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		topic, payload, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if topic != "" {
+			embedded.Publish(topic, []byte(payload), false)
+		}
+	}
+}
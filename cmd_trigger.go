@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+func runTrigger(args []string) error {
+	flags := flag.NewFlagSet("trigger", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Print the OS commands instead of running them")
+	payload := flags.String("payload", "", "Command payload, eg. ON or OFF for switches. Defaults to PRESS, ON or install")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: pc2mqtt trigger [flags] <entity>")
+		fmt.Fprintln(flags.Output(), "Runs the action of an entity, named by unique id or its last part, eg. shutdown, without MQTT.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("Missing entity")
+	}
+	// Flags may also follow the entity, eg. trigger shutdown -dry-run
+	name := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return err
+	}
+
+	opts := loadOptions
+	opts.SkipSecrets = true
+	if err := appconfig.LoadConfig(opts); err != nil {
+		return err
+	}
+	system.SetPowerOptions(appconfig.RequireConfig().Commands.PowerOptions())
+
+	entity, err := entities.FindEntityWithCommand(entities.GetEntities(), name)
+	if err != nil {
+		return err
+	}
+
+	if *payload == "" {
+		*payload = entities.DefaultCommandPayload(entity)
+	}
+	if *dryRun {
+		system.SetDryRun(os.Stdout)
+	}
+
+	fmt.Printf("Triggering %s with %s\n", entity.GetDiscoveryConfig().UniqueId, *payload)
+	done := make(chan error, 1)
+	entity.QueueAction(*payload, func(err error) {
+		done <- err
+	})
+	if err := <-done; err != nil {
+		return fmt.Errorf("Action failed: %w", err)
+	}
+	fmt.Println("Action succeeded")
+	return nil
+}
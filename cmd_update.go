@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/leonlatsch/pc2mqtt/internal/service"
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+func runUpdate(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ContinueOnError)
+	force := flags.Bool("force", false, "Install the latest release even if it is not newer")
+	restart := flags.Bool("restart", true, "Restart the service after updating")
+	name := flags.String("name", "pc2mqtt", "Name of the service to restart")
+	user := flags.Bool("user", false, "Restart a service of the current user instead of a system wide one")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	release, err := update.Latest(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to check for updates: %w", err)
+	}
+
+	current := version.Get()
+	if !*force && !update.IsRelease(current) {
+		fmt.Printf("%s is no release version, use -force to install %s\n", current, release.TagName)
+		return nil
+	}
+	if !*force && !update.IsNewer(release.TagName, current) {
+		fmt.Printf("%s is the latest version\n", current)
+		return nil
+	}
+
+	executable, err := update.Executable()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updating %s from %s to %s\n", executable, current, release.TagName)
+	if err := update.Install(ctx, release, executable); err != nil {
+		return fmt.Errorf("Failed to update: %w", err)
+	}
+	fmt.Printf("Updated to %s\n", release.TagName)
+
+	if !*restart {
+		return nil
+	}
+	conf := service.Config{Name: *name, User: *user}
+	if err := service.Restart(conf); err != nil {
+		return fmt.Errorf("Updated, but failed to restart service %s. Restart pc2mqtt manually or use -restart=false: %w", *name, err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+func runVersion(args []string) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	check := flags.Bool("check", false, "Check GitHub for a newer release")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	fmt.Printf("pc2mqtt %s\n", version.Get())
+	if commit := version.GetCommit(); commit != "" {
+		fmt.Printf("commit:  %s\n", commit)
+	}
+	if date := version.GetDate(); date != "" {
+		fmt.Printf("built:   %s\n", date)
+	}
+	fmt.Printf("go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	if !*check {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	release, err := update.Latest(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to check for updates: %w", err)
+	}
+
+	if !update.IsRelease(version.Get()) {
+		fmt.Printf("\n%s is no release version, the latest release is %s: %s\n", version.Get(), release.TagName, release.HtmlUrl)
+	} else if update.IsNewer(release.TagName, version.Get()) {
+		fmt.Printf("\nA newer version %s is available: %s\n", release.TagName, release.HtmlUrl)
+	} else {
+		fmt.Printf("\n%s is the latest version\n", version.Get())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// maxPendingActions bounds the queued actions per entity, including the running one.
+const maxPendingActions = 4
+
+var errActionQueueFull = errors.New("Too many pending commands for this entity")
+
+// actionQueue runs entity actions on a bounded number of workers. Actions of the same entity
+// run one after another, so a hung action only holds up its own entity until it times out.
+type actionQueue struct {
+	mu      sync.Mutex
+	lanes   map[string][]queuedAction
+	workers chan struct{}
+}
+
+type queuedAction struct {
+	action func(ctx context.Context) error
+	done   func(error)
+}
+
+var actions = &actionQueue{lanes: make(map[string][]queuedAction)}
+
+// QueueAction runs action in the background after the previously queued actions for key,
+// usually the command topic, and passes its error to done. Entities implementing
+// EntityWithCommand themselves use it to get the same timeout and panic handling.
+// The context passed to action is canceled when it exceeds commands.action_timeout.
+func QueueAction(key string, action func(ctx context.Context) error, done func(error)) {
+	actions.enqueue(key, queuedAction{action: action, done: done})
+}
+
+func (queue *actionQueue) enqueue(key string, queued queuedAction) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	pending, running := queue.lanes[key]
+	if len(pending) >= maxPendingActions {
+		go queued.done(errActionQueueFull)
+		return
+	}
+	queue.lanes[key] = append(pending, queued)
+	if !running {
+		go queue.drain(key)
+	}
+}
+
+// drain runs the actions queued for key until none are left.
+func (queue *actionQueue) drain(key string) {
+	for {
+		queue.mu.Lock()
+		pending := queue.lanes[key]
+		if len(pending) == 0 {
+			delete(queue.lanes, key)
+			queue.mu.Unlock()
+			return
+		}
+		queued := pending[0]
+		queue.mu.Unlock()
+
+		commandsConf := appconfig.RequireConfig().Commands
+		workers := queue.acquireWorker(commandsConf.MaxParallelActions)
+		err := runAction(queued.action, time.Duration(commandsConf.ActionTimeout)*time.Second)
+		<-workers
+
+		// The action stays in the lane while it runs, so enqueue knows a drain is active
+		queue.mu.Lock()
+		queue.lanes[key] = queue.lanes[key][1:]
+		queue.mu.Unlock()
+		queued.done(err)
+	}
+}
+
+// acquireWorker blocks until fewer than max actions run and returns the channel to release the worker on.
+func (queue *actionQueue) acquireWorker(max int) chan struct{} {
+	queue.mu.Lock()
+	if queue.workers == nil || cap(queue.workers) != max {
+		queue.workers = make(chan struct{}, max)
+	}
+	workers := queue.workers
+	queue.mu.Unlock()
+
+	workers <- struct{}{}
+	return workers
+}
+
+// runAction runs action and converts a panic into an error. With a timeout the context of action
+// is canceled after timeout, which stops the commands it runs, and runAction stops waiting for it.
+func runAction(action func(ctx context.Context) error, timeout time.Duration) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logger.Error("Action panicked", "panic", recovered)
+				result <- fmt.Errorf("Action panicked: %v", recovered)
+			}
+		}()
+		result <- action(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		// An action failing after its timeout failed because it was canceled
+		if err == nil || ctx.Err() == nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+	logger.Warn("Action timed out and was canceled", "timeout", timeout)
+	return fmt.Errorf("Action did not finish within %s and was canceled", timeout)
+}
@@ -0,0 +1,77 @@
+package entities
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// audioQueryTimeout bounds reading the default audio input
+const audioQueryTimeout = 5 * time.Second
+
+var (
+	audioInputMu sync.Mutex
+	// audioInput is the last default audio input read, kept while the audio tool fails
+	audioInput string
+)
+
+// getAudioEntities returns the select of the default audio input, eg. to switch from the desk microphone to a
+// headset, if pactl, the AudioDeviceCmdlets PowerShell module or SwitchAudioSource finds inputs.
+func getAudioEntities() []Entity {
+	inputs := system.AudioInputs()
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	inputId := appConf.DeviceName + "_select_audio_input"
+	var options []string
+	for _, input := range inputs {
+		options = append(options, input.Name)
+	}
+	return []Entity{
+		Select{
+			State: readAudioInput,
+			SetState: func(name string) error {
+				logger.Info("Audio input selected", "input", name)
+				return system.SetAudioInput(name)
+			},
+			ResultTopic:    appConf.DeviceName + "/select/audio_input/result",
+			Debounce:       entityDebounce("audio_input"),
+			Retain:         entityRetain("audio_input"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/select/" + appConf.DeviceId + "/" + inputId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "select." + inputId,
+				UniqueId:        inputId,
+				Name:            translate("Audio input"),
+				Icon:            "mdi:microphone",
+				StateTopic:      appConf.DeviceName + "/select/audio_input/state",
+				CommandTopic:    appConf.DeviceName + "/select/audio_input/command",
+				Options:         options,
+				EntityCategory:  entityCategory("audio_input", ""),
+				Qos:             entityCommandQos("audio_input"),
+			},
+		},
+	}
+}
+
+// readAudioInput returns the name of the default audio input, or the last one read if the audio tool fails.
+func readAudioInput() string {
+	ctx, cancel := context.WithTimeout(context.Background(), audioQueryTimeout)
+	defer cancel()
+
+	audioInputMu.Lock()
+	defer audioInputMu.Unlock()
+	input, err := system.GetAudioInput(ctx)
+	if err != nil {
+		logger.Warn("Failed to read the audio input", "err", err)
+		return audioInput
+	}
+	audioInput = input
+	return input
+}
@@ -0,0 +1,39 @@
+package entities
+
+// WithEntityAvailability makes an entity depend on both the device availability and
+// its own availability topic, so it can go unavailable independently of the device.
+func WithEntityAvailability(config *DiscoveryConfig, topic string) *DiscoveryConfig {
+	config.Availability = []Availability{
+		GetDeviceAvailability(),
+		{
+			Topic:               topic,
+			PayloadAvailable:    payloadOnline,
+			PayloadNotAvailable: payloadOffline,
+		},
+	}
+	config.AvailabilityMode = AvailabilityModeAll
+	return config
+}
+
+// AvailabilityPayloads returns the payload to publish on every availability topic used by entityList.
+// The device availability topic is always available, the one of read_only never and entity specific topics
+// follow IsAvailable.
+func AvailabilityPayloads(entityList []Entity) map[string]string {
+	deviceTopic := GetDeviceAvailability().Topic
+	readOnlyTopic := readOnlyAvailabilityTopic()
+	payloads := make(map[string]string)
+	for _, ety := range entityList {
+		for _, availability := range ety.GetDiscoveryConfig().Availability {
+			payload := availability.PayloadAvailable
+			if checker, ok := ety.(EntityWithAvailability); ok && availability.Topic != deviceTopic && !checker.IsAvailable() {
+				payload = availability.PayloadNotAvailable
+			}
+			if availability.Topic == readOnlyTopic {
+				payload = availability.PayloadNotAvailable
+			}
+			payloads[availability.Topic] = payload
+		}
+	}
+
+	return payloads
+}
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// getConfigActionEntities returns a button for every action of the config.
+func getConfigActionEntities() []Entity {
+	actions := appconfig.RequireConfig().Actions
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var entityList []Entity
+	for _, name := range names {
+		entityList = append(entityList, newConfigActionButton(name, actions[name]))
+	}
+	return entityList
+}
+
+func newConfigActionButton(name string, action appconfig.ActionAppConfig) Button {
+	appConf := appconfig.RequireConfig()
+	key := "action_" + name
+	objectId := appConf.DeviceName + "_button_" + key
+	displayName := action.Name
+	if displayName == "" {
+		displayName = strings.ReplaceAll(name, "_", " ")
+	}
+	icon := action.Icon
+	switch {
+	case icon != "":
+	case action.Command != "":
+		icon = "mdi:console"
+	default:
+		icon = "mdi:script-text"
+	}
+
+	return Button{
+		Action: func(ctx context.Context) error {
+			logger.Info("Running action", "action", name, "run_as", action.RunAs)
+			if action.Command != "" {
+				return system.RunCommandAs(ctx, system.GetShellCommand(action.Command), action.RunAs)
+			}
+			cmd, err := system.GetAppleScriptCommand(action.AppleScript)
+			if err != nil {
+				return err
+			}
+			return system.RunCommandAs(ctx, cmd, action.RunAs)
+		},
+		ResultTopic:    appConf.DeviceName + "/button/" + key + "/result",
+		Debounce:       entityDebounce(key),
+		Cooldown:       entityCooldown(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "button." + objectId,
+			UniqueId:        objectId,
+			Name:            displayName,
+			Icon:            icon,
+			StateTopic:      appConf.DeviceName + "/button/" + key + "/state",
+			CommandTopic:    appConf.DeviceName + "/button/" + key + "/command",
+			PayloadPress:    entityPayloadPress(key),
+			EntityCategory:  entityCategory(key, ""),
+			Qos:             entityCommandQos(key),
+		},
+	}
+}
@@ -0,0 +1,112 @@
+package entities
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+var (
+	desktopMu sync.Mutex
+	desktop   system.Desktop
+)
+
+// SetDesktop stores the state of the Linux desktop shown by the idle time, display and session sensors.
+func SetDesktop(newDesktop system.Desktop) {
+	desktopMu.Lock()
+	desktop = newDesktop
+	desktopMu.Unlock()
+	SetSession(newDesktop.Session)
+}
+
+func getDesktop() system.Desktop {
+	desktopMu.Lock()
+	defer desktopMu.Unlock()
+	return desktop
+}
+
+func desktopEnabled() bool {
+	return runtime.GOOS == system.LINUX && appconfig.RequireConfig().Desktop.Enabled
+}
+
+// getDesktopEntities returns the idle time sensor and display binary sensor of the Linux desktop, with desktop.enabled.
+func getDesktopEntities() []Entity {
+	if !desktopEnabled() {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	idleId := appConf.DeviceName + "_sensor_idle_time"
+	idleInterval := entityInterval("idle_time")
+	idleFormat := entityPayloadFormat("idle_time")
+	displayId := appConf.DeviceName + "_sensor_display"
+	displayTopic := appConf.DeviceName + "/binary_sensor/display"
+	return []Entity{
+		Sensor{
+			Value: func() string {
+				return strconv.FormatInt(int64(getDesktop().Idle/time.Second), 10)
+			},
+			Interval:       time.Duration(idleInterval) * time.Second,
+			Deadband:       entityDeadband("idle_time"),
+			Average:        entityAverage("idle_time"),
+			Threshold:      entityThreshold("idle_time"),
+			Format:         idleFormat,
+			Retain:         entityRetain("idle_time"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + idleId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:            GetDevice(),
+				Availability:      []Availability{GetDeviceAvailability()},
+				DefaultEntityId:   "sensor." + idleId,
+				UniqueId:          idleId,
+				Name:              translate("Idle time"),
+				Icon:              "mdi:timer-sand",
+				StateTopic:        appConf.DeviceName + "/sensor/idle_time/state",
+				ValueTemplate:     idleFormat.ValueTemplate(),
+				ExpireAfter:       entityExpireAfter("idle_time", sensorRefreshInterval("idle_time", idleInterval)),
+				DeviceClass:       DeviceClassDuration,
+				StateClass:        StateClassMeasurement,
+				UnitOfMeasurement: UnitSeconds,
+				EntityCategory:    entityCategory("idle_time", ""),
+				Qos:               entityQos("idle_time"),
+			},
+		},
+		BinarySensor{
+			State: func() bool {
+				on := getDesktop().DisplayOn
+				return on != nil && *on
+			},
+			// Only X11 and sway report the display power
+			Available:      func() bool { return getDesktop().DisplayOn != nil },
+			Retain:         entityRetain("display"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/binary_sensor/" + appConf.DeviceId + "/" + displayId + "/config",
+			DiscoveryConfig: WithEntityAvailability(&DiscoveryConfig{
+				Device:          GetDevice(),
+				DefaultEntityId: "binary_sensor." + displayId,
+				UniqueId:        displayId,
+				Name:            translate("Display"),
+				Icon:            "mdi:monitor",
+				StateTopic:      displayTopic + "/state",
+				DeviceClass:     DeviceClassPower,
+				PayloadOn:       PayloadOn,
+				PayloadOff:      PayloadOff,
+				EntityCategory:  entityCategory("display", ""),
+				Qos:             entityQos("display"),
+			}, displayTopic+"/availability"),
+		},
+	}
+}
+
+// DesktopDisplaySensor returns the display sensor of entityList.
+func DesktopDisplaySensor(entityList []Entity) (BinarySensor, bool) {
+	displayId := appconfig.RequireConfig().DeviceName + "_sensor_display"
+	for _, ety := range entityList {
+		if sensor, ok := ety.(BinarySensor); ok && sensor.GetDiscoveryConfig().UniqueId == displayId {
+			return sensor, true
+		}
+	}
+	return BinarySensor{}, false
+}
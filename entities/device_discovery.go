@@ -0,0 +1,135 @@
+package entities
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+// DeviceDiscoveryConfig bundles all entities of the device into a single discovery message.
+// https://www.home-assistant.io/integrations/mqtt/#device-discovery-payload
+type DeviceDiscoveryConfig struct {
+	Device     Device                    `json:"device"`
+	Origin     Origin                    `json:"origin"`
+	Components map[string]map[string]any `json:"components"`
+}
+
+type Origin struct {
+	Name       string `json:"name"`
+	SwVersion  string `json:"sw_version,omitempty"`
+	SupportUrl string `json:"support_url,omitempty"`
+}
+
+func GetOrigin() Origin {
+	return Origin{
+		Name:       "pc2mqtt",
+		SwVersion:  version.Get(),
+		SupportUrl: "https://github.com/leonlatsch/pc2mqtt",
+	}
+}
+
+// GetDeviceDiscoveryTopic returns the topic of the device discovery message of device.
+func GetDeviceDiscoveryTopic(device Device) string {
+	appConf := appconfig.RequireConfig()
+	return appConf.Mqtt.AutoDiscoveryPrefix + "/device/" + device.Identifiers + "/config"
+}
+
+// GetDeviceDiscoveryConfigs builds one device discovery message per device in entityList,
+// keyed by its discovery topic. Entities of sub devices end up in their own message.
+func GetDeviceDiscoveryConfigs(entityList []Entity) (map[string]DeviceDiscoveryConfig, error) {
+	configs := make(map[string]DeviceDiscoveryConfig)
+	for _, ety := range entityList {
+		discoveryConfig := ety.GetDiscoveryConfig()
+		topic := GetDeviceDiscoveryTopic(discoveryConfig.Device)
+		config, ok := configs[topic]
+		if !ok {
+			config = DeviceDiscoveryConfig{
+				Device:     discoveryConfig.Device,
+				Origin:     GetOrigin(),
+				Components: make(map[string]map[string]any),
+			}
+			configs[topic] = config
+		}
+
+		buf, err := json.Marshal(discoveryConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		var component map[string]any
+		if err := json.Unmarshal(buf, &component); err != nil {
+			return nil, err
+		}
+
+		// The device is shared by all components and unset options only bloat the single message
+		delete(component, "device")
+		for key, value := range component {
+			if value == "" {
+				delete(component, key)
+			}
+		}
+		component["platform"] = GetPlatform(ety)
+		config.Components[discoveryConfig.UniqueId] = component
+	}
+
+	return configs, nil
+}
+
+// GetPlatform returns the Home Assistant MQTT platform of ety.
+func GetPlatform(ety Entity) string {
+	switch ety.(type) {
+	case BinarySensor:
+		return "binary_sensor"
+	case Sensor:
+		return "sensor"
+	case Button:
+		return "button"
+	case Switch:
+		return "switch"
+	case Select:
+		return "select"
+	case Notify:
+		return "notify"
+	case Event:
+		return "event"
+	case DeviceTracker:
+		return "device_tracker"
+	case DeviceTrigger:
+		return "device_automation"
+	case Update:
+		return "update"
+	default:
+		return ""
+	}
+}
+
+// GetDeviceDiscoveryUpdates builds the device discovery messages of the devices touched by change from the
+// current entityList. Removed components are reduced to their platform, which makes Home Assistant drop them,
+// and devices without components left map to nil, which is published as an empty message to remove the device.
+func GetDeviceDiscoveryUpdates(entityList []Entity, change Change) (map[string]*DeviceDiscoveryConfig, error) {
+	configs, err := GetDeviceDiscoveryConfigs(entityList)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]*DeviceDiscoveryConfig)
+	for _, ety := range slices.Concat(change.Added, change.Removed) {
+		topic := GetDeviceDiscoveryTopic(ety.GetDiscoveryConfig().Device)
+		if config, ok := configs[topic]; ok {
+			updates[topic] = &config
+		} else {
+			updates[topic] = nil
+		}
+	}
+
+	for _, ety := range change.Removed {
+		config := updates[GetDeviceDiscoveryTopic(ety.GetDiscoveryConfig().Device)]
+		if config != nil {
+			config.Components[ety.GetDiscoveryConfig().UniqueId] = map[string]any{"platform": GetPlatform(ety)}
+		}
+	}
+
+	return updates, nil
+}
@@ -0,0 +1,123 @@
+package entities
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/diagnostics"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+// Home Assistant rejects states longer than 255 characters
+const maxStateLength = 255
+
+// getDiagnosticEntities returns sensors about pc2mqtt itself, if diagnostics are enabled.
+func getDiagnosticEntities() []Entity {
+	if !appconfig.RequireConfig().Diagnostics.Enabled {
+		return nil
+	}
+
+	entityList := []Entity{
+		newDiagnosticSensor("version", "Version", "mdi:tag", SensorClass{}, version.Get),
+		newDiagnosticSensor("uptime", "Uptime", "mdi:timer-outline", SensorClass{
+			DeviceClass: DeviceClassDuration,
+			StateClass:  StateClassMeasurement,
+			Unit:        UnitSeconds,
+		}, func() string {
+			return strconv.FormatInt(int64(diagnostics.Uptime().Seconds()), 10)
+		}),
+		newDiagnosticSensor("publishes", "Publishes", "mdi:upload", SensorClass{StateClass: StateClassTotalIncreasing}, func() string {
+			return strconv.FormatUint(diagnostics.Publishes(), 10)
+		}),
+		newDiagnosticSensor("publish_failures", "Publish failures", "mdi:upload-off", SensorClass{StateClass: StateClassTotalIncreasing}, func() string {
+			return strconv.FormatUint(diagnostics.PublishFailures(), 10)
+		}),
+		newDiagnosticSensor("reconnects", "Reconnects", "mdi:connection", SensorClass{StateClass: StateClassTotalIncreasing}, func() string {
+			return strconv.FormatUint(diagnostics.Reconnects(), 10)
+		}),
+		newDiagnosticSensor("failed_connects", "Failed connection attempts", "mdi:lan-disconnect", SensorClass{StateClass: StateClassTotalIncreasing}, func() string {
+			return strconv.FormatUint(diagnostics.FailedConnects(), 10)
+		}),
+		newDiagnosticSensor("last_error", "Last error", "mdi:alert-circle-outline", SensorClass{}, func() string {
+			lastError := diagnostics.LastError()
+			if lastError == "" {
+				return "none"
+			}
+			if len(lastError) > maxStateLength {
+				return lastError[:maxStateLength]
+			}
+			return lastError
+		}),
+	}
+	if appconfig.RequireConfig().Diagnostics.Runtime {
+		entityList = append(entityList, getRuntimeSensors()...)
+	}
+	return entityList
+}
+
+// getRuntimeSensors returns sensors about the Go runtime of pc2mqtt, to spot leaks on long running machines.
+func getRuntimeSensors() []Entity {
+	return []Entity{
+		newDiagnosticSensor("memory", "Memory", "mdi:memory", SensorClass{
+			DeviceClass: DeviceClassDataSize,
+			StateClass:  StateClassMeasurement,
+			Unit:        Mega.Unit(),
+		}, func() string {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			// Memory returned to the OS still counts in Sys
+			return strconv.FormatFloat(Mega.Convert(float64(stats.Sys-stats.HeapReleased)), 'f', 1, 64)
+		}),
+		newDiagnosticSensor("goroutines", "Goroutines", "mdi:format-list-numbered", SensorClass{StateClass: StateClassMeasurement}, func() string {
+			return strconv.Itoa(runtime.NumGoroutine())
+		}),
+		newDiagnosticSensor("gc_pause", "GC pause", "mdi:timer-pause-outline", SensorClass{
+			DeviceClass: DeviceClassDuration,
+			StateClass:  StateClassMeasurement,
+			Unit:        UnitMilliseconds,
+		}, func() string {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			if stats.NumGC == 0 {
+				return "0"
+			}
+			lastPause := time.Duration(stats.PauseNs[(stats.NumGC+255)%256])
+			return strconv.FormatFloat(float64(lastPause)/float64(time.Millisecond), 'f', 3, 64)
+		}),
+	}
+}
+
+func newDiagnosticSensor(key string, name string, icon string, class SensorClass, value func() string) Sensor {
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_diagnostic_" + key
+	format := entityPayloadFormat(key)
+	interval := entityInterval(key)
+	return Sensor{
+		Value:          value,
+		Interval:       time.Duration(interval) * time.Second,
+		Deadband:       entityDeadband(key),
+		Average:        entityAverage(key),
+		Threshold:      entityThreshold(key),
+		Format:         format,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:            GetDevice(),
+			Availability:      []Availability{GetDeviceAvailability()},
+			DefaultEntityId:   "sensor." + objectId,
+			UniqueId:          objectId,
+			Name:              translate(name),
+			Icon:              icon,
+			StateTopic:        appConf.DeviceName + "/sensor/diagnostic_" + key + "/state",
+			ValueTemplate:     format.ValueTemplate(),
+			ExpireAfter:       entityExpireAfter(key, sensorRefreshInterval(key, interval)),
+			DeviceClass:       class.DeviceClass,
+			StateClass:        class.StateClass,
+			UnitOfMeasurement: class.Unit,
+			EntityCategory:    entityCategory(key, EntityCategoryDiagnostic),
+			Qos:               entityQos(key),
+		},
+	}
+}
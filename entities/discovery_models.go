@@ -0,0 +1,117 @@
+package entities
+
+import "github.com/leonlatsch/pc2mqtt/internal/appconfig"
+
+type DiscoveryConfig struct {
+	Device            Device         `json:"device"`
+	Availability      []Availability `json:"availability"`
+	AvailabilityMode  string         `json:"availability_mode,omitempty"`
+	CommandTopic      string         `json:"command_topic"`
+	Name              string         `json:"name"`
+	Icon              string         `json:"icon"`
+	ObjectId          string         `json:"object_id,omitempty"` // Deprecated: use DefaultEntityId
+	DefaultEntityId   string         `json:"default_entity_id,omitempty"`
+	StateTopic        string         `json:"state_topic"`
+	ValueTemplate     string         `json:"value_template,omitempty"`
+	ExpireAfter       int            `json:"expire_after,omitempty"`
+	DeviceClass       string         `json:"device_class,omitempty"`
+	StateClass        string         `json:"state_class,omitempty"`
+	UnitOfMeasurement string         `json:"unit_of_measurement,omitempty"`
+	PayloadOn         string         `json:"payload_on"`
+	PayloadOff        string         `json:"payload_off"`
+	PayloadInstall    string         `json:"payload_install,omitempty"`
+	PayloadPress      string         `json:"payload_press,omitempty"`
+	PayloadHome       string         `json:"payload_home,omitempty"`
+	PayloadNotHome    string         `json:"payload_not_home,omitempty"`
+	SourceType        string         `json:"source_type,omitempty"`
+	UniqueId          string         `json:"unique_id"`
+	Qos               int            `json:"qos"`
+	Schema            string         `json:"schema"`
+	EntityCategory    string         `json:"entity_category,omitempty"`
+	EventTypes        []string       `json:"event_types,omitempty"`
+	Options           []string       `json:"options,omitempty"`
+	// AutomationType, Topic, Type, Subtype and Payload describe device triggers
+	AutomationType string `json:"automation_type,omitempty"`
+	Topic          string `json:"topic,omitempty"`
+	Type           string `json:"type,omitempty"`
+	Subtype        string `json:"subtype,omitempty"`
+	Payload        string `json:"payload,omitempty"`
+	// JsonAttributesTopic receives a JSON object whose keys become attributes of the entity
+	JsonAttributesTopic string `json:"json_attributes_topic,omitempty"`
+}
+
+const (
+	AvailabilityModeAll    = "all"
+	AvailabilityModeAny    = "any"
+	AvailabilityModeLatest = "latest"
+)
+
+// https://www.home-assistant.io/integrations/sensor/#device-class
+const (
+	DeviceClassPower       = "power"
+	DeviceClassDataSize    = "data_size"
+	DeviceClassDataRate    = "data_rate"
+	DeviceClassTemperature = "temperature"
+	DeviceClassDuration    = "duration"
+	DeviceClassTimestamp   = "timestamp"
+	// DeviceClassEnum sensors have one of the options as state
+	DeviceClassEnum = "enum"
+)
+
+// https://developers.home-assistant.io/docs/core/entity/sensor/#available-state-classes
+const (
+	StateClassMeasurement     = "measurement"
+	StateClassTotal           = "total"
+	StateClassTotalIncreasing = "total_increasing"
+)
+
+const (
+	UnitSeconds      = "s"
+	UnitMilliseconds = "ms"
+	UnitPercent      = "%"
+	UnitCelsius      = "°C"
+	UnitWatts        = "W"
+	UnitRpm          = "RPM"
+)
+
+// PayloadPress is the default payload_press of Home Assistant buttons.
+const PayloadPress = "PRESS"
+
+// The default payload_on and payload_off of Home Assistant binary sensors and switches
+const (
+	PayloadOn  = "ON"
+	PayloadOff = "OFF"
+)
+
+// The default payload_home and payload_not_home of Home Assistant device trackers
+const (
+	PayloadHome    = "home"
+	PayloadNotHome = "not_home"
+)
+
+// SourceTypeRouter tells Home Assistant a device tracker reports a device seen on the network.
+const SourceTypeRouter = "router"
+
+const (
+	EntityCategoryConfig     = appconfig.EntityCategoryConfig
+	EntityCategoryDiagnostic = appconfig.EntityCategoryDiagnostic
+)
+
+type Device struct {
+	Identifiers      string `json:"identifiers"`
+	Manufacturer     string `json:"manufacturer,omitempty"`
+	Model            string `json:"model,omitempty"`
+	HwVersion        string `json:"hw_version,omitempty"`
+	SwVersion        string `json:"sw_version,omitempty"`
+	Name             string `json:"name"`
+	SuggestedArea    string `json:"suggested_area,omitempty"`
+	ConfigurationUrl string `json:"configuration_url,omitempty"`
+	// ViaDevice links a sub device to the identifiers of the PC it is managed through.
+	ViaDevice string `json:"via_device,omitempty"`
+}
+
+type Availability struct {
+	Topic               string `json:"topic"`
+	PayloadAvailable    string `json:"payload_available"`
+	PayloadNotAvailable string `json:"payload_not_available"`
+}
@@ -0,0 +1,22 @@
+package entities
+
+import (
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/slug"
+)
+
+// withEntityIds turns the default entity ids of entityList into valid Home Assistant entity ids, eg.
+// button.my_pc_button_shutdown for the device my-pc. Home Assistant ignores invalid ones, which also covers
+// entities of programs embedding pc2mqtt.
+func withEntityIds(entityList []Entity) []Entity {
+	for _, ety := range entityList {
+		config := ety.GetDiscoveryConfig()
+		domain, objectId, ok := strings.Cut(config.DefaultEntityId, ".")
+		if !ok {
+			continue
+		}
+		config.DefaultEntityId = domain + "." + slug.EntityId(objectId)
+	}
+	return entityList
+}
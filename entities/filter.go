@@ -0,0 +1,87 @@
+package entities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+func FilterEntitiesWithCommands(entityList []Entity) []EntityWithCommand {
+	var result []EntityWithCommand
+	for _, item := range entityList {
+		if v, ok := item.(EntityWithCommand); ok {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+// FindEntityWithCommand returns the entity of entityList with a command whose unique id is name
+// or ends in _<name>, eg. shutdown for the shutdown button. Entities of the PC itself win over
+// those of sub devices, eg. the shutdown button of a host.
+func FindEntityWithCommand(entityList []Entity, name string) (EntityWithCommand, error) {
+	var uniqueIds []string
+	var matches []EntityWithCommand
+	for _, entity := range FilterEntitiesWithCommands(entityList) {
+		uniqueId := entity.GetDiscoveryConfig().UniqueId
+		if uniqueId == name {
+			return entity, nil
+		}
+		if strings.HasSuffix(uniqueId, "_"+name) {
+			matches = append(matches, entity)
+		}
+		uniqueIds = append(uniqueIds, uniqueId)
+	}
+
+	if len(matches) > 1 {
+		deviceId := appconfig.RequireConfig().DeviceId
+		own := slices.DeleteFunc(slices.Clone(matches), func(entity EntityWithCommand) bool {
+			return entity.GetDiscoveryConfig().Device.Identifiers != deviceId
+		})
+		if len(own) == 1 {
+			return own[0], nil
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("No entity with a command named %s. Available: %s", name, strings.Join(uniqueIds, ", "))
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s matches several entities, use the unique id. Available: %s", name, strings.Join(uniqueIds, ", "))
+	}
+}
+
+// DefaultCommandPayload returns the payload running the action of entity: payload_on for
+// switches, the selected option of selects, the install payload for updates, the confirmation payload
+// of buttons requiring one and PRESS otherwise.
+func DefaultCommandPayload(entity EntityWithCommand) string {
+	config := entity.GetDiscoveryConfig()
+	switch v := entity.(type) {
+	case Switch:
+		return config.PayloadOn
+	case Select:
+		return v.State()
+	case Update:
+		return config.PayloadInstall
+	default:
+		if config.PayloadPress != "" {
+			return config.PayloadPress
+		}
+		return PayloadPress
+	}
+}
+
+// CheckCommandPayload returns an error if entity is a button requiring a confirmation payload and
+// payload is another one.
+func CheckCommandPayload(entity EntityWithCommand, payload string) error {
+	button, ok := entity.(Button)
+	if !ok || button.DiscoveryConfig.PayloadPress == "" || payload == button.DiscoveryConfig.PayloadPress {
+		return nil
+	}
+	return fmt.Errorf("Ignoring command on %q, the payload is not the confirmation set in payload_press", button.DiscoveryConfig.CommandTopic)
+}
@@ -0,0 +1,240 @@
+package entities
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/logging"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+var logger = logging.For(appconfig.LogModuleEntities)
+
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+func init() {
+	// The built-in modules, registered before any entities of programs embedding pc2mqtt
+	RegisterProvider(getSystemEntities)
+	RegisterProvider(getDiagnosticEntities)
+	RegisterProvider(getUpdateEntities)
+	RegisterProvider(getScheduleEntities)
+	RegisterProvider(getPowerEntities)
+	RegisterProvider(getSessionEntities)
+	RegisterProvider(getPresenceEntities)
+	RegisterProvider(getDesktopEntities)
+	RegisterProvider(getNotifyEntities)
+	RegisterProvider(getMessageEntities)
+	RegisterProvider(getSystemEventEntities)
+	RegisterProvider(getMacEntities)
+	RegisterProvider(getGpuEntities)
+	RegisterProvider(getGpuSensorEntities)
+	RegisterProvider(getAudioEntities)
+	RegisterProvider(getPrintQueueEntities)
+	RegisterProvider(getResourceEntities)
+	RegisterProvider(getNetworkEntities)
+	RegisterProvider(getTailscaleEntities)
+	RegisterProvider(getHostEntities)
+	RegisterProvider(getConfigActionEntities)
+	RegisterProvider(getDebugEntities)
+}
+
+// getSystemEntities returns the power sensor and the shutdown, reboot and sleep buttons. The sleep button is
+// left out where the PC can't sleep with the sleep mode.
+func getSystemEntities() []Entity {
+	appConf := appconfig.RequireConfig()
+	entityList := []Entity{
+		BinarySensor{
+			Retain:         entityRetain("power"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/binary_sensor/" + appConf.DeviceId + "/" + appConf.DeviceName + "_sensor_power/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device: GetDevice(),
+				Availability: []Availability{
+					{
+						Topic:               appConf.DeviceName + "/binary_sensor/availability",
+						PayloadAvailable:    payloadOnline,
+						PayloadNotAvailable: payloadOffline,
+					},
+				},
+				DefaultEntityId: "binary_sensor." + appConf.DeviceName + "_sensor_power",
+				UniqueId:        appConf.DeviceName + "_sensor_power",
+				Name:            translate("Power"),
+				Icon:            "mdi:power",
+				StateTopic:      GetDeviceAvailability().Topic,
+				DeviceClass:     DeviceClassPower,
+				PayloadOn:       GetDeviceAvailability().PayloadAvailable,
+				PayloadOff:      GetDeviceAvailability().PayloadNotAvailable,
+				ExpireAfter:     entityExpireAfter("power", appConf.Heartbeat.Interval),
+				EntityCategory:  entityCategory("power", ""),
+				Qos:             entityQos("power"),
+			},
+		},
+		Button{
+			Action: func(ctx context.Context) error {
+				logger.Info("Shutdown button pressed, executing system shutdown")
+				if err := runPowerAction(ctx, "shutdown", system.InhibitShutdown, system.Shutdown); err != nil {
+					return err
+				}
+				logger.Info("System shutdown initiated")
+				return nil
+			},
+			ResultTopic:    appConf.DeviceName + "/button/shutdown/result",
+			Debounce:       entityDebounce("shutdown"),
+			Cooldown:       entityCooldown("shutdown"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + appConf.DeviceName + "_button_shutdown/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + appConf.DeviceName + "_button_shutdown",
+				UniqueId:        appConf.DeviceName + "_button_shutdown",
+				Name:            translate("Shutdown"),
+				Icon:            "mdi:power",
+				StateTopic:      appConf.DeviceName + "/button/shutdown/state",
+				CommandTopic:    appConf.DeviceName + "/button/shutdown/command",
+				PayloadPress:    entityPayloadPress("shutdown"),
+				EntityCategory:  entityCategory("shutdown", ""),
+				Qos:             entityCommandQos("shutdown"),
+			},
+		},
+		Button{
+			Action: func(ctx context.Context) error {
+				logger.Info("Reboot button pressed, executing system reboot")
+				if err := runPowerAction(ctx, "reboot", system.InhibitShutdown, system.Reboot); err != nil {
+					return err
+				}
+				logger.Info("System reboot initiated")
+				return nil
+			},
+			ResultTopic:    appConf.DeviceName + "/button/reboot/result",
+			Debounce:       entityDebounce("reboot"),
+			Cooldown:       entityCooldown("reboot"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + appConf.DeviceName + "_button_reboot/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + appConf.DeviceName + "_button_reboot",
+				UniqueId:        appConf.DeviceName + "_button_reboot",
+				Name:            translate("Reboot"),
+				Icon:            "mdi:restart",
+				StateTopic:      appConf.DeviceName + "/button/reboot/state",
+				CommandTopic:    appConf.DeviceName + "/button/reboot/command",
+				PayloadPress:    entityPayloadPress("reboot"),
+				EntityCategory:  entityCategory("reboot", ""),
+				Qos:             entityCommandQos("reboot"),
+			},
+		},
+	}
+	if !system.SleepModeSupported(appConf.Commands.OsSleepMode()) {
+		return entityList
+	}
+
+	return append(entityList,
+		Button{
+			Action: func(ctx context.Context) error {
+				logger.Info("Sleep button pressed, suspending the system", "mode", appConf.Commands.OsSleepMode())
+				if err := runPowerAction(ctx, "sleep", system.InhibitSleep, system.Suspend); err != nil {
+					return err
+				}
+				logger.Info("System suspend initiated")
+				return nil
+			},
+			ResultTopic:    appConf.DeviceName + "/button/sleep/result",
+			Debounce:       entityDebounce("sleep"),
+			Cooldown:       entityCooldown("sleep"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + appConf.DeviceName + "_button_sleep/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + appConf.DeviceName + "_button_sleep",
+				UniqueId:        appConf.DeviceName + "_button_sleep",
+				Name:            translate("Sleep"),
+				Icon:            "mdi:sleep",
+				StateTopic:      appConf.DeviceName + "/button/sleep/state",
+				CommandTopic:    appConf.DeviceName + "/button/sleep/command",
+				PayloadPress:    entityPayloadPress("sleep"),
+				EntityCategory:  entityCategory("sleep", ""),
+				Qos:             entityCommandQos("sleep"),
+			},
+		},
+	)
+}
+
+// getDebugEntities returns a test button doing nothing but logging, in debug mode only.
+func getDebugEntities() []Entity {
+	appConf := appconfig.RequireConfig()
+	if !appConf.DebugMode {
+		return nil
+	}
+
+	return []Entity{
+		Button{
+			Action: func(context.Context) error {
+				logger.Info("Test button pressed")
+				return nil
+			},
+			ResultTopic:    appConf.DeviceName + "/button/test/result",
+			Debounce:       entityDebounce("test"),
+			Cooldown:       entityCooldown("test"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + appConf.DeviceName + "_button_test/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + appConf.DeviceName + "_button_test",
+				UniqueId:        appConf.DeviceName + "_button_test",
+				Name:            translate("Test"),
+				Icon:            "mdi:test-tube",
+				StateTopic:      appConf.DeviceName + "/button/test/state",
+				CommandTopic:    appConf.DeviceName + "/button/test/command",
+				PayloadPress:    entityPayloadPress("test"),
+				EntityCategory:  entityCategory("test", EntityCategoryDiagnostic),
+				Qos:             entityCommandQos("test"),
+			},
+		},
+	}
+}
+
+func GetDeviceAvailability() Availability {
+	appConf := appconfig.RequireConfig()
+	return Availability{
+		Topic:               appConf.DeviceName + "/state",
+		PayloadAvailable:    payloadOnline,
+		PayloadNotAvailable: payloadOffline,
+	}
+
+}
+func GetDevice() Device {
+	appConf := appconfig.RequireConfig()
+	hardware := system.Hardware()
+	model := hardware.Model
+	if model == "" {
+		model = runtime.GOOS + "/" + runtime.GOARCH
+	}
+
+	return Device{
+		Identifiers:      appConf.DeviceId,
+		Manufacturer:     hardware.Manufacturer,
+		Model:            model,
+		HwVersion:        hardware.Version,
+		SwVersion:        version.Get(),
+		Name:             appConf.DeviceDisplayName,
+		SuggestedArea:    appConf.SuggestedArea,
+		ConfigurationUrl: appConf.ConfigurationUrl,
+	}
+}
+
+// GetSubDevice returns a separate Home Assistant device for something managed by this PC,
+// eg. a VM or container, linked to the PC with via_device. key must be unique per PC.
+func GetSubDevice(key string, name string, model string) Device {
+	appConf := appconfig.RequireConfig()
+	return Device{
+		Identifiers:   appConf.DeviceId + "_" + key,
+		Model:         model,
+		Name:          name,
+		SuggestedArea: appConf.SuggestedArea,
+		ViaDevice:     appConf.DeviceId,
+	}
+}
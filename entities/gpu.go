@@ -0,0 +1,99 @@
+package entities
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// gpuQueryTimeout bounds reading the graphics mode, which supergfxctl asks supergfxd for over D-Bus
+const gpuQueryTimeout = 5 * time.Second
+
+var (
+	gpuModeMu sync.Mutex
+	// gpuMode is the last graphics mode read, kept while the tool fails
+	gpuMode string
+)
+
+// getGpuEntities returns the graphics mode select and the pending action sensor of laptops with switchable
+// graphics, if supergfxctl, system76-power or prime-select is installed.
+func getGpuEntities() []Entity {
+	if len(system.GpuModes()) == 0 {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	modeId := appConf.DeviceName + "_select_gpu_mode"
+	pendingKey := "gpu_pending_action"
+	pendingId := appConf.DeviceName + "_sensor_" + pendingKey
+	pendingInterval := entityInterval(pendingKey)
+	return []Entity{
+		Select{
+			State: readGpuMode,
+			SetState: func(mode string) error {
+				logger.Info("GPU mode selected", "mode", mode)
+				return system.SetGpuMode(mode)
+			},
+			ResultTopic:    appConf.DeviceName + "/select/gpu_mode/result",
+			Debounce:       entityDebounce("gpu_mode"),
+			Retain:         entityRetain("gpu_mode"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/select/" + appConf.DeviceId + "/" + modeId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "select." + modeId,
+				UniqueId:        modeId,
+				Name:            translate("GPU mode"),
+				Icon:            "mdi:expansion-card",
+				StateTopic:      appConf.DeviceName + "/select/gpu_mode/state",
+				CommandTopic:    appConf.DeviceName + "/select/gpu_mode/command",
+				Options:         system.GpuModes(),
+				EntityCategory:  entityCategory("gpu_mode", EntityCategoryConfig),
+				Qos:             entityCommandQos("gpu_mode"),
+			},
+		},
+		Sensor{
+			Poll: func(ctx context.Context) (string, error) {
+				ctx, cancel := context.WithTimeout(ctx, gpuQueryTimeout)
+				defer cancel()
+				return system.GetGpuPendingAction(ctx)
+			},
+			Interval:       time.Duration(pendingInterval) * time.Second,
+			Retain:         entityRetain(pendingKey),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + pendingId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "sensor." + pendingId,
+				UniqueId:        pendingId,
+				Name:            translate("GPU mode pending action"),
+				Icon:            "mdi:restart-alert",
+				StateTopic:      appConf.DeviceName + "/sensor/" + pendingKey + "/state",
+				ExpireAfter:     entityExpireAfter(pendingKey, sensorRefreshInterval(pendingKey, pendingInterval)),
+				DeviceClass:     DeviceClassEnum,
+				Options:         []string{system.GpuPendingNone, system.GpuPendingLogout, system.GpuPendingReboot},
+				EntityCategory:  entityCategory(pendingKey, EntityCategoryDiagnostic),
+				Qos:             entityQos(pendingKey),
+			},
+		},
+	}
+}
+
+// readGpuMode returns the graphics mode the tool is set to, or the last one read if it fails.
+func readGpuMode() string {
+	ctx, cancel := context.WithTimeout(context.Background(), gpuQueryTimeout)
+	defer cancel()
+
+	gpuModeMu.Lock()
+	defer gpuModeMu.Unlock()
+	mode, err := system.GetGpuMode(ctx)
+	if err != nil {
+		logger.Warn("Failed to read the GPU mode", "err", err)
+		return gpuMode
+	}
+	gpuMode = mode
+	return mode
+}
@@ -0,0 +1,67 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// getGpuSensorEntities returns the utilization, temperature, fan speed, power draw and video memory sensors of
+// every GPU, as far as its driver reports them.
+func getGpuSensorEntities() []Entity {
+	var entities []Entity
+	for i, gpu := range system.Gpus() {
+		// gpu_temperature for the first GPU, gpu2_temperature for the second
+		key, name := "gpu", "GPU"
+		if i > 0 {
+			key, name = "gpu"+strconv.Itoa(i+1), "GPU "+strconv.Itoa(i+1)
+		}
+
+		fanUnit := UnitRpm
+		if gpu.FanPercent {
+			fanUnit = UnitPercent
+		}
+		sensors := []struct {
+			key   string
+			name  string
+			icon  string
+			class SensorClass
+			value func(system.GpuReading) *float64
+			// scale converts the value to the unit
+			scale func(float64) float64
+		}{
+			{"utilization", "utilization", "mdi:expansion-card", SensorClass{StateClass: StateClassMeasurement, Unit: UnitPercent},
+				func(reading system.GpuReading) *float64 { return reading.Utilization }, nil},
+			{"temperature", "temperature", "mdi:thermometer", SensorClass{DeviceClass: DeviceClassTemperature, StateClass: StateClassMeasurement, Unit: UnitCelsius},
+				func(reading system.GpuReading) *float64 { return reading.Temperature }, nil},
+			{"fan_speed", "fan speed", "mdi:fan", SensorClass{StateClass: StateClassMeasurement, Unit: fanUnit},
+				func(reading system.GpuReading) *float64 { return reading.FanSpeed }, nil},
+			{"power", "power", "mdi:flash", SensorClass{DeviceClass: DeviceClassPower, StateClass: StateClassMeasurement, Unit: UnitWatts},
+				func(reading system.GpuReading) *float64 { return reading.Power }, nil},
+			{"memory_used", "memory used", "mdi:memory", SensorClass{DeviceClass: DeviceClassDataSize, StateClass: StateClassMeasurement, Unit: Mega.Unit()},
+				func(reading system.GpuReading) *float64 { return reading.MemoryUsed }, Mega.Convert},
+		}
+		for _, sensor := range sensors {
+			if sensor.value(gpu.Reports) == nil {
+				continue
+			}
+			entities = append(entities, newResourceSensor(key+"_"+sensor.key, name+" "+sensor.name, sensor.icon, sensor.class, func(ctx context.Context) (string, error) {
+				reading, err := gpu.Read(ctx)
+				if err != nil {
+					return "", err
+				}
+				value := sensor.value(reading)
+				if value == nil {
+					return "", errors.New(gpu.Name + " no longer reports its " + sensor.name)
+				}
+				if sensor.scale != nil {
+					return strconv.FormatFloat(sensor.scale(*value), 'f', 0, 64), nil
+				}
+				return strconv.FormatFloat(*value, 'f', 1, 64), nil
+			}))
+		}
+	}
+	return entities
+}
@@ -0,0 +1,247 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// https://homieiot.github.io/specification/spec-core-v4_0_0/
+const homieVersion = "4.0.0"
+
+// Values of the $state attribute of a Homie device
+const (
+	HomieStateInit         = "init"
+	HomieStateReady        = "ready"
+	HomieStateDisconnected = "disconnected"
+	HomieStateLost         = "lost"
+)
+
+// Homie datatypes
+const (
+	homieBoolean  = "boolean"
+	homieFloat    = "float"
+	homieString   = "string"
+	homieEnum     = "enum"
+	homieDatetime = "datetime"
+)
+
+// HomieNode presents an entity as a Homie node with a single property holding its state or command.
+type HomieNode struct {
+	Id       string
+	Entity   Entity
+	Property HomieProperty
+}
+
+type HomieProperty struct {
+	Id       string
+	Datatype string
+	Format   string
+	Unit     string
+	Settable bool
+	Retained bool
+}
+
+// HomieDeviceTopic returns the base topic of the Homie device, <homie.base_topic>/<device_name>.
+func HomieDeviceTopic() string {
+	appConf := appconfig.RequireConfig()
+	return appConf.Homie.BaseTopic + "/" + homieId(appConf.DeviceName)
+}
+
+// HomieStateTopic returns the topic of the $state attribute of the Homie device.
+func HomieStateTopic() string {
+	return HomieDeviceTopic() + "/$state"
+}
+
+// homieId converts name to a Homie topic id of lowercase letters, digits and hyphens.
+func homieId(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+	return strings.Trim(id, "-")
+}
+
+// HomieNodes returns a node per entity of entityList. Nodes are identified by the unique id of
+// their entity without the device name, eg. button-shutdown.
+func HomieNodes(entityList []Entity) []HomieNode {
+	prefix := appconfig.RequireConfig().DeviceName + "_"
+	var nodes []HomieNode
+	for _, ety := range entityList {
+		// Their events are the values of the system event node
+		if _, ok := ety.(DeviceTrigger); ok {
+			continue
+		}
+		config := ety.GetDiscoveryConfig()
+		node := HomieNode{
+			Id:     homieId(strings.TrimPrefix(config.UniqueId, prefix)),
+			Entity: ety,
+			Property: HomieProperty{
+				Id:       "state",
+				Datatype: homieString,
+				Unit:     config.UnitOfMeasurement,
+				Retained: true,
+			},
+		}
+
+		switch v := ety.(type) {
+		case BinarySensor:
+			node.Property.Datatype = homieBoolean
+		case Sensor:
+			switch {
+			case config.DeviceClass == DeviceClassTimestamp:
+				node.Property.Datatype = homieDatetime
+			case config.UnitOfMeasurement != "" || config.StateClass != "":
+				node.Property.Datatype = homieFloat
+			}
+		case Switch:
+			node.Property.Datatype = homieBoolean
+			node.Property.Settable = true
+		case Select:
+			node.Property.Datatype = homieEnum
+			node.Property.Format = strings.Join(v.DiscoveryConfig.Options, ",")
+			node.Property.Settable = true
+		case Button:
+			// Presses are events without a state
+			node.Property.Id = "press"
+			node.Property.Datatype = homieEnum
+			node.Property.Format = DefaultCommandPayload(v)
+			node.Property.Settable = true
+			node.Property.Retained = false
+		case Event:
+			node.Property.Id = "event"
+			node.Property.Datatype = homieEnum
+			node.Property.Format = strings.Join(v.DiscoveryConfig.EventTypes, ",")
+			node.Property.Retained = false
+		case Update:
+			node.Property.Settable = true
+		case EntityWithCommand:
+			node.Property.Settable = v.GetDiscoveryConfig().CommandTopic != ""
+		}
+		if appconfig.RequireConfig().ReadOnly {
+			node.Property.Settable = false
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// FindHomieNode returns the node of nodes with the given id.
+func FindHomieNode(nodes []HomieNode, id string) (HomieNode, bool) {
+	i := slices.IndexFunc(nodes, func(node HomieNode) bool {
+		return node.Id == id
+	})
+	if i < 0 {
+		return HomieNode{}, false
+	}
+	return nodes[i], true
+}
+
+// HomieAttributes returns the retained attribute messages of the Homie device with nodes, keyed
+// by topic. The $state attribute is left out.
+func HomieAttributes(nodes []HomieNode) map[string]string {
+	deviceTopic := HomieDeviceTopic()
+	var nodeIds []string
+	attributes := map[string]string{
+		deviceTopic + "/$homie":          homieVersion,
+		deviceTopic + "/$name":           GetDevice().Name,
+		deviceTopic + "/$implementation": "pc2mqtt",
+	}
+
+	for _, node := range nodes {
+		nodeIds = append(nodeIds, node.Id)
+		nodeTopic := deviceTopic + "/" + node.Id
+		name := node.Entity.GetDiscoveryConfig().Name
+		attributes[nodeTopic+"/$name"] = name
+		attributes[nodeTopic+"/$type"] = GetPlatform(node.Entity)
+		attributes[nodeTopic+"/$properties"] = node.Property.Id
+
+		propertyTopic := node.Topic()
+		attributes[propertyTopic+"/$name"] = name
+		attributes[propertyTopic+"/$datatype"] = node.Property.Datatype
+		attributes[propertyTopic+"/$settable"] = strconv.FormatBool(node.Property.Settable)
+		attributes[propertyTopic+"/$retained"] = strconv.FormatBool(node.Property.Retained)
+		if node.Property.Format != "" {
+			attributes[propertyTopic+"/$format"] = node.Property.Format
+		}
+		if node.Property.Unit != "" {
+			attributes[propertyTopic+"/$unit"] = node.Property.Unit
+		}
+	}
+	attributes[deviceTopic+"/$nodes"] = strings.Join(nodeIds, ",")
+	return attributes
+}
+
+// Topic returns the topic of the node's property, which holds its value.
+func (node HomieNode) Topic() string {
+	return HomieDeviceTopic() + "/" + node.Id + "/" + node.Property.Id
+}
+
+// Value converts a state payload of the node's entity to the value of its property.
+func (node HomieNode) Value(payload string) string {
+	switch v := node.Entity.(type) {
+	case BinarySensor:
+		return strconv.FormatBool(payload == v.DiscoveryConfig.PayloadOn)
+	case Switch:
+		return strconv.FormatBool(payload == v.DiscoveryConfig.PayloadOn)
+	case Sensor:
+		if !v.Format.Json {
+			return payload
+		}
+		var wrapped struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(payload), &wrapped); err != nil {
+			return payload
+		}
+		var text string
+		if err := json.Unmarshal(wrapped.Value, &text); err == nil {
+			return text
+		}
+		return string(wrapped.Value)
+	case Event:
+		var event struct {
+			EventType string `json:"event_type"`
+		}
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return payload
+		}
+		return event.EventType
+	default:
+		return payload
+	}
+}
+
+// CommandPayload converts a value set on the node's property to the command payload of its entity.
+func (node HomieNode) CommandPayload(value string) (string, error) {
+	entity, ok := node.Entity.(EntityWithCommand)
+	if !ok || !node.Property.Settable {
+		return "", fmt.Errorf("%s is not settable", node.Id)
+	}
+
+	switch v := entity.(type) {
+	case Switch:
+		switch value {
+		case "true":
+			return v.DiscoveryConfig.PayloadOn, nil
+		case "false":
+			return v.DiscoveryConfig.PayloadOff, nil
+		default:
+			return "", fmt.Errorf("Invalid value %q. Use true or false", value)
+		}
+	case Button:
+		// A required confirmation has to be set as value, the executor checks it
+		if v.DiscoveryConfig.PayloadPress != "" {
+			return value, nil
+		}
+		return PayloadPress, nil
+	default:
+		return value, nil
+	}
+}
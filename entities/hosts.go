@@ -0,0 +1,161 @@
+package entities
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/remote"
+)
+
+// getHostEntities returns a device with power and uptime sensors and shutdown, reboot and wake
+// buttons for every host controlled over SSH.
+func getHostEntities() []Entity {
+	var entityList []Entity
+	for _, host := range GetHosts() {
+		entityList = append(entityList, newHostEntities(host)...)
+	}
+	return entityList
+}
+
+// GetHosts returns the configured hosts sorted by name.
+func GetHosts() []remote.Host {
+	hosts := appconfig.RequireConfig().Hosts
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var result []remote.Host
+	for _, name := range names {
+		result = append(result, remote.NewHost(name, hosts[name]))
+	}
+	return result
+}
+
+// HostEntities returns the entities of entityList belonging to the device of host.
+func HostEntities(entityList []Entity, host remote.Host) []Entity {
+	identifiers := hostDevice(host).Identifiers
+	var result []Entity
+	for _, ety := range entityList {
+		if ety.GetDiscoveryConfig().Device.Identifiers == identifiers {
+			result = append(result, ety)
+		}
+	}
+	return result
+}
+
+func hostDevice(host remote.Host) Device {
+	name := appconfig.RequireConfig().Hosts[host.Name].Name
+	if name == "" {
+		name = host.Name
+	}
+	return GetSubDevice("host_"+host.Name, name, host.Os()+" via SSH")
+}
+
+func newHostEntities(host remote.Host) []Entity {
+	appConf := appconfig.RequireConfig()
+	device := hostDevice(host)
+	prefix := "host_" + host.Name + "_"
+	topic := appConf.DeviceName + "/host/" + host.Name
+
+	uptimeKey := prefix + "uptime"
+	uptimeId := appConf.DeviceName + "_" + uptimeKey
+	uptimeInterval := entityInterval(uptimeKey)
+	uptimeFormat := entityPayloadFormat(uptimeKey)
+	entityList := []Entity{
+		BinarySensor{
+			State:          func() bool { return remote.Online(host.Name) },
+			Retain:         entityRetain(prefix + "power"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/binary_sensor/" + appConf.DeviceId + "/" + appConf.DeviceName + "_" + prefix + "power/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          device,
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "binary_sensor." + appConf.DeviceName + "_" + prefix + "power",
+				UniqueId:        appConf.DeviceName + "_" + prefix + "power",
+				Name:            translate("Power"),
+				Icon:            "mdi:power",
+				StateTopic:      topic + "/binary_sensor/power/state",
+				DeviceClass:     DeviceClassPower,
+				PayloadOn:       PayloadOn,
+				PayloadOff:      PayloadOff,
+				EntityCategory:  entityCategory(prefix+"power", ""),
+				Qos:             entityQos(prefix + "power"),
+			},
+		},
+		Sensor{
+			Poll: func(ctx context.Context) (string, error) {
+				uptime, err := host.Uptime(ctx)
+				if err != nil {
+					return "", err
+				}
+				return strconv.FormatInt(int64(uptime.Seconds()), 10), nil
+			},
+			// Reading the uptime of a machine that is off would only time out
+			Available:      func() bool { return remote.Online(host.Name) },
+			Interval:       time.Duration(uptimeInterval) * time.Second,
+			Deadband:       entityDeadband(uptimeKey),
+			Average:        entityAverage(uptimeKey),
+			Threshold:      entityThreshold(uptimeKey),
+			Format:         uptimeFormat,
+			Retain:         entityRetain(uptimeKey),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + uptimeId + "/config",
+			DiscoveryConfig: WithEntityAvailability(&DiscoveryConfig{
+				Device:            device,
+				DefaultEntityId:   "sensor." + uptimeId,
+				UniqueId:          uptimeId,
+				Name:              translate("Uptime"),
+				Icon:              "mdi:timer-outline",
+				StateTopic:        topic + "/sensor/uptime/state",
+				ValueTemplate:     uptimeFormat.ValueTemplate(),
+				ExpireAfter:       entityExpireAfter(uptimeKey, sensorRefreshInterval(uptimeKey, uptimeInterval)),
+				DeviceClass:       DeviceClassDuration,
+				StateClass:        StateClassMeasurement,
+				UnitOfMeasurement: UnitSeconds,
+				EntityCategory:    entityCategory(uptimeKey, EntityCategoryDiagnostic),
+				Qos:               entityQos(uptimeKey),
+			}, topic+"/availability"),
+		},
+		newHostButton(host, device, "shutdown", "Shutdown", "mdi:power", host.Shutdown),
+		newHostButton(host, device, "reboot", "Reboot", "mdi:restart", host.Reboot),
+	}
+	if host.CanWake() {
+		entityList = append(entityList, newHostButton(host, device, "wake", "Wake", "mdi:power-on", func(context.Context) error {
+			return host.Wake()
+		}))
+	}
+	return entityList
+}
+
+func newHostButton(host remote.Host, device Device, action string, name string, icon string, run func(ctx context.Context) error) Button {
+	appConf := appconfig.RequireConfig()
+	key := "host_" + host.Name + "_" + action
+	objectId := appConf.DeviceName + "_" + key
+	topic := appConf.DeviceName + "/host/" + host.Name + "/button/" + action
+	return Button{
+		Action: func(ctx context.Context) error {
+			logger.Info("Host button pressed", "host", host.Name, "action", action)
+			return run(ctx)
+		},
+		ResultTopic:    topic + "/result",
+		Debounce:       entityDebounce(key),
+		Cooldown:       entityCooldown(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          device,
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "button." + objectId,
+			UniqueId:        objectId,
+			Name:            translate(name),
+			Icon:            icon,
+			StateTopic:      topic + "/state",
+			CommandTopic:    topic + "/command",
+			PayloadPress:    entityPayloadPress(key),
+			EntityCategory:  entityCategory(key, ""),
+			Qos:             entityCommandQos(key),
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// getMacEntities returns the display off button and the keep awake switch, which use pmset and caffeinate on macOS.
+func getMacEntities() []Entity {
+	if runtime.GOOS != system.MACOS {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	return []Entity{
+		Button{
+			Action: func(ctx context.Context) error {
+				logger.Info("Display off button pressed, turning the display off")
+				cmd, err := system.GetDisplayOffCommand()
+				if err != nil {
+					return err
+				}
+				return system.RunCommandContext(ctx, cmd)
+			},
+			ResultTopic:    appConf.DeviceName + "/button/display_off/result",
+			Debounce:       entityDebounce("display_off"),
+			Cooldown:       entityCooldown("display_off"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + appConf.DeviceName + "_button_display_off/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + appConf.DeviceName + "_button_display_off",
+				UniqueId:        appConf.DeviceName + "_button_display_off",
+				Name:            translate("Display off"),
+				Icon:            "mdi:monitor-off",
+				StateTopic:      appConf.DeviceName + "/button/display_off/state",
+				CommandTopic:    appConf.DeviceName + "/button/display_off/command",
+				PayloadPress:    entityPayloadPress("display_off"),
+				EntityCategory:  entityCategory("display_off", ""),
+				Qos:             entityCommandQos("display_off"),
+			},
+		},
+		Switch{
+			State: system.KeepingAwake,
+			SetState: func(on bool) error {
+				logger.Info("Keep awake switched", "on", on)
+				return system.KeepAwake(on)
+			},
+			ResultTopic:    appConf.DeviceName + "/switch/keep_awake/result",
+			Debounce:       entityDebounce("keep_awake"),
+			Retain:         entityRetain("keep_awake"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/switch/" + appConf.DeviceId + "/" + appConf.DeviceName + "_switch_keep_awake/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "switch." + appConf.DeviceName + "_switch_keep_awake",
+				UniqueId:        appConf.DeviceName + "_switch_keep_awake",
+				Name:            translate("Keep awake"),
+				Icon:            "mdi:coffee",
+				StateTopic:      appConf.DeviceName + "/switch/keep_awake/state",
+				CommandTopic:    appConf.DeviceName + "/switch/keep_awake/command",
+				PayloadOn:       PayloadOn,
+				PayloadOff:      PayloadOff,
+				EntityCategory:  entityCategory("keep_awake", ""),
+				Qos:             entityCommandQos("keep_awake"),
+			},
+		},
+	}
+}
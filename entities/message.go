@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// MessageClosed is a full-screen message that closed, eg. dismissed by the user.
+type MessageClosed struct {
+	Notify Notify
+	// Reason is system.MessageDismissed, system.MessageTimedOut or system.MessageReplaced
+	Reason string
+}
+
+var messageClosures = make(chan MessageClosed, 16)
+
+// MessageClosures receives the full-screen messages that closed.
+func MessageClosures() <-chan MessageClosed {
+	return messageClosures
+}
+
+// messagePayload is a command payload of the full-screen message entity in JSON.
+type messagePayload struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	// Duration is in seconds, nil uses message.duration
+	Duration *int `json:"duration"`
+}
+
+// ParseMessage returns the full-screen message of a command payload, either plain text or a JSON object like
+// {"title": "Dinner", "message": "Dinner is ready!", "duration": 30}.
+func ParseMessage(payload string) (system.FullScreenMessage, error) {
+	message := system.FullScreenMessage{
+		Message:  payload,
+		Duration: time.Duration(appconfig.RequireConfig().Message.Duration) * time.Second,
+	}
+	if strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		var parsed messagePayload
+		if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+			return system.FullScreenMessage{}, fmt.Errorf("Invalid message: %w", err)
+		}
+		if parsed.Duration != nil {
+			if *parsed.Duration < 0 {
+				return system.FullScreenMessage{}, fmt.Errorf("Invalid message duration %d. Must not be negative", *parsed.Duration)
+			}
+			message.Duration = time.Duration(*parsed.Duration) * time.Second
+		}
+		message.Title, message.Message = parsed.Title, parsed.Message
+	}
+	if strings.TrimSpace(message.Message) == "" {
+		return system.FullScreenMessage{}, fmt.Errorf("Invalid message. Set message")
+	}
+	return message, nil
+}
+
+// getMessageEntities returns the notify entity showing full-screen messages on Windows and Linux desktops.
+func getMessageEntities() []Entity {
+	if runtime.GOOS != system.WINDOWS && !desktopEnabled() {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_message"
+	var notify Notify
+	notify = Notify{
+		Send: func(payload string) error {
+			message, err := ParseMessage(payload)
+			if err != nil {
+				return err
+			}
+			logger.Info("Showing full-screen message", "title", message.Title, "duration", message.Duration)
+			return system.ShowMessage(message, func(reason string) {
+				select {
+				case messageClosures <- MessageClosed{Notify: notify, Reason: reason}:
+				default:
+					logger.Warn("Too many closed messages, dropping one", "reason", reason)
+				}
+			})
+		},
+		ResultTopic:    appConf.DeviceName + "/notify/message/result",
+		Debounce:       entityDebounce("message"),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/notify/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "notify." + objectId,
+			UniqueId:        objectId,
+			Name:            translate("Full-screen message"),
+			Icon:            "mdi:monitor-screenshot",
+			CommandTopic:    appConf.DeviceName + "/notify/message/command",
+			EntityCategory:  entityCategory("message", ""),
+			Qos:             entityCommandQos("message"),
+		},
+	}
+	return []Entity{notify}
+}
+
+// MessageClosedTopic returns the topic the reasons full-screen messages shown by notify closed are published to.
+func MessageClosedTopic(notify Notify) string {
+	return strings.TrimSuffix(notify.DiscoveryConfig.CommandTopic, "/command") + "/closed"
+}
@@ -0,0 +1,440 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Entity interface {
+	GetDiscoveryTopic() string
+	GetDiscoveryConfig() *DiscoveryConfig
+}
+
+type EntityWithCommand interface {
+	Entity
+	GetResultTopic() string
+	// GetDebounce returns the time in which repeated commands are ignored.
+	GetDebounce() time.Duration
+	// QueueAction runs the entity's action for the command payload in the background and passes its error to done.
+	QueueAction(payload string, done func(error))
+}
+
+// EntityWithAvailability is implemented by entities that can become unavailable on their own,
+// eg. sensors backed by optional tools. Their own availability topics report IsAvailable,
+// the device availability topic stays online.
+type EntityWithAvailability interface {
+	Entity
+	IsAvailable() bool
+}
+
+// EntityWithCooldown is implemented by entities whose action must not run again within a cooldown.
+type EntityWithCooldown interface {
+	EntityWithCommand
+	// GetCooldown returns the time after running the action in which commands are rejected, zero for none.
+	GetCooldown() time.Duration
+}
+
+// https://www.home-assistant.io/integrations/binary_sensor.mqtt
+type BinarySensor struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	Retain          bool
+	// State returns whether the sensor is on. Nil means always on, like the power sensor of the running PC.
+	State func() bool
+	// Available reports whether State is known. Nil means always available.
+	Available func() bool
+}
+
+func (sensor BinarySensor) GetDiscoveryTopic() string {
+	return sensor.DiscoveryTopic
+}
+
+func (sensor BinarySensor) GetDiscoveryConfig() *DiscoveryConfig {
+	return sensor.DiscoveryConfig
+}
+
+func (sensor BinarySensor) IsAvailable() bool {
+	return sensor.Available == nil || sensor.Available()
+}
+
+// Payload returns PayloadOn or PayloadOff for the current state.
+func (sensor BinarySensor) Payload() string {
+	if sensor.State == nil || sensor.State() {
+		return sensor.DiscoveryConfig.PayloadOn
+	}
+	return sensor.DiscoveryConfig.PayloadOff
+}
+
+// https://www.home-assistant.io/integrations/sensor.mqtt
+type Sensor struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	Retain          bool
+	// Value returns the current state
+	Value func() string
+	// Poll reads the current state like Value, but may fail, eg. when a device can't be read.
+	// It is used instead of Value when set. Failing sensors are polled less often.
+	Poll func(ctx context.Context) (string, error)
+	// Available reports whether Value can currently be read. Nil means always available.
+	Available func() bool
+	// Format shapes the published state. The zero value publishes Value as is.
+	Format PayloadFormat
+	// Interval between polls. Zero polls every diagnostics.interval.
+	Interval time.Duration
+	// Deadband is the smallest numeric change published. Set, only changes are published like with
+	// polling.changes_only.
+	Deadband float64
+	// Average publishes the moving average of this many polled numeric values. 0 and 1 publish them as polled.
+	Average int
+	// Threshold publishes a threshold crossed event whenever the numeric value crosses it. Nil disables it.
+	Threshold *float64
+}
+
+func (sensor Sensor) GetDiscoveryTopic() string {
+	return sensor.DiscoveryTopic
+}
+
+func (sensor Sensor) GetDiscoveryConfig() *DiscoveryConfig {
+	return sensor.DiscoveryConfig
+}
+
+func (sensor Sensor) IsAvailable() bool {
+	return sensor.Available == nil || sensor.Available()
+}
+
+// Payload returns the current state formatted for publishing.
+func (sensor Sensor) Payload() string {
+	return sensor.Format.Apply(sensor.Value())
+}
+
+// Read polls the current unformatted state, from Poll if set and Value otherwise.
+func (sensor Sensor) Read(ctx context.Context) (string, error) {
+	if sensor.Poll == nil {
+		return sensor.Value(), nil
+	}
+	return sensor.Poll(ctx)
+}
+
+// Changed reports whether value differs from the previously published one by at least the deadband.
+// Values that are not numeric differ whenever they are not equal.
+func (sensor Sensor) Changed(previous string, value string) bool {
+	if value == previous {
+		return false
+	}
+	if sensor.Deadband <= 0 {
+		return true
+	}
+
+	previousNumber, err := strconv.ParseFloat(previous, 64)
+	if err != nil {
+		return true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return true
+	}
+	return math.Abs(number-previousNumber) >= sensor.Deadband
+}
+
+// Crossed reports whether value is on the other side of the threshold than previous.
+// Values that are not numeric never cross it.
+func (sensor Sensor) Crossed(previous string, value string) bool {
+	if sensor.Threshold == nil {
+		return false
+	}
+
+	previousNumber, err := strconv.ParseFloat(previous, 64)
+	if err != nil {
+		return false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	return (previousNumber >= *sensor.Threshold) != (number >= *sensor.Threshold)
+}
+
+// SensorClass tells Home Assistant how to interpret a sensor's state. Sensors with a
+// state class are recorded in the long-term statistics.
+type SensorClass struct {
+	DeviceClass string
+	StateClass  string
+	Unit        string
+}
+
+type Button struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	ResultTopic     string
+	Debounce        time.Duration
+	Cooldown        time.Duration
+	Action          func(ctx context.Context) error
+}
+
+func (button Button) GetDiscoveryTopic() string {
+	return button.DiscoveryTopic
+}
+
+func (button Button) GetDiscoveryConfig() *DiscoveryConfig {
+	return button.DiscoveryConfig
+}
+
+func (button Button) GetResultTopic() string {
+	return button.ResultTopic
+}
+
+func (button Button) GetDebounce() time.Duration {
+	return button.Debounce
+}
+
+func (button Button) GetCooldown() time.Duration {
+	return button.Cooldown
+}
+
+func (button Button) QueueAction(payload string, done func(error)) {
+	QueueAction(button.DiscoveryConfig.CommandTopic, button.Action, done)
+}
+
+// https://www.home-assistant.io/integrations/switch.mqtt
+type Switch struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	ResultTopic     string
+	Debounce        time.Duration
+	Retain          bool
+	// State returns whether the switch is on
+	State func() bool
+	// SetState turns the switch on or off
+	SetState func(on bool) error
+}
+
+func (sw Switch) GetDiscoveryTopic() string {
+	return sw.DiscoveryTopic
+}
+
+func (sw Switch) GetDiscoveryConfig() *DiscoveryConfig {
+	return sw.DiscoveryConfig
+}
+
+func (sw Switch) GetResultTopic() string {
+	return sw.ResultTopic
+}
+
+func (sw Switch) GetDebounce() time.Duration {
+	return sw.Debounce
+}
+
+// Payload returns PayloadOn or PayloadOff for the current state.
+func (sw Switch) Payload() string {
+	if sw.State() {
+		return sw.DiscoveryConfig.PayloadOn
+	}
+	return sw.DiscoveryConfig.PayloadOff
+}
+
+func (sw Switch) QueueAction(payload string, done func(error)) {
+	QueueAction(sw.DiscoveryConfig.CommandTopic, func(context.Context) error {
+		switch payload {
+		case sw.DiscoveryConfig.PayloadOn:
+			return sw.SetState(true)
+		case sw.DiscoveryConfig.PayloadOff:
+			return sw.SetState(false)
+		default:
+			return fmt.Errorf("Invalid payload %q. Use %q or %q", payload, sw.DiscoveryConfig.PayloadOn, sw.DiscoveryConfig.PayloadOff)
+		}
+	}, done)
+}
+
+// https://www.home-assistant.io/integrations/select.mqtt
+type Select struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	ResultTopic     string
+	Debounce        time.Duration
+	Retain          bool
+	// State returns the selected option
+	State func() string
+	// SetState selects one of the options
+	SetState func(option string) error
+}
+
+func (sel Select) GetDiscoveryTopic() string {
+	return sel.DiscoveryTopic
+}
+
+func (sel Select) GetDiscoveryConfig() *DiscoveryConfig {
+	return sel.DiscoveryConfig
+}
+
+func (sel Select) GetResultTopic() string {
+	return sel.ResultTopic
+}
+
+func (sel Select) GetDebounce() time.Duration {
+	return sel.Debounce
+}
+
+// Payload returns the selected option.
+func (sel Select) Payload() string {
+	return sel.State()
+}
+
+func (sel Select) QueueAction(payload string, done func(error)) {
+	QueueAction(sel.DiscoveryConfig.CommandTopic, func(context.Context) error {
+		if !slices.Contains(sel.DiscoveryConfig.Options, payload) {
+			return fmt.Errorf("Invalid option %q. Use one of %s", payload, strings.Join(sel.DiscoveryConfig.Options, ", "))
+		}
+		return sel.SetState(payload)
+	}, done)
+}
+
+// https://www.home-assistant.io/integrations/notify.mqtt
+type Notify struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	ResultTopic     string
+	Debounce        time.Duration
+	// Send shows the notification of a command payload
+	Send func(payload string) error
+}
+
+func (notify Notify) GetDiscoveryTopic() string {
+	return notify.DiscoveryTopic
+}
+
+func (notify Notify) GetDiscoveryConfig() *DiscoveryConfig {
+	return notify.DiscoveryConfig
+}
+
+func (notify Notify) GetResultTopic() string {
+	return notify.ResultTopic
+}
+
+func (notify Notify) GetDebounce() time.Duration {
+	return notify.Debounce
+}
+
+func (notify Notify) QueueAction(payload string, done func(error)) {
+	QueueAction(notify.DiscoveryConfig.CommandTopic, func(context.Context) error {
+		return notify.Send(payload)
+	}, done)
+}
+
+// https://www.home-assistant.io/integrations/device_tracker.mqtt
+type DeviceTracker struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	Retain          bool
+	// State returns whether the tracked PC is home
+	State func() bool
+}
+
+func (tracker DeviceTracker) GetDiscoveryTopic() string {
+	return tracker.DiscoveryTopic
+}
+
+func (tracker DeviceTracker) GetDiscoveryConfig() *DiscoveryConfig {
+	return tracker.DiscoveryConfig
+}
+
+// Payload returns PayloadHome or PayloadNotHome for the current state.
+func (tracker DeviceTracker) Payload() string {
+	if tracker.State() {
+		return tracker.DiscoveryConfig.PayloadHome
+	}
+	return tracker.DiscoveryConfig.PayloadNotHome
+}
+
+// https://www.home-assistant.io/integrations/event.mqtt
+type Event struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+}
+
+func (event Event) GetDiscoveryTopic() string {
+	return event.DiscoveryTopic
+}
+
+func (event Event) GetDiscoveryConfig() *DiscoveryConfig {
+	return event.DiscoveryConfig
+}
+
+// Payload returns the state message firing eventType, one of the event types of the entity.
+func (event Event) Payload(eventType string) string {
+	payload, _ := json.Marshal(map[string]string{"event_type": eventType})
+	return string(payload)
+}
+
+// DeviceTrigger is no entity in Home Assistant, but a trigger of the device in the automation editor.
+// https://www.home-assistant.io/integrations/device_trigger.mqtt
+type DeviceTrigger struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+}
+
+func (trigger DeviceTrigger) GetDiscoveryTopic() string {
+	return trigger.DiscoveryTopic
+}
+
+func (trigger DeviceTrigger) GetDiscoveryConfig() *DiscoveryConfig {
+	return trigger.DiscoveryConfig
+}
+
+// https://www.home-assistant.io/integrations/update.mqtt
+type Update struct {
+	DiscoveryTopic  string
+	DiscoveryConfig *DiscoveryConfig
+	ResultTopic     string
+	Debounce        time.Duration
+	Retain          bool
+	// State returns the installed and the latest version
+	State func() UpdateState
+	// Install installs the latest version
+	Install func() error
+}
+
+// UpdateState is the JSON state of an update entity.
+type UpdateState struct {
+	InstalledVersion string `json:"installed_version"`
+	LatestVersion    string `json:"latest_version,omitempty"`
+	Title            string `json:"title,omitempty"`
+	ReleaseUrl       string `json:"release_url,omitempty"`
+	ReleaseSummary   string `json:"release_summary,omitempty"`
+}
+
+func (update Update) GetDiscoveryTopic() string {
+	return update.DiscoveryTopic
+}
+
+func (update Update) GetDiscoveryConfig() *DiscoveryConfig {
+	return update.DiscoveryConfig
+}
+
+func (update Update) GetResultTopic() string {
+	return update.ResultTopic
+}
+
+func (update Update) GetDebounce() time.Duration {
+	return update.Debounce
+}
+
+// Payload returns the current state as JSON.
+func (update Update) Payload() string {
+	payload, _ := json.Marshal(update.State())
+	return string(payload)
+}
+
+func (update Update) QueueAction(payload string, done func(error)) {
+	QueueAction(update.DiscoveryConfig.CommandTopic, func(context.Context) error {
+		if payload != update.DiscoveryConfig.PayloadInstall {
+			return fmt.Errorf("Invalid payload %q. Use %q", payload, update.DiscoveryConfig.PayloadInstall)
+		}
+		return update.Install()
+	}, done)
+}
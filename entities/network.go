@@ -0,0 +1,140 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/slug"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+type networkSample struct {
+	bytes uint64
+	at    time.Time
+}
+
+var networkSamples = struct {
+	mu   sync.Mutex
+	last map[string]networkSample
+}{last: map[string]networkSample{}}
+
+// getNetworkEntities returns a received and a sent sensor per included network interface, or a single pair for
+// all physical interfaces with network.aggregate.
+func getNetworkEntities() []Entity {
+	conf := appconfig.RequireConfig().Network
+	if !conf.Enabled {
+		return nil
+	}
+	interfaces, err := readIncludedInterfaces()
+	if err != nil {
+		logger.Warn("Failed to read the network interfaces", "err", err)
+		return nil
+	}
+
+	if conf.Aggregate {
+		var received, sent uint64
+		for _, adapter := range interfaces {
+			if adapter.Physical {
+				received += adapter.Received
+				sent += adapter.Sent
+			}
+		}
+		return []Entity{
+			newNetworkSensor("network_received", "Network received", "mdi:download-network", received, func(adapter system.NetworkInterface) (uint64, bool) {
+				return adapter.Received, adapter.Physical
+			}),
+			newNetworkSensor("network_sent", "Network sent", "mdi:upload-network", sent, func(adapter system.NetworkInterface) (uint64, bool) {
+				return adapter.Sent, adapter.Physical
+			}),
+		}
+	}
+
+	var entities []Entity
+	for _, adapter := range interfaces {
+		name := adapter.Name
+		key := "network_" + interfaceKey(name)
+		entities = append(entities,
+			newNetworkSensor(key+"_received", name+" received", "mdi:download-network", adapter.Received, func(adapter system.NetworkInterface) (uint64, bool) {
+				return adapter.Received, adapter.Name == name
+			}),
+			newNetworkSensor(key+"_sent", name+" sent", "mdi:upload-network", adapter.Sent, func(adapter system.NetworkInterface) (uint64, bool) {
+				return adapter.Sent, adapter.Name == name
+			}),
+		)
+	}
+	return entities
+}
+
+// newNetworkSensor returns a sensor with the bytes per second of the interfaces counted by count, which returns
+// the bytes of an interface and whether it is counted. bytes is the current count, the first poll publishes the
+// rate since now.
+func newNetworkSensor(key string, name string, icon string, bytes uint64, count func(system.NetworkInterface) (uint64, bool)) Sensor {
+	networkSamples.mu.Lock()
+	if _, ok := networkSamples.last[key]; !ok {
+		networkSamples.last[key] = networkSample{bytes: bytes, at: time.Now()}
+	}
+	networkSamples.mu.Unlock()
+
+	return newResourceSensor(key, name, icon, SensorClass{
+		DeviceClass: DeviceClassDataRate,
+		StateClass:  StateClassMeasurement,
+		Unit:        Kilo.Unit() + "/s",
+	}, func(context.Context) (string, error) {
+		interfaces, err := readIncludedInterfaces()
+		if err != nil {
+			return "", err
+		}
+		var total uint64
+		found := false
+		for _, adapter := range interfaces {
+			if bytes, counted := count(adapter); counted {
+				total += bytes
+				found = true
+			}
+		}
+		if !found {
+			return "", errors.New("Network interface of " + key + " is gone")
+		}
+		return strconv.FormatFloat(Kilo.Convert(networkRate(key, total)), 'f', 1, 64), nil
+	})
+}
+
+// networkRate returns the bytes per second since the last sample of key. A counter that went back, eg. after
+// an interface was recreated, counts as no traffic.
+func networkRate(key string, bytes uint64) float64 {
+	networkSamples.mu.Lock()
+	defer networkSamples.mu.Unlock()
+	now := time.Now()
+	last, ok := networkSamples.last[key]
+	networkSamples.last[key] = networkSample{bytes: bytes, at: now}
+	seconds := now.Sub(last.at).Seconds()
+	if !ok || bytes < last.bytes || seconds <= 0 {
+		return 0
+	}
+	return float64(bytes-last.bytes) / seconds
+}
+
+// readIncludedInterfaces returns the network interfaces matching network.include and network.exclude.
+func readIncludedInterfaces() ([]system.NetworkInterface, error) {
+	conf := appconfig.RequireConfig().Network
+	interfaces, err := system.ReadNetworkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	included := interfaces[:0]
+	for _, adapter := range interfaces {
+		if conf.Matches(adapter.Name) {
+			included = append(included, adapter)
+		}
+	}
+	return included, nil
+}
+
+// interfaceKey turns an interface name into a part of entity names, eg. "Wi-Fi 2" into wi_fi_2.
+func interfaceKey(name string) string {
+	return slug.EntityId(name)
+}
@@ -0,0 +1,135 @@
+package entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// NotificationClick is an action of a notification clicked by the user.
+type NotificationClick struct {
+	Notify Notify
+	// Action is the id of the clicked action
+	Action string
+}
+
+var notificationClicks = make(chan NotificationClick, 16)
+
+// NotificationClicks receives the actions clicked in notifications shown by notify entities.
+func NotificationClicks() <-chan NotificationClick {
+	return notificationClicks
+}
+
+// notificationPayload is a command payload of a notify entity in JSON. Actions are ids, which are also the
+// labels, or objects with id and label.
+type notificationPayload struct {
+	Title   string            `json:"title"`
+	Message string            `json:"message"`
+	Image   string            `json:"image"`
+	Actions []json.RawMessage `json:"actions"`
+	Urgency string            `json:"urgency"`
+	Tag     string            `json:"tag"`
+}
+
+// ParseNotification returns the notification of a command payload, either plain text shown as message or a
+// JSON object like {"title": "Backup", "message": "Done", "urgency": "low", "tag": "backup", "actions": ["ok", {"id": "undo", "label": "Undo"}]}.
+func ParseNotification(payload string) (system.Notification, error) {
+	notification := system.Notification{Title: GetDevice().Name, Message: payload}
+	if !strings.HasPrefix(strings.TrimSpace(payload), "{") {
+		return notification, nil
+	}
+
+	var parsed notificationPayload
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil {
+		return system.Notification{}, fmt.Errorf("Invalid notification: %w", err)
+	}
+	if parsed.Message == "" {
+		return system.Notification{}, fmt.Errorf("Invalid notification. Set message")
+	}
+	switch parsed.Urgency {
+	case "", system.UrgencyLow, system.UrgencyNormal, system.UrgencyCritical:
+	default:
+		return system.Notification{}, fmt.Errorf("Invalid notification urgency %q. Use low, normal or critical", parsed.Urgency)
+	}
+	// Windows limits toast tags to 64 characters
+	if len(parsed.Tag) > 64 {
+		return system.Notification{}, fmt.Errorf("Invalid notification tag %q. Use at most 64 characters", parsed.Tag)
+	}
+	notification.Message, notification.Image = parsed.Message, parsed.Image
+	notification.Urgency, notification.Tag = parsed.Urgency, parsed.Tag
+	if parsed.Title != "" {
+		notification.Title = parsed.Title
+	}
+	for _, raw := range parsed.Actions {
+		var action system.NotificationAction
+		if err := json.Unmarshal(raw, &action.Id); err != nil {
+			var object struct {
+				Id    string `json:"id"`
+				Label string `json:"label"`
+			}
+			if err := json.Unmarshal(raw, &object); err != nil {
+				return system.Notification{}, fmt.Errorf("Invalid notification action %s. Use an id or {\"id\": ..., \"label\": ...}", raw)
+			}
+			action = system.NotificationAction{Id: object.Id, Label: object.Label}
+		}
+		if action.Id == "" {
+			return system.Notification{}, fmt.Errorf("Invalid notification action %s. Set an id", raw)
+		}
+		if action.Label == "" {
+			action.Label = action.Id
+		}
+		notification.Actions = append(notification.Actions, action)
+	}
+	return notification, nil
+}
+
+// getNotifyEntities returns the notify entity showing notifications on Windows and Linux desktops.
+func getNotifyEntities() []Entity {
+	if runtime.GOOS != system.WINDOWS && !desktopEnabled() {
+		return nil
+	}
+
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_notify"
+	var notify Notify
+	notify = Notify{
+		Send: func(payload string) error {
+			notification, err := ParseNotification(payload)
+			if err != nil {
+				return err
+			}
+			logger.Info("Showing notification", "title", notification.Title)
+			return system.Notify(notification, func(action string) {
+				select {
+				case notificationClicks <- NotificationClick{Notify: notify, Action: action}:
+				default:
+					logger.Warn("Too many notification clicks, dropping one", "action", action)
+				}
+			})
+		},
+		ResultTopic:    appConf.DeviceName + "/notify/notify/result",
+		Debounce:       entityDebounce("notify"),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/notify/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "notify." + objectId,
+			UniqueId:        objectId,
+			Name:            translate("Notification"),
+			Icon:            "mdi:message-badge",
+			CommandTopic:    appConf.DeviceName + "/notify/notify/command",
+			EntityCategory:  entityCategory("notify", ""),
+			Qos:             entityCommandQos("notify"),
+		},
+	}
+	return []Entity{notify}
+}
+
+// NotificationActionTopic returns the topic the clicked actions of notifications shown by notify are published to.
+func NotificationActionTopic(notify Notify) string {
+	return strings.TrimSuffix(notify.DiscoveryConfig.CommandTopic, "/command") + "/action"
+}
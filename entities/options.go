@@ -0,0 +1,135 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// entityQos returns the QoS for the entity named key, falling back to the global mqtt.qos.
+func entityQos(key string) int {
+	appConf := appconfig.RequireConfig()
+	if qos := appConf.Entities[key].Qos; qos != nil {
+		return *qos
+	}
+	return appConf.Mqtt.Qos
+}
+
+// entityCommandQos returns the QoS for the command entity named key, falling back to
+// commands.qos and then to the global mqtt.qos.
+func entityCommandQos(key string) int {
+	appConf := appconfig.RequireConfig()
+	if qos := appConf.Entities[key].Qos; qos != nil {
+		return *qos
+	}
+	if qos := appConf.Commands.Qos; qos != nil {
+		return *qos
+	}
+	return appConf.Mqtt.Qos
+}
+
+// entityRetain returns the retain flag for the entity named key, falling back to the global mqtt.retain.
+func entityRetain(key string) bool {
+	appConf := appconfig.RequireConfig()
+	if retain := appConf.Entities[key].Retain; retain != nil {
+		return *retain
+	}
+	return appConf.Mqtt.Retain
+}
+
+// entityDebounce returns the debounce window for the entity named key, falling back to commands.debounce.
+func entityDebounce(key string) time.Duration {
+	appConf := appconfig.RequireConfig()
+	if debounce := appConf.Entities[key].Debounce; debounce != nil {
+		return time.Duration(*debounce) * time.Second
+	}
+	return time.Duration(appConf.Commands.Debounce) * time.Second
+}
+
+// entityPayloadPress returns the payload the button named key requires, eg. CONFIRM-SHUTDOWN, or empty if
+// any payload presses it.
+func entityPayloadPress(key string) string {
+	return appconfig.RequireConfig().Entities[key].PayloadPress
+}
+
+// entityCooldown returns the time after running the action of the button named key in which it is rejected.
+func entityCooldown(key string) time.Duration {
+	return time.Duration(appconfig.RequireConfig().Entities[key].Cooldown) * time.Second
+}
+
+// entityInterval returns the seconds between polls of the sensor named key, falling back to diagnostics.interval.
+func entityInterval(key string) int {
+	appConf := appconfig.RequireConfig()
+	if interval := appConf.Entities[key].Interval; interval != nil {
+		return *interval
+	}
+	return appConf.Diagnostics.Interval
+}
+
+// entityThreshold returns the threshold of the sensor named key, nil if none is set.
+func entityThreshold(key string) *float64 {
+	return appconfig.RequireConfig().Entities[key].Threshold
+}
+
+// entityDeadband returns the smallest numeric change published for the sensor named key.
+func entityDeadband(key string) float64 {
+	if deadband := appconfig.RequireConfig().Entities[key].Deadband; deadband != nil {
+		return *deadband
+	}
+	return 0
+}
+
+// entityAverage returns the number of polled values the sensor named key publishes the moving average of.
+func entityAverage(key string) int {
+	return appconfig.RequireConfig().Entities[key].Average
+}
+
+// sensorRefreshInterval returns the longest time in seconds between two state updates of the sensor named key
+// polled every interval seconds. With polling.changes_only or a deadband unchanged states wait for the forced
+// refresh.
+func sensorRefreshInterval(key string, interval int) int {
+	polling := appconfig.RequireConfig().Polling
+	if !polling.ChangesOnly && entityDeadband(key) == 0 || interval <= 0 {
+		return interval
+	}
+	if polling.ForceInterval <= 0 {
+		return 0
+	}
+	return max(interval, polling.ForceInterval)
+}
+
+// Number of updates a sensor may miss before Home Assistant marks it unavailable.
+const expireAfterMissedUpdates = 3
+
+// entityExpireAfter returns the expire_after for the sensor named key, which is updated
+// every interval seconds. An interval of 0 means the state is not republished and never expires.
+func entityExpireAfter(key string, interval int) int {
+	if expireAfter := appconfig.RequireConfig().Entities[key].ExpireAfter; expireAfter != nil {
+		return *expireAfter
+	}
+	if interval <= 0 {
+		return 0
+	}
+	return interval * expireAfterMissedUpdates
+}
+
+// entityPayloadFormat returns the payload format configured for the sensor named key.
+func entityPayloadFormat(key string) PayloadFormat {
+	entity := appconfig.RequireConfig().Entities[key]
+	return PayloadFormat{
+		Json:      entity.PayloadFormat == appconfig.PayloadFormatJson,
+		Precision: entity.Precision,
+	}
+}
+
+// entityCategory returns the entity_category for the entity named key, falling back to fallback.
+func entityCategory(key string, fallback string) string {
+	switch category := appconfig.RequireConfig().Entities[key].EntityCategory; category {
+	case "":
+		return fallback
+	case appconfig.EntityCategoryNone:
+		return ""
+	default:
+		return category
+	}
+}
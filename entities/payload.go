@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// PayloadFormat describes how a sensor state is published.
+type PayloadFormat struct {
+	// Json wraps the state in {"value": ...}.
+	Json bool
+	// Precision rounds numeric states to the given number of decimals. Nil keeps them as is.
+	Precision *int
+}
+
+// Apply formats the raw state. Non-numeric states are never rounded.
+func (format PayloadFormat) Apply(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	isNumber := err == nil
+	if isNumber && format.Precision != nil {
+		value = strconv.FormatFloat(number, 'f', *format.Precision, 64)
+	}
+
+	if !format.Json {
+		return value
+	}
+
+	var payload any = value
+	if isNumber {
+		payload = json.Number(value)
+	}
+	buf, err := json.Marshal(map[string]any{"value": payload})
+	if err != nil {
+		return value
+	}
+	return string(buf)
+}
+
+// ValueTemplate returns the Home Assistant value_template extracting the state from the payload.
+func (format PayloadFormat) ValueTemplate() string {
+	if format.Json {
+		return "{{ value_json.value }}"
+	}
+	return ""
+}
@@ -0,0 +1,338 @@
+package entities
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// inhibitorCheckTimeout bounds asking the system for inhibitors before an action
+const inhibitorCheckTimeout = 5 * time.Second
+
+const (
+	// powerWarningTag replaces the shown warning with the next one, eg. the reminder shortly before the action
+	powerWarningTag = "pc2mqtt_power"
+	// powerWarningReminder is how long before the action the warning is shown again
+	powerWarningReminder = 10 * time.Second
+	powerWarningCancel   = "cancel"
+)
+
+var (
+	powerMu sync.Mutex
+	// pendingAction is the power action waiting for inhibitors or the end of its warning, empty if none
+	pendingAction string
+	// pendingActionAt is when the warned about pendingAction runs, zero if it waits for inhibitors
+	pendingActionAt time.Time
+	powerStateWake  = make(chan struct{}, 1)
+)
+
+// PowerStateChanges receives a value whenever a power action starts or stops waiting for inhibitors or its warning.
+func PowerStateChanges() <-chan struct{} {
+	return powerStateWake
+}
+
+func setPendingAction(action string) {
+	setPendingActionAt(action, time.Time{})
+}
+
+func setPendingActionAt(action string, at time.Time) {
+	powerMu.Lock()
+	changed := pendingAction != action || !pendingActionAt.Equal(at)
+	pendingAction, pendingActionAt = action, at
+	powerMu.Unlock()
+
+	if changed {
+		select {
+		case powerStateWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func getPendingAction() (string, time.Time) {
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	return pendingAction, pendingActionAt
+}
+
+// runPowerAction runs action, eg. shutdown, unless programs block what, InhibitShutdown or InhibitSleep.
+// Depending on inhibitors.mode blocked actions run anyway, wait for the inhibitors or fail. Shutdown and
+// reboot are announced to the user first when commands.shutdown_warning is set. Once ctx is done, eg. after
+// commands.action_timeout, it stops waiting and fails without running action.
+func runPowerAction(ctx context.Context, name string, what string, action func() error) error {
+	conf := appconfig.RequireConfig().Inhibitors
+	deadline := time.Now().Add(time.Duration(conf.MaxWait) * time.Second)
+	defer setPendingAction("")
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, inhibitorCheckTimeout)
+		inhibitors, err := system.Inhibitors(checkCtx, what)
+		cancel()
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s cancelled: %w", capitalize(name), ctx.Err())
+		}
+		if err != nil {
+			// Not knowing about inhibitors must not keep the PC from shutting down
+			logger.Warn("Failed to check inhibitors", "action", name, "err", err)
+			break
+		}
+		if len(inhibitors) == 0 {
+			break
+		}
+
+		blockers := joinInhibitorDetails(inhibitors)
+		if conf.Mode == appconfig.InhibitorsIgnore {
+			logger.Warn("Ignoring programs blocking "+name, "inhibitors", blockers)
+			break
+		}
+		if conf.Mode == appconfig.InhibitorsAbort {
+			return fmt.Errorf("%s blocked by %s", capitalize(name), blockers)
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%s still blocked by %s after %d seconds", capitalize(name), blockers, conf.MaxWait)
+		}
+
+		setPendingAction(name)
+		logger.Info("Waiting for programs blocking "+name, "inhibitors", blockers, "retry_interval", conf.RetryInterval)
+		retry := time.NewTimer(min(time.Duration(conf.RetryInterval)*time.Second, time.Until(deadline)))
+		select {
+		case <-retry.C:
+		case <-ctx.Done():
+			retry.Stop()
+			return fmt.Errorf("%s cancelled while waiting for %s: %w", capitalize(name), blockers, ctx.Err())
+		}
+	}
+
+	if what == system.InhibitShutdown {
+		if err := warnPowerAction(ctx, name); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s cancelled: %w", capitalize(name), ctx.Err())
+	}
+	fireSystemEvent(name)
+	return action()
+}
+
+// warnPowerAction shows a notification counting down commands.shutdown_warning seconds before action
+// runs and fails if the user clicks Cancel or ctx is done. The pending action sensors show the countdown in
+// Home Assistant. Where notifications can't be shown the countdown runs anyway.
+func warnPowerAction(ctx context.Context, name string) error {
+	seconds := appconfig.RequireConfig().Commands.ShutdownWarning
+	if seconds <= 0 {
+		return nil
+	}
+
+	at := time.Now().Add(time.Duration(seconds) * time.Second)
+	setPendingActionAt(name, at)
+	logger.Info("Warning about "+name, "seconds", seconds)
+
+	// Clicks of warnings shown for earlier actions don't reach this one
+	cancelled := make(chan struct{}, 1)
+	onAction := func(id string) {
+		if id != powerWarningCancel {
+			return
+		}
+		select {
+		case cancelled <- struct{}{}:
+		default:
+		}
+	}
+	showPowerWarning(name, at, onAction)
+
+	var reminder <-chan time.Time
+	if time.Until(at) > 2*powerWarningReminder {
+		reminderTimer := time.NewTimer(time.Until(at) - powerWarningReminder)
+		defer reminderTimer.Stop()
+		reminder = reminderTimer.C
+	}
+	done := time.NewTimer(time.Until(at))
+	defer done.Stop()
+
+	for {
+		select {
+		case <-reminder:
+			showPowerWarning(name, at, onAction)
+		case <-cancelled:
+			logger.Info(capitalize(name) + " cancelled on the PC")
+			cancelPowerWarning(name)
+			return fmt.Errorf("%s cancelled on the PC", capitalize(name))
+		case <-ctx.Done():
+			logger.Warn(capitalize(name)+" cancelled during the warning", "err", ctx.Err())
+			cancelPowerWarning(name)
+			return fmt.Errorf("%s cancelled: %w", capitalize(name), ctx.Err())
+		case <-done.C:
+			return nil
+		}
+	}
+}
+
+// cancelPowerWarning replaces the warning by a notification that name was cancelled.
+func cancelPowerWarning(name string) {
+	fireSystemEvent(name + "_cancelled")
+	notifyPowerWarning(system.Notification{
+		Title:   GetDevice().Name,
+		Message: fmt.Sprintf(translate("%s cancelled"), translate(capitalize(name))),
+		Urgency: system.UrgencyLow,
+		Tag:     powerWarningTag,
+	}, nil)
+}
+
+func showPowerWarning(name string, at time.Time, onAction func(id string)) {
+	seconds := int(time.Until(at).Round(time.Second).Seconds())
+	notifyPowerWarning(system.Notification{
+		Title:   GetDevice().Name,
+		Message: fmt.Sprintf(translate("%s at %s, in %d seconds"), translate(capitalize(name)), at.Format(time.TimeOnly), seconds),
+		Actions: []system.NotificationAction{{Id: powerWarningCancel, Label: translate("Cancel")}},
+		Urgency: system.UrgencyCritical,
+		Tag:     powerWarningTag,
+	}, onAction)
+}
+
+func notifyPowerWarning(notification system.Notification, onAction func(id string)) {
+	if err := system.Notify(notification, onAction); err != nil {
+		logger.Warn("Failed to show the power warning", "err", err)
+	}
+}
+
+func joinInhibitorDetails(inhibitors []system.Inhibitor) string {
+	details := make([]string, len(inhibitors))
+	for i, inhibitor := range inhibitors {
+		details[i] = inhibitor.String()
+	}
+	return strings.Join(details, ", ")
+}
+
+func capitalize(text string) string {
+	if text == "" {
+		return text
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// getPowerEntities returns sensors with the programs blocking shutdown and the power action waiting for
+// them, where the system reports inhibitors.
+func getPowerEntities() []Entity {
+	if runtime.GOOS != system.LINUX && runtime.GOOS != system.WINDOWS {
+		return nil
+	}
+
+	return []Entity{
+		newPowerSensor("blocked_by", "Shutdown blocked by", "mdi:power-plug-off", func(ctx context.Context) (string, error) {
+			inhibitors, err := system.Inhibitors(ctx, system.InhibitShutdown)
+			if err != nil {
+				return "", err
+			}
+			if len(inhibitors) == 0 {
+				return "none", nil
+			}
+			return system.JoinInhibitors(inhibitors), nil
+		}),
+		newPowerSensor("pending_action", "Pending action", "mdi:timer-sand", func(ctx context.Context) (string, error) {
+			if action, _ := getPendingAction(); action != "" {
+				return action, nil
+			}
+			return "none", nil
+		}),
+		pendingActionAtSensor(),
+		shutdownCountdownSensor(),
+	}
+}
+
+// pendingActionAtSensor returns the sensor with the time a warned about power action runs, which Home
+// Assistant shows as countdown.
+func pendingActionAtSensor() Sensor {
+	sensor := newPowerSensor("pending_action_at", "Pending action at", "mdi:timer-alert", func(ctx context.Context) (string, error) {
+		if _, at := getPendingAction(); !at.IsZero() {
+			return at.Format(time.RFC3339), nil
+		}
+		// Home Assistant shows an empty timestamp as unknown
+		return "", nil
+	})
+	sensor.DiscoveryConfig.DeviceClass = DeviceClassTimestamp
+	return sensor
+}
+
+// shutdownCountdownSensor returns the sensor with the seconds until a warned about power action runs, 0 if
+// none is pending.
+func shutdownCountdownSensor() Sensor {
+	sensor := newPowerSensor("shutdown_countdown", "Shutdown countdown", "mdi:timer-outline", func(ctx context.Context) (string, error) {
+		_, at := getPendingAction()
+		if at.IsZero() {
+			return "0", nil
+		}
+		return strconv.Itoa(max(int(math.Ceil(time.Until(at).Seconds())), 0)), nil
+	})
+	sensor.DiscoveryConfig.DeviceClass = DeviceClassDuration
+	sensor.DiscoveryConfig.UnitOfMeasurement = UnitSeconds
+	return sensor
+}
+
+// PowerCountdownRunning reports whether a warned about power action counts down to running.
+func PowerCountdownRunning() bool {
+	_, at := getPendingAction()
+	return !at.IsZero()
+}
+
+// PowerStateSensors returns the sensors of entityList about blocked and pending power actions.
+func PowerStateSensors(entityList []Entity) []Entity {
+	return powerSensors(entityList, "blocked_by", "pending_action", "pending_action_at", "shutdown_countdown")
+}
+
+// CountdownSensors returns the shutdown countdown sensor of entityList, which is published every few seconds
+// while PowerCountdownRunning.
+func CountdownSensors(entityList []Entity) []Entity {
+	return powerSensors(entityList, "shutdown_countdown")
+}
+
+func powerSensors(entityList []Entity, keys ...string) []Entity {
+	uniqueIds := make([]string, len(keys))
+	for i, key := range keys {
+		uniqueIds[i] = powerSensorId(key)
+	}
+	var sensors []Entity
+	for _, ety := range entityList {
+		if slices.Contains(uniqueIds, ety.GetDiscoveryConfig().UniqueId) {
+			sensors = append(sensors, ety)
+		}
+	}
+	return sensors
+}
+
+func powerSensorId(key string) string {
+	return appconfig.RequireConfig().DeviceName + "_sensor_" + key
+}
+
+func newPowerSensor(key string, name string, icon string, poll func(ctx context.Context) (string, error)) Sensor {
+	appConf := appconfig.RequireConfig()
+	objectId := powerSensorId(key)
+	interval := entityInterval(key)
+	return Sensor{
+		Poll:           poll,
+		Interval:       time.Duration(interval) * time.Second,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "sensor." + objectId,
+			UniqueId:        objectId,
+			Name:            translate(name),
+			Icon:            icon,
+			StateTopic:      appConf.DeviceName + "/sensor/" + key + "/state",
+			ExpireAfter:     entityExpireAfter(key, sensorRefreshInterval(key, interval)),
+			EntityCategory:  entityCategory(key, EntityCategoryDiagnostic),
+			Qos:             entityQos(key),
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// userActive reports whether a user is logged in with an unlocked session. Where pc2mqtt doesn't know the
+// session, the running PC counts as active.
+func userActive() bool {
+	if runtime.GOOS != system.WINDOWS && !desktopEnabled() {
+		return true
+	}
+	session := getSession()
+	return session.LoggedIn && !session.Locked
+}
+
+// PresenceTracker returns the presence device tracker of entityList.
+func PresenceTracker(entityList []Entity) (DeviceTracker, bool) {
+	uniqueId := appconfig.RequireConfig().DeviceName + "_device_tracker_presence"
+	for _, ety := range entityList {
+		if tracker, ok := ety.(DeviceTracker); ok && tracker.DiscoveryConfig.UniqueId == uniqueId {
+			return tracker, true
+		}
+	}
+	return DeviceTracker{}, false
+}
+
+// getPresenceEntities returns the device tracker that is home while the PC is reachable and a user is active,
+// for presence detection in Home Assistant.
+func getPresenceEntities() []Entity {
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_device_tracker_presence"
+	return []Entity{
+		DeviceTracker{
+			State:          userActive,
+			Retain:         entityRetain("presence"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/device_tracker/" + appConf.DeviceId + "/" + objectId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device: GetDevice(),
+				// Unreachable, the tracker becomes unavailable and Home Assistant uses the other trackers of a person
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "device_tracker." + objectId,
+				UniqueId:        objectId,
+				Name:            translate("Presence"),
+				Icon:            "mdi:account-check",
+				StateTopic:      appConf.DeviceName + "/device_tracker/presence/state",
+				PayloadHome:     PayloadHome,
+				PayloadNotHome:  PayloadNotHome,
+				SourceType:      SourceTypeRouter,
+				EntityCategory:  entityCategory("presence", ""),
+				Qos:             entityQos("presence"),
+			},
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// printQueueState is the payload of the print queue sensor. Home Assistant shows count as state and both
+// keys as attributes.
+type printQueueState struct {
+	Count int               `json:"count"`
+	Jobs  []system.PrintJob `json:"jobs"`
+}
+
+// getPrintQueueEntities returns the sensor with the jobs waiting in the print queue and the button clearing it,
+// with CUPS or the Windows print spooler.
+func getPrintQueueEntities() []Entity {
+	if !system.HasPrintQueue() {
+		return nil
+	}
+
+	queue := newResourceSensor("print_queue", "Print queue", "mdi:printer", SensorClass{StateClass: StateClassMeasurement}, func(ctx context.Context) (string, error) {
+		jobs, err := system.PrintJobs(ctx)
+		if err != nil {
+			return "", err
+		}
+		if jobs == nil {
+			jobs = []system.PrintJob{}
+		}
+		payload, err := json.Marshal(printQueueState{Count: len(jobs), Jobs: jobs})
+		if err != nil {
+			return "", err
+		}
+		return string(payload), nil
+	})
+	// The payload is JSON already, payload_format doesn't apply
+	queue.Format = PayloadFormat{}
+	queue.DiscoveryConfig.ValueTemplate = "{{ value_json.count }}"
+	queue.DiscoveryConfig.JsonAttributesTopic = queue.DiscoveryConfig.StateTopic
+
+	appConf := appconfig.RequireConfig()
+	clearId := appConf.DeviceName + "_button_clear_print_queue"
+	return []Entity{
+		queue,
+		Button{
+			Action: func(context.Context) error {
+				logger.Info("Clear print queue button pressed")
+				return system.ClearPrintQueue()
+			},
+			ResultTopic:    appConf.DeviceName + "/button/clear_print_queue/result",
+			Debounce:       entityDebounce("clear_print_queue"),
+			Cooldown:       entityCooldown("clear_print_queue"),
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/button/" + appConf.DeviceId + "/" + clearId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "button." + clearId,
+				UniqueId:        clearId,
+				Name:            translate("Clear print queue"),
+				Icon:            "mdi:printer-off",
+				StateTopic:      appConf.DeviceName + "/button/clear_print_queue/state",
+				CommandTopic:    appConf.DeviceName + "/button/clear_print_queue/command",
+				PayloadPress:    entityPayloadPress("clear_print_queue"),
+				EntityCategory:  entityCategory("clear_print_queue", ""),
+				Qos:             entityCommandQos("clear_print_queue"),
+			},
+		},
+	}
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"slices"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// readOnlyAvailabilityTopic returns the availability topic of the entities with commands with read_only,
+// which stays offline.
+func readOnlyAvailabilityTopic() string {
+	return appconfig.RequireConfig().DeviceName + "/commands/availability"
+}
+
+// withReadOnly makes the buttons and other entities with commands of entityList unavailable with read_only,
+// so Home Assistant doesn't offer actions that would be ignored.
+func withReadOnly(entityList []Entity) []Entity {
+	if !appconfig.RequireConfig().ReadOnly {
+		return entityList
+	}
+
+	topic := readOnlyAvailabilityTopic()
+	for _, ety := range entityList {
+		if _, ok := ety.(EntityWithCommand); !ok {
+			continue
+		}
+		// Fixed entities keep their config across calls
+		config := ety.GetDiscoveryConfig()
+		if slices.ContainsFunc(config.Availability, func(availability Availability) bool { return availability.Topic == topic }) {
+			continue
+		}
+		config.Availability = append(config.Availability, Availability{
+			Topic:               topic,
+			PayloadAvailable:    payloadOnline,
+			PayloadNotAvailable: payloadOffline,
+		})
+		config.AvailabilityMode = AvailabilityModeAll
+	}
+	return entityList
+}
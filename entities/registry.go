@@ -0,0 +1,171 @@
+package entities
+
+import (
+	"slices"
+	"sync"
+)
+
+// Registry collects the entities of pc2mqtt. The built-in modules, programs embedding pc2mqtt
+// and config driven entities register into it at startup. While the registry is watched, eg. by
+// a running bridge, entities registered or unregistered later are reported to the watchers.
+type Registry struct {
+	mu        sync.Mutex
+	providers []func() []Entity
+	fixed     []Entity
+
+	// refreshMu serializes refreshes, so watchers see the changes in order
+	refreshMu sync.Mutex
+	watchers  map[int]func(Change)
+	nextWatch int
+	// known holds the entities reported so far, nil while not watched
+	known []Entity
+}
+
+// Change lists the entities added to and removed from a registry since the last change.
+type Change struct {
+	Added   []Entity
+	Removed []Entity
+}
+
+var defaultRegistry = &Registry{}
+
+// Register adds fixed entities to the registry.
+func (registry *Registry) Register(entityList ...Entity) {
+	registry.mu.Lock()
+	registry.fixed = append(registry.fixed, entityList...)
+	registry.mu.Unlock()
+	registry.Refresh()
+}
+
+// Unregister removes the fixed entities with the given unique ids from the registry.
+func (registry *Registry) Unregister(uniqueIds ...string) {
+	registry.mu.Lock()
+	registry.fixed = slices.DeleteFunc(registry.fixed, func(ety Entity) bool {
+		return slices.Contains(uniqueIds, ety.GetDiscoveryConfig().UniqueId)
+	})
+	registry.mu.Unlock()
+	registry.Refresh()
+}
+
+// RegisterProvider adds a function building entities. It is called every time the entities
+// are collected, after the config is loaded, so it may use the device name or id.
+// Call Refresh when the entities of a provider change while the registry is watched.
+func (registry *Registry) RegisterProvider(provider func() []Entity) {
+	registry.mu.Lock()
+	registry.providers = append(registry.providers, provider)
+	registry.mu.Unlock()
+	registry.Refresh()
+}
+
+// Entities returns the entities of all registered providers, followed by the fixed entities. With read_only
+// the entities with commands are unavailable. Default entity ids are made valid Home Assistant entity ids.
+func (registry *Registry) Entities() []Entity {
+	registry.mu.Lock()
+	providers := slices.Clone(registry.providers)
+	entityList := slices.Clone(registry.fixed)
+	registry.mu.Unlock()
+
+	var provided []Entity
+	for _, provider := range providers {
+		provided = append(provided, provider()...)
+	}
+	return withEntityIds(withReadOnly(append(provided, entityList...)))
+}
+
+// Watch calls watcher with every change of the registry until the returned stop function is called.
+// Changes are computed against the entities at the time of the first Watch call. watcher is called
+// on the goroutine changing the registry and must not change the registry itself.
+func (registry *Registry) Watch(watcher func(Change)) (stop func()) {
+	registry.refreshMu.Lock()
+	defer registry.refreshMu.Unlock()
+
+	if registry.watchers == nil {
+		registry.watchers = make(map[int]func(Change))
+		registry.known = registry.Entities()
+	}
+	id := registry.nextWatch
+	registry.nextWatch++
+	registry.watchers[id] = watcher
+
+	return func() {
+		registry.refreshMu.Lock()
+		defer registry.refreshMu.Unlock()
+		delete(registry.watchers, id)
+		if len(registry.watchers) == 0 {
+			registry.watchers = nil
+			registry.known = nil
+		}
+	}
+}
+
+// Refresh collects the entities again and reports the added and removed ones to the watchers.
+// It does nothing while the registry is not watched.
+func (registry *Registry) Refresh() {
+	registry.refreshMu.Lock()
+	defer registry.refreshMu.Unlock()
+	if registry.watchers == nil {
+		return
+	}
+
+	current := registry.Entities()
+	change := Change{
+		Added:   missingEntities(current, registry.known),
+		Removed: missingEntities(registry.known, current),
+	}
+	registry.known = current
+
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return
+	}
+	for _, watcher := range registry.watchers {
+		watcher(change)
+	}
+}
+
+// missingEntities returns the entities of entityList not in others. Entities are compared by
+// discovery topic, which identifies them in Home Assistant.
+func missingEntities(entityList []Entity, others []Entity) []Entity {
+	topics := make(map[string]bool, len(others))
+	for _, ety := range others {
+		topics[ety.GetDiscoveryTopic()] = true
+	}
+
+	var missing []Entity
+	for _, ety := range entityList {
+		if !topics[ety.GetDiscoveryTopic()] {
+			missing = append(missing, ety)
+		}
+	}
+	return missing
+}
+
+// GetEntities returns the entities of the default registry.
+func GetEntities() []Entity {
+	return defaultRegistry.Entities()
+}
+
+// Register adds fixed entities to the default registry. Entities added while the bridge runs are announced right away.
+func Register(entityList ...Entity) {
+	defaultRegistry.Register(entityList...)
+}
+
+// Unregister removes fixed entities from the default registry. Entities removed while the bridge runs
+// are removed from Home Assistant.
+func Unregister(uniqueIds ...string) {
+	defaultRegistry.Unregister(uniqueIds...)
+}
+
+// RegisterProvider adds a function building entities to the default registry.
+func RegisterProvider(provider func() []Entity) {
+	defaultRegistry.RegisterProvider(provider)
+}
+
+// Refresh reports changed entities of the providers in the default registry to its watchers.
+func Refresh() {
+	defaultRegistry.Refresh()
+}
+
+// Watch calls watcher with every change of the default registry until stop is called.
+func Watch(watcher func(Change)) (stop func()) {
+	return defaultRegistry.Watch(watcher)
+}
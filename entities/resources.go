@@ -0,0 +1,83 @@
+package entities
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// getResourceEntities returns sensors with the CPU and memory usage of the PC, on FreeBSD.
+func getResourceEntities() []Entity {
+	if runtime.GOOS != system.FREEBSD {
+		return nil
+	}
+
+	percent := SensorClass{StateClass: StateClassMeasurement, Unit: UnitPercent}
+	return []Entity{
+		newResourceSensor("cpu_usage", "CPU usage", "mdi:cpu-64-bit", percent, func(context.Context) (string, error) {
+			usage, err := system.ReadCpuUsage()
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(usage, 'f', 1, 64), nil
+		}),
+		newResourceSensor("memory_used", "Memory used", "mdi:memory", SensorClass{
+			DeviceClass: DeviceClassDataSize,
+			StateClass:  StateClassMeasurement,
+			Unit:        Mega.Unit(),
+		}, func(context.Context) (string, error) {
+			memory, err := system.ReadMemory()
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(Mega.Convert(float64(memory.Used)), 'f', 0, 64), nil
+		}),
+		newResourceSensor("memory_usage", "Memory usage", "mdi:memory", percent, func(context.Context) (string, error) {
+			memory, err := system.ReadMemory()
+			if err != nil {
+				return "", err
+			}
+			if memory.Total == 0 {
+				return "0", nil
+			}
+			return strconv.FormatFloat(float64(memory.Used)/float64(memory.Total)*100, 'f', 1, 64), nil
+		}),
+	}
+}
+
+func newResourceSensor(key string, name string, icon string, class SensorClass, read func(ctx context.Context) (string, error)) Sensor {
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_sensor_" + key
+	format := entityPayloadFormat(key)
+	interval := entityInterval(key)
+	return Sensor{
+		Poll:           read,
+		Interval:       time.Duration(interval) * time.Second,
+		Deadband:       entityDeadband(key),
+		Average:        entityAverage(key),
+		Threshold:      entityThreshold(key),
+		Format:         format,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:            GetDevice(),
+			Availability:      []Availability{GetDeviceAvailability()},
+			DefaultEntityId:   "sensor." + objectId,
+			UniqueId:          objectId,
+			Name:              translate(name),
+			Icon:              icon,
+			StateTopic:        appConf.DeviceName + "/sensor/" + key + "/state",
+			ValueTemplate:     format.ValueTemplate(),
+			ExpireAfter:       entityExpireAfter(key, sensorRefreshInterval(key, interval)),
+			DeviceClass:       class.DeviceClass,
+			StateClass:        class.StateClass,
+			UnitOfMeasurement: class.Unit,
+			EntityCategory:    entityCategory(key, ""),
+			Qos:               entityQos(key),
+		},
+	}
+}
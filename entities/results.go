@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// CommandResult is published to an entity's result topic after its action ran.
+type CommandResult struct {
+	Success   bool   `json:"success"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+func NewCommandResult(err error) CommandResult {
+	result := CommandResult{
+		Success:   err == nil,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if err == nil {
+		return result
+	}
+
+	result.Error = err.Error()
+	result.ExitCode = -1
+
+	var cmdErr *system.CommandError
+	if errors.As(err, &cmdErr) {
+		result.ExitCode = cmdErr.ExitCode
+		result.Stderr = cmdErr.Stderr
+	}
+
+	return result
+}
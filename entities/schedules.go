@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/cron"
+)
+
+// getScheduleEntities returns a sensor with the next run of every schedule.
+func getScheduleEntities() []Entity {
+	schedules := appconfig.RequireConfig().Schedules
+	names := make([]string, 0, len(schedules))
+	for name := range schedules {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var entityList []Entity
+	for _, name := range names {
+		schedule, err := cron.Parse(schedules[name].Cron)
+		if err != nil {
+			// The config was validated
+			continue
+		}
+		entityList = append(entityList, newScheduleSensor(name, schedule))
+	}
+	return entityList
+}
+
+// ScheduleSensorId returns the unique id of the sensor with the next run of the schedule name.
+func ScheduleSensorId(name string) string {
+	return appconfig.RequireConfig().DeviceName + "_schedule_" + name
+}
+
+func newScheduleSensor(name string, schedule cron.Schedule) Sensor {
+	appConf := appconfig.RequireConfig()
+	key := "schedule_" + name
+	objectId := ScheduleSensorId(name)
+	interval := entityInterval(key)
+	displayName := strings.ReplaceAll(name, "_", " ")
+	return Sensor{
+		Value: func() string {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				// Home Assistant shows an empty timestamp as unknown
+				return ""
+			}
+			return next.Format(time.RFC3339)
+		},
+		Interval:       time.Duration(interval) * time.Second,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "sensor." + objectId,
+			UniqueId:        objectId,
+			Name:            strings.ToUpper(displayName[:1]) + displayName[1:],
+			Icon:            "mdi:calendar-clock",
+			StateTopic:      appConf.DeviceName + "/sensor/" + key + "/state",
+			ExpireAfter:     entityExpireAfter(key, sensorRefreshInterval(key, interval)),
+			DeviceClass:     DeviceClassTimestamp,
+			EntityCategory:  entityCategory(key, EntityCategoryDiagnostic),
+			Qos:             entityQos(key),
+		},
+	}
+}
@@ -0,0 +1,81 @@
+package entities
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+var (
+	sessionMu sync.Mutex
+	session   system.Session
+)
+
+// SetSession stores the state of the user session shown by the locked and logged in sensors.
+func SetSession(newSession system.Session) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	session = newSession
+}
+
+func getSession() system.Session {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return session
+}
+
+// getSessionEntities returns binary sensors for whether a user is logged in and whether the session is locked,
+// on Windows and Linux desktops.
+func getSessionEntities() []Entity {
+	if runtime.GOOS != system.WINDOWS && !desktopEnabled() {
+		return nil
+	}
+
+	return []Entity{
+		newSessionSensor("logged_in", "User logged in", "mdi:account", func() bool { return getSession().LoggedIn }),
+		newSessionSensor("locked", "Locked", "mdi:lock", func() bool { return getSession().Locked }),
+	}
+}
+
+// SessionSensor returns the sensor of entityList changed by change, eg. the locked sensor for system.SessionLock.
+func SessionSensor(entityList []Entity, change string) (BinarySensor, bool) {
+	key := "logged_in"
+	if change == system.SessionLock || change == system.SessionUnlock {
+		key = "locked"
+	}
+	for _, ety := range entityList {
+		if sensor, ok := ety.(BinarySensor); ok && sensor.GetDiscoveryConfig().UniqueId == sessionSensorId(key) {
+			return sensor, true
+		}
+	}
+	return BinarySensor{}, false
+}
+
+func sessionSensorId(key string) string {
+	return appconfig.RequireConfig().DeviceName + "_sensor_" + key
+}
+
+func newSessionSensor(key string, name string, icon string, state func() bool) BinarySensor {
+	appConf := appconfig.RequireConfig()
+	objectId := sessionSensorId(key)
+	return BinarySensor{
+		State:          state,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/binary_sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "binary_sensor." + objectId,
+			UniqueId:        objectId,
+			Name:            translate(name),
+			Icon:            icon,
+			StateTopic:      appConf.DeviceName + "/binary_sensor/" + key + "/state",
+			PayloadOn:       PayloadOn,
+			PayloadOff:      PayloadOff,
+			EntityCategory:  entityCategory(key, ""),
+			Qos:             entityQos(key),
+		},
+	}
+}
@@ -0,0 +1,129 @@
+package entities
+
+import (
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// Event types of power actions starting, eg. by pressing the shutdown button, and cancelled in the warning notification
+const (
+	EventShutdown          = "shutdown"
+	EventReboot            = "reboot"
+	EventSleep             = "sleep"
+	EventShutdownCancelled = "shutdown_cancelled"
+	EventRebootCancelled   = "reboot_cancelled"
+)
+
+// Subtypes of the device triggers, grouping the event types
+const (
+	triggerSubtypeSession     = "session"
+	triggerSubtypeSleep       = "sleep"
+	triggerSubtypePowerSupply = "power_supply"
+	triggerSubtypePower       = "power"
+)
+
+var systemEvents = make(chan string, 16)
+
+// SystemEvents receives the event types fired by entities for the system event entity, eg. shutdown_cancelled.
+func SystemEvents() <-chan string {
+	return systemEvents
+}
+
+func fireSystemEvent(eventType string) {
+	select {
+	case systemEvents <- eventType:
+	default:
+		logger.Warn("Too many system events, dropping one", "event_type", eventType)
+	}
+}
+
+// SystemEventEntity returns the system event entity of entityList.
+func SystemEventEntity(entityList []Entity) (Event, bool) {
+	uniqueId := appconfig.RequireConfig().DeviceName + "_event_system"
+	for _, ety := range entityList {
+		if event, ok := ety.(Event); ok && event.DiscoveryConfig.UniqueId == uniqueId {
+			return event, true
+		}
+	}
+	return Event{}, false
+}
+
+// systemEventTypes returns the event types that happen on this PC: session changes, sleep and resume,
+// AC power and lid changes and power actions starting or cancelled in their warning.
+func systemEventTypes() []string {
+	eventTypes := []string{EventShutdown, EventReboot, EventSleep}
+	if runtime.GOOS == system.WINDOWS || desktopEnabled() {
+		eventTypes = append(eventTypes, system.SessionLock, system.SessionUnlock, system.SessionLogon, system.SessionLogoff)
+	}
+	// Elsewhere resume is only noticed afterwards by the clock jumping
+	if runtime.GOOS == system.WINDOWS || runtime.GOOS == system.LINUX {
+		eventTypes = append(eventTypes, system.SleepSuspend)
+	}
+	eventTypes = append(eventTypes, system.SleepResume)
+	eventTypes = append(eventTypes, system.PowerSupplyChanges()...)
+	if appconfig.RequireConfig().Commands.ShutdownWarning > 0 {
+		eventTypes = append(eventTypes, EventShutdownCancelled, EventRebootCancelled)
+	}
+	return eventTypes
+}
+
+// systemEventSubtype returns the device trigger subtype of eventType.
+func systemEventSubtype(eventType string) string {
+	switch eventType {
+	case system.SessionLock, system.SessionUnlock, system.SessionLogon, system.SessionLogoff:
+		return triggerSubtypeSession
+	case system.SleepSuspend, system.SleepResume:
+		return triggerSubtypeSleep
+	case system.PowerSupplyAcPlugged, system.PowerSupplyAcUnplugged, system.PowerSupplyLidClosed, system.PowerSupplyLidOpened:
+		return triggerSubtypePowerSupply
+	default:
+		return triggerSubtypePower
+	}
+}
+
+// getSystemEventEntities returns the event entity firing on system events, so automations can trigger on
+// them instead of sensor changes, and a device trigger per event type for the automation editor.
+func getSystemEventEntities() []Entity {
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_event_system"
+	stateTopic := appConf.DeviceName + "/event/system/state"
+	eventTypes := systemEventTypes()
+	entityList := []Entity{
+		Event{
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/event/" + appConf.DeviceId + "/" + objectId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:          GetDevice(),
+				Availability:    []Availability{GetDeviceAvailability()},
+				DefaultEntityId: "event." + objectId,
+				UniqueId:        objectId,
+				Name:            translate("System event"),
+				Icon:            "mdi:bell-ring",
+				StateTopic:      stateTopic,
+				EventTypes:      eventTypes,
+				EntityCategory:  entityCategory("system_event", ""),
+				Qos:             entityQos("system_event"),
+			},
+		},
+	}
+
+	for _, eventType := range eventTypes {
+		triggerId := appConf.DeviceName + "_trigger_" + eventType
+		entityList = append(entityList, DeviceTrigger{
+			DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/device_automation/" + appConf.DeviceId + "/" + triggerId + "/config",
+			DiscoveryConfig: &DiscoveryConfig{
+				Device:         GetDevice(),
+				UniqueId:       triggerId,
+				AutomationType: "trigger",
+				Topic:          stateTopic,
+				Type:           eventType,
+				Subtype:        systemEventSubtype(eventType),
+				ValueTemplate:  "{{ value_json.event_type }}",
+				Payload:        eventType,
+				Qos:            entityQos("system_event"),
+			},
+		})
+	}
+	return entityList
+}
@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/tailscale"
+)
+
+// getTailscaleEntities returns sensors with the connection state, exit node and IP of the PC in its tailnet,
+// if Tailscale is installed.
+func getTailscaleEntities() []Entity {
+	if !tailscale.Installed() {
+		return nil
+	}
+
+	return []Entity{
+		newTailscaleSensor("state", "Tailscale", "mdi:vpn", func(status tailscale.Status) string {
+			// eg. running, stopped or needslogin
+			return strings.ToLower(status.BackendState)
+		}),
+		newTailscaleSensor("exit_node", "Tailscale exit node", "mdi:exit-run", func(status tailscale.Status) string {
+			if exitNode := status.ExitNode(); exitNode != "" {
+				return exitNode
+			}
+			return "none"
+		}),
+		newTailscaleSensor("ip", "Tailscale IP", "mdi:ip-network", tailscale.Status.IP),
+	}
+}
+
+func newTailscaleSensor(key string, name string, icon string, value func(tailscale.Status) string) Sensor {
+	appConf := appconfig.RequireConfig()
+	key = "tailscale_" + key
+	objectId := appConf.DeviceName + "_" + key
+	interval := entityInterval(key)
+	return Sensor{
+		Poll: func(ctx context.Context) (string, error) {
+			status, err := tailscale.GetStatus(ctx)
+			if err != nil {
+				return "", err
+			}
+			return value(status), nil
+		},
+		Interval:       time.Duration(interval) * time.Second,
+		Retain:         entityRetain(key),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/sensor/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "sensor." + objectId,
+			UniqueId:        objectId,
+			Name:            translate(name),
+			Icon:            icon,
+			StateTopic:      appConf.DeviceName + "/sensor/" + key + "/state",
+			ExpireAfter:     entityExpireAfter(key, sensorRefreshInterval(key, interval)),
+			EntityCategory:  entityCategory(key, EntityCategoryDiagnostic),
+			Qos:             entityQos(key),
+		},
+	}
+}
@@ -0,0 +1,69 @@
+package entities
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// RetainedTopics returns the discovery, availability and state topics of entityList and
+// the Homie topics if enabled, which are the topics holding retained messages.
+func RetainedTopics(entityList []Entity) []string {
+	var topics []string
+	add := func(topic string) {
+		if topic != "" && !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+
+	appConf := appconfig.RequireConfig()
+	discoveryMode := appConf.Mqtt.DiscoveryMode
+	add(GetDeviceAvailability().Topic)
+	for _, ety := range entityList {
+		config := ety.GetDiscoveryConfig()
+		discoveryTopic := ety.GetDiscoveryTopic()
+		if discoveryMode == appconfig.DiscoveryModeDevice {
+			discoveryTopic = GetDeviceDiscoveryTopic(config.Device)
+		}
+		if discoveryMode != appconfig.DiscoveryModeNone {
+			for _, topic := range DiscoveryTopics(discoveryTopic) {
+				add(topic)
+			}
+		}
+		for _, availability := range config.Availability {
+			add(availability.Topic)
+		}
+		add(config.StateTopic)
+	}
+
+	if appConf.Homie.Enabled {
+		nodes := HomieNodes(entityList)
+		attributes := HomieAttributes(nodes)
+		for _, topic := range slices.Sorted(maps.Keys(attributes)) {
+			add(topic)
+		}
+		for _, node := range nodes {
+			if node.Property.Retained {
+				add(node.Topic())
+			}
+		}
+		add(HomieStateTopic())
+	}
+
+	return topics
+}
+
+// DiscoveryTopics returns topic, which starts with mqtt.auto_discovery_prefix,
+// for every configured discovery prefix.
+func DiscoveryTopics(topic string) []string {
+	mqttConf := appconfig.RequireConfig().Mqtt
+	suffix := strings.TrimPrefix(topic, mqttConf.AutoDiscoveryPrefix)
+
+	var topics []string
+	for _, prefix := range mqttConf.DiscoveryPrefixes() {
+		topics = append(topics, prefix+suffix)
+	}
+	return topics
+}
@@ -0,0 +1,107 @@
+package entities
+
+import (
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// translations maps a language code to the translated entity names and messages, keyed by the English text.
+// Messages are format strings, eg. the shutdown warning "%s at %s, in %d seconds".
+// Names missing for a language fall back to English.
+var translations = map[string]map[string]string{
+	"da": {
+		"Power":    "Strøm",
+		"Shutdown": "Luk ned",
+		"Reboot":   "Genstart",
+		"Sleep":    "Slumre",
+		"Test":     "Test",
+		"Cancel":   "Annuller",
+
+		"%s at %s, in %d seconds": "%s kl. %s, om %d sekunder",
+		"%s cancelled":            "%s annulleret",
+	},
+	"de": {
+		"Power":    "Eingeschaltet",
+		"Shutdown": "Herunterfahren",
+		"Reboot":   "Neustarten",
+		"Sleep":    "Energie sparen",
+		"Test":     "Test",
+		"Cancel":   "Abbrechen",
+
+		"%s at %s, in %d seconds": "%s um %s, in %d Sekunden",
+		"%s cancelled":            "%s abgebrochen",
+	},
+	"es": {
+		"Power":    "Encendido",
+		"Shutdown": "Apagar",
+		"Reboot":   "Reiniciar",
+		"Sleep":    "Suspender",
+		"Test":     "Prueba",
+		"Cancel":   "Cancelar",
+
+		"%s at %s, in %d seconds": "%s a las %s, en %d segundos",
+		"%s cancelled":            "%s cancelado",
+	},
+	"fr": {
+		"Power":    "Alimentation",
+		"Shutdown": "Éteindre",
+		"Reboot":   "Redémarrer",
+		"Sleep":    "Mettre en veille",
+		"Test":     "Test",
+		"Cancel":   "Annuler",
+
+		"%s at %s, in %d seconds": "%s à %s, dans %d secondes",
+		"%s cancelled":            "%s annulé",
+	},
+	"it": {
+		"Power":    "Acceso",
+		"Shutdown": "Spegni",
+		"Reboot":   "Riavvia",
+		"Sleep":    "Sospendi",
+		"Test":     "Prova",
+		"Cancel":   "Annulla",
+
+		"%s at %s, in %d seconds": "%s alle %s, tra %d secondi",
+		"%s cancelled":            "%s annullato",
+	},
+	"nl": {
+		"Power":    "Aan",
+		"Shutdown": "Afsluiten",
+		"Reboot":   "Herstarten",
+		"Sleep":    "Slaapstand",
+		"Test":     "Test",
+		"Cancel":   "Annuleren",
+
+		"%s at %s, in %d seconds": "%s om %s, over %d seconden",
+		"%s cancelled":            "%s geannuleerd",
+	},
+	"sv": {
+		"Power":    "Ström",
+		"Shutdown": "Stäng av",
+		"Reboot":   "Starta om",
+		"Sleep":    "Strömsparläge",
+		"Test":     "Test",
+		"Cancel":   "Avbryt",
+
+		"%s at %s, in %d seconds": "%s kl. %s, om %d sekunder",
+		"%s cancelled":            "%s avbruten",
+	},
+}
+
+// translate returns name in the configured language.
+func translate(name string) string {
+	language := strings.ToLower(appconfig.RequireConfig().Language)
+	if translated, ok := translations[language][name]; ok {
+		return translated
+	}
+
+	// Accept regional codes like de-AT or de_CH
+	if base, _, found := strings.Cut(strings.ReplaceAll(language, "_", "-"), "-"); found {
+		if translated, ok := translations[base][name]; ok {
+			return translated
+		}
+	}
+
+	return name
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"math"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// ByteScale is the magnitude a byte sensor publishes its value in.
+// The unit system from the config decides between SI (GB) and binary (GiB) units.
+type ByteScale int
+
+const (
+	Kilo ByteScale = iota + 1
+	Mega
+	Giga
+	Tera
+)
+
+var (
+	siByteUnits     = []string{"B", "kB", "MB", "GB", "TB"}
+	binaryByteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+)
+
+// Unit returns the unit_of_measurement matching the configured unit system.
+func (scale ByteScale) Unit() string {
+	if appconfig.RequireConfig().UnitSystem == appconfig.UnitSystemSI {
+		return siByteUnits[scale]
+	}
+	return binaryByteUnits[scale]
+}
+
+// Convert scales a value in bytes to the unit returned by Unit.
+func (scale ByteScale) Convert(bytes float64) float64 {
+	base := 1024.0
+	if appconfig.RequireConfig().UnitSystem == appconfig.UnitSystemSI {
+		base = 1000.0
+	}
+	return bytes / math.Pow(base, float64(scale))
+}
@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"context"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+	"github.com/leonlatsch/pc2mqtt/internal/version"
+)
+
+const payloadInstall = "install"
+
+// getUpdateEntities returns the update entity if update_check is enabled.
+func getUpdateEntities() []Entity {
+	if !appconfig.RequireConfig().UpdateCheck {
+		return nil
+	}
+	return []Entity{getUpdateEntity()}
+}
+
+// getUpdateEntity returns the update entity comparing the running pc2mqtt version with the
+// latest release. Installing it replaces the binary and restarts pc2mqtt.
+func getUpdateEntity() Update {
+	appConf := appconfig.RequireConfig()
+	objectId := appConf.DeviceName + "_update_pc2mqtt"
+	return Update{
+		State: func() UpdateState {
+			state := UpdateState{
+				InstalledVersion: version.Get(),
+				Title:            "pc2mqtt",
+			}
+			if release, ok := update.LatestKnown(); ok {
+				state.LatestVersion = release.TagName
+				state.ReleaseUrl = release.HtmlUrl
+				state.ReleaseSummary = release.Body
+				if len(state.ReleaseSummary) > maxStateLength {
+					state.ReleaseSummary = state.ReleaseSummary[:maxStateLength]
+				}
+			}
+			return state
+		},
+		Install: func() error {
+			logger.Info("Update install requested, installing the latest release")
+			return update.InstallLatest(context.Background())
+		},
+		Retain:         entityRetain("update"),
+		ResultTopic:    appConf.DeviceName + "/update/pc2mqtt/result",
+		Debounce:       entityDebounce("update"),
+		DiscoveryTopic: appConf.Mqtt.AutoDiscoveryPrefix + "/update/" + appConf.DeviceId + "/" + objectId + "/config",
+		DiscoveryConfig: &DiscoveryConfig{
+			Device:          GetDevice(),
+			Availability:    []Availability{GetDeviceAvailability()},
+			DefaultEntityId: "update." + objectId,
+			UniqueId:        objectId,
+			Name:            "pc2mqtt",
+			Icon:            "mdi:package-up",
+			StateTopic:      appConf.DeviceName + "/update/pc2mqtt/state",
+			CommandTopic:    appConf.DeviceName + "/update/pc2mqtt/command",
+			PayloadInstall:  payloadInstall,
+			EntityCategory:  entityCategory("update", EntityCategoryConfig),
+			Qos:             entityCommandQos("update"),
+		},
+	}
+}
@@ -0,0 +1,113 @@
+// Package events is the internal event bus of pc2mqtt. Sensors, command executors and outputs
+// like the MQTT connection exchange events through it instead of calling each other, so programs
+// embedding pc2mqtt can add outputs, eg. a REST API or Prometheus metrics, by subscribing.
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/entities"
+)
+
+// Kind tells what happened.
+type Kind string
+
+const (
+	// StateUpdated carries the formatted state of a sensor, switch or update entity in Payload.
+	StateUpdated Kind = "state_updated"
+	// CommandReceived carries a command for Entity with the command payload in Payload.
+	CommandReceived Kind = "command_received"
+	// CommandFinished reports the outcome of a command in Err, nil on success.
+	CommandFinished Kind = "command_finished"
+	// ThresholdCrossed carries the polled value of a sensor with a threshold in Payload, which is
+	// on the other side of the threshold than the value in Previous.
+	ThresholdCrossed Kind = "threshold_crossed"
+	// ConnectionLost reports the lost broker connection with the cause in Err. It has no Entity.
+	ConnectionLost Kind = "connection_lost"
+	// SessionChanged carries a lock, unlock, logon or logoff of a user session in Payload for the
+	// locked or logged in sensor.
+	SessionChanged Kind = "session_changed"
+	// CommandRejected reports a command for Entity that didn't run with the reason in Err, eg. its cooldown.
+	CommandRejected Kind = "command_rejected"
+)
+
+// Kinds lists all event kinds.
+var Kinds = []Kind{StateUpdated, CommandReceived, CommandFinished, ThresholdCrossed, ConnectionLost, SessionChanged, CommandRejected}
+
+type Event struct {
+	Kind    Kind
+	Entity  entities.Entity
+	Payload string
+	// Previous is the value before Payload, set for ThresholdCrossed.
+	Previous string
+	// Source tells where a command came from, the topic it was received on or schedule:<name>.
+	Source string
+	// Retain tells outputs keeping messages, like MQTT, to keep the state.
+	Retain bool
+	Err    error
+	Time   time.Time
+}
+
+// Handler receives events. Handlers run on the goroutine publishing the event, one after another,
+// so slow handlers have to hand the work off to their own goroutine.
+type Handler func(event Event)
+
+// Bus delivers published events to the handlers subscribed to their kind.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers []*subscriber
+}
+
+type subscriber struct {
+	handler Handler
+	kinds   []Kind
+}
+
+var defaultBus = &Bus{}
+
+// Subscribe calls handler with every event of kinds, or of every kind if none are given,
+// until the returned function is called.
+func (bus *Bus) Subscribe(handler Handler, kinds ...Kind) (unsubscribe func()) {
+	sub := &subscriber{handler: handler, kinds: kinds}
+	bus.mu.Lock()
+	bus.subscribers = append(bus.subscribers, sub)
+	bus.mu.Unlock()
+
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		bus.subscribers = slices.DeleteFunc(bus.subscribers, func(s *subscriber) bool {
+			return s == sub
+		})
+	}
+}
+
+// Publish delivers event to the subscribed handlers in the order they subscribed.
+// A zero Time is set to now.
+func (bus *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	bus.mu.Lock()
+	subscribers := slices.Clone(bus.subscribers)
+	bus.mu.Unlock()
+
+	for _, sub := range subscribers {
+		if len(sub.kinds) == 0 || slices.Contains(sub.kinds, event.Kind) {
+			sub.handler(event)
+		}
+	}
+}
+
+// Subscribe calls handler with the events of kinds published on the default bus, which pc2mqtt uses.
+func Subscribe(handler Handler, kinds ...Kind) (unsubscribe func()) {
+	return defaultBus.Subscribe(handler, kinds...)
+}
+
+// Publish delivers event to the handlers subscribed on the default bus.
+func Publish(event Event) {
+	defaultBus.Publish(event)
+}
@@ -0,0 +1,55 @@
+package appconfig
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var tlsSchemes = []string{"ssl", "tls", "mqtts", "mqtt+ssl", "tcps", "wss"}
+var websocketSchemes = []string{"ws", "wss"}
+var brokerSchemes = []string{"tcp", "mqtt", "ssl", "tls", "mqtts", "mqtt+ssl", "tcps", "ws", "wss"}
+
+// BrokerUrls splits the comma separated mqtt.url into single broker URLs.
+func (conf MqttAppConfig) BrokerUrls() []string {
+	var urls []string
+	for _, brokerUrl := range strings.Split(conf.Url, ",") {
+		if brokerUrl = strings.TrimSpace(brokerUrl); brokerUrl != "" {
+			urls = append(urls, brokerUrl)
+		}
+	}
+	return urls
+}
+
+// DiscoveryPrefixes returns mqtt.auto_discovery_prefix followed by mqtt.additional_discovery_prefixes.
+func (conf MqttAppConfig) DiscoveryPrefixes() []string {
+	prefixes := []string{conf.AutoDiscoveryPrefix}
+	for _, prefix := range conf.AdditionalDiscoveryPrefixes {
+		if prefix != "" && !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// UsesTls reports whether the broker connection is encrypted.
+func (conf MqttAppConfig) UsesTls() bool {
+	return conf.Tls.Enabled || conf.anyUrlScheme(tlsSchemes)
+}
+
+// UsesWebsocket reports whether the broker is reached over websockets.
+func (conf MqttAppConfig) UsesWebsocket() bool {
+	if conf.Url == "" {
+		return conf.Transport == TransportWebsocket
+	}
+	return conf.anyUrlScheme(websocketSchemes)
+}
+
+func (conf MqttAppConfig) anyUrlScheme(schemes []string) bool {
+	for _, brokerUrl := range conf.BrokerUrls() {
+		if u, err := url.Parse(brokerUrl); err == nil && slices.Contains(schemes, u.Scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package appconfig
+
+import (
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// PowerOptions returns how shutdown, reboot and sleep run.
+func (conf CommandsAppConfig) PowerOptions() system.PowerOptions {
+	return system.PowerOptions{LinuxPower: conf.LinuxPower, HybridShutdown: conf.HybridShutdown, SleepMode: conf.OsSleepMode()}
+}
+
+// OsSleepMode returns the sleep mode of this OS, suspend unless sleep_mode sets one.
+func (conf CommandsAppConfig) OsSleepMode() string {
+	if mode, ok := conf.SleepMode[runtime.GOOS]; ok {
+		return mode
+	}
+	return system.SleepModeSuspend
+}
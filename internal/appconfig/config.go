@@ -4,16 +4,31 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/leonlatsch/pc2mqtt/internal/credentials"
+	"github.com/leonlatsch/pc2mqtt/internal/slug"
 	"github.com/leonlatsch/pc2mqtt/internal/system"
 )
 
-const configFileName = "config.json"
+const DefaultConfigFileName = "config.json"
 const configFileMode = 0644
 
 var localConfig *AppConfig = nil
+var configPath = DefaultConfigFileName
+
+// LoadOptions select which config is loaded.
+type LoadOptions struct {
+	// Path is a config file or a directory holding config.json and one
+	// <profile>.json per profile. Defaults to config.json.
+	Path string
+	// Profile names the profile merged over the base config. Empty loads the base config only.
+	Profile string
+	// SkipSecrets leaves secrets stored outside the config file and encrypted values unresolved.
+	SkipSecrets bool
+}
 
 func RequireConfig() *AppConfig {
 	if localConfig == nil {
@@ -23,39 +38,32 @@ func RequireConfig() *AppConfig {
 	return localConfig
 }
 
-func createEmptyConfig() error {
-	newEmptyConfig := AppConfig{
-		DeviceId:   uuid.New().String(),
-		DeviceName: strings.ToLower(system.Hostname()),
-		Mqtt: MqttAppConfig{
-			Host:                "YOUR MQTT HOST",
-			Port:                1883,
-			Username:            "MQTT USER",
-			Password:            "MQTT PASSWORD",
-			AutoDiscoveryPrefix: "homeassistant",
-		},
-		DebugMode: false,
-	}
-
-	if err := SaveConfig(newEmptyConfig); err != nil {
-		return err
-	}
-
-	return nil
+func createEmptyConfig(path string) error {
+	return WriteDefaultConfig(path, false)
 }
 
-func configExists() bool {
-	_, err := os.Stat(configFileName)
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
 }
 
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// ConfigDir returns the directory of the loaded config file. Local state is kept there as well.
+func ConfigDir() string {
+	return filepath.Dir(configPath)
+}
+
 func SaveConfig(conf AppConfig) error {
 	confJson, err := json.MarshalIndent(conf, "", "    ")
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(configFileName, confJson, configFileMode); err != nil {
+	if err := os.WriteFile(configPath, confJson, configFileMode); err != nil {
 		return err
 	}
 
@@ -63,27 +71,198 @@ func SaveConfig(conf AppConfig) error {
 	return nil
 }
 
-func LoadConfig() error {
-	if !configExists() {
-		if err := createEmptyConfig(); err != nil {
+func LoadConfig(opts LoadOptions) error {
+	path := opts.Path
+	if path == "" {
+		path = DefaultConfigFileName
+	}
+
+	var raw map[string]any
+	var err error
+	if isDir(path) {
+		raw, err = loadProfileDir(path, opts.Profile)
+	} else {
+		raw, err = loadProfileFile(path, opts.Profile)
+	}
+	if err != nil {
+		return err
+	}
+
+	expandValues(raw)
+
+	if !opts.SkipSecrets {
+		if _, err := decryptValues(raw); err != nil {
 			return err
 		}
-		return errors.New("Config does not exist. Created initial config")
 	}
 
-	var conf AppConfig
-	buf, err := os.ReadFile(configFileName)
+	buf, err := json.Marshal(raw)
 	if err != nil {
 		return err
 	}
 
+	conf := defaultAppConfig()
 	if err := json.Unmarshal(buf, &conf); err != nil {
 		return err
 	}
 
-	// Ensure device name is lowercase for consistency
-	conf.DeviceName = strings.ToLower(conf.DeviceName)
+	// Topics and ids use the slug of the device name, Home Assistant still shows it as configured
+	conf.DeviceDisplayName = conf.DeviceName
+	if conf.Slugify {
+		conf.DeviceName = slug.Make(conf.DeviceName)
+	} else {
+		conf.DeviceName = strings.ToLower(conf.DeviceName)
+		conf.DeviceDisplayName = conf.DeviceName
+	}
+
+	if conf.MachineDeviceId {
+		deviceId, err := machineDeviceId()
+		if err != nil {
+			return errors.New("Failed to derive device id from machine id: " + err.Error())
+		}
+		conf.DeviceId = deviceId
+	}
+
+	if err := validateConfig(conf); err != nil {
+		return err
+	}
+
+	if conf.Mqtt.UseKeychain && !opts.SkipSecrets {
+		password, err := credentials.Get(KeychainAccount(conf))
+		if err != nil {
+			return errors.New("Failed to read MQTT password from OS credential store: " + err.Error())
+		}
+		conf.Mqtt.Password = password
+	}
 
 	localConfig = &conf
 	return nil
 }
+
+// loadProfileFile reads a single config file and merges the selected
+// entry of its "profiles" object over the top level values.
+func loadProfileFile(path string, profile string) (map[string]any, error) {
+	if !fileExists(path) {
+		if err := createEmptyConfig(path); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("Config does not exist. Created initial config")
+	}
+
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	configPath = path
+
+	profiles, _ := raw["profiles"].(map[string]any)
+	delete(raw, "profiles")
+	if profile == "" {
+		return raw, nil
+	}
+
+	override, ok := profiles[profile].(map[string]any)
+	if !ok {
+		return nil, errors.New("Profile " + profile + " not found in " + path)
+	}
+
+	mergeConfig(raw, override)
+	return raw, nil
+}
+
+// loadProfileDir merges <dir>/<profile>.json over the optional <dir>/config.json.
+func loadProfileDir(dir string, profile string) (map[string]any, error) {
+	base := filepath.Join(dir, DefaultConfigFileName)
+	raw := map[string]any{}
+	if fileExists(base) {
+		var err error
+		if raw, err = readConfigFile(base); err != nil {
+			return nil, err
+		}
+		delete(raw, "profiles")
+	}
+	configPath = base
+
+	if profile == "" {
+		if !fileExists(base) {
+			return nil, errors.New("No profile selected and " + base + " does not exist")
+		}
+		return raw, nil
+	}
+
+	profilePath := filepath.Join(dir, profile+".json")
+	if !fileExists(profilePath) {
+		return nil, errors.New("Profile " + profile + " not found in " + dir)
+	}
+
+	override, err := readConfigFile(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	configPath = profilePath
+
+	mergeConfig(raw, override)
+	return raw, nil
+}
+
+func readConfigFile(path string) (map[string]any, error) {
+	return readConfigFileVisiting(path, map[string]bool{})
+}
+
+func readConfigFileVisiting(path string, visiting map[string]bool) (map[string]any, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[absPath] {
+		return nil, errors.New(path + " includes itself")
+	}
+	visiting[absPath] = true
+	defer delete(visiting, absPath)
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := map[string]any{}
+	if err := json.Unmarshal(stripComments(buf), &raw); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+
+	if err := resolveIncludes(raw, path, visiting); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// mergeConfig merges src into dst. Nested objects are merged key by key,
+// every other value in src replaces the one in dst.
+func mergeConfig(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		srcObj, srcIsObj := value.(map[string]any)
+		dstObj, dstIsObj := dst[key].(map[string]any)
+		if srcIsObj && dstIsObj {
+			mergeConfig(dstObj, srcObj)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// KeychainAccount is the account the MQTT password is stored under in the OS credential store.
+func KeychainAccount(conf AppConfig) string {
+	return conf.Mqtt.Username + "@" + conf.Mqtt.Host
+}
+
+// machineDeviceId hashes the OS machine id into a UUID. The raw machine id
+// is not published since it is meant to stay confidential.
+func machineDeviceId() (string, error) {
+	machineId, err := system.MachineId()
+	if err != nil {
+		return "", err
+	}
+
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("pc2mqtt:"+machineId)).String(), nil
+}
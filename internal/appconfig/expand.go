@@ -0,0 +1,57 @@
+package appconfig
+
+import (
+	"os"
+	"regexp"
+	"runtime"
+
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+// Matches ${NAME} and ${NAME:-default}. A bare $NAME is left alone so
+// passwords containing a dollar sign keep working.
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// templateVariables are available even when no environment variable of that name is set.
+var templateVariables = map[string]func() string{
+	"HOSTNAME": system.Hostname,
+	"OS":       func() string { return runtime.GOOS },
+	"ARCH":     func() string { return runtime.GOARCH },
+}
+
+func lookupVariable(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	if fn, ok := templateVariables[name]; ok {
+		return fn(), true
+	}
+	return "", false
+}
+
+func expandString(value string) string {
+	return variablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		if value, ok := lookupVariable(groups[1]); ok && value != "" {
+			return value
+		}
+		return groups[2]
+	})
+}
+
+// expandValues replaces variables in every string value of a decoded config.
+func expandValues(value any) any {
+	switch v := value.(type) {
+	case string:
+		return expandString(v)
+	case map[string]any:
+		for key, item := range v {
+			v[key] = expandValues(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = expandValues(item)
+		}
+	}
+	return value
+}
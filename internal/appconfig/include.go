@@ -0,0 +1,78 @@
+package appconfig
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// resolveIncludes merges every file listed in the "include" value of raw into raw.
+// Paths are relative to the including file and may be glob patterns.
+// Included files may include further files; cycles are rejected.
+func resolveIncludes(raw map[string]any, path string, visiting map[string]bool) error {
+	value, ok := raw["include"]
+	if !ok {
+		return nil
+	}
+	delete(raw, "include")
+
+	var patterns []string
+	switch v := value.(type) {
+	case string:
+		patterns = []string{v}
+	case []any:
+		for _, item := range v {
+			pattern, ok := item.(string)
+			if !ok {
+				return errors.New(path + ": include entries must be strings")
+			}
+			patterns = append(patterns, pattern)
+		}
+	default:
+		return errors.New(path + ": include must be a string or a list of strings")
+	}
+
+	dir := filepath.Dir(path)
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return errors.New(path + ": " + err.Error())
+		}
+		if len(matches) == 0 {
+			return errors.New(path + ": include " + pattern + " matches no files")
+		}
+
+		for _, match := range matches {
+			included, err := readConfigFileVisiting(match, visiting)
+			if err != nil {
+				return err
+			}
+			mergeIncluded(raw, included)
+		}
+	}
+
+	return nil
+}
+
+// mergeIncluded works like mergeConfig but appends lists instead of
+// replacing them, so entities can be spread over several files.
+func mergeIncluded(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		switch srcValue := value.(type) {
+		case map[string]any:
+			if dstObj, ok := dst[key].(map[string]any); ok {
+				mergeIncluded(dstObj, srcValue)
+				continue
+			}
+		case []any:
+			if dstList, ok := dst[key].([]any); ok {
+				dst[key] = append(dstList, srcValue...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
@@ -1,16 +1,370 @@
 package appconfig
 
 type MqttAppConfig struct {
-	Host                string `json:"host"`
-	Port                int    `json:"port"`
-	Username            string `json:"username"`
-	Password            string `json:"password"`
-	AutoDiscoveryPrefix string `json:"auto_discovery_prefix"`
+	Url                  string `json:"url"`
+	Host                 string `json:"host"`
+	Port                 int    `json:"port"`
+	Username             string `json:"username"`
+	Password             string `json:"password"`
+	ClientId             string `json:"client_id"`
+	CleanSession         bool   `json:"clean_session"`
+	ResumeSubs           bool   `json:"resume_subs"`
+	KeepAlive            int    `json:"keep_alive"`
+	PingTimeout          int    `json:"ping_timeout"`
+	ConnectTimeout       int    `json:"connect_timeout"`
+	ConnectRetryInterval int    `json:"connect_retry_interval"`
+	MaxReconnectInterval int    `json:"max_reconnect_interval"`
+	// ReconnectBackoff is how the delay between connection attempts grows, see ReconnectBackoffExponential
+	ReconnectBackoff string `json:"reconnect_backoff"`
+	// ReconnectJitter is the percent by which the delay between connection attempts is spread randomly
+	ReconnectJitter int `json:"reconnect_jitter"`
+	// WaitForNetwork is the seconds startup waits for the broker to resolve and the first connection, eg. while
+	// Wi-Fi connects on boot
+	WaitForNetwork              int          `json:"wait_for_network"`
+	UseKeychain                 bool         `json:"use_keychain"`
+	AutoDiscoveryPrefix         string       `json:"auto_discovery_prefix"`
+	AdditionalDiscoveryPrefixes []string     `json:"additional_discovery_prefixes"`
+	HaStatusTopic               string       `json:"ha_status_topic"`
+	DiscoveryMode               string       `json:"discovery_mode"`
+	Qos                         int          `json:"qos"`
+	Retain                      bool         `json:"retain"`
+	Transport                   string       `json:"transport"`
+	WebsocketPath               string       `json:"websocket_path"`
+	Proxy                       string       `json:"proxy"`
+	Tls                         TLSAppConfig `json:"tls"`
 }
 
+type TLSAppConfig struct {
+	Enabled            bool   `json:"enabled"`
+	CaFile             string `json:"ca_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+}
+
+const (
+	TransportTcp       = "tcp"
+	TransportWebsocket = "websocket"
+)
+
+const (
+	// ReconnectBackoffExponential doubles the delay from connect_retry_interval for every failed attempt up to
+	// max_reconnect_interval
+	ReconnectBackoffExponential = "exponential"
+	// ReconnectBackoffFixed retries every connect_retry_interval
+	ReconnectBackoffFixed = "fixed"
+)
+
+const (
+	DiscoveryModeEntity = "entity"
+	DiscoveryModeDevice = "device"
+	// DiscoveryModeNone publishes no Home Assistant discovery, eg. for other MQTT consumers using homie.
+	DiscoveryModeNone = "none"
+)
+
+const (
+	OnPanicExit    = "exit"
+	OnPanicRestart = "restart"
+)
+
+const (
+	UnitSystemBinary = "binary"
+	UnitSystemSI     = "si"
+)
+
 type AppConfig struct {
-	DeviceId   string        `json:"device_id"`
-	DeviceName string        `json:"device_name"`
-	Mqtt       MqttAppConfig `json:"mqtt"`
-	DebugMode  bool          `json:"debug_mode"`
+	DeviceId         string                       `json:"device_id"`
+	MachineDeviceId  bool                         `json:"machine_device_id"`
+	DeviceName       string                       `json:"device_name"`
+	Slugify          bool                         `json:"slugify"`
+	SuggestedArea    string                       `json:"suggested_area"`
+	ConfigurationUrl string                       `json:"configuration_url"`
+	Mqtt             MqttAppConfig                `json:"mqtt"`
+	Homie            HomieAppConfig               `json:"homie"`
+	UnitSystem       string                       `json:"unit_system"`
+	Language         string                       `json:"language"`
+	Entities         map[string]EntityAppConfig   `json:"entities"`
+	Heartbeat        HeartbeatAppConfig           `json:"heartbeat"`
+	OfflineQueue     OfflineQueueAppConfig        `json:"offline_queue"`
+	Commands         CommandsAppConfig            `json:"commands"`
+	Inhibitors       InhibitorsAppConfig          `json:"inhibitors"`
+	Audit            AuditAppConfig               `json:"audit"`
+	Schedules        map[string]ScheduleAppConfig `json:"schedules"`
+	Webhooks         []WebhookAppConfig           `json:"webhooks"`
+	Hosts            map[string]HostAppConfig     `json:"hosts"`
+	Actions          map[string]ActionAppConfig   `json:"actions"`
+	Desktop          DesktopAppConfig             `json:"desktop"`
+	Message          MessageAppConfig             `json:"message"`
+	Network          NetworkAppConfig             `json:"network"`
+	Diagnostics      DiagnosticsAppConfig         `json:"diagnostics"`
+	Polling          PollingAppConfig             `json:"polling"`
+	Health           HealthAppConfig              `json:"health"`
+	Logging          LoggingAppConfig             `json:"logging"`
+	OnPanic          string                       `json:"on_panic"`
+	UpdateCheck      bool                         `json:"update_check"`
+	DebugMode        bool                         `json:"debug_mode"`
+	// ReadOnly publishes sensors but no commands, for monitoring-only PCs
+	ReadOnly bool `json:"read_only"`
+	// DeviceDisplayName is device_name as configured, while DeviceName is its slug used in topics and ids
+	DeviceDisplayName string `json:"-"`
+}
+
+// EntityAppConfig overrides global options for a single entity. Unset values keep the global ones.
+type EntityAppConfig struct {
+	Qos      *int  `json:"qos"`
+	Retain   *bool `json:"retain"`
+	Debounce *int  `json:"debounce"`
+	// PayloadFormat and Precision only apply to sensors.
+	PayloadFormat string `json:"payload_format"`
+	Precision     *int   `json:"precision"`
+	ExpireAfter   *int   `json:"expire_after"`
+	// Interval in seconds between polls of a sensor.
+	Interval *int `json:"interval"`
+	// Deadband is the smallest numeric change of a sensor that is published. Unchanged states are published every
+	// polling.force_interval, like with polling.changes_only.
+	Deadband *float64 `json:"deadband"`
+	// Average publishes the moving average of this many polled numeric values of a sensor, eg. to smooth CPU usage.
+	Average int `json:"average"`
+	// Threshold publishes a threshold_crossed event, eg. for webhooks, whenever a sensor crosses it.
+	Threshold *float64 `json:"threshold"`
+	// EntityCategory is "config", "diagnostic" or "none" for the main device view.
+	EntityCategory string `json:"entity_category"`
+	// PayloadPress is the confirmation payload a button requires, eg. CONFIRM-SHUTDOWN. Other payloads are rejected.
+	PayloadPress string `json:"payload_press"`
+	// Cooldown is the seconds after running the action of a button in which it is rejected, also across restarts.
+	Cooldown int `json:"cooldown"`
+}
+
+const (
+	EntityCategoryConfig     = "config"
+	EntityCategoryDiagnostic = "diagnostic"
+	EntityCategoryNone       = "none"
+)
+
+const (
+	PayloadFormatRaw  = "raw"
+	PayloadFormatJson = "json"
+)
+
+type HeartbeatAppConfig struct {
+	Interval int  `json:"interval"`
+	Retain   bool `json:"retain"`
+}
+
+type OfflineQueueAppConfig struct {
+	Enabled bool `json:"enabled"`
+	Persist bool `json:"persist"`
+}
+
+type CommandsAppConfig struct {
+	Debounce            int  `json:"debounce"`
+	MaxActionsPerMinute int  `json:"max_actions_per_minute"`
+	IgnoreRetained      bool `json:"ignore_retained"`
+	StartupGracePeriod  int  `json:"startup_grace_period"`
+	// Qos of command buttons and their subscriptions. Nil keeps mqtt.qos.
+	Qos                *int `json:"qos"`
+	ActionTimeout      int  `json:"action_timeout"`
+	MaxParallelActions int  `json:"max_parallel_actions"`
+	// LinuxPower is how Linux powers off, reboots and suspends, eg. loginctl on systems without systemd
+	LinuxPower string `json:"linux_power"`
+	// HybridShutdown keeps Fast Startup on Windows instead of powering off fully
+	HybridShutdown bool `json:"hybrid_shutdown"`
+	// ShutdownWarning is the seconds a notification with Cancel is shown before shutdown and reboot, 0 disables it
+	ShutdownWarning int `json:"shutdown_warning"`
+	// SleepMode maps an OS, eg. linux, to how the sleep button sleeps, eg. hibernate
+	SleepMode map[string]string `json:"sleep_mode"`
+	// QuietHours are time windows in which the commands of some entities are rejected or deferred
+	QuietHours []QuietHoursAppConfig `json:"quiet_hours"`
+}
+
+// QuietHoursAppConfig holds back the commands of Entities from From to To on Days, eg. reboots during working hours.
+type QuietHoursAppConfig struct {
+	// From and To are local times like 08:00. A window ending before it starts runs over midnight.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Days the window starts on as the day of week field of a cron expression, eg. mon-fri. Empty means every day.
+	Days string `json:"days"`
+	// Entities are named like the entity of schedules, eg. shutdown. Empty means shutdown and reboot.
+	Entities []string `json:"entities"`
+	// Mode is QuietHoursReject or QuietHoursDefer. Empty rejects.
+	Mode string `json:"mode"`
+}
+
+const (
+	QuietHoursReject = "reject"
+	// QuietHoursDefer runs the last command once the window ends
+	QuietHoursDefer = "defer"
+)
+
+// InhibitorsAppConfig decides what shutdown, reboot and sleep do while other programs block them,
+// eg. a backup holding a logind inhibitor lock.
+type InhibitorsAppConfig struct {
+	Mode string `json:"mode"`
+	// RetryInterval is the seconds between checks while an action waits for the inhibitors.
+	RetryInterval int `json:"retry_interval"`
+	// MaxWait is the seconds after which a waiting action fails.
+	MaxWait int `json:"max_wait"`
+}
+
+const (
+	InhibitorsIgnore = "ignore"
+	InhibitorsRetry  = "retry"
+	InhibitorsAbort  = "abort"
+)
+
+// AuditAppConfig records every executed command to a file and <device_name>/audit/last.
+type AuditAppConfig struct {
+	Enabled bool `json:"enabled"`
+	// File holds a JSON line per command, relative paths are next to the config file
+	File string `json:"file"`
+}
+
+// ScheduleAppConfig runs the action of an entity or publishes a message at the times of a cron expression.
+// Either Entity or Topic is set.
+type ScheduleAppConfig struct {
+	Cron string `json:"cron"`
+	// Entity names the entity whose action runs, eg. shutdown, like the keys of Entities.
+	Entity string `json:"entity"`
+	// Topic is published to instead of running an action.
+	Topic string `json:"topic"`
+	// Payload of the command or message. Defaults to the command payload of the entity.
+	Payload string `json:"payload"`
+	Retain  bool   `json:"retain"`
+	// Wake wakes the PC from sleep for each run. Only supported on macOS.
+	Wake bool `json:"wake"`
+}
+
+// WebhookAppConfig posts a JSON message to Url on every event of Events.
+type WebhookAppConfig struct {
+	Url string `json:"url"`
+	// Events are kinds of the event bus. Empty uses DefaultWebhookEvents.
+	Events  []string          `json:"events"`
+	Headers map[string]string `json:"headers"`
+}
+
+// The event kinds of the events package, which webhooks can receive
+const (
+	WebhookEventStateUpdated     = "state_updated"
+	WebhookEventCommandReceived  = "command_received"
+	WebhookEventCommandFinished  = "command_finished"
+	WebhookEventThresholdCrossed = "threshold_crossed"
+	WebhookEventConnectionLost   = "connection_lost"
+	WebhookEventSessionChanged   = "session_changed"
+	WebhookEventCommandRejected  = "command_rejected"
+)
+
+var (
+	WebhookEvents        = []string{WebhookEventStateUpdated, WebhookEventCommandReceived, WebhookEventCommandFinished, WebhookEventThresholdCrossed, WebhookEventConnectionLost, WebhookEventSessionChanged, WebhookEventCommandRejected}
+	DefaultWebhookEvents = []string{WebhookEventCommandFinished, WebhookEventThresholdCrossed, WebhookEventConnectionLost}
+)
+
+// ActionAppConfig is a button running a script configured by the user.
+type ActionAppConfig struct {
+	// Name is shown in Home Assistant. Defaults to the key with spaces for underscores.
+	Name string `json:"name"`
+	Icon string `json:"icon"`
+	// AppleScript is run with osascript. Only supported on macOS.
+	AppleScript string `json:"applescript"`
+	// Command is run with sh, or cmd.exe on Windows. Either AppleScript or Command is set.
+	Command string `json:"command"`
+	// RunAs is the user running the action, or "console" for the user logged in on the screen. Empty runs
+	// it as pc2mqtt's own user. Windows only supports "console".
+	RunAs string `json:"run_as"`
+}
+
+// HostAppConfig is another machine controlled over SSH, which doesn't run pc2mqtt itself.
+type HostAppConfig struct {
+	// Name shown in Home Assistant. Defaults to the key of the host.
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Port of the SSH server. 0 uses 22.
+	Port int    `json:"port"`
+	User string `json:"user"`
+	// SshKey is the private key file. Empty uses the keys and config of ssh.
+	SshKey string `json:"ssh_key"`
+	// Os of the host decides the shutdown, reboot and uptime commands: linux, windows, darwin or freebsd. Empty means linux.
+	Os string `json:"os"`
+	// Mac address for Wake-on-LAN. Empty omits the wake button.
+	Mac string `json:"mac"`
+	// Broadcast address the Wake-on-LAN packet is sent to. Empty uses 255.255.255.255.
+	Broadcast string `json:"broadcast"`
+}
+
+// DesktopAppConfig reads the graphical session of a Linux desktop.
+type DesktopAppConfig struct {
+	Enabled bool `json:"enabled"`
+	// Backend is auto, x11 or wayland.
+	Backend string `json:"backend"`
+	// Interval in seconds between reads of the lock and display state.
+	Interval int `json:"interval"`
+}
+
+// MessageAppConfig sets up the full-screen messages.
+type MessageAppConfig struct {
+	// Duration is the seconds a message is shown unless its payload sets one, 0 until it is dismissed.
+	Duration int `json:"duration"`
+}
+
+// NetworkAppConfig publishes the throughput of the network interfaces.
+type NetworkAppConfig struct {
+	Enabled bool `json:"enabled"`
+	// Include and Exclude are regular expressions matching whole interface names, eg. "eth0" or "veth.*".
+	// Empty Include takes every interface.
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	// Aggregate sums the included physical interfaces into a single received and a single sent sensor.
+	Aggregate bool `json:"aggregate"`
+}
+
+type DiagnosticsAppConfig struct {
+	Enabled  bool `json:"enabled"`
+	Interval int  `json:"interval"`
+	// Runtime adds sensors about the memory, goroutines and garbage collection of pc2mqtt.
+	Runtime bool `json:"runtime"`
+}
+
+// PollingAppConfig tunes the scheduler reading the sensors.
+type PollingAppConfig struct {
+	Workers    int `json:"workers"`
+	Timeout    int `json:"timeout"`
+	Jitter     int `json:"jitter"`
+	MaxBackoff int `json:"max_backoff"`
+	// ChangesOnly publishes sensor states only when they changed, and unchanged ones every ForceInterval seconds.
+	ChangesOnly   bool `json:"changes_only"`
+	ForceInterval int  `json:"force_interval"`
+}
+
+type LoggingAppConfig struct {
+	Level   string            `json:"level"`
+	Format  string            `json:"format"`
+	Modules map[string]string `json:"modules"`
+	// MqttLevel mirrors the log at this level or above to <device_name>/log. Empty disables it.
+	MqttLevel string `json:"mqtt_level"`
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+const (
+	LogModuleBridge   = "bridge"
+	LogModuleCommands = "commands"
+	LogModuleQueue    = "queue"
+	LogModuleHealth   = "health"
+	LogModuleEntities = "entities"
+	LogModuleMqtt     = "mqtt"
+)
+
+var LogModules = []string{LogModuleBridge, LogModuleCommands, LogModuleQueue, LogModuleHealth, LogModuleEntities, LogModuleMqtt}
+
+// HomieAppConfig publishes the entities following the Homie 4.0 convention, eg. for openHAB.
+type HomieAppConfig struct {
+	Enabled   bool   `json:"enabled"`
+	BaseTopic string `json:"base_topic"`
+}
+
+type HealthAppConfig struct {
+	Listen        string `json:"listen"`
+	MaxPublishAge int    `json:"max_publish_age"`
 }
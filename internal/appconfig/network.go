@@ -0,0 +1,43 @@
+package appconfig
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Matches reports whether the interface name is included and not excluded.
+func (conf NetworkAppConfig) Matches(name string) bool {
+	if len(conf.Include) > 0 && !anyInterfacePattern(conf.Include, name) {
+		return false
+	}
+	return !anyInterfacePattern(conf.Exclude, name)
+}
+
+func anyInterfacePattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Validated when the config was loaded
+		if matched, err := interfacePattern(pattern); err == nil && matched.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfacePattern compiles pattern to match whole interface names, so "eth0" doesn't match "veth0".
+func interfacePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+func validateNetwork(conf NetworkAppConfig) error {
+	for _, list := range []struct {
+		name     string
+		patterns []string
+	}{{"network.include", conf.Include}, {"network.exclude", conf.Exclude}} {
+		for _, pattern := range list.patterns {
+			if _, err := interfacePattern(pattern); err != nil {
+				return fmt.Errorf("Invalid %s %q. Use a regular expression: %w", list.name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package appconfig
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/cron"
+)
+
+// quietHoursEntities are held back by windows without entities
+var quietHoursEntities = []string{"shutdown", "reboot"}
+
+// EntityNames returns the names of the entities whose commands the window holds back.
+func (conf QuietHoursAppConfig) EntityNames() []string {
+	if len(conf.Entities) == 0 {
+		return quietHoursEntities
+	}
+	return conf.Entities
+}
+
+// End returns the end of the window now is in, false if now is outside of it. Windows starting the day
+// before may still last.
+func (conf QuietHoursAppConfig) End(now time.Time) (time.Time, bool) {
+	// Validated when the config was loaded
+	from, _ := time.Parse(quietHoursLayout, conf.From)
+	to, _ := time.Parse(quietHoursLayout, conf.To)
+	days, _ := quietHoursDays(conf.Days)
+
+	for _, offset := range []int{0, -1} {
+		day := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, now.Location())
+		if !days.Matches(day) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, now.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, now.Location())
+		if !end.After(start) {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+const quietHoursLayout = "15:04"
+
+// quietHoursDays parses the day of week field of a cron expression, every day if empty.
+func quietHoursDays(days string) (cron.Schedule, error) {
+	if days == "" {
+		days = "*"
+	}
+	return cron.Parse("* * * * " + days)
+}
+
+func validateQuietHours(name string, conf QuietHoursAppConfig) error {
+	for _, field := range []struct{ name, value string }{{"from", conf.From}, {"to", conf.To}} {
+		if _, err := time.Parse(quietHoursLayout, field.value); err != nil {
+			return fmt.Errorf("Invalid %s.%s %q. Use a time like 08:00", name, field.name, field.value)
+		}
+	}
+	if conf.From == conf.To {
+		return errors.New("Invalid " + name + ". from and to must differ")
+	}
+	if _, err := quietHoursDays(conf.Days); err != nil {
+		return fmt.Errorf("Invalid %s.days %q. Use days of week like mon-fri or sat,sun", name, conf.Days)
+	}
+	switch conf.Mode {
+	case "", QuietHoursReject, QuietHoursDefer:
+	default:
+		return errors.New("Invalid " + name + ".mode " + conf.Mode + ". Use " + QuietHoursReject + " or " + QuietHoursDefer)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package appconfig
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EncryptedPrefix marks config values encrypted by pc2mqtt credentials encrypt. The rest is the
+// base64 of salt, nonce and AES-256-GCM ciphertext.
+const EncryptedPrefix = "enc:"
+
+// KeyFileName is the key file next to the config, holding the passphrase or generated key of the
+// encrypted values.
+const KeyFileName = "pc2mqtt.key"
+const keyFileMode = 0600
+
+const (
+	secretSaltSize = 16
+	// Recommended by OWASP for PBKDF2-HMAC-SHA256
+	secretKeyIterations = 600000
+)
+
+// PassphraseEnv is the environment variable holding the passphrase of the encrypted values.
+const PassphraseEnv = "PC2MQTT_PASSPHRASE"
+
+var ErrNoSecretKey = errors.New("The config has encrypted values, but neither PC2MQTT_PASSPHRASE nor a key file is set")
+
+var (
+	passphraseOnce sync.Once
+	passphrase     string
+
+	// derivedKeys caches the AES keys derived from the secret key by salt, deriving one takes a while
+	derivedKeysMu sync.Mutex
+	derivedKeys   = make(map[string][]byte)
+)
+
+// Passphrase returns the passphrase of PC2MQTT_PASSPHRASE. The first call removes it from the environment,
+// so the commands pc2mqtt runs don't inherit it. Call it at startup.
+func Passphrase() string {
+	passphraseOnce.Do(func() {
+		passphrase = os.Getenv(PassphraseEnv)
+		os.Unsetenv(PassphraseEnv)
+	})
+	return passphrase
+}
+
+// SetPassphrase replaces the passphrase, eg. by one entered at a prompt.
+func SetPassphrase(value string) {
+	Passphrase()
+	passphrase = value
+}
+
+// KeyFilePath returns the key file of the loaded config, PC2MQTT_KEY_FILE or pc2mqtt.key in the config directory.
+func KeyFilePath() string {
+	if path := os.Getenv("PC2MQTT_KEY_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(ConfigDir(), KeyFileName)
+}
+
+// SecretKey returns the passphrase of PC2MQTT_PASSPHRASE or else the content of the key file.
+func SecretKey() (string, error) {
+	if passphrase := Passphrase(); passphrase != "" {
+		return passphrase, nil
+	}
+	buf, err := os.ReadFile(KeyFilePath())
+	if os.IsNotExist(err) {
+		return "", ErrNoSecretKey
+	}
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(buf))
+	if key == "" {
+		return "", errors.New("Key file " + KeyFilePath() + " is empty")
+	}
+	return key, nil
+}
+
+// WriteKeyFile stores key in the key file, readable only by its owner.
+func WriteKeyFile(key string) error {
+	return os.WriteFile(KeyFilePath(), []byte(key+"\n"), keyFileMode)
+}
+
+// GenerateKey returns a random key for the key file.
+func GenerateKey() string {
+	return base64.StdEncoding.EncodeToString(randomBytes(32))
+}
+
+// randomBytes returns n random bytes. crypto/rand never fails since Go 1.24.
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return buf
+}
+
+// EncryptSecret encrypts value with key into a config value starting with EncryptedPrefix.
+func EncryptSecret(key string, value string) (string, error) {
+	salt := randomBytes(secretSaltSize)
+	gcm, err := secretCipher(key, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := randomBytes(gcm.NonceSize())
+
+	sealed := gcm.Seal(append(salt, nonce...), nonce, []byte(value), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(key string, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil || len(sealed) < secretSaltSize {
+		return "", errors.New("Invalid encrypted value")
+	}
+	salt, sealed := sealed[:secretSaltSize], sealed[secretSaltSize:]
+	gcm, err := secretCipher(key, salt)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("Invalid encrypted value")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("Decrypting a config value failed, wrong passphrase or key file")
+	}
+	return string(plain), nil
+}
+
+// secretCipher derives the AES-256 key of a value from key and its salt.
+func secretCipher(key string, salt []byte) (cipher.AEAD, error) {
+	derived, err := deriveKey(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey returns the AES-256 key derived from key and salt, which is cached for reloads of the config.
+func deriveKey(key string, salt []byte) ([]byte, error) {
+	hash := sha256.Sum256([]byte(key))
+	cacheKey := string(hash[:]) + string(salt)
+
+	derivedKeysMu.Lock()
+	defer derivedKeysMu.Unlock()
+	if derived, ok := derivedKeys[cacheKey]; ok {
+		return derived, nil
+	}
+	derived, err := pbkdf2.Key(sha256.New, key, salt, secretKeyIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys[cacheKey] = derived
+	return derived, nil
+}
+
+// decryptValues replaces every encrypted string value of a decoded config by its plain text, which
+// only stays in memory. The key is read on the first encrypted value.
+func decryptValues(value any) (any, error) {
+	var key string
+	var decrypt func(value any) (any, error)
+	decrypt = func(value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			if !strings.HasPrefix(v, EncryptedPrefix) {
+				return v, nil
+			}
+			if key == "" {
+				var err error
+				if key, err = SecretKey(); err != nil {
+					return nil, err
+				}
+			}
+			return decryptSecret(key, v)
+		case map[string]any:
+			for name, item := range v {
+				plain, err := decrypt(item)
+				if err != nil {
+					return nil, err
+				}
+				v[name] = plain
+			}
+		case []any:
+			for i, item := range v {
+				plain, err := decrypt(item)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = plain
+			}
+		}
+		return value, nil
+	}
+	return decrypt(value)
+}
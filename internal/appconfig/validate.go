@@ -0,0 +1,436 @@
+package appconfig
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/cron"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+func validateConfig(conf AppConfig) error {
+	if conf.DeviceName == "" {
+		return errors.New("Invalid device_name " + conf.DeviceDisplayName + ". Must contain letters or digits")
+	}
+	// The device name is a topic level, a slash would split it and wildcards can't be published to
+	if strings.ContainsAny(conf.DeviceName, "/+#") {
+		return errors.New("Invalid device_name " + conf.DeviceDisplayName + ". Must not contain /, + or #, enable slugify")
+	}
+
+	switch conf.Mqtt.Transport {
+	case TransportTcp, TransportWebsocket:
+	default:
+		return errors.New("Invalid mqtt.transport " + conf.Mqtt.Transport + ". Use " + TransportTcp + " or " + TransportWebsocket)
+	}
+
+	switch conf.Mqtt.DiscoveryMode {
+	case DiscoveryModeEntity, DiscoveryModeDevice, DiscoveryModeNone:
+	default:
+		return errors.New("Invalid mqtt.discovery_mode " + conf.Mqtt.DiscoveryMode + ". Use " + DiscoveryModeEntity + ", " + DiscoveryModeDevice + " or " + DiscoveryModeNone)
+	}
+
+	if conf.Homie.BaseTopic == "" || strings.ContainsAny(conf.Homie.BaseTopic, "+#") {
+		return errors.New("Invalid homie.base_topic " + conf.Homie.BaseTopic + ". Must not be empty or contain wildcards")
+	}
+
+	timings := map[string]int{
+		"mqtt.keep_alive":             conf.Mqtt.KeepAlive,
+		"mqtt.ping_timeout":           conf.Mqtt.PingTimeout,
+		"mqtt.connect_timeout":        conf.Mqtt.ConnectTimeout,
+		"mqtt.connect_retry_interval": conf.Mqtt.ConnectRetryInterval,
+		"mqtt.max_reconnect_interval": conf.Mqtt.MaxReconnectInterval,
+	}
+	switch conf.Mqtt.ReconnectBackoff {
+	case ReconnectBackoffExponential, ReconnectBackoffFixed:
+	default:
+		return errors.New("Invalid mqtt.reconnect_backoff " + conf.Mqtt.ReconnectBackoff + ". Use " + ReconnectBackoffExponential + " or " + ReconnectBackoffFixed)
+	}
+	if conf.Mqtt.ReconnectJitter < 0 || conf.Mqtt.ReconnectJitter > 100 {
+		return errors.New("Invalid mqtt.reconnect_jitter. Must be between 0 and 100")
+	}
+	if conf.Mqtt.WaitForNetwork < 0 {
+		return errors.New("Invalid mqtt.wait_for_network. Must not be negative")
+	}
+	for name, seconds := range timings {
+		if seconds <= 0 {
+			return fmt.Errorf("Invalid %s %d. Must be at least 1 second", name, seconds)
+		}
+	}
+
+	if err := validateBrokerUrls(conf.Mqtt); err != nil {
+		return err
+	}
+
+	if conf.ConfigurationUrl != "" {
+		u, err := url.Parse(conf.ConfigurationUrl)
+		if err != nil || !slices.Contains([]string{"http", "https", "homeassistant"}, u.Scheme) {
+			return fmt.Errorf("Invalid configuration_url %q. Use an http, https or homeassistant URL", conf.ConfigurationUrl)
+		}
+	}
+
+	switch conf.UnitSystem {
+	case UnitSystemBinary, UnitSystemSI:
+	default:
+		return errors.New("Invalid unit_system " + conf.UnitSystem + ". Use " + UnitSystemBinary + " or " + UnitSystemSI)
+	}
+
+	if err := validateQos("mqtt.qos", conf.Mqtt.Qos); err != nil {
+		return err
+	}
+	for name, entity := range conf.Entities {
+		if entity.Qos != nil {
+			if err := validateQos("entities."+name+".qos", *entity.Qos); err != nil {
+				return err
+			}
+		}
+
+		switch entity.PayloadFormat {
+		case "", PayloadFormatRaw, PayloadFormatJson:
+		default:
+			return errors.New("Invalid entities." + name + ".payload_format " + entity.PayloadFormat + ". Use " + PayloadFormatRaw + " or " + PayloadFormatJson)
+		}
+		if entity.Precision != nil && *entity.Precision < 0 {
+			return errors.New("Invalid entities." + name + ".precision. Must not be negative")
+		}
+		if entity.ExpireAfter != nil && *entity.ExpireAfter < 0 {
+			return errors.New("Invalid entities." + name + ".expire_after. Must not be negative")
+		}
+		if entity.Interval != nil && *entity.Interval < 0 {
+			return errors.New("Invalid entities." + name + ".interval. Must not be negative")
+		}
+		if entity.Deadband != nil && *entity.Deadband < 0 {
+			return errors.New("Invalid entities." + name + ".deadband. Must not be negative")
+		}
+		if entity.Average < 0 {
+			return errors.New("Invalid entities." + name + ".average. Must not be negative")
+		}
+		if entity.Cooldown < 0 {
+			return errors.New("Invalid entities." + name + ".cooldown. Must not be negative")
+		}
+
+		switch entity.EntityCategory {
+		case "", EntityCategoryConfig, EntityCategoryDiagnostic, EntityCategoryNone:
+		default:
+			return errors.New("Invalid entities." + name + ".entity_category " + entity.EntityCategory + ". Use " + EntityCategoryConfig + ", " + EntityCategoryDiagnostic + " or " + EntityCategoryNone)
+		}
+	}
+
+	if conf.Commands.Qos != nil {
+		if err := validateQos("commands.qos", *conf.Commands.Qos); err != nil {
+			return err
+		}
+	}
+
+	if err := validateInhibitors(conf.Inhibitors, conf.Commands); err != nil {
+		return err
+	}
+
+	for name, schedule := range conf.Schedules {
+		if err := validateSchedule(name, schedule); err != nil {
+			return err
+		}
+	}
+
+	for name, action := range conf.Actions {
+		if err := validateAction(name, action); err != nil {
+			return err
+		}
+	}
+
+	if err := validateDesktop(conf.Desktop); err != nil {
+		return err
+	}
+	if conf.Message.Duration < 0 {
+		return errors.New("Invalid message.duration. Must not be negative")
+	}
+	if err := validateNetwork(conf.Network); err != nil {
+		return err
+	}
+
+	for name, host := range conf.Hosts {
+		if err := validateHost(name, host); err != nil {
+			return err
+		}
+	}
+
+	for i, webhook := range conf.Webhooks {
+		if err := validateWebhook(fmt.Sprintf("webhooks[%d]", i), webhook); err != nil {
+			return err
+		}
+	}
+
+	if err := validateLogging(conf.Logging); err != nil {
+		return err
+	}
+
+	switch conf.OnPanic {
+	case OnPanicExit, OnPanicRestart:
+	default:
+		return errors.New("Invalid on_panic " + conf.OnPanic + ". Use " + OnPanicExit + " or " + OnPanicRestart)
+	}
+
+	if conf.Health.MaxPublishAge < 0 {
+		return errors.New("Invalid health.max_publish_age. Must not be negative")
+	}
+
+	if conf.Commands.Debounce < 0 {
+		return errors.New("Invalid commands.debounce. Must not be negative")
+	}
+	if conf.Commands.StartupGracePeriod < 0 {
+		return errors.New("Invalid commands.startup_grace_period. Must not be negative")
+	}
+	if conf.Commands.ActionTimeout < 0 {
+		return errors.New("Invalid commands.action_timeout. Must not be negative")
+	}
+	if conf.Commands.MaxParallelActions < 1 {
+		return errors.New("Invalid commands.max_parallel_actions. Must be at least 1")
+	}
+	if conf.Audit.Enabled && conf.Audit.File == "" {
+		return errors.New("Invalid audit.file. Set a path or disable audit")
+	}
+	if conf.Commands.ShutdownWarning < 0 {
+		return errors.New("Invalid commands.shutdown_warning. Must not be negative")
+	}
+	// The action would be cancelled during its warning, in any inhibitors mode
+	if conf.Commands.ActionTimeout > 0 && conf.Commands.ShutdownWarning >= conf.Commands.ActionTimeout {
+		return errors.New("Invalid commands.shutdown_warning. Must be shorter than commands.action_timeout")
+	}
+	switch conf.Commands.LinuxPower {
+	case system.LinuxPowerAuto, system.LinuxPowerSystemctl, system.LinuxPowerLoginctl, system.LinuxPowerShutdown, system.LinuxPowerSysrq:
+	default:
+		return errors.New("Invalid commands.linux_power " + conf.Commands.LinuxPower + ". Use auto, systemctl, loginctl, shutdown or sysrq")
+	}
+	for goos, mode := range conf.Commands.SleepMode {
+		switch goos {
+		case system.LINUX, system.WINDOWS, system.MACOS, system.FREEBSD:
+		default:
+			return errors.New("Invalid commands.sleep_mode OS " + goos + ". Use linux, windows, darwin or freebsd")
+		}
+		switch mode {
+		case system.SleepModeSuspend, system.SleepModeHibernate, system.SleepModeHybrid:
+		default:
+			return errors.New("Invalid commands.sleep_mode " + mode + " for " + goos + ". Use suspend, hibernate or hybrid-sleep")
+		}
+	}
+	for i, window := range conf.Commands.QuietHours {
+		if err := validateQuietHours(fmt.Sprintf("commands.quiet_hours[%d]", i), window); err != nil {
+			return err
+		}
+	}
+
+	if conf.Polling.Workers < 1 {
+		return errors.New("Invalid polling.workers. Must be at least 1")
+	}
+	if conf.Polling.Timeout < 1 {
+		return errors.New("Invalid polling.timeout. Must be at least 1")
+	}
+	if conf.Polling.Jitter < 0 || conf.Polling.Jitter > 100 {
+		return errors.New("Invalid polling.jitter. Must be between 0 and 100")
+	}
+	if conf.Polling.MaxBackoff < 0 {
+		return errors.New("Invalid polling.max_backoff. Must not be negative")
+	}
+	if conf.Polling.ForceInterval < 0 {
+		return errors.New("Invalid polling.force_interval. Must not be negative")
+	}
+
+	return nil
+}
+
+func validateInhibitors(conf InhibitorsAppConfig, commands CommandsAppConfig) error {
+	switch conf.Mode {
+	case InhibitorsIgnore, InhibitorsRetry, InhibitorsAbort:
+	default:
+		return errors.New("Invalid inhibitors.mode " + conf.Mode + ". Use " + InhibitorsIgnore + ", " + InhibitorsRetry + " or " + InhibitorsAbort)
+	}
+	if conf.RetryInterval < 1 {
+		return errors.New("Invalid inhibitors.retry_interval. Must be at least 1")
+	}
+	if conf.MaxWait < 0 {
+		return errors.New("Invalid inhibitors.max_wait. Must not be negative")
+	}
+	// The action would be reported as failed while it still waits
+	if conf.Mode == InhibitorsRetry && commands.ActionTimeout > 0 && conf.MaxWait+commands.ShutdownWarning >= commands.ActionTimeout {
+		return errors.New("Invalid inhibitors.max_wait. Together with commands.shutdown_warning must be shorter than commands.action_timeout")
+	}
+	return nil
+}
+
+// namePattern matches schedule, action and host names, which become part of topics and unique ids
+var namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func validateSchedule(name string, schedule ScheduleAppConfig) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("Invalid schedules %q. Use lowercase letters, digits and underscores", name)
+	}
+	// The error quotes the expression
+	if _, err := cron.Parse(schedule.Cron); err != nil {
+		return err
+	}
+	if (schedule.Entity == "") == (schedule.Topic == "") {
+		return errors.New("Invalid schedules." + name + ". Set either entity or topic")
+	}
+	if schedule.Topic != "" && strings.ContainsAny(schedule.Topic, "+#") {
+		return errors.New("Invalid schedules." + name + ".topic " + schedule.Topic + ". Must not contain wildcards")
+	}
+	if schedule.Wake && runtime.GOOS != system.MACOS {
+		return errors.New("Invalid schedules." + name + ".wake. Waking from sleep is only supported on macOS")
+	}
+	return nil
+}
+
+func validateAction(name string, action ActionAppConfig) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("Invalid actions %q. Use lowercase letters, digits and underscores", name)
+	}
+	hasAppleScript, hasCommand := strings.TrimSpace(action.AppleScript) != "", strings.TrimSpace(action.Command) != ""
+	if hasAppleScript == hasCommand {
+		return errors.New("Invalid actions." + name + ". Set either applescript or command")
+	}
+	if hasAppleScript && runtime.GOOS != system.MACOS {
+		return errors.New("Invalid actions." + name + ".applescript. AppleScript is only supported on macOS")
+	}
+	switch {
+	case action.RunAs == "":
+	case runtime.GOOS == system.WINDOWS && action.RunAs != system.RunAsConsole:
+		return errors.New("Invalid actions." + name + ".run_as " + action.RunAs + ". Windows only supports " + system.RunAsConsole)
+	case action.RunAs == system.RunAsConsole && runtime.GOOS != system.WINDOWS && runtime.GOOS != system.LINUX && runtime.GOOS != system.MACOS:
+		return errors.New("Invalid actions." + name + ".run_as. " + runtime.GOOS + " has no console user")
+	case action.RunAs != system.RunAsConsole && runtime.GOOS != system.WINDOWS && os.Getuid() != 0:
+		// Switching users needs root, the service units don't allow sudo to gain privileges
+		account, err := user.Lookup(action.RunAs)
+		if err != nil {
+			return errors.New("Invalid actions." + name + ".run_as " + action.RunAs + ". Unknown user")
+		}
+		if account.Uid != strconv.Itoa(os.Getuid()) {
+			return errors.New("Invalid actions." + name + ".run_as " + action.RunAs + ". Running as another user needs pc2mqtt to run as root")
+		}
+	}
+	return nil
+}
+
+func validateDesktop(conf DesktopAppConfig) error {
+	switch conf.Backend {
+	case system.DesktopAuto, system.DesktopX11, system.DesktopWayland:
+	default:
+		return errors.New("Invalid desktop.backend " + conf.Backend + ". Use " + system.DesktopAuto + ", " + system.DesktopX11 + " or " + system.DesktopWayland)
+	}
+	if conf.Interval < 1 {
+		return errors.New("Invalid desktop.interval. Must be at least 1")
+	}
+	if conf.Enabled && runtime.GOOS != system.LINUX {
+		return errors.New("Invalid desktop.enabled. Reading the desktop is only supported on Linux")
+	}
+	return nil
+}
+
+func validateHost(name string, host HostAppConfig) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("Invalid hosts %q. Use lowercase letters, digits and underscores", name)
+	}
+	// A leading hyphen would be read as an ssh option
+	if host.Address == "" || strings.ContainsAny(host.Address, " @") || strings.HasPrefix(host.Address, "-") {
+		return fmt.Errorf("Invalid hosts.%s.address %q. Use a host name or IP address, the user goes to user", name, host.Address)
+	}
+	if strings.HasPrefix(host.User, "-") || strings.ContainsAny(host.User, " @") {
+		return fmt.Errorf("Invalid hosts.%s.user %q. Must not start with a hyphen or contain spaces or @", name, host.User)
+	}
+	if host.Port < 0 || host.Port > 65535 {
+		return fmt.Errorf("Invalid hosts.%s.port %d. Must be between 0 and 65535", name, host.Port)
+	}
+	switch host.Os {
+	case "", system.LINUX, system.WINDOWS, system.MACOS, system.FREEBSD:
+	default:
+		return errors.New("Invalid hosts." + name + ".os " + host.Os + ". Use " + system.LINUX + ", " + system.WINDOWS + ", " + system.MACOS + " or " + system.FREEBSD)
+	}
+	if host.Mac != "" {
+		if _, err := net.ParseMAC(host.Mac); err != nil {
+			return fmt.Errorf("Invalid hosts.%s.mac %q. Use a MAC address like 00:11:22:33:44:55", name, host.Mac)
+		}
+	}
+	return nil
+}
+
+func validateWebhook(name string, webhook WebhookAppConfig) error {
+	u, err := url.Parse(webhook.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid %s.url %q. Use an http or https URL", name, webhook.Url)
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return fmt.Errorf("Invalid %s.events %q. Use %s", name, event, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+func validateLogging(conf LoggingAppConfig) error {
+	switch conf.Format {
+	case LogFormatText, LogFormatJson:
+	default:
+		return errors.New("Invalid logging.format " + conf.Format + ". Use " + LogFormatText + " or " + LogFormatJson)
+	}
+
+	if err := validateLogLevel("logging.level", conf.Level); err != nil {
+		return err
+	}
+	for module, level := range conf.Modules {
+		if !slices.Contains(LogModules, module) {
+			return fmt.Errorf("Invalid logging.modules %q. Use %s", module, strings.Join(LogModules, ", "))
+		}
+		if err := validateLogLevel("logging.modules."+module, level); err != nil {
+			return err
+		}
+	}
+	if conf.MqttLevel != "" {
+		return validateLogLevel("logging.mqtt_level", conf.MqttLevel)
+	}
+	return nil
+}
+
+func validateLogLevel(name string, level string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("Invalid %s %q. Use debug, info, warn or error", name, level)
+	}
+	return nil
+}
+
+func validateQos(name string, qos int) error {
+	if qos < 0 || qos > 2 {
+		return fmt.Errorf("Invalid %s %d. Use 0, 1 or 2", name, qos)
+	}
+	return nil
+}
+
+func validateBrokerUrls(conf MqttAppConfig) error {
+	websocketUrls := 0
+	for _, brokerUrl := range conf.BrokerUrls() {
+		u, err := url.Parse(brokerUrl)
+		if err != nil {
+			return fmt.Errorf("Invalid mqtt.url %q: %v", brokerUrl, err)
+		}
+		if !slices.Contains(brokerSchemes, u.Scheme) {
+			return fmt.Errorf("Invalid mqtt.url %q. Supported schemes are %s", brokerUrl, strings.Join(brokerSchemes, ", "))
+		}
+		if slices.Contains(websocketSchemes, u.Scheme) {
+			websocketUrls++
+		}
+	}
+
+	if conf.Proxy != "" && websocketUrls > 0 && websocketUrls < len(conf.BrokerUrls()) {
+		return errors.New("mqtt.proxy can't be used with a mix of websocket and tcp brokers in mqtt.url")
+	}
+
+	return nil
+}
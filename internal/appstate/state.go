@@ -0,0 +1,187 @@
+package appstate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+const stateFileName = "pc2mqtt-state.json"
+const stateFileMode = 0644
+
+// State is persisted next to the config file and survives restarts.
+type State struct {
+	// RetainedTopics lists every topic pc2mqtt ever published retained messages to.
+	RetainedTopics []string `json:"retained_topics"`
+	// PendingMessages were queued while the broker was unreachable.
+	PendingMessages []PendingMessage `json:"pending_messages,omitempty"`
+	// LastExecutions are the times the actions with a cooldown last ran, keyed by unique id.
+	LastExecutions map[string]time.Time `json:"last_executions,omitempty"`
+	// DiscoveryHashes are the hashes of the discovery configs last published to DiscoveryBroker, keyed by topic.
+	DiscoveryHashes map[string]string `json:"discovery_hashes,omitempty"`
+	DiscoveryBroker string            `json:"discovery_broker,omitempty"`
+}
+
+type PendingMessage struct {
+	Topic   string `json:"topic"`
+	Qos     byte   `json:"qos"`
+	Retain  bool   `json:"retain"`
+	Payload string `json:"payload"`
+}
+
+var mutex sync.Mutex
+
+// path replaces the state file next to the config when set.
+var path string
+
+// SetPath keeps the state in file instead of next to the config, eg. a temporary file that leaves the real
+// state alone. Empty uses the state file next to the config again.
+func SetPath(file string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	path = file
+}
+
+func statePath() string {
+	if path != "" {
+		return path
+	}
+	return filepath.Join(appconfig.ConfigDir(), stateFileName)
+}
+
+func load() (State, error) {
+	var state State
+	buf, err := os.ReadFile(statePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(buf, &state)
+	return state, err
+}
+
+func save(state State) error {
+	buf, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(statePath(), buf, stateFileMode)
+}
+
+// update loads the state, applies fn and saves the result.
+func update(fn func(state *State)) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state, err := load()
+	if err != nil {
+		return err
+	}
+
+	fn(&state)
+	return save(state)
+}
+
+// RecordRetainedTopics adds topics to the retained topics known from previous runs.
+func RecordRetainedTopics(topics []string) error {
+	return update(func(state *State) {
+		for _, topic := range topics {
+			if !slices.Contains(state.RetainedTopics, topic) {
+				state.RetainedTopics = append(state.RetainedTopics, topic)
+			}
+		}
+		slices.Sort(state.RetainedTopics)
+	})
+}
+
+// RetainedTopics returns every retained topic recorded so far.
+func RetainedTopics() ([]string, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state, err := load()
+	return state.RetainedTopics, err
+}
+
+// ClearRetainedTopics forgets all recorded retained topics and the discovery configs published to them.
+func ClearRetainedTopics() error {
+	return update(func(state *State) {
+		state.RetainedTopics = nil
+		state.DiscoveryHashes = nil
+		state.DiscoveryBroker = ""
+	})
+}
+
+// SavePendingMessages replaces the persisted offline queue.
+func SavePendingMessages(messages []PendingMessage) error {
+	return update(func(state *State) {
+		state.PendingMessages = messages
+	})
+}
+
+// PendingMessages returns the persisted offline queue.
+func PendingMessages() ([]PendingMessage, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state, err := load()
+	return state.PendingMessages, err
+}
+
+// RecordExecution stores at as the last execution of the action with the unique id.
+func RecordExecution(uniqueId string, at time.Time) error {
+	return update(func(state *State) {
+		if state.LastExecutions == nil {
+			state.LastExecutions = make(map[string]time.Time)
+		}
+		state.LastExecutions[uniqueId] = at
+	})
+}
+
+// LastExecution returns the last recorded execution of the action with the unique id, zero if none.
+func LastExecution(uniqueId string) (time.Time, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state, err := load()
+	return state.LastExecutions[uniqueId], err
+}
+
+// RecordDiscoveryHashes stores the hashes of discovery configs published to broker by topic, forgetting
+// those of another broker. An empty hash forgets the topic, eg. after removing its config.
+func RecordDiscoveryHashes(broker string, hashes map[string]string) error {
+	return update(func(state *State) {
+		if state.DiscoveryHashes == nil || state.DiscoveryBroker != broker {
+			state.DiscoveryHashes = make(map[string]string)
+			state.DiscoveryBroker = broker
+		}
+		for topic, hash := range hashes {
+			if hash == "" {
+				delete(state.DiscoveryHashes, topic)
+				continue
+			}
+			state.DiscoveryHashes[topic] = hash
+		}
+	})
+}
+
+// DiscoveryHashes returns the hashes of the discovery configs last published to broker, keyed by topic.
+func DiscoveryHashes(broker string) (map[string]string, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	state, err := load()
+	if state.DiscoveryBroker != broker {
+		return nil, err
+	}
+	return state.DiscoveryHashes, err
+}
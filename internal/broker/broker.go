@@ -0,0 +1,433 @@
+// Package broker is a minimal in-memory MQTT 3.1.1 broker for simulating pc2mqtt without a real broker.
+// It keeps retained messages and wills, but no sessions, and delivers everything with QoS 0.
+package broker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/leonlatsch/pc2mqtt/internal/mqttclient"
+)
+
+// MQTT control packet types
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetPubrec      = 5
+	packetPubrel      = 6
+	packetPubcomp     = 7
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+var errMalformed = errors.New("malformed packet")
+
+// Message is a message published to the broker.
+type Message struct {
+	// ClientId of the publishing client, empty for messages injected with Publish.
+	ClientId string
+	Topic    string
+	Payload  []byte
+	Qos      byte
+	Retain   bool
+}
+
+type Broker struct {
+	listener net.Listener
+	// OnPublish is called for every published message, including wills. Set it before clients connect.
+	OnPublish func(msg Message)
+
+	mu         sync.Mutex
+	clients    map[*session]bool
+	retained   map[string]Message
+	subscribed chan struct{}
+}
+
+type session struct {
+	conn     net.Conn
+	writeMu  sync.Mutex
+	clientId string
+	filters  []string
+	will     *Message
+}
+
+// Listen starts a broker on addr, eg. 127.0.0.1:0 for a random free port.
+func Listen(addr string) (*Broker, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	broker := &Broker{
+		listener:   listener,
+		clients:    map[*session]bool{},
+		retained:   map[string]Message{},
+		subscribed: make(chan struct{}),
+	}
+	go broker.accept()
+	return broker, nil
+}
+
+// Url returns the tcp:// URL clients connect to.
+func (broker *Broker) Url() string {
+	return "tcp://" + broker.listener.Addr().String()
+}
+
+// Close stops accepting clients and disconnects the connected ones.
+func (broker *Broker) Close() error {
+	err := broker.listener.Close()
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	for client := range broker.clients {
+		client.conn.Close()
+	}
+	return err
+}
+
+// Publish delivers a message to all subscribed clients, as if a client published it.
+func (broker *Broker) Publish(topic string, payload []byte, retain bool) {
+	broker.publish(Message{Topic: topic, Payload: payload, Retain: retain})
+}
+
+// WaitSubscribed blocks until a client subscribed to a filter matching topic.
+func (broker *Broker) WaitSubscribed(ctx context.Context, topic string) error {
+	for {
+		broker.mu.Lock()
+		subscribed := broker.subscribed
+		matched := broker.hasSubscriber(topic)
+		broker.mu.Unlock()
+		if matched {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-subscribed:
+		}
+	}
+}
+
+func (broker *Broker) hasSubscriber(topic string) bool {
+	for client := range broker.clients {
+		for _, filter := range client.filters {
+			if mqttclient.MatchTopic(filter, topic) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (broker *Broker) accept() {
+	for {
+		conn, err := broker.listener.Accept()
+		if err != nil {
+			return
+		}
+		go broker.serve(conn)
+	}
+}
+
+func (broker *Broker) publish(msg Message) {
+	if broker.OnPublish != nil {
+		broker.OnPublish(msg)
+	}
+
+	broker.mu.Lock()
+	if msg.Retain {
+		if len(msg.Payload) == 0 {
+			delete(broker.retained, msg.Topic)
+		} else {
+			broker.retained[msg.Topic] = msg
+		}
+	}
+	var targets []*session
+	for client := range broker.clients {
+		for _, filter := range client.filters {
+			if mqttclient.MatchTopic(filter, msg.Topic) {
+				targets = append(targets, client)
+				break
+			}
+		}
+	}
+	broker.mu.Unlock()
+
+	for _, client := range targets {
+		client.deliver(msg, false)
+	}
+}
+
+func (broker *Broker) serve(conn net.Conn) {
+	client := &session{conn: conn}
+	reader := bufio.NewReader(conn)
+	cleanDisconnect := false
+	defer func() {
+		conn.Close()
+		broker.mu.Lock()
+		delete(broker.clients, client)
+		broker.mu.Unlock()
+		if !cleanDisconnect && client.will != nil {
+			broker.publish(*client.will)
+		}
+	}()
+
+	for {
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case packetConnect:
+			if err := client.connect(body); err != nil {
+				return
+			}
+			broker.mu.Lock()
+			broker.clients[client] = true
+			broker.mu.Unlock()
+			client.send(packetConnack<<4, []byte{0, 0})
+		case packetPublish:
+			msg, id, err := parsePublish(header, body)
+			if err != nil {
+				return
+			}
+			msg.ClientId = client.clientId
+			switch msg.Qos {
+			case 1:
+				client.send(packetPuback<<4, id)
+			case 2:
+				client.send(packetPubrec<<4, id)
+			}
+			broker.publish(msg)
+		case packetPubrel:
+			if len(body) < 2 {
+				return
+			}
+			client.send(packetPubcomp<<4, body[:2])
+		case packetSubscribe:
+			if err := broker.subscribe(client, body); err != nil {
+				return
+			}
+		case packetUnsubscribe:
+			if err := broker.unsubscribe(client, body); err != nil {
+				return
+			}
+		case packetPingreq:
+			client.send(packetPingresp<<4, nil)
+		case packetDisconnect:
+			cleanDisconnect = true
+			return
+		}
+	}
+}
+
+func (client *session) connect(body []byte) error {
+	// Protocol name, level, flags and keep alive
+	_, rest, err := readString(body)
+	if err != nil || len(rest) < 4 {
+		return errMalformed
+	}
+	flags := rest[1]
+	rest = rest[4:]
+
+	if client.clientId, rest, err = readString(rest); err != nil {
+		return err
+	}
+
+	if flags&0x04 != 0 {
+		var topic, payload string
+		if topic, rest, err = readString(rest); err != nil {
+			return err
+		}
+		if payload, _, err = readString(rest); err != nil {
+			return err
+		}
+		client.will = &Message{
+			ClientId: client.clientId,
+			Topic:    topic,
+			Payload:  []byte(payload),
+			Qos:      (flags >> 3) & 0x03,
+			Retain:   flags&0x20 != 0,
+		}
+	}
+	// Credentials are accepted without checking
+	return nil
+}
+
+func (broker *Broker) subscribe(client *session, body []byte) error {
+	if len(body) < 2 {
+		return errMalformed
+	}
+	id, rest := body[:2], body[2:]
+
+	var filters []string
+	var granted []byte
+	for len(rest) > 0 {
+		filter, remaining, err := readString(rest)
+		if err != nil || len(remaining) < 1 {
+			return errMalformed
+		}
+		filters = append(filters, filter)
+		// Everything is delivered with QoS 0
+		granted = append(granted, 0)
+		rest = remaining[1:]
+	}
+
+	broker.mu.Lock()
+	client.filters = append(client.filters, filters...)
+	var retained []Message
+	for _, msg := range broker.retained {
+		for _, filter := range filters {
+			if mqttclient.MatchTopic(filter, msg.Topic) {
+				retained = append(retained, msg)
+				break
+			}
+		}
+	}
+	close(broker.subscribed)
+	broker.subscribed = make(chan struct{})
+	broker.mu.Unlock()
+
+	client.send(packetSuback<<4, append(id, granted...))
+	for _, msg := range retained {
+		client.deliver(msg, true)
+	}
+	return nil
+}
+
+func (broker *Broker) unsubscribe(client *session, body []byte) error {
+	if len(body) < 2 {
+		return errMalformed
+	}
+	id, rest := body[:2], body[2:]
+
+	broker.mu.Lock()
+	for len(rest) > 0 {
+		filter, remaining, err := readString(rest)
+		if err != nil {
+			broker.mu.Unlock()
+			return err
+		}
+		rest = remaining
+		for i, existing := range client.filters {
+			if existing == filter {
+				client.filters = append(client.filters[:i], client.filters[i+1:]...)
+				break
+			}
+		}
+	}
+	broker.mu.Unlock()
+
+	client.send(packetUnsuback<<4, id)
+	return nil
+}
+
+func (client *session) deliver(msg Message, retained bool) {
+	body := binary.BigEndian.AppendUint16(nil, uint16(len(msg.Topic)))
+	body = append(body, msg.Topic...)
+	body = append(body, msg.Payload...)
+
+	header := byte(packetPublish << 4)
+	if retained {
+		header |= 0x01
+	}
+	client.send(header, body)
+}
+
+func (client *session) send(header byte, body []byte) {
+	packet := append([]byte{header}, encodeLength(len(body))...)
+	packet = append(packet, body...)
+
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+	client.conn.Write(packet)
+}
+
+func readPacket(reader *bufio.Reader) (byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errMalformed
+		}
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	return header, body, err
+}
+
+func parsePublish(header byte, body []byte) (Message, []byte, error) {
+	msg := Message{
+		Qos:    (header >> 1) & 0x03,
+		Retain: header&0x01 != 0,
+	}
+
+	topic, rest, err := readString(body)
+	if err != nil {
+		return msg, nil, err
+	}
+	msg.Topic = topic
+
+	var id []byte
+	if msg.Qos > 0 {
+		if len(rest) < 2 {
+			return msg, nil, errMalformed
+		}
+		id, rest = rest[:2], rest[2:]
+	}
+	msg.Payload = rest
+	return msg, id, nil
+}
+
+func encodeLength(length int) []byte {
+	var encoded []byte
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		encoded = append(encoded, digit)
+		if length == 0 {
+			return encoded
+		}
+	}
+}
+
+func readString(data []byte) (string, []byte, error) {
+	if len(data) < 2 {
+		return "", nil, errMalformed
+	}
+	length := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+length {
+		return "", nil, errMalformed
+	}
+	return string(data[2 : 2+length]), data[2+length:], nil
+}
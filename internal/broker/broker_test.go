@@ -0,0 +1,224 @@
+package broker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// startBroker starts a broker on a random port, which is closed when the test ends.
+func startBroker(t *testing.T) *Broker {
+	t.Helper()
+	broker, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+// connectClient connects a paho client to broker, which is disconnected when the test ends.
+func connectClient(t *testing.T, broker *Broker, clientId string) mqtt.Client {
+	t.Helper()
+	opts := mqtt.NewClientOptions().AddBroker(broker.Url()).SetClientID(clientId).SetAutoReconnect(false)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("Connecting failed: %v", token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+	return client
+}
+
+// subscribe subscribes client to filter and returns the received messages.
+func subscribe(t *testing.T, client mqtt.Client, filter string) <-chan mqtt.Message {
+	t.Helper()
+	received := make(chan mqtt.Message, 16)
+	token := client.Subscribe(filter, 0, func(_ mqtt.Client, msg mqtt.Message) { received <- msg })
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("Subscribing to %s failed: %v", filter, token.Error())
+	}
+	return received
+}
+
+func receive(t *testing.T, received <-chan mqtt.Message) mqtt.Message {
+	t.Helper()
+	select {
+	case msg := <-received:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("No message received")
+		return nil
+	}
+}
+
+// writePacket writes an MQTT packet with header and body to conn.
+func writePacket(t *testing.T, conn net.Conn, header byte, body []byte) {
+	t.Helper()
+	packet := append([]byte{header}, encodeLength(len(body))...)
+	if _, err := conn.Write(append(packet, body...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint16(buf, uint16(len(s))), s...)
+}
+
+func TestConnect(t *testing.T) {
+	broker := startBroker(t)
+	conn, err := net.Dial("tcp", broker.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, 0x02, 0, 60)
+	body = appendString(body, "raw")
+	writePacket(t, conn, packetConnect<<4, body)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	header, ack, err := readPacket(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != packetConnack<<4 || len(ack) != 2 || ack[1] != 0 {
+		t.Errorf("Got packet %#x %v, want CONNACK accepted", header, ack)
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	broker := startBroker(t)
+	published := make(chan Message, 16)
+	broker.OnPublish = func(msg Message) { published <- msg }
+	publisher := connectClient(t, broker, "publisher")
+	subscriber := connectClient(t, broker, "subscriber")
+	received := subscribe(t, subscriber, "pc/+/state")
+
+	publisher.Publish("pc/cpu/command", 1, false, "ignored").WaitTimeout(time.Second)
+	if token := publisher.Publish("pc/cpu/state", 1, false, "42"); !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("Publishing failed: %v", token.Error())
+	}
+
+	msg := receive(t, received)
+	if msg.Topic() != "pc/cpu/state" || string(msg.Payload()) != "42" || msg.Retained() {
+		t.Errorf("Received %s %q retained %t, want pc/cpu/state 42", msg.Topic(), msg.Payload(), msg.Retained())
+	}
+	for _, topic := range []string{"pc/cpu/command", "pc/cpu/state"} {
+		if msg := <-published; msg.Topic != topic || msg.ClientId != "publisher" || msg.Qos != 1 {
+			t.Errorf("OnPublish got %+v, want %s of publisher with QoS 1", msg, topic)
+		}
+	}
+
+	// Unsubscribed filters no longer receive messages
+	subscriber.Unsubscribe("pc/+/state").WaitTimeout(time.Second)
+	broker.Publish("pc/cpu/state", []byte("43"), false)
+	select {
+	case msg := <-received:
+		t.Errorf("Received %s after unsubscribing", msg.Topic())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRetained(t *testing.T) {
+	broker := startBroker(t)
+	broker.Publish("pc/state", []byte("online"), true)
+	broker.Publish("pc/cleared", []byte("old"), true)
+	// An empty retained message clears the retained one
+	broker.Publish("pc/cleared", nil, true)
+
+	received := subscribe(t, connectClient(t, broker, "subscriber"), "pc/#")
+	msg := receive(t, received)
+	if msg.Topic() != "pc/state" || string(msg.Payload()) != "online" || !msg.Retained() {
+		t.Errorf("Received %s %q retained %t, want the retained pc/state online", msg.Topic(), msg.Payload(), msg.Retained())
+	}
+	select {
+	case msg := <-received:
+		t.Errorf("Received %s %q, want no other retained message", msg.Topic(), msg.Payload())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLastWill(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		clean bool
+	}{
+		{"connection lost", false},
+		{"clean disconnect", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			broker := startBroker(t)
+			received := subscribe(t, connectClient(t, broker, "subscriber"), "pc/state")
+
+			opts := mqtt.NewClientOptions().AddBroker(broker.Url()).SetClientID("pc").SetAutoReconnect(false).
+				SetWill("pc/state", "offline", 1, true)
+			client := mqtt.NewClient(opts)
+			if token := client.Connect(); !token.WaitTimeout(time.Second) || token.Error() != nil {
+				t.Fatalf("Connecting failed: %v", token.Error())
+			}
+
+			if test.clean {
+				// The quiesce time lets paho send the DISCONNECT packet before closing the connection
+				client.Disconnect(250)
+			} else {
+				// Drop the connection from the broker's side, as if the network failed
+				broker.mu.Lock()
+				for session := range broker.clients {
+					if session.clientId == "pc" {
+						session.conn.Close()
+					}
+				}
+				broker.mu.Unlock()
+			}
+
+			select {
+			case msg := <-received:
+				if test.clean {
+					t.Errorf("Will %q was published after a clean disconnect", msg.Payload())
+				} else if string(msg.Payload()) != "offline" {
+					t.Errorf("Will is %q, want offline", msg.Payload())
+				}
+			case <-time.After(200 * time.Millisecond):
+				if !test.clean {
+					t.Error("Will was not published after the connection was lost")
+				}
+			}
+		})
+	}
+}
+
+func TestWildcards(t *testing.T) {
+	for _, test := range []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"pc/state", "pc/state", true},
+		{"pc/state", "pc/state/x", false},
+		{"pc/+/state", "pc/cpu/state", true},
+		{"pc/+/state", "pc/cpu/gpu/state", false},
+		{"pc/+", "pc", false},
+		{"+/+", "/state", true},
+		{"pc/#", "pc", true},
+		{"pc/#", "pc/cpu/state", true},
+		{"pc/#", "other/cpu", false},
+		{"#", "pc/cpu/state", true},
+	} {
+		t.Run(test.filter+" "+test.topic, func(t *testing.T) {
+			broker := startBroker(t)
+			subscribe(t, connectClient(t, broker, "subscriber"), test.filter)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if matched := broker.WaitSubscribed(ctx, test.topic) == nil; matched != test.match {
+				t.Errorf("Filter %s matches %s: %t, want %t", test.filter, test.topic, matched, test.match)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package credentials
+
+import "errors"
+
+// service groups all secrets stored by pc2mqtt in the OS credential store.
+const service = "pc2mqtt"
+
+var ErrNotFound = errors.New("credential not found in OS credential store")
+
+// Get reads the secret stored for account from the OS credential store.
+func Get(account string) (string, error) {
+	return get(account)
+}
+
+// Set stores secret for account in the OS credential store, replacing an existing one.
+func Set(account string, secret string) error {
+	return set(account, secret)
+}
+
+// Delete removes the secret stored for account from the OS credential store.
+func Delete(account string) error {
+	return remove(account)
+}
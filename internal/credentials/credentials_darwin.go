@@ -0,0 +1,52 @@
+package credentials
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// macOS stores generic passwords in the login keychain through the security tool.
+
+func get(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// set runs add-generic-password in the interactive mode of security, which reads it from stdin, so the secret
+// doesn't show up in the arguments of the process to other users.
+func set(account string, secret string) error {
+	if strings.ContainsAny(secret, "\r\n") {
+		return errors.New("The macOS keychain doesn't support secrets with line breaks")
+	}
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader("add-generic-password -U -s " + quote(service) + " -a " + quote(account) + " -w " + quote(secret) + "\n")
+	out, err := cmd.CombinedOutput()
+	// Failing commands don't change the exit code of the interactive mode, only print an error
+	output := strings.TrimSpace(strings.ReplaceAll(string(out), "security> ", ""))
+	if err != nil || output != "" {
+		return errors.New("security add-generic-password failed: " + output)
+	}
+	return nil
+}
+
+// quote quotes value as a single argument of a command line of security -i.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func remove(account string) error {
+	out, err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).CombinedOutput()
+	if err != nil {
+		return errors.New("security delete-generic-password failed: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Linux uses the Secret Service API (GNOME Keyring, KWallet) through secret-tool from libsecret.
+
+func get(account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "account", account).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(account string, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", service+" "+account, "service", service, "account", account)
+	cmd.Stdin = bytes.NewBufferString(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.New("secret-tool store failed: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func remove(account string) error {
+	if out, err := exec.Command("secret-tool", "clear", "service", service, "account", account).CombinedOutput(); err != nil {
+		return errors.New("secret-tool clear failed: " + strings.TrimSpace(string(out)))
+	}
+	return nil
+}
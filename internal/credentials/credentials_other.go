@@ -0,0 +1,22 @@
+//go:build !linux && !darwin && !windows
+
+package credentials
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errUnsupported = errors.New(runtime.GOOS + " does not support an OS credential store")
+
+func get(account string) (string, error) {
+	return "", errUnsupported
+}
+
+func set(account string, secret string) error {
+	return errUnsupported
+}
+
+func remove(account string) error {
+	return errUnsupported
+}
@@ -0,0 +1,188 @@
+// Package cron parses cron expressions and computes when they run next.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxYears bounds the search for the next run, so expressions that never match, eg. February 30, end
+const maxYears = 5
+
+// Schedule is a parsed cron expression. The fields hold one bit per matching value.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set if the day of month or week starts with *. Otherwise a day
+	// matching either of them matches, like in Vixie cron.
+	domAny, dowAny bool
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	days    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Sunday is 0 or 7
+	weekdays = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses an expression of five fields: minute, hour, day of month, month and day of week.
+// Fields take *, numbers, ranges like 1-5, lists like 1,3 and steps like */15 or 0-30/10.
+// Months and days of week may be named, eg. JAN or MON-FRI. The macros @yearly, @monthly,
+// @weekly, @daily and @hourly are supported as well.
+func Parse(expr string) (Schedule, error) {
+	if macro, ok := macros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("Invalid cron expression %q. Must have 5 fields: minute, hour, day of month, month and day of week", expr)
+	}
+
+	var schedule Schedule
+	var err error
+	for i, field := range []struct {
+		bits   *uint64
+		bounds bounds
+		name   string
+	}{
+		{&schedule.minute, minutes, "minute"},
+		{&schedule.hour, hours, "hour"},
+		{&schedule.dom, days, "day of month"},
+		{&schedule.month, months, "month"},
+		{&schedule.dow, weekdays, "day of week"},
+	} {
+		if *field.bits, err = parseField(fields[i], field.bounds); err != nil {
+			return Schedule{}, fmt.Errorf("Invalid %s in cron expression %q: %w", field.name, expr, err)
+		}
+	}
+
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow = schedule.dow&^(1<<7) | 1
+	}
+	schedule.domAny = strings.HasPrefix(fields[2], "*")
+	schedule.dowAny = strings.HasPrefix(fields[4], "*")
+	return schedule, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		valueRange, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("%q is no valid step", stepText)
+			}
+		}
+
+		low, high := b.min, b.max
+		if valueRange != "*" {
+			lowText, highText, isRange := strings.Cut(valueRange, "-")
+			var err error
+			if low, err = parseValue(lowText, b); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = parseValue(highText, b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// 10/15 counts from 10 to the end
+				high = b.max
+			}
+		}
+		if low > high {
+			return 0, fmt.Errorf("%q ends before it starts", valueRange)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(text string, b bounds) (int, error) {
+	if value, ok := b.names[strings.ToLower(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < b.min || value > b.max {
+		return 0, fmt.Errorf("%q is not between %d and %d", text, b.min, b.max)
+	}
+	return value, nil
+}
+
+// Next returns the first run after t in the location of t, or the zero time if the
+// expression never matches. Runs in the hour skipped when the clocks are turned forward
+// are missed. When they are turned back, runs at a fixed hour run once in the repeated hour.
+func (s Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + maxYears
+
+	for t.Year() <= limit {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// The clocks were turned back and the hour repeats
+				next = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			}
+			t = next
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Matches reports whether the minute of t is a run of s.
+func (s Schedule) Matches(t time.Time) bool {
+	return has(s.month, int(t.Month())) && s.dayMatches(t) && has(s.hour, t.Hour()) && has(s.minute, t.Minute())
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
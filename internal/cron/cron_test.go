@@ -0,0 +1,240 @@
+package cron
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// values lists the values set in bits.
+func values(bits uint64) []int {
+	var values []int
+	for value := range 64 {
+		if has(bits, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func span(low, high, step int) []int {
+	var values []int
+	for value := low; value <= high; value += step {
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		expr                          string
+		minute, hour, dom, month, dow []int
+		domAny, dowAny                bool
+	}{
+		{"* * * * *", span(0, 59, 1), span(0, 23, 1), span(1, 31, 1), span(1, 12, 1), span(0, 6, 1), true, true},
+		{"0 12 1 6 3", []int{0}, []int{12}, []int{1}, []int{6}, []int{3}, false, false},
+		{"0-4 8-10 1-3 * 1-5", span(0, 4, 1), span(8, 10, 1), span(1, 3, 1), span(1, 12, 1), span(1, 5, 1), false, false},
+		{"*/15 */6 */10 */3 */2", span(0, 45, 15), span(0, 18, 6), []int{1, 11, 21, 31}, []int{1, 4, 7, 10}, []int{0, 2, 4, 6}, true, true},
+		{"0-30/10 10/5 5/10 2-12/5 1-5/2", span(0, 30, 10), span(10, 20, 5), []int{5, 15, 25}, []int{2, 7, 12}, []int{1, 3, 5}, false, false},
+		{"1,5,10-12 0,12 1,15 1,7 0,6", []int{1, 5, 10, 11, 12}, []int{0, 12}, []int{1, 15}, []int{1, 7}, []int{0, 6}, false, false},
+		{"0 0 * JAN,jul mon-FRI", []int{0}, []int{0}, span(1, 31, 1), []int{1, 7}, span(1, 5, 1), true, false},
+		{"0 0 * nov-dec fri-sat", []int{0}, []int{0}, span(1, 31, 1), []int{11, 12}, []int{5, 6}, true, false},
+		// Sunday is 0 and 7
+		{"0 0 * * 7", []int{0}, []int{0}, span(1, 31, 1), span(1, 12, 1), []int{0}, true, false},
+		{"0 0 * * 5-7", []int{0}, []int{0}, span(1, 31, 1), span(1, 12, 1), []int{0, 5, 6}, true, false},
+		{"@hourly", []int{0}, span(0, 23, 1), span(1, 31, 1), span(1, 12, 1), span(0, 6, 1), true, true},
+		{"@weekly", []int{0}, []int{0}, span(1, 31, 1), span(1, 12, 1), []int{0}, true, false},
+		{" @Yearly ", []int{0}, []int{0}, []int{1}, []int{1}, span(0, 6, 1), false, true},
+	} {
+		t.Run(test.expr, func(t *testing.T) {
+			schedule, err := Parse(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []struct {
+				name           string
+				bits           uint64
+				want           []int
+				isAny, wantAny bool
+			}{
+				{"minute", schedule.minute, test.minute, false, false},
+				{"hour", schedule.hour, test.hour, false, false},
+				{"day of month", schedule.dom, test.dom, schedule.domAny, test.domAny},
+				{"month", schedule.month, test.month, false, false},
+				{"day of week", schedule.dow, test.dow, schedule.dowAny, test.dowAny},
+			} {
+				if got := values(field.bits); !reflect.DeepEqual(got, field.want) {
+					t.Errorf("%s is %v, want %v", field.name, got, field.want)
+				}
+				if field.isAny != field.wantAny {
+					t.Errorf("%s starting with * is %t, want %t", field.name, field.isAny, field.wantAny)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"* * * foo *",
+		"* * * * mon-",
+		"@reboot",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Friday
+	from := time.Date(2026, 5, 15, 10, 20, 30, 500, time.UTC)
+	for _, test := range []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2026, 5, 15, 10, 21, 0, 0, time.UTC)},
+		{"later this hour", "45 * * * *", time.Date(2026, 5, 15, 10, 45, 0, 0, time.UTC)},
+		// The minute of from itself has started already
+		{"not the current minute", "20 10 * * *", time.Date(2026, 5, 16, 10, 20, 0, 0, time.UTC)},
+		{"next hour", "*/15 11-13 * * *", time.Date(2026, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{"tomorrow", "0 9 * * *", time.Date(2026, 5, 16, 9, 0, 0, 0, time.UTC)},
+		{"day of week", "0 8 * * mon", time.Date(2026, 5, 18, 8, 0, 0, 0, time.UTC)},
+		{"weekdays", "0 8 * * mon-fri", time.Date(2026, 5, 18, 8, 0, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 8 * * 7", time.Date(2026, 5, 17, 8, 0, 0, 0, time.UTC)},
+		{"day of month", "0 0 1 * *", time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"named month", "0 0 1 feb *", time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"end of month", "0 0 31 * *", time.Date(2026, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{"skips short months", "0 0 31 jun-aug *", time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", time.Time{}},
+
+		// Restricted days of month and week match either of them
+		{"day of month or week", "0 0 20 * mon", time.Date(2026, 5, 18, 0, 0, 0, 0, time.UTC)},
+		{"day of week or month", "0 0 16 * mon", time.Date(2026, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"day of week in another month", "0 0 1 6 fri", time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// A * in either of them restricts the days to the other one
+		{"any day of month", "0 0 * * mon", time.Date(2026, 5, 18, 0, 0, 0, 0, time.UTC)},
+		{"any day of week", "0 0 16 * *", time.Date(2026, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{"stepped day of week", "0 0 20 * */3", time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(from); !got.Equal(test.want) {
+				t.Errorf("Next of %q is %s, want %s", test.expr, got, test.want)
+			}
+			if !test.want.IsZero() && !schedule.Matches(test.want) {
+				t.Errorf("%q doesn't match %s", test.expr, test.want)
+			}
+		})
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cet := time.FixedZone("CET", 3600)
+	cest := time.FixedZone("CEST", 2*3600)
+
+	for _, test := range []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			// On March 29, 2026 the clocks jump from 02:00 CET to 03:00 CEST
+			name: "runs in the skipped hour are missed",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 28, 12, 0, 0, 0, berlin),
+			want: []time.Time{time.Date(2026, 3, 30, 2, 30, 0, 0, cest)},
+		},
+		{
+			name: "minutes across the skipped hour",
+			expr: "*/30 * * * *",
+			from: time.Date(2026, 3, 29, 1, 0, 0, 0, berlin),
+			want: []time.Time{
+				time.Date(2026, 3, 29, 1, 30, 0, 0, cet),
+				time.Date(2026, 3, 29, 3, 0, 0, 0, cest),
+				time.Date(2026, 3, 29, 3, 30, 0, 0, cest),
+			},
+		},
+		{
+			name: "hours across the skipped hour",
+			expr: "0 * * * *",
+			from: time.Date(2026, 3, 29, 0, 30, 0, 0, berlin),
+			want: []time.Time{
+				time.Date(2026, 3, 29, 1, 0, 0, 0, cet),
+				time.Date(2026, 3, 29, 3, 0, 0, 0, cest),
+				time.Date(2026, 3, 29, 4, 0, 0, 0, cest),
+			},
+		},
+		{
+			// On October 25, 2026 the clocks are turned back from 03:00 CEST to 02:00 CET.
+			// A fixed time runs once.
+			name: "fixed time in the repeated hour",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 10, 24, 12, 0, 0, 0, berlin),
+			want: []time.Time{
+				time.Date(2026, 10, 25, 2, 30, 0, 0, cet),
+				time.Date(2026, 10, 26, 2, 30, 0, 0, cet),
+			},
+		},
+		{
+			name: "minutes in the repeated hour",
+			expr: "*/30 * * * *",
+			from: time.Date(2026, 10, 25, 1, 45, 0, 0, cest),
+			want: []time.Time{
+				time.Date(2026, 10, 25, 2, 0, 0, 0, cest),
+				time.Date(2026, 10, 25, 2, 30, 0, 0, cest),
+				time.Date(2026, 10, 25, 2, 0, 0, 0, cet),
+				time.Date(2026, 10, 25, 2, 30, 0, 0, cet),
+				time.Date(2026, 10, 25, 3, 0, 0, 0, cet),
+			},
+		},
+		{
+			name: "hours after the repeated hour",
+			expr: "0 3 * * *",
+			from: time.Date(2026, 10, 25, 2, 30, 0, 0, cest),
+			want: []time.Time{time.Date(2026, 10, 25, 3, 0, 0, 0, cet)},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := Parse(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := test.from.In(berlin)
+			for _, want := range test.want {
+				next = schedule.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("Next is %s, want %s", next, want)
+				}
+				if next.Location() != berlin {
+					t.Errorf("Next is in %s, want Europe/Berlin", next.Location())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+// Package dbus is a minimal client of the D-Bus system and session bus, enough to call methods of
+// services like logind or the notification server and to receive their signals.
+// https://dbus.freedesktop.org/doc/dbus-specification.html
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connectTimeout bounds connecting and authenticating to the bus
+const connectTimeout = 5 * time.Second
+
+// maxMessageLength is the largest message the specification allows
+const maxMessageLength = 1 << 27
+
+const (
+	typeMethodCall   = 1
+	typeMethodReturn = 2
+	typeError        = 3
+	typeSignal       = 4
+)
+
+// Header fields
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// Error is an error reply of a method call.
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// Signal is a signal received from the bus.
+type Signal struct {
+	Sender    string
+	Path      ObjectPath
+	Interface string
+	Member    string
+	Body      []any
+}
+
+type message struct {
+	kind        byte
+	serial      uint32
+	replySerial uint32
+	path        ObjectPath
+	iface       string
+	member      string
+	errorName   string
+	sender      string
+	body        []any
+}
+
+// Conn is a connection to a bus. Replies and signals are read in the background.
+type Conn struct {
+	conn net.Conn
+
+	writeMu sync.Mutex
+	serial  uint32
+
+	mu       sync.Mutex
+	pending  map[uint32]chan *message
+	signals  []chan<- Signal
+	closed   chan struct{}
+	closeErr error
+}
+
+// addressPaths returns the socket paths of the unix:path= options of the bus address in env.
+func addressPaths(env string) []string {
+	var paths []string
+	if address := os.Getenv(env); address != "" {
+		for _, option := range strings.Split(address, ";") {
+			if path, ok := strings.CutPrefix(option, "unix:path="); ok {
+				path, _, _ = strings.Cut(path, ",")
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// SystemBus connects to the system bus and authenticates as the current user.
+func SystemBus() (*Conn, error) {
+	paths := append(addressPaths("DBUS_SYSTEM_BUS_ADDRESS"), "/run/dbus/system_bus_socket", "/var/run/dbus/system_bus_socket")
+	conn, err := dial(paths, os.Getuid())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the D-Bus system bus: %w", err)
+	}
+	return conn, nil
+}
+
+// SessionBus connects to the session bus of the current user in DBUS_SESSION_BUS_ADDRESS or /run/user/<uid>/bus.
+// The bus checks the user against the credentials of the socket, so not even root can connect to the bus of
+// another user.
+func SessionBus() (*Conn, error) {
+	uid := os.Getuid()
+	paths := append(addressPaths("DBUS_SESSION_BUS_ADDRESS"), "/run/user/"+strconv.Itoa(uid)+"/bus")
+	conn, err := dial(paths, uid)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to the D-Bus session bus of user %d: %w", uid, err)
+	}
+	return conn, nil
+}
+
+// dial connects to the first of paths accepting a connection.
+func dial(paths []string, uid int) (*Conn, error) {
+	var err error
+	for _, path := range paths {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("unix", path, connectTimeout); err == nil {
+			return newConn(conn, uid)
+		}
+	}
+	return nil, err
+}
+
+func newConn(conn net.Conn, uid int) (*Conn, error) {
+	conn.SetDeadline(time.Now().Add(connectTimeout))
+	reader := bufio.NewReader(conn)
+	if err := authenticate(conn, reader, uid); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &Conn{
+		conn:    conn,
+		pending: make(map[uint32]chan *message),
+		closed:  make(chan struct{}),
+	}
+	go c.read(reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if _, err := c.Call(ctx, "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// authenticate runs the EXTERNAL authentication as uid, which the bus checks against the credentials of the socket.
+func authenticate(conn net.Conn, reader *bufio.Reader, uid int) error {
+	identity := hex.EncodeToString([]byte(strconv.Itoa(uid)))
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + identity + "\r\n")); err != nil {
+		return err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus authentication failed: %s", strings.TrimSpace(line))
+	}
+	_, err = conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// Close closes the connection. Pending calls fail and signal channels are closed.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Call calls method of iface on the object path of destination and returns the values of the reply.
+func (c *Conn) Call(ctx context.Context, destination string, path ObjectPath, iface string, method string, args ...any) ([]any, error) {
+	reply := make(chan *message, 1)
+	c.writeMu.Lock()
+	c.serial++
+	serial := c.serial
+	c.mu.Lock()
+	if c.closeErr != nil {
+		c.mu.Unlock()
+		c.writeMu.Unlock()
+		return nil, c.closeErr
+	}
+	c.pending[serial] = reply
+	c.mu.Unlock()
+	err := c.write(serial, destination, path, iface, method, args)
+	c.writeMu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, serial)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.kind == typeError {
+			dbusErr := &Error{Name: msg.errorName}
+			if len(msg.body) > 0 {
+				dbusErr.Message, _ = msg.body[0].(string)
+			}
+			return nil, dbusErr
+		}
+		return msg.body, nil
+	case <-c.closed:
+		return nil, c.closeErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Subscribe receives the signals matching rule, eg. type='signal',interface='org.freedesktop.login1.Manager',
+// on signals until the connection is closed, which closes signals.
+// https://dbus.freedesktop.org/doc/dbus-specification.html#message-bus-routing-match-rules
+func (c *Conn) Subscribe(ctx context.Context, rule string, signals chan<- Signal) error {
+	c.mu.Lock()
+	c.signals = append(c.signals, signals)
+	c.mu.Unlock()
+	_, err := c.Call(ctx, "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", rule)
+	return err
+}
+
+// write sends a method call. The caller holds writeMu.
+func (c *Conn) write(serial uint32, destination string, path ObjectPath, iface string, method string, args []any) error {
+	var sig Signature
+	body := &encoder{}
+	for _, arg := range args {
+		s, err := signatureOf(arg)
+		if err != nil {
+			return err
+		}
+		sig += s
+		if err := body.value(arg); err != nil {
+			return err
+		}
+	}
+
+	header := &encoder{buf: []byte{'l', typeMethodCall, 0, 1}}
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(serial)
+	fields := &encoder{}
+	field := func(code byte, v any) {
+		fields.align(8)
+		fields.buf = append(fields.buf, code)
+		s, _ := signatureOf(v)
+		fields.value(Variant{Signature: s, Value: v})
+	}
+	field(fieldPath, path)
+	field(fieldInterface, iface)
+	field(fieldMember, method)
+	field(fieldDestination, destination)
+	if sig != "" {
+		field(fieldSignature, sig)
+	}
+	// The fields are aligned relative to the message, which the array length and padding keep at 8 byte boundaries
+	header.uint32(uint32(len(fields.buf)))
+	header.align(8)
+	header.buf = append(header.buf, fields.buf...)
+	header.align(8)
+
+	_, err := c.conn.Write(append(header.buf, body.buf...))
+	return err
+}
+
+// read dispatches the received messages until the connection fails.
+func (c *Conn) read(reader io.Reader) {
+	var err error
+	for {
+		var msg *message
+		if msg, err = readMessage(reader); err != nil {
+			break
+		}
+
+		switch msg.kind {
+		case typeMethodReturn, typeError:
+			c.mu.Lock()
+			reply, ok := c.pending[msg.replySerial]
+			c.mu.Unlock()
+			if ok {
+				reply <- msg
+			}
+		case typeSignal:
+			signal := Signal{Sender: msg.sender, Path: msg.path, Interface: msg.iface, Member: msg.member, Body: msg.body}
+			c.mu.Lock()
+			subscribers := c.signals
+			c.mu.Unlock()
+			for _, signals := range subscribers {
+				select {
+				case signals <- signal:
+				default:
+					// A slow subscriber must not block the replies
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.closeErr = fmt.Errorf("D-Bus connection closed: %w", err)
+	for _, signals := range c.signals {
+		close(signals)
+	}
+	c.signals = nil
+	c.mu.Unlock()
+	close(c.closed)
+}
+
+func readMessage(reader io.Reader) (*message, error) {
+	// Fixed part of the header and the length of the header fields
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(reader, fixed); err != nil {
+		return nil, err
+	}
+	if fixed[0] != 'l' {
+		return nil, errors.New("Big endian D-Bus messages are not supported")
+	}
+	bodyLength := order.Uint32(fixed[4:8])
+	fieldsLength := order.Uint32(fixed[12:16])
+	headerLength := (16 + int(fieldsLength) + 7) &^ 7
+	if uint64(headerLength)+uint64(bodyLength) > maxMessageLength {
+		return nil, errors.New("D-Bus message too long")
+	}
+
+	buf := make([]byte, headerLength+int(bodyLength))
+	copy(buf, fixed)
+	if _, err := io.ReadFull(reader, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	msg := &message{kind: fixed[1], serial: order.Uint32(fixed[8:12])}
+	d := &decoder{buf: buf[:16+fieldsLength], pos: 12}
+	fields, err := d.value("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	var sig Signature
+	for _, f := range fields.([]any) {
+		f := f.([]any)
+		value := f[1].(Variant).Value
+		switch f[0].(byte) {
+		case fieldPath:
+			msg.path, _ = value.(ObjectPath)
+		case fieldInterface:
+			msg.iface, _ = value.(string)
+		case fieldMember:
+			msg.member, _ = value.(string)
+		case fieldErrorName:
+			msg.errorName, _ = value.(string)
+		case fieldReplySerial:
+			msg.replySerial, _ = value.(uint32)
+		case fieldSender:
+			msg.sender, _ = value.(string)
+		case fieldSignature:
+			sig, _ = value.(Signature)
+		}
+	}
+
+	body := &decoder{buf: buf[headerLength:]}
+	if msg.body, err = body.values(sig); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,215 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type headerField struct {
+	code  byte
+	value any
+}
+
+// writeMessage writes a message of kind with fields and the body args to conn, like the bus does.
+func writeMessage(conn net.Conn, kind byte, serial uint32, fields []headerField, args ...any) error {
+	var sig Signature
+	body := &encoder{}
+	for _, arg := range args {
+		s, err := signatureOf(arg)
+		if err != nil {
+			return err
+		}
+		sig += s
+		if err := body.value(arg); err != nil {
+			return err
+		}
+	}
+	if sig != "" {
+		fields = append(fields, headerField{fieldSignature, sig})
+	}
+
+	header := &encoder{buf: []byte{'l', kind, 0, 1}}
+	header.uint32(uint32(len(body.buf)))
+	header.uint32(serial)
+	encoded := &encoder{}
+	for _, f := range fields {
+		encoded.align(8)
+		encoded.buf = append(encoded.buf, f.code)
+		s, _ := signatureOf(f.value)
+		if err := encoded.value(Variant{Signature: s, Value: f.value}); err != nil {
+			return err
+		}
+	}
+	header.uint32(uint32(len(encoded.buf)))
+	header.align(8)
+	header.buf = append(header.buf, encoded.buf...)
+	header.align(8)
+	_, err := conn.Write(append(header.buf, body.buf...))
+	return err
+}
+
+func TestWriteReadMessage(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args []any
+		want []any
+	}{
+		{"no arguments", nil, nil},
+		{"byte", []any{byte(1)}, []any{byte(1)}},
+		{
+			name: "notification",
+			args: []any{"pc2mqtt", uint32(0), "", "Shutdown", "in 60s", []string{"cancel", "Cancel"}, map[string]Variant{"urgency": {"y", byte(2)}}, int32(-1)},
+			want: []any{"pc2mqtt", uint32(0), "", "Shutdown", "in 60s", []any{"cancel", "Cancel"}, []any{[]any{"urgency", Variant{"y", byte(2)}}}, int32(-1)},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			c := &Conn{conn: client}
+			written := make(chan error, 1)
+			go func() {
+				written <- c.write(7, "org.freedesktop.Notifications", "/org/freedesktop/Notifications", "org.freedesktop.Notifications", "Notify", test.args)
+			}()
+
+			msg, err := readMessage(server)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := <-written; err != nil {
+				t.Fatal(err)
+			}
+			want := &message{
+				kind:   typeMethodCall,
+				serial: 7,
+				path:   "/org/freedesktop/Notifications",
+				iface:  "org.freedesktop.Notifications",
+				member: "Notify",
+				body:   test.want,
+			}
+			if !reflect.DeepEqual(msg, want) {
+				t.Errorf("Read %+v, want %+v", msg, want)
+			}
+		})
+	}
+}
+
+// fakeBus serves conn like a bus: it accepts the authentication of uid, replies to Hello and AddMatch,
+// fails Fail, echoes the arguments of Echo and sends a signal after AddMatch.
+func fakeBus(t *testing.T, conn net.Conn, uid string) {
+	reader := bufio.NewReader(conn)
+	if line, err := reader.ReadString('\n'); err != nil || line != "\x00AUTH EXTERNAL "+uid+"\r\n" {
+		t.Errorf("Got authentication %q, %v", line, err)
+		return
+	}
+	conn.Write([]byte("OK 0123456789abcdef\r\n"))
+	if line, err := reader.ReadString('\n'); err != nil || line != "BEGIN\r\n" {
+		t.Errorf("Got %q, %v, want BEGIN", line, err)
+		return
+	}
+
+	var serial uint32
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			return
+		}
+		serial++
+		reply := []headerField{{fieldReplySerial, msg.serial}}
+		switch msg.member {
+		case "Hello":
+			writeMessage(conn, typeMethodReturn, serial, reply, ":1.42")
+		case "AddMatch":
+			writeMessage(conn, typeMethodReturn, serial, reply)
+			serial++
+			writeMessage(conn, typeSignal, serial, []headerField{
+				{fieldPath, ObjectPath("/org/freedesktop/login1")},
+				{fieldInterface, "org.freedesktop.login1.Manager"},
+				{fieldMember, "PrepareForSleep"},
+				{fieldSender, ":1.2"},
+			}, true)
+		case "Fail":
+			writeMessage(conn, typeError, serial, append(reply, headerField{fieldErrorName, "org.freedesktop.DBus.Error.AccessDenied"}), "Permission denied")
+		case "Echo":
+			var args []any
+			for _, arg := range msg.body {
+				if s, ok := arg.(string); ok {
+					args = append(args, s)
+				}
+			}
+			writeMessage(conn, typeMethodReturn, serial, reply, args...)
+		}
+	}
+}
+
+func TestConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go fakeBus(t, server, "31303030")
+
+	c, err := newConn(client, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	body, err := c.Call(ctx, "org.example", "/org/example", "org.example", "Echo", "a", "b")
+	if err != nil || !reflect.DeepEqual(body, []any{"a", "b"}) {
+		t.Errorf("Echo returned %v, %v, want [a b]", body, err)
+	}
+
+	_, err = c.Call(ctx, "org.example", "/org/example", "org.example", "Fail")
+	var dbusErr *Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.DBus.Error.AccessDenied" || dbusErr.Message != "Permission denied" {
+		t.Errorf("Fail returned %v, want AccessDenied", err)
+	}
+
+	signals := make(chan Signal, 1)
+	if err := c.Subscribe(ctx, "type='signal'", signals); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case signal := <-signals:
+		want := Signal{Sender: ":1.2", Path: "/org/freedesktop/login1", Interface: "org.freedesktop.login1.Manager", Member: "PrepareForSleep", Body: []any{true}}
+		if !reflect.DeepEqual(signal, want) {
+			t.Errorf("Received %+v, want %+v", signal, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("No signal received")
+	}
+
+	// Losing the connection closes the signal channels and fails new calls
+	server.Close()
+	select {
+	case _, ok := <-signals:
+		if ok {
+			t.Error("Received a signal after the connection was lost")
+		}
+	case <-ctx.Done():
+		t.Fatal("Signal channel was not closed")
+	}
+	if _, err := c.Call(ctx, "org.example", "/org/example", "org.example", "Echo"); err == nil {
+		t.Error("Call succeeded after the connection was lost")
+	}
+}
+
+func TestAuthenticationRejected(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		bufio.NewReader(server).ReadString('\n')
+		server.Write([]byte("REJECTED EXTERNAL\r\n"))
+	}()
+
+	if _, err := newConn(client, 0); err == nil || err.Error() != "D-Bus authentication failed: REJECTED EXTERNAL" {
+		t.Errorf("Got %v, want the rejected authentication", err)
+	}
+}
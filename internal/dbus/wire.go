@@ -0,0 +1,377 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// ObjectPath is a D-Bus object path, eg. /org/freedesktop/login1.
+type ObjectPath string
+
+// Signature is a D-Bus type signature, eg. a(ssssuu).
+type Signature string
+
+// Variant is a value of the D-Bus type v together with its signature.
+type Variant struct {
+	Signature Signature
+	Value     any
+}
+
+// Messages are always written little endian
+var order = binary.LittleEndian
+
+var errShortMessage = errors.New("D-Bus message ends unexpectedly")
+
+// encoder writes values in the D-Bus wire format. Alignment is relative to the start of buf,
+// which is the start of the message or its 8 byte aligned body.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = order.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) signature(s Signature) {
+	e.buf = append(e.buf, byte(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+// array writes the length of the elements written by elements, which start aligned to alignment.
+// The padding before the first element doesn't count to the length.
+func (e *encoder) array(alignment int, elements func()) {
+	e.uint32(0)
+	lengthAt := len(e.buf) - 4
+	e.align(alignment)
+	start := len(e.buf)
+	elements()
+	order.PutUint32(e.buf[lengthAt:], uint32(len(e.buf)-start))
+}
+
+// signatureOf returns the D-Bus type of the Go values supported as arguments.
+func signatureOf(v any) (Signature, error) {
+	switch v.(type) {
+	case bool:
+		return "b", nil
+	case byte:
+		return "y", nil
+	case int32:
+		return "i", nil
+	case uint32:
+		return "u", nil
+	case int64:
+		return "x", nil
+	case uint64:
+		return "t", nil
+	case string:
+		return "s", nil
+	case ObjectPath:
+		return "o", nil
+	case Signature:
+		return "g", nil
+	case Variant:
+		return "v", nil
+	case []string:
+		return "as", nil
+	case map[string]Variant:
+		return "a{sv}", nil
+	default:
+		return "", fmt.Errorf("Unsupported D-Bus argument type %T", v)
+	}
+}
+
+func (e *encoder) value(v any) error {
+	switch v := v.(type) {
+	case bool:
+		var b uint32
+		if v {
+			b = 1
+		}
+		e.uint32(b)
+	case byte:
+		e.buf = append(e.buf, v)
+	case int32:
+		e.uint32(uint32(v))
+	case uint32:
+		e.uint32(v)
+	case int64:
+		e.align(8)
+		e.buf = order.AppendUint64(e.buf, uint64(v))
+	case uint64:
+		e.align(8)
+		e.buf = order.AppendUint64(e.buf, v)
+	case string:
+		e.string(v)
+	case ObjectPath:
+		e.string(string(v))
+	case Signature:
+		e.signature(v)
+	case Variant:
+		e.signature(v.Signature)
+		return e.value(v.Value)
+	case []string:
+		e.array(4, func() {
+			for _, s := range v {
+				e.string(s)
+			}
+		})
+	case map[string]Variant:
+		// Sorted, so equal maps encode equally
+		keys := slices.Sorted(maps.Keys(v))
+		var err error
+		e.array(8, func() {
+			for _, key := range keys {
+				e.align(8)
+				e.string(key)
+				if err == nil {
+					err = e.value(v[key])
+				}
+			}
+		})
+		return err
+	default:
+		return fmt.Errorf("Unsupported D-Bus argument type %T", v)
+	}
+	return nil
+}
+
+// decoder reads values in the D-Bus wire format from buf, aligned relative to its start.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errShortMessage
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) {
+		return nil, errShortMessage
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint32(b), nil
+}
+
+func (d *decoder) uint64() (uint64, error) {
+	if err := d.align(8); err != nil {
+		return 0, err
+	}
+	b, err := d.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint64(b), nil
+}
+
+func (d *decoder) string() (string, error) {
+	length, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.next(int(length) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(b[:length]), nil
+}
+
+func (d *decoder) signature() (Signature, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return "", err
+	}
+	s, err := d.next(int(b[0]) + 1)
+	if err != nil {
+		return "", err
+	}
+	return Signature(s[:b[0]]), nil
+}
+
+// values decodes the values of the complete types in sig.
+func (d *decoder) values(sig Signature) ([]any, error) {
+	var values []any
+	for sig != "" {
+		first, rest, err := splitType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(first)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		sig = rest
+	}
+	return values, nil
+}
+
+// value decodes a single complete type. Arrays, structs and dict entries become []any.
+func (d *decoder) value(sig Signature) (any, error) {
+	switch sig[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		v, err := d.uint32()
+		return v != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'n' {
+			return int16(order.Uint16(b)), nil
+		}
+		return order.Uint16(b), nil
+	case 'i':
+		v, err := d.uint32()
+		return int32(v), err
+	case 'u', 'h':
+		return d.uint32()
+	case 'x':
+		v, err := d.uint64()
+		return int64(v), err
+	case 't':
+		return d.uint64()
+	case 'd':
+		v, err := d.uint64()
+		return math.Float64frombits(v), err
+	case 's':
+		return d.string()
+	case 'o':
+		s, err := d.string()
+		return ObjectPath(s), err
+	case 'g':
+		return d.signature()
+	case 'v':
+		s, err := d.signature()
+		if err != nil {
+			return nil, err
+		}
+		if _, rest, err := splitType(s); err != nil || rest != "" {
+			return nil, fmt.Errorf("Invalid D-Bus variant signature %q", s)
+		}
+		v, err := d.value(s)
+		return Variant{Signature: s, Value: v}, err
+	case 'a':
+		length, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		elem := sig[1:]
+		if err := d.align(alignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(length)
+		if end > len(d.buf) {
+			return nil, errShortMessage
+		}
+		elements := []any{}
+		for d.pos < end {
+			v, err := d.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, v)
+		}
+		return elements, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.values(sig[1 : len(sig)-1])
+	default:
+		return nil, fmt.Errorf("Unsupported D-Bus type %q", sig)
+	}
+}
+
+func alignment(t byte) int {
+	switch t {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	default:
+		return 4
+	}
+}
+
+// splitType splits the first complete type off sig, eg. a(su) off a(su)b.
+func splitType(sig Signature) (Signature, Signature, error) {
+	if sig == "" {
+		return "", "", errors.New("Empty D-Bus signature")
+	}
+	switch sig[0] {
+	case 'a':
+		elem, rest, err := splitType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return "a" + elem, rest, nil
+	case '(', '{':
+		closing := map[byte]byte{'(': ')', '{': '}'}[sig[0]]
+		depth := 0
+		for i := 0; i < len(sig); i++ {
+			switch sig[i] {
+			case '(', '{':
+				depth++
+			case ')', '}':
+				depth--
+				if depth == 0 {
+					if sig[i] != closing {
+						return "", "", fmt.Errorf("Invalid D-Bus signature %q", sig)
+					}
+					return sig[:i+1], sig[i+1:], nil
+				}
+			}
+		}
+		return "", "", fmt.Errorf("Invalid D-Bus signature %q", sig)
+	default:
+		return sig[:1], sig[1:], nil
+	}
+}
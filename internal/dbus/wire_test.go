@@ -0,0 +1,233 @@
+package dbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args []any
+		sig  Signature
+		want []any
+	}{
+		{
+			name: "basic types",
+			args: []any{true, byte(7), int32(-2), uint32(3), int64(-4), uint64(5), "text", ObjectPath("/org/freedesktop/login1"), Signature("a(su)")},
+			sig:  "byiuxtsog",
+			want: []any{true, byte(7), int32(-2), uint32(3), int64(-4), uint64(5), "text", ObjectPath("/org/freedesktop/login1"), Signature("a(su)")},
+		},
+		{
+			name: "byte before 64 bit",
+			args: []any{byte(1), uint64(2), byte(3), int64(4)},
+			sig:  "ytyx",
+			want: []any{byte(1), uint64(2), byte(3), int64(4)},
+		},
+		{
+			name: "string before 64 bit",
+			args: []any{"odd", uint64(1), "", int64(-1)},
+			sig:  "stsx",
+			want: []any{"odd", uint64(1), "", int64(-1)},
+		},
+		{
+			name: "arrays",
+			args: []any{byte(1), []string{"a", "bc"}, []string{}, uint32(2)},
+			sig:  "yasasu",
+			want: []any{byte(1), []any{"a", "bc"}, []any{}, uint32(2)},
+		},
+		{
+			name: "variants",
+			args: []any{byte(1), Variant{"t", uint64(2)}, Variant{"as", []string{"x"}}, Variant{"v", Variant{"s", "nested"}}},
+			sig:  "yvvv",
+			want: []any{byte(1), Variant{"t", uint64(2)}, Variant{"as", []any{"x"}}, Variant{"v", Variant{"s", "nested"}}},
+		},
+		{
+			name: "dict of variants",
+			args: []any{"app", map[string]Variant{
+				"urgency":  {"y", byte(2)},
+				"actions":  {"as", []string{"cancel", "Cancel"}},
+				"deadline": {"t", uint64(1 << 40)},
+			}, map[string]Variant{}, int32(-1)},
+			sig: "sa{sv}a{sv}i",
+			want: []any{"app", []any{
+				[]any{"actions", Variant{"as", []any{"cancel", "Cancel"}}},
+				[]any{"deadline", Variant{"t", uint64(1 << 40)}},
+				[]any{"urgency", Variant{"y", byte(2)}},
+			}, []any{}, int32(-1)},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			e := &encoder{}
+			var sig Signature
+			for _, arg := range test.args {
+				s, err := signatureOf(arg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sig += s
+				if err := e.value(arg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if sig != test.sig {
+				t.Errorf("Signature is %s, want %s", sig, test.sig)
+			}
+
+			d := &decoder{buf: e.buf}
+			got, err := d.values(sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Decoded %#v, want %#v", got, test.want)
+			}
+			if d.pos != len(e.buf) {
+				t.Errorf("Decoded %d of %d bytes", d.pos, len(e.buf))
+			}
+		})
+	}
+}
+
+func TestEncodeAlignment(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args []any
+		want []byte
+	}{
+		{
+			name: "64 bit after byte",
+			args: []any{byte(1), uint64(2)},
+			want: []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name: "32 bit after signature",
+			args: []any{Signature("u"), uint32(3)},
+			want: []byte{1, 'u', 0, 0, 3, 0, 0, 0},
+		},
+		{
+			// The padding after the length of a dict doesn't count to its length
+			name: "empty dict",
+			args: []any{map[string]Variant{}, byte(4)},
+			want: []byte{0, 0, 0, 0, 0, 0, 0, 0, 4},
+		},
+		{
+			name: "dict entries",
+			args: []any{byte(1), map[string]Variant{"a": {"y", byte(5)}, "b": {"u", uint32(6)}}},
+			want: []byte{
+				1, 0, 0, 0, 32, 0, 0, 0, // byte, array length
+				1, 0, 0, 0, 'a', 0, 1, 'y', 0, 5, 0, 0, 0, 0, 0, 0, // {"a", <y 5>}, padded to the next entry
+				1, 0, 0, 0, 'b', 0, 1, 'u', 0, 0, 0, 0, 6, 0, 0, 0, // {"b", <u 6>}
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			e := &encoder{}
+			for _, arg := range test.args {
+				if err := e.value(arg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(e.buf, test.want) {
+				t.Errorf("Encoded %v, want %v", e.buf, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStructs(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		sig  Signature
+		buf  []byte
+		want []any
+	}{
+		{
+			name: "struct after byte",
+			sig:  "y(yt)",
+			buf: []byte{
+				7, 0, 0, 0, 0, 0, 0, 0, // byte, padding to the struct
+				1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, // (1, 2)
+			},
+			want: []any{byte(7), []any{byte(1), uint64(2)}},
+		},
+		{
+			// Like ListSessions of logind, each struct starts at 8 bytes
+			name: "array of structs",
+			sig:  "a(su)b",
+			buf: []byte{
+				24, 0, 0, 0, 0, 0, 0, 0, // array length, padding to the first struct
+				1, 0, 0, 0, 'x', 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, // ("x", 1), padding to the next struct
+				2, 0, 0, 0, 'y', 'z', 0, 0, 2, 0, 0, 0, // ("yz", 2)
+				1, 0, 0, 0, // true
+			},
+			want: []any{[]any{[]any{"x", uint32(1)}, []any{"yz", uint32(2)}}, true},
+		},
+		{
+			name: "nested struct and array",
+			sig:  "(y(qas))",
+			buf: []byte{
+				1, 0, 3, 0, 0, 0, 0, 0, // byte, padding to the inner struct
+				9, 0, 0, 0, 12, 0, 0, 0, // uint16, padding, array length
+				1, 0, 0, 0, 'a', 0, 0, 0, 1, 0, 0, 0, 'b', 0, // "a", "b"
+			},
+			want: []any{[]any{byte(1), []any{uint16(9), []any{"a", "b"}}}},
+		},
+		{
+			name: "variant holding a struct",
+			sig:  "yv",
+			buf: []byte{
+				1, 4, '(', 'b', 'n', ')', 0, 0, // byte, signature (bn), padding to the struct
+				1, 0, 0, 0, 0xfe, 0xff, // true, -2
+			},
+			want: []any{byte(1), Variant{"(bn)", []any{true, int16(-2)}}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			d := &decoder{buf: test.buf}
+			got, err := d.values(test.sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Decoded %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeShortMessage(t *testing.T) {
+	e := &encoder{}
+	e.value("text")
+	e.value(uint64(1))
+	for n := range len(e.buf) {
+		d := &decoder{buf: e.buf[:n]}
+		if _, err := d.values("st"); err == nil {
+			t.Errorf("Decoding %d of %d bytes succeeded", n, len(e.buf))
+		}
+	}
+}
+
+func TestSplitType(t *testing.T) {
+	for _, test := range []struct {
+		sig, first, rest Signature
+		valid            bool
+	}{
+		{"s", "s", "", true},
+		{"su", "s", "u", true},
+		{"a(su)b", "a(su)", "b", true},
+		{"a{sv}as", "a{sv}", "as", true},
+		{"(y(qas))t", "(y(qas))", "t", true},
+		{"aa{sa(ii)}", "aa{sa(ii)}", "", true},
+		{"(su", "", "", false},
+		{"(s}", "", "", false},
+		{"a", "", "", false},
+		{"", "", "", false},
+	} {
+		first, rest, err := splitType(test.sig)
+		if (err == nil) != test.valid || first != test.first || rest != test.rest {
+			t.Errorf("splitType(%q) = %q, %q, %v, want %q, %q, valid %t", test.sig, first, rest, err, test.first, test.rest, test.valid)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package diagnostics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	startTime       = time.Now()
+	publishes       atomic.Uint64
+	publishFailures atomic.Uint64
+	publishRetries  atomic.Uint64
+	reconnects      atomic.Uint64
+	failedConnects  atomic.Uint64
+	lastPublish     atomic.Int64
+
+	lastErrorMutex sync.Mutex
+	lastError      string
+)
+
+// RecordPublish counts a successful publish.
+func RecordPublish() {
+	publishes.Add(1)
+	lastPublish.Store(time.Now().UnixNano())
+}
+
+// RecordPublishFailure counts a publish that failed after all retries.
+func RecordPublishFailure() {
+	publishFailures.Add(1)
+}
+
+// RecordPublishRetries counts publishes that are retried.
+func RecordPublishRetries(count int) {
+	publishRetries.Add(uint64(count))
+}
+
+// RecordReconnect counts a reconnect after the initial connection.
+func RecordReconnect() {
+	reconnects.Add(1)
+}
+
+// RecordFailedConnect counts a failed attempt to connect to the broker.
+func RecordFailedConnect() {
+	failedConnects.Add(1)
+}
+
+// RecordError remembers err as the last error.
+func RecordError(err error) {
+	lastErrorMutex.Lock()
+	defer lastErrorMutex.Unlock()
+	lastError = err.Error()
+}
+
+func Uptime() time.Duration {
+	return time.Since(startTime)
+}
+
+func Publishes() uint64 {
+	return publishes.Load()
+}
+
+func PublishFailures() uint64 {
+	return publishFailures.Load()
+}
+
+func PublishRetries() uint64 {
+	return publishRetries.Load()
+}
+
+// LastPublish returns the time of the last successful publish, or the zero time.
+func LastPublish() time.Time {
+	nanos := lastPublish.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func Reconnects() uint64 {
+	return reconnects.Load()
+}
+
+// FailedConnects returns the failed attempts to connect to the broker.
+func FailedConnects() uint64 {
+	return failedConnects.Load()
+}
+
+func LastError() string {
+	lastErrorMutex.Lock()
+	defer lastErrorMutex.Unlock()
+	return lastError
+}
@@ -0,0 +1,205 @@
+// Package logging provides leveled, structured loggers per module on top of log/slog.
+// Loggers can be created before the config is loaded; Setup applies the format and levels later.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+type state struct {
+	handler slog.Handler
+	format  string
+	level   slog.Level
+	modules map[string]slog.Level
+}
+
+// mirror is an additional output receiving the records at level or above, eg. MQTT.
+type mirror struct {
+	handler slog.Handler
+	level   slog.Level
+}
+
+func (s *state) levelFor(module string) slog.Level {
+	if level, ok := s.modules[module]; ok {
+		return level
+	}
+	return s.level
+}
+
+var (
+	current       atomic.Pointer[state]
+	currentMirror atomic.Pointer[mirror]
+)
+
+func init() {
+	current.Store(&state{
+		handler: newHandler(os.Stderr, appconfig.LogFormatText, slog.LevelDebug),
+		format:  appconfig.LogFormatText,
+		level:   slog.LevelInfo,
+	})
+	slog.SetDefault(For(""))
+}
+
+// Setup applies the logging config. debug lowers the default level to debug like debug_mode always did,
+// except for the MQTT client.
+func Setup(conf appconfig.LoggingAppConfig, debug bool) error {
+	level, err := ParseLevel(conf.Level)
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]slog.Level)
+	if debug {
+		// The MQTT client's debug output is only useful when asked for explicitly
+		modules[appconfig.LogModuleMqtt] = level
+		level = min(level, slog.LevelDebug)
+	}
+	for module, name := range conf.Modules {
+		if modules[module], err = ParseLevel(name); err != nil {
+			return err
+		}
+	}
+
+	// Module levels may be lower than the default, so the handler itself lets everything through
+	current.Store(&state{
+		handler: newHandler(os.Stderr, conf.Format, slog.LevelDebug),
+		format:  conf.Format,
+		level:   level,
+		modules: modules,
+	})
+	slog.SetDefault(For(""))
+	setupPahoLoggers()
+	return nil
+}
+
+// Mirror also writes the records at level or above, which pass the module levels, to w in the
+// configured format with one Write call per record, until the returned function is called.
+// Records of the MQTT client are left out, as publishing the mirrored records would log again.
+// Only one mirror is active at a time.
+func Mirror(w io.Writer, level slog.Level) (stop func()) {
+	m := &mirror{handler: newHandler(w, current.Load().format, level), level: level}
+	currentMirror.Store(m)
+	return func() {
+		currentMirror.CompareAndSwap(m, nil)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("Invalid log level %q. Use debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+func newHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	if format == appconfig.LogFormatJson {
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	}
+
+	// Service managers like systemd and launchd add their own timestamps
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	})
+}
+
+// For returns the logger of module. Its level can be set in logging.modules.
+func For(module string) *slog.Logger {
+	return slog.New(&moduleHandler{module: module})
+}
+
+// moduleHandler resolves the current handler and level on every record, so loggers
+// created in package variables pick up the config once it is loaded.
+type moduleHandler struct {
+	module string
+	ops    []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= current.Load().levelFor(h.module)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, record slog.Record) error {
+	err := h.resolve(current.Load().handler).Handle(ctx, record)
+	if m := currentMirror.Load(); m != nil && record.Level >= m.level && h.module != appconfig.LogModuleMqtt {
+		err = errors.Join(err, h.resolve(m.handler).Handle(ctx, record.Clone()))
+	}
+	return err
+}
+
+// resolve adds the module and the attributes and groups of the logger to handler.
+func (h *moduleHandler) resolve(handler slog.Handler) slog.Handler {
+	if h.module != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	}
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler
+}
+
+func (h *moduleHandler) with(op func(slog.Handler) slog.Handler) *moduleHandler {
+	return &moduleHandler{module: h.module, ops: append(h.ops[:len(h.ops):len(h.ops)], op)}
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// pahoLogger forwards the log output of the paho MQTT client to the "mqtt" module.
+type pahoLogger struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// Println and Printf skip formatting paho's very chatty debug output unless it is logged.
+func (l pahoLogger) Println(v ...any) {
+	if l.logger.Enabled(context.Background(), l.level) {
+		l.logger.Log(context.Background(), l.level, strings.TrimSpace(fmt.Sprintln(v...)))
+	}
+}
+
+func (l pahoLogger) Printf(format string, v ...any) {
+	if l.logger.Enabled(context.Background(), l.level) {
+		l.logger.Log(context.Background(), l.level, strings.TrimSpace(fmt.Sprintf(format, v...)))
+	}
+}
+
+var pahoLoggersOnce sync.Once
+
+// setupPahoLoggers hooks paho up once, its loggers follow later Setup calls. Replacing them while paho
+// clients run would race with their goroutines.
+func setupPahoLoggers() {
+	pahoLoggersOnce.Do(func() {
+		logger := For(appconfig.LogModuleMqtt)
+		mqtt.CRITICAL = pahoLogger{logger, slog.LevelError}
+		mqtt.ERROR = pahoLogger{logger, slog.LevelError}
+		mqtt.WARN = pahoLogger{logger, slog.LevelWarn}
+		mqtt.DEBUG = pahoLogger{logger, slog.LevelDebug}
+	})
+}
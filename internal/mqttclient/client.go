@@ -0,0 +1,95 @@
+// Package mqttclient defines the narrow MQTT client pc2mqtt publishes and subscribes with,
+// implemented by the paho client and by an in-memory fake for tests.
+package mqttclient
+
+import (
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Client publishes and subscribes on a broker connection. Connecting and disconnecting stay
+// with the owner of the underlying client.
+type Client interface {
+	Publisher
+	Subscriber
+	// IsConnectionOpen reports whether the connection to the broker is currently up.
+	IsConnectionOpen() bool
+}
+
+type Publisher interface {
+	// Publish sends payload, a string or []byte. The token completes once the broker acknowledged it.
+	Publish(topic string, qos byte, retain bool, payload any) Token
+}
+
+type Subscriber interface {
+	// Subscribe calls handler with every message on the topic filters, which map to their QoS.
+	Subscribe(filters map[string]byte, handler MessageHandler) Token
+	Unsubscribe(topics ...string) Token
+}
+
+// Token completes when the broker acknowledged an operation.
+type Token interface {
+	Wait() bool
+	WaitTimeout(timeout time.Duration) bool
+	Done() <-chan struct{}
+	Error() error
+}
+
+// Message is a message received on a subscription.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Qos      byte
+	Retained bool
+}
+
+type MessageHandler func(msg Message)
+
+// FromPaho adapts a paho client.
+func FromPaho(client mqtt.Client) Client {
+	return pahoClient{client}
+}
+
+type pahoClient struct {
+	client mqtt.Client
+}
+
+func (paho pahoClient) Publish(topic string, qos byte, retain bool, payload any) Token {
+	return paho.client.Publish(topic, qos, retain, payload)
+}
+
+func (paho pahoClient) Subscribe(filters map[string]byte, handler MessageHandler) Token {
+	return paho.client.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+		handler(Message{
+			Topic:    msg.Topic(),
+			Payload:  msg.Payload(),
+			Qos:      msg.Qos(),
+			Retained: msg.Retained(),
+		})
+	})
+}
+
+func (paho pahoClient) Unsubscribe(topics ...string) Token {
+	return paho.client.Unsubscribe(topics...)
+}
+
+func (paho pahoClient) IsConnectionOpen() bool {
+	return paho.client.IsConnectionOpen()
+}
+
+// MatchTopic reports whether topic matches filter, which may contain + and # wildcards.
+func MatchTopic(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
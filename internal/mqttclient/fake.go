@@ -0,0 +1,139 @@
+package mqttclient
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory Client for tests. It records published messages and delivers
+// messages passed to Deliver to the matching subscriptions.
+type Fake struct {
+	mu            sync.Mutex
+	connected     bool
+	published     []Message
+	subscriptions map[string]MessageHandler
+	publishErr    error
+}
+
+// NewFake returns a connected fake client.
+func NewFake() *Fake {
+	return &Fake{
+		connected:     true,
+		subscriptions: make(map[string]MessageHandler),
+	}
+}
+
+func (fake *Fake) Publish(topic string, qos byte, retain bool, payload any) Token {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.publishErr != nil {
+		return doneToken{fake.publishErr}
+	}
+
+	msg := Message{Topic: topic, Qos: qos, Retained: retain}
+	switch p := payload.(type) {
+	case string:
+		msg.Payload = []byte(p)
+	case []byte:
+		msg.Payload = p
+	}
+	fake.published = append(fake.published, msg)
+	return doneToken{}
+}
+
+func (fake *Fake) Subscribe(filters map[string]byte, handler MessageHandler) Token {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for filter := range filters {
+		fake.subscriptions[filter] = handler
+	}
+	return doneToken{}
+}
+
+func (fake *Fake) Unsubscribe(topics ...string) Token {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, topic := range topics {
+		delete(fake.subscriptions, topic)
+	}
+	return doneToken{}
+}
+
+func (fake *Fake) IsConnectionOpen() bool {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.connected
+}
+
+// SetConnected simulates losing or regaining the broker connection.
+func (fake *Fake) SetConnected(connected bool) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.connected = connected
+}
+
+// FailPublishes makes every following publish fail with err, nil publishes again.
+func (fake *Fake) FailPublishes(err error) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.publishErr = err
+}
+
+// Published returns the messages published so far, in order.
+func (fake *Fake) Published() []Message {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return slices.Clone(fake.published)
+}
+
+// Subscriptions returns the subscribed topic filters, sorted.
+func (fake *Fake) Subscriptions() []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return slices.Sorted(maps.Keys(fake.subscriptions))
+}
+
+// Deliver passes msg to the handlers of all subscriptions matching its topic, as if the broker sent it.
+func (fake *Fake) Deliver(msg Message) {
+	fake.mu.Lock()
+	var handlers []MessageHandler
+	for filter, handler := range fake.subscriptions {
+		if MatchTopic(filter, msg.Topic) {
+			handlers = append(handlers, handler)
+		}
+	}
+	fake.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(msg)
+	}
+}
+
+// doneToken is a token that completed right away.
+type doneToken struct {
+	err error
+}
+
+var closedChannel = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+func (token doneToken) Wait() bool {
+	return true
+}
+
+func (token doneToken) WaitTimeout(time.Duration) bool {
+	return true
+}
+
+func (token doneToken) Done() <-chan struct{} {
+	return closedChannel
+}
+
+func (token doneToken) Error() error {
+	return token.err
+}
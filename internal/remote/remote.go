@@ -0,0 +1,208 @@
+// Package remote controls other machines over SSH, without pc2mqtt running on them.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+	"github.com/leonlatsch/pc2mqtt/internal/system"
+)
+
+const defaultSshPort = 22
+
+// connectTimeout bounds connecting to a host, both for SSH and for checking whether it is up
+const connectTimeout = 5 * time.Second
+
+// Host is a machine controlled over SSH.
+type Host struct {
+	Name   string
+	config appconfig.HostAppConfig
+}
+
+// NewHost returns the host name configured with config.
+func NewHost(name string, config appconfig.HostAppConfig) Host {
+	return Host{Name: name, config: config}
+}
+
+// Os returns the operating system of the host, linux if not configured.
+func (host Host) Os() string {
+	if host.config.Os == "" {
+		return system.LINUX
+	}
+	return host.config.Os
+}
+
+func (host Host) port() int {
+	if host.config.Port == 0 {
+		return defaultSshPort
+	}
+	return host.config.Port
+}
+
+// Command returns the ssh command running remoteCommand on the host. ssh never prompts,
+// so a missing key or unknown host key fails instead of hanging.
+func (host Host) Command(ctx context.Context, remoteCommand string) *exec.Cmd {
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=" + strconv.Itoa(int(connectTimeout.Seconds())),
+		"-p", strconv.Itoa(host.port()),
+	}
+	if host.config.SshKey != "" {
+		args = append(args, "-i", host.config.SshKey)
+	}
+	target := host.config.Address
+	if host.config.User != "" {
+		target = host.config.User + "@" + target
+	}
+	// -- ends the options, so neither the target nor the command is read as one
+	args = append(args, "--", target, remoteCommand)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// Run runs remoteCommand on the host and returns its trimmed output.
+func (host Host) Run(ctx context.Context, remoteCommand string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := host.Command(ctx, remoteCommand)
+	cmd.Stdout = &stdout
+	if err := system.RunCommand(cmd); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// sudo prefixes command with sudo, unless the host is logged into as root. -n fails instead of asking for a password.
+func (host Host) sudo(command string) string {
+	if host.config.User == "root" {
+		return command
+	}
+	return "sudo -n " + command
+}
+
+// Shutdown powers the host off.
+func (host Host) Shutdown(ctx context.Context) error {
+	command := host.sudo("systemctl poweroff")
+	switch host.Os() {
+	case system.WINDOWS:
+		command = "shutdown /s /t 0"
+	case system.MACOS:
+		command = host.sudo("shutdown -h now")
+	case system.FREEBSD:
+		command = host.sudo("shutdown -p now")
+	}
+	_, err := host.Run(ctx, command)
+	return err
+}
+
+// Reboot restarts the host.
+func (host Host) Reboot(ctx context.Context) error {
+	command := host.sudo("systemctl reboot")
+	switch host.Os() {
+	case system.WINDOWS:
+		command = "shutdown /r /t 0"
+	case system.MACOS, system.FREEBSD:
+		command = host.sudo("shutdown -r now")
+	}
+	_, err := host.Run(ctx, command)
+	return err
+}
+
+// Wake sends a Wake-on-LAN packet to the host.
+func (host Host) Wake() error {
+	if host.config.Mac == "" {
+		return errors.New("No mac configured for host " + host.Name)
+	}
+	broadcast := host.config.Broadcast
+	if broadcast == "" {
+		broadcast = system.WakeOnLanBroadcast
+	}
+	return system.WakeOnLan(host.config.Mac, broadcast)
+}
+
+// CanWake reports whether a MAC address for Wake-on-LAN is configured.
+func (host Host) CanWake() bool {
+	return host.config.Mac != ""
+}
+
+// Uptime reads how long the host is running.
+func (host Host) Uptime(ctx context.Context) (time.Duration, error) {
+	switch host.Os() {
+	case system.WINDOWS:
+		out, err := host.Run(ctx, `powershell -NoProfile -Command "[int]((Get-Date) - (Get-CimInstance Win32_OperatingSystem).LastBootUpTime).TotalSeconds"`)
+		if err != nil {
+			return 0, err
+		}
+		seconds, err := strconv.Atoi(out)
+		if err != nil {
+			return 0, fmt.Errorf("Unexpected uptime %q", out)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	case system.MACOS, system.FREEBSD:
+		// { sec = 1700000000, usec = 0 } Tue Nov 14 22:13:20 2023
+		out, err := host.Run(ctx, "sysctl -n kern.boottime")
+		if err != nil {
+			return 0, err
+		}
+		var sec, usec int64
+		if _, err := fmt.Sscanf(out, "{ sec = %d, usec = %d }", &sec, &usec); err != nil {
+			return 0, fmt.Errorf("Unexpected boot time %q", out)
+		}
+		return time.Since(time.Unix(sec, usec*1000)), nil
+	default:
+		// 12345.67 23456.78
+		out, err := host.Run(ctx, "cat /proc/uptime")
+		if err != nil {
+			return 0, err
+		}
+		fields := strings.Fields(out)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("Unexpected uptime %q", out)
+		}
+		seconds, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("Unexpected uptime %q", out)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+}
+
+// Reachable reports whether the SSH port of the host accepts connections.
+func (host Host) Reachable(ctx context.Context) bool {
+	dialer := net.Dialer{Timeout: connectTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host.config.Address, strconv.Itoa(host.port())))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// online holds the hosts found reachable by the last check
+var (
+	onlineMu sync.Mutex
+	online   = make(map[string]bool)
+)
+
+// Online reports whether the host name was reachable at the last check.
+func Online(name string) bool {
+	onlineMu.Lock()
+	defer onlineMu.Unlock()
+	return online[name]
+}
+
+// SetOnline records the result of checking the host name and reports whether it changed.
+func SetOnline(name string, reachable bool) bool {
+	onlineMu.Lock()
+	defer onlineMu.Unlock()
+	changed := online[name] != reachable
+	online[name] = reachable
+	return changed
+}
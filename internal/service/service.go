@@ -0,0 +1,56 @@
+// Package service installs pc2mqtt as a service of the OS service manager.
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// Config describes the service to install.
+type Config struct {
+	Name        string
+	Description string
+	// Executable is the absolute path of the pc2mqtt binary.
+	Executable string
+	// Args are passed to the executable, eg. the -config flag.
+	Args []string
+	// WorkingDir is the directory of the config file. pc2mqtt keeps its state file there.
+	WorkingDir string
+	// User installs a service of the current user instead of a system wide one.
+	User bool
+}
+
+// Install registers the service, enables it at boot or login and starts it.
+func Install(conf Config) error {
+	return install(conf)
+}
+
+// Uninstall stops and removes the service.
+func Uninstall(conf Config) error {
+	return uninstall(conf)
+}
+
+// Status prints the state of the service as reported by the service manager.
+func Status(conf Config) error {
+	return status(conf)
+}
+
+// Restart restarts the installed service, eg. to pick up an updated binary.
+func Restart(conf Config) error {
+	return restart(conf)
+}
+
+// Run runs the bridge as a service started by the service manager, which stops it by canceling ctx.
+// Only Windows services need it, other service managers run the bridge directly.
+func Run(conf Config, run func(ctx context.Context) error) error {
+	return runService(conf, run)
+}
+
+// run executes a service manager command with its output shown to the user.
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
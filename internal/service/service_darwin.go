@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+const labelPrefix = "com.github.leonlatsch."
+
+var plistTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{{xml .Label}}</string>
+    <key>ProgramArguments</key>
+    <array>
+{{- range .Arguments}}
+        <string>{{xml .}}</string>
+{{- end}}
+    </array>
+    <key>WorkingDirectory</key>
+    <string>{{xml .WorkingDir}}</string>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <true/>
+    <key>StandardOutPath</key>
+    <string>{{xml .LogPath}}</string>
+    <key>StandardErrorPath</key>
+    <string>{{xml .LogPath}}</string>
+</dict>
+</plist>
+`))
+
+func xmlEscape(value string) (string, error) {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(value))
+	return buf.String(), err
+}
+
+// plistPath returns the LaunchAgent of the current user, which starts at login,
+// or the system wide LaunchDaemon, which starts at boot and runs as root.
+func plistPath(conf Config) (string, error) {
+	if !conf.User {
+		return filepath.Join("/Library/LaunchDaemons", labelPrefix+conf.Name+".plist"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", labelPrefix+conf.Name+".plist"), nil
+}
+
+func domain(conf Config) string {
+	if conf.User {
+		return "gui/" + strconv.Itoa(os.Getuid())
+	}
+	return "system"
+}
+
+func install(conf Config) error {
+	if !conf.User && os.Geteuid() != 0 {
+		return errors.New("Installing a LaunchDaemon requires root. Use sudo or -user for a LaunchAgent starting at login")
+	}
+
+	path, err := plistPath(conf)
+	if err != nil {
+		return err
+	}
+
+	var plist bytes.Buffer
+	err = plistTemplate.Execute(&plist, map[string]any{
+		"Label":      labelPrefix + conf.Name,
+		"Arguments":  append([]string{conf.Executable}, conf.Args...),
+		"WorkingDir": conf.WorkingDir,
+		"LogPath":    filepath.Join(conf.WorkingDir, conf.Name+".log"),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, plist.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	return run("launchctl", "bootstrap", domain(conf), path)
+}
+
+func uninstall(conf Config) error {
+	path, err := plistPath(conf)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("Service %s is not installed: %w", conf.Name, err)
+	}
+
+	if err := run("launchctl", "bootout", domain(conf), path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", path)
+	return nil
+}
+
+func status(conf Config) error {
+	return run("launchctl", "print", domain(conf)+"/"+labelPrefix+conf.Name)
+}
+
+func restart(conf Config) error {
+	return run("launchctl", "kickstart", "-k", domain(conf)+"/"+labelPrefix+conf.Name)
+}
+
+func runService(conf Config, run func(ctx context.Context) error) error {
+	return errors.New("launchd runs pc2mqtt directly, service run is only used on Windows")
+}
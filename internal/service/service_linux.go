@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Sandboxing is limited to what keeps shutdown and reboot working through logind and
+// systemd, which are reached over D-Bus unix sockets. The binary directory stays writable
+// for the update entity.
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description={{.Description}}
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{.ExecStart}}
+WorkingDirectory={{.WorkingDir}}
+Restart=always
+RestartSec=5
+NoNewPrivileges=true
+{{- if not .User}}
+ProtectSystem=strict
+ProtectHome=read-only
+ReadWritePaths={{.WorkingDir}}{{if ne .BinaryDir .WorkingDir}} {{.BinaryDir}}{{end}}
+PrivateTmp=true
+ProtectKernelTunables=true
+ProtectKernelModules=true
+ProtectControlGroups=true
+RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6 AF_NETLINK
+RestrictRealtime=true
+LockPersonality=true
+{{- end}}
+
+[Install]
+WantedBy={{if .User}}default.target{{else}}multi-user.target{{end}}
+`))
+
+func unitPath(conf Config) (string, error) {
+	if !conf.User {
+		return filepath.Join("/etc/systemd/system", conf.Name+".service"), nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user", conf.Name+".service"), nil
+}
+
+func systemctl(conf Config, args ...string) error {
+	if conf.User {
+		args = append([]string{"--user"}, args...)
+	}
+	return run("systemctl", args...)
+}
+
+// quoteArg quotes an ExecStart argument when it contains characters systemd would split on.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\") {
+		return arg
+	}
+	return strconv.Quote(arg)
+}
+
+func install(conf Config) error {
+	if !conf.User && os.Geteuid() != 0 {
+		return errors.New("Installing a system service requires root. Use sudo or -user for a user service")
+	}
+
+	path, err := unitPath(conf)
+	if err != nil {
+		return err
+	}
+
+	execStart := []string{quoteArg(conf.Executable)}
+	for _, arg := range conf.Args {
+		execStart = append(execStart, quoteArg(arg))
+	}
+
+	var unit bytes.Buffer
+	err = unitTemplate.Execute(&unit, map[string]any{
+		"Description": conf.Description,
+		"ExecStart":   strings.Join(execStart, " "),
+		"WorkingDir":  conf.WorkingDir,
+		"BinaryDir":   filepath.Dir(conf.Executable),
+		"User":        conf.User,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, unit.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	if err := systemctl(conf, "daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl(conf, "enable", "--now", conf.Name)
+}
+
+func uninstall(conf Config) error {
+	path, err := unitPath(conf)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("Service %s is not installed: %w", conf.Name, err)
+	}
+
+	if err := systemctl(conf, "disable", "--now", conf.Name); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", path)
+
+	return systemctl(conf, "daemon-reload")
+}
+
+func status(conf Config) error {
+	err := systemctl(conf, "status", "--no-pager", conf.Name)
+	// systemctl status exits with 3 for stopped services, which is a valid status
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 3 {
+		return nil
+	}
+	return err
+}
+
+func restart(conf Config) error {
+	return systemctl(conf, "restart", conf.Name)
+}
+
+func runService(conf Config, run func(ctx context.Context) error) error {
+	return errors.New("systemd runs pc2mqtt directly, service run is only used on Windows")
+}
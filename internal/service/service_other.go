@@ -0,0 +1,31 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+var errUnsupported = errors.New("Installing a service is not supported on " + runtime.GOOS)
+
+func install(conf Config) error {
+	return errUnsupported
+}
+
+func uninstall(conf Config) error {
+	return errUnsupported
+}
+
+func status(conf Config) error {
+	return errUnsupported
+}
+
+func restart(conf Config) error {
+	return errUnsupported
+}
+
+func runService(conf Config, run func(ctx context.Context) error) error {
+	return errUnsupported
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var stateNames = map[svc.State]string{
+	svc.Stopped:      "stopped",
+	svc.StartPending: "starting",
+	svc.StopPending:  "stopping",
+	svc.Running:      "running",
+}
+
+func connectManager() (*mgr.Mgr, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open the service control manager, run as administrator: %w", err)
+	}
+	return manager, nil
+}
+
+func openService(manager *mgr.Mgr, name string) (*mgr.Service, error) {
+	service, err := manager.OpenService(name)
+	if err != nil {
+		return nil, fmt.Errorf("Service %s is not installed: %w", name, err)
+	}
+	return service, nil
+}
+
+func install(conf Config) error {
+	if conf.User {
+		return errors.New("Windows services always run system wide, -user is not supported")
+	}
+
+	manager, err := connectManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+
+	// The service manager starts "pc2mqtt <args> service run", which connects back to it
+	args := append(conf.Args, "service", "run", "-name", conf.Name)
+	service, err := manager.CreateService(conf.Name, conf.Executable, mgr.Config{
+		DisplayName:  conf.Name,
+		Description:  conf.Description,
+		StartType:    mgr.StartAutomatic,
+		ErrorControl: mgr.ErrorNormal,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("Failed to create service %s: %w", conf.Name, err)
+	}
+	defer service.Close()
+
+	// Restart after 5 seconds when pc2mqtt fails, like Restart=always on systemd. This also restarts
+	// it into a binary installed by the update entity, which stops the service with an error.
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 5 * time.Second}
+	if err := service.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, 24*60*60); err != nil {
+		return fmt.Errorf("Failed to set the recovery actions of service %s: %w", conf.Name, err)
+	}
+	if err := service.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		return fmt.Errorf("Failed to set the recovery actions of service %s: %w", conf.Name, err)
+	}
+	fmt.Printf("Installed service %s\n", conf.Name)
+
+	if err := service.Start(); err != nil {
+		return fmt.Errorf("Failed to start service %s: %w", conf.Name, err)
+	}
+	fmt.Printf("Started service %s\n", conf.Name)
+	return nil
+}
+
+func uninstall(conf Config) error {
+	manager, err := connectManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+
+	service, err := openService(manager, conf.Name)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+
+	if _, err := service.Control(svc.Stop); err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return fmt.Errorf("Failed to stop service %s: %w", conf.Name, err)
+	}
+
+	if err := service.Delete(); err != nil {
+		return fmt.Errorf("Failed to delete service %s: %w", conf.Name, err)
+	}
+	fmt.Printf("Removed service %s\n", conf.Name)
+	return nil
+}
+
+func status(conf Config) error {
+	manager, err := connectManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+
+	service, err := openService(manager, conf.Name)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+
+	status, err := service.Query()
+	if err != nil {
+		return err
+	}
+
+	state, ok := stateNames[status.State]
+	if !ok {
+		state = fmt.Sprintf("state %d", status.State)
+	}
+	fmt.Printf("Service %s is %s\n", conf.Name, state)
+	return nil
+}
+
+func restart(conf Config) error {
+	manager, err := connectManager()
+	if err != nil {
+		return err
+	}
+	defer manager.Disconnect()
+
+	service, err := openService(manager, conf.Name)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+
+	status, err := service.Control(svc.Stop)
+	if err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return fmt.Errorf("Failed to stop service %s: %w", conf.Name, err)
+	}
+
+	// The service publishes its offline status before it stops, give it time to do so
+	deadline := time.Now().Add(30 * time.Second)
+	for err == nil && status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timeout waiting for service %s to stop", conf.Name)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if status, err = service.Query(); err != nil {
+			return err
+		}
+	}
+
+	if err := service.Start(); err != nil {
+		return fmt.Errorf("Failed to start service %s: %w", conf.Name, err)
+	}
+	fmt.Printf("Restarted service %s\n", conf.Name)
+	return nil
+}
+
+// handler runs the bridge for the service manager until it asks the service to stop.
+type handler struct {
+	run func(ctx context.Context) error
+	err error
+}
+
+func (h *handler) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- h.run(ctx)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case h.err = <-done:
+			if h.err != nil {
+				// A service specific exit code lets the recovery actions restart the service
+				return true, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+			}
+		}
+	}
+}
+
+func runService(conf Config, run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return errors.New("service run is started by the Windows service manager. Use service install instead")
+	}
+
+	// Services have no console, log to a file next to the config instead
+	if conf.WorkingDir != "" {
+		logFile, err := os.OpenFile(filepath.Join(conf.WorkingDir, conf.Name+".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			os.Stderr = logFile
+			defer logFile.Close()
+		}
+	}
+
+	h := &handler{run: run}
+	if err := svc.Run(conf.Name, h); err != nil {
+		return err
+	}
+	return h.err
+}
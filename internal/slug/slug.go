@@ -0,0 +1,50 @@
+package slug
+
+import "strings"
+
+// transliterations spells out letters that have no ASCII equivalent, the rest of the accents is dropped.
+var transliterations = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'æ': "ae", 'œ': "oe", 'ø': "o", 'å': "a",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a",
+	'ç': "c", 'č': "c", 'ć': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y",
+	'ł': "l", 'đ': "d", 'ð': "d", 'þ': "th",
+	'ř': "r", 'š': "s", 'ś': "s", 'ž': "z", 'ź': "z", 'ż': "z",
+}
+
+// Make turns text into a slug usable in MQTT topics, unique ids and Home Assistant entity ids,
+// eg. "Leon's Büro PC" into "leons_buero_pc". Lowercase letters, digits, underscores and hyphens are kept,
+// so a name that already is a slug doesn't change. Any other run of characters becomes a single
+// underscore. Text without a single letter or digit gives an empty slug.
+func Make(text string) string {
+	var slug strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-':
+			slug.WriteRune(r)
+		case transliterations[r] != "":
+			slug.WriteString(transliterations[r])
+		case r == '\'' || r == '’' || r == '`' || r == '´':
+			// Apostrophes join the word, "Leon's" is "leons"
+		case !strings.HasSuffix(slug.String(), "_"):
+			slug.WriteByte('_')
+		}
+	}
+	return strings.Trim(slug.String(), "_-")
+}
+
+// EntityId turns text into the object id of a Home Assistant entity id, the part after the domain. Those allow
+// neither hyphens nor repeated underscores, eg. "my-pc__cpu" gives my_pc_cpu.
+func EntityId(text string) string {
+	id := strings.ReplaceAll(Make(text), "-", "_")
+	for strings.Contains(id, "__") {
+		id = strings.ReplaceAll(id, "__", "_")
+	}
+	return strings.Trim(id, "_")
+}
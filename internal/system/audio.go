@@ -0,0 +1,70 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// audioInputsTtl is how long the audio inputs read are reused, the entities listing them are built often
+const audioInputsTtl = 30 * time.Second
+
+// audioTimeout bounds a call of the audio tool
+const audioTimeout = 10 * time.Second
+
+// AudioDevice is a microphone or another audio input.
+type AudioDevice struct {
+	// Id is what the audio tool selects the device by
+	Id string
+	// Name is shown to the user
+	Name string
+}
+
+var audioInputs struct {
+	mu      sync.Mutex
+	devices []AudioDevice
+	read    time.Time
+}
+
+// AudioInputs returns the audio inputs of the PC, read again after audioInputsTtl, eg. once a headset is plugged
+// in. None if no supported audio tool is installed.
+func AudioInputs() []AudioDevice {
+	audioInputs.mu.Lock()
+	defer audioInputs.mu.Unlock()
+	if time.Since(audioInputs.read) < audioInputsTtl {
+		return audioInputs.devices
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), audioTimeout)
+	defer cancel()
+	// Without the tool or an audio server, eg. before the user logged in, there are no inputs
+	devices, _ := readAudioInputs(ctx)
+	audioInputs.devices, audioInputs.read = devices, time.Now()
+	return devices
+}
+
+// GetAudioInput returns the name of the default audio input.
+func GetAudioInput(ctx context.Context) (string, error) {
+	id, err := defaultAudioInput(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, device := range AudioInputs() {
+		if device.Id == id {
+			return device.Name, nil
+		}
+	}
+	return id, nil
+}
+
+// SetAudioInput makes the audio input named name the default, also for the programs recording right now where
+// the OS supports it.
+func SetAudioInput(name string) error {
+	for _, device := range AudioInputs() {
+		if device.Name == name {
+			return setDefaultAudioInput(device.Id)
+		}
+	}
+	return errors.New("Unknown audio input " + name)
+}
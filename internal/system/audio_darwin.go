@@ -0,0 +1,33 @@
+package system
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// readAudioInputs lists the input devices with SwitchAudioSource of switchaudio-osx, which selects them by name.
+func readAudioInputs(ctx context.Context) ([]AudioDevice, error) {
+	out, err := exec.CommandContext(ctx, "SwitchAudioSource", "-a", "-t", "input").Output()
+	if err != nil {
+		return nil, err
+	}
+	var devices []AudioDevice
+	for _, name := range strings.Split(string(out), "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			devices = append(devices, AudioDevice{Id: name, Name: name})
+		}
+	}
+	return devices, nil
+}
+
+// defaultAudioInput returns the name of the default input device.
+func defaultAudioInput(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "SwitchAudioSource", "-c", "-t", "input").Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// setDefaultAudioInput makes the input device named id the default.
+func setDefaultAudioInput(id string) error {
+	return RunCommand(exec.Command("SwitchAudioSource", "-t", "input", "-s", id))
+}
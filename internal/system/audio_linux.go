@@ -0,0 +1,95 @@
+package system
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// readAudioInputs lists the sources of PulseAudio or PipeWire with pactl, without the monitors of the outputs.
+func readAudioInputs(ctx context.Context) ([]AudioDevice, error) {
+	out, err := pactl(ctx, "list", "sources")
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks start with "Source #<index>" followed by indented "Name: " and "Description: " lines
+	var devices []AudioDevice
+	lines := bufio.NewScanner(strings.NewReader(out))
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if name, ok := strings.CutPrefix(line, "Name: "); ok {
+			devices = append(devices, AudioDevice{Id: name, Name: name})
+		} else if description, ok := strings.CutPrefix(line, "Description: "); ok && len(devices) > 0 {
+			devices[len(devices)-1].Name = description
+		}
+	}
+
+	var inputs []AudioDevice
+	for _, device := range devices {
+		if !strings.HasSuffix(device.Id, ".monitor") {
+			inputs = append(inputs, device)
+		}
+	}
+	return inputs, nil
+}
+
+// defaultAudioInput returns the name of the default source.
+func defaultAudioInput(ctx context.Context) (string, error) {
+	out, err := pactl(ctx, "info")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if name, ok := strings.CutPrefix(line, "Default Source: "); ok {
+			return strings.TrimSpace(name), nil
+		}
+	}
+	return "", errors.New("pactl reports no default source")
+}
+
+// setDefaultAudioInput makes the source id the default, which PipeWire and PulseAudio also move the streams to.
+func setDefaultAudioInput(id string) error {
+	cmd := exec.Command("pactl", "set-default-source", id)
+	if err := inUserSession(cmd); err != nil {
+		return err
+	}
+	return RunCommand(cmd)
+}
+
+// pactl runs pactl with args in the session of the user and returns its output, in English for parsing.
+func pactl(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "pactl", args...)
+	if err := inUserSession(cmd); err != nil {
+		return "", err
+	}
+	cmd.Env = append(cmd.Env, "LC_ALL=C")
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// inUserSession switches cmd to the user logged in on seat0 if pc2mqtt runs as root, eg. as a system service,
+// for tools talking to a server of the session, like the audio server.
+func inUserSession(cmd *exec.Cmd) error {
+	if os.Getuid() != 0 {
+		cmd.Env = os.Environ()
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), logindTimeout)
+	defer cancel()
+	uid, err := activeUid(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return err
+	}
+	_, err = withCredential(cmd, account)
+	return err
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !windows && !darwin
+
+package system
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+var errAudioUnsupported = errors.New(runtime.GOOS + " does not support selecting the audio input")
+
+// readAudioInputs returns an error, audio inputs are only selected on Linux, Windows and macOS.
+func readAudioInputs(ctx context.Context) ([]AudioDevice, error) {
+	return nil, errAudioUnsupported
+}
+
+func defaultAudioInput(ctx context.Context) (string, error) {
+	return "", errAudioUnsupported
+}
+
+func setDefaultAudioInput(id string) error {
+	return errAudioUnsupported
+}
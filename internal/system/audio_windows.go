@@ -0,0 +1,60 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// errNoAudioCmdlets is returned without the AudioDeviceCmdlets module, Windows has no tool switching the default
+// audio device
+var errNoAudioCmdlets = errors.New("Selecting the audio input needs the PowerShell module AudioDeviceCmdlets")
+
+// readAudioInputs lists the recording devices with Get-AudioDevice of the AudioDeviceCmdlets module.
+func readAudioInputs(ctx context.Context) ([]AudioDevice, error) {
+	out, err := audioCmdlet(ctx, `Get-AudioDevice -List | Where-Object Type -eq 'Recording' | ForEach-Object { $_.ID + [char]9 + $_.Name }`)
+	if err != nil {
+		return nil, err
+	}
+	var devices []AudioDevice
+	for _, line := range strings.Split(out, "\n") {
+		if id, name, ok := strings.Cut(strings.TrimSpace(line), "\t"); ok {
+			devices = append(devices, AudioDevice{Id: id, Name: name})
+		}
+	}
+	return devices, nil
+}
+
+// defaultAudioInput returns the id of the default recording device.
+func defaultAudioInput(ctx context.Context) (string, error) {
+	out, err := audioCmdlet(ctx, `(Get-AudioDevice -Recording).ID`)
+	return strings.TrimSpace(out), err
+}
+
+// setDefaultAudioInput makes the recording device id the default, also for communication.
+func setDefaultAudioInput(id string) error {
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would call Set-AudioDevice -ID "+id)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), audioTimeout)
+	defer cancel()
+	_, err := audioCmdlet(ctx, `Set-AudioDevice -ID $env:PC2MQTT_AUDIO_ID | Out-Null`, "PC2MQTT_AUDIO_ID="+id)
+	return err
+}
+
+// audioCmdlet runs script in PowerShell with the AudioDeviceCmdlets module and returns its output.
+func audioCmdlet(ctx context.Context, script string, env ...string) (string, error) {
+	script = "$ErrorActionPreference = 'Stop'\nImport-Module AudioDeviceCmdlets\n" + script
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(script))
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "AudioDeviceCmdlets") {
+		return "", errNoAudioCmdlets
+	}
+	return string(out), err
+}
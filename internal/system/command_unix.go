@@ -0,0 +1,138 @@
+//go:build !windows
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// loginPath is the PATH of commands run as another user, which don't get pc2mqtt's own.
+const loginPath = "/usr/local/bin:/usr/bin:/bin:/usr/local/sbin:/usr/sbin:/sbin"
+
+// GetShellCommand returns the command running command with sh, eg. "pkill firefox && echo done". It runs in its
+// own process group, so killing it also stops the programs it started.
+func GetShellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// killCommand kills the started cmd, with its process group if it has its own.
+func killCommand(cmd *exec.Cmd) {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
+
+// RunCommandAs runs cmd like RunCommandContext as the user runAs, a user name or RunAsConsole for the user logged in
+// on the console. Switching users needs pc2mqtt to run as root, eg. as a system service. Empty runAs runs cmd
+// as pc2mqtt's own user.
+func RunCommandAs(ctx context.Context, cmd *exec.Cmd, runAs string) error {
+	if runAs == "" {
+		return RunCommandContext(ctx, cmd)
+	}
+	account, err := lookupRunAs(runAs)
+	if err != nil {
+		return err
+	}
+	if account.Uid == strconv.Itoa(os.Getuid()) {
+		return RunCommandContext(ctx, cmd)
+	}
+
+	// sudo would drop the environment and can't gain privileges in the service units, which set NoNewPrivileges
+	if os.Getuid() != 0 {
+		return fmt.Errorf("Running a command as %s needs pc2mqtt to run as root", account.Username)
+	}
+	if dryRun != nil && cmd.Err == nil {
+		fmt.Fprintf(dryRun, "Would run as %s: %s\n", account.Username, cmd.String())
+		return nil
+	}
+	asUser, err := commandAsUser(cmd, account)
+	if err != nil {
+		return err
+	}
+	return RunCommandContext(ctx, asUser)
+}
+
+// lookupRunAs returns the account of runAs.
+func lookupRunAs(runAs string) (*user.User, error) {
+	if runAs != RunAsConsole {
+		account, err := user.Lookup(runAs)
+		if err != nil {
+			return nil, fmt.Errorf("Unknown user %s: %w", runAs, err)
+		}
+		return account, nil
+	}
+
+	uid, err := consoleUid()
+	if err != nil {
+		return nil, err
+	}
+	return user.LookupId(strconv.Itoa(uid))
+}
+
+// withCredential switches cmd to account with the environment of a login of the user, including its
+// session bus for commands talking to the desktop. pc2mqtt's own environment isn't passed on, an environment
+// and directory set on cmd are kept.
+func withCredential(cmd *exec.Cmd, account *user.User) (*exec.Cmd, error) {
+	uid, err := strconv.ParseUint(account.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(account.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	var groups []uint32
+	if ids, err := account.GroupIds(); err == nil {
+		for _, id := range ids {
+			if group, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(group))
+			}
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	if info, err := os.Stat(account.HomeDir); err == nil && info.IsDir() && cmd.Dir == "" {
+		cmd.Dir = account.HomeDir
+	}
+	cmd.Env = append(cmd.Env,
+		"PATH="+loginPath,
+		"HOME="+account.HomeDir,
+		"USER="+account.Username,
+		"LOGNAME="+account.Username,
+		"SHELL="+loginShell(account.Username),
+	)
+	runtimeDir := "/run/user/" + account.Uid
+	if _, err := os.Stat(runtimeDir); err == nil {
+		cmd.Env = append(cmd.Env, "XDG_RUNTIME_DIR="+runtimeDir, "DBUS_SESSION_BUS_ADDRESS=unix:path="+runtimeDir+"/bus")
+	}
+	return cmd, nil
+}
+
+// loginShell returns the shell of username from /etc/passwd, or sh if it isn't listed there.
+func loginShell(username string) string {
+	passwd, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return "/bin/sh"
+	}
+	for line := range strings.Lines(string(passwd)) {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) == 7 && fields[0] == username && fields[6] != "" {
+			return fields[6]
+		}
+	}
+	return "/bin/sh"
+}
@@ -0,0 +1,117 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	userenv                     = syscall.NewLazyDLL("userenv.dll")
+	procCreateEnvironmentBlock  = userenv.NewProc("CreateEnvironmentBlock")
+	procDestroyEnvironmentBlock = userenv.NewProc("DestroyEnvironmentBlock")
+	procWTSQueryUserToken       = wtsapi32.NewProc("WTSQueryUserToken")
+)
+
+const createUnicodeEnvironment = 0x00000400
+
+// GetShellCommand returns the command running command with cmd.exe, eg. "start notepad".
+func GetShellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("cmd.exe")
+	// cmd.exe parses its command line itself, Go's quoting of arguments would break quotes in command
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /C ` + command}
+	return cmd
+}
+
+// RunCommandAs runs cmd like RunCommandContext, with runAs RunAsConsole in the session of the user logged in on the
+// console. This needs pc2mqtt running as a service under the SYSTEM account. Empty runAs runs cmd as pc2mqtt's
+// own user. Windows can't switch to other users without their password.
+func RunCommandAs(ctx context.Context, cmd *exec.Cmd, runAs string) error {
+	switch runAs {
+	case "":
+		return RunCommandContext(ctx, cmd)
+	case RunAsConsole:
+		return runInConsoleSession(ctx, cmd)
+	default:
+		return errors.New("Windows only supports running as " + RunAsConsole)
+	}
+}
+
+// runInConsoleSession starts cmd with the token of the console user on its interactive desktop and waits for it,
+// or terminates it once ctx is done.
+func runInConsoleSession(ctx context.Context, cmd *exec.Cmd) error {
+	commandLine := commandLineOf(cmd)
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would run in the console session: "+commandLine)
+		return nil
+	}
+
+	session, _, _ := procWTSGetActiveConsoleSessionId.Call()
+	if uint32(session) == noConsoleSession {
+		return errors.New("No user is logged in")
+	}
+	var token syscall.Token
+	if ret, _, err := procWTSQueryUserToken.Call(session, uintptr(unsafe.Pointer(&token))); ret == 0 {
+		return fmt.Errorf("Reading the token of the console user failed, which needs pc2mqtt running as a service: %w", err)
+	}
+	defer token.Close()
+
+	var env *uint16
+	if ret, _, err := procCreateEnvironmentBlock.Call(uintptr(unsafe.Pointer(&env)), uintptr(token), 0); ret == 0 {
+		return fmt.Errorf("Creating the environment of the console user failed: %w", err)
+	}
+	defer procDestroyEnvironmentBlock.Call(uintptr(unsafe.Pointer(env)))
+
+	commandLinePtr, err := syscall.UTF16PtrFromString(commandLine)
+	if err != nil {
+		return err
+	}
+	desktop, _ := syscall.UTF16PtrFromString(`winsta0\default`)
+	startup := &syscall.StartupInfo{Cb: uint32(unsafe.Sizeof(syscall.StartupInfo{})), Desktop: desktop}
+	var process syscall.ProcessInformation
+	err = syscall.CreateProcessAsUser(token, nil, commandLinePtr, nil, nil, false, createUnicodeEnvironment,
+		env, nil, startup, &process)
+	if err != nil {
+		return &CommandError{Command: commandLine, ExitCode: -1, Err: err}
+	}
+	defer syscall.CloseHandle(process.Process)
+	defer syscall.CloseHandle(process.Thread)
+
+	stop := context.AfterFunc(ctx, func() { syscall.TerminateProcess(process.Process, 1) })
+	_, err = syscall.WaitForSingleObject(process.Process, syscall.INFINITE)
+	if !stop() {
+		return &CommandError{Command: commandLine, ExitCode: -1, Err: ctx.Err()}
+	}
+	if err != nil {
+		return err
+	}
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(process.Process, &exitCode); err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return &CommandError{Command: commandLine, ExitCode: int(exitCode), Err: fmt.Errorf("exit status %d", exitCode)}
+	}
+	return nil
+}
+
+// killCommand kills the started cmd.
+func killCommand(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+// commandLineOf returns the command line Go would start cmd with.
+func commandLineOf(cmd *exec.Cmd) string {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.CmdLine != "" {
+		return cmd.SysProcAttr.CmdLine
+	}
+	args := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = syscall.EscapeArg(arg)
+	}
+	return strings.Join(args, " ")
+}
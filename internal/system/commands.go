@@ -1,25 +1,44 @@
 package system
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"time"
 )
 
 const (
 	WINDOWS = "windows"
 	MACOS   = "darwin"
 	LINUX   = "linux"
+	FREEBSD = "freebsd"
 )
 
+// RunAsConsole runs a command as the user logged in on the console, see RunCommandAs.
+const RunAsConsole = "console"
+
 func GetShutdownCommand() (*exec.Cmd, error) {
 	switch runtime.GOOS {
 	case WINDOWS:
-		return exec.Command("shutdown", "/s"), nil
+		if powerOptions.HybridShutdown {
+			return exec.Command("shutdown", "/s", "/hybrid", "/t", "0"), nil
+		}
+		return exec.Command("shutdown", "/s", "/t", "0"), nil
 	case MACOS:
+		if os.Geteuid() != 0 {
+			return loginwindowCommand("aevtrsdn"), nil
+		}
 		return exec.Command("shutdown", "-h", "now"), nil
 	case LINUX:
-		return exec.Command("systemctl", "poweroff", "--ignore-inhibitors"), nil
+		return linuxPowerCommand(linuxPowerOff)
+	case FREEBSD:
+		return exec.Command("shutdown", "-p", "now"), nil
 	default:
 		return nil, errors.New(runtime.GOOS + " does not support shutdown")
 	}
@@ -30,10 +49,172 @@ func GetRebootCommand() (*exec.Cmd, error) {
 	case WINDOWS:
 		return exec.Command("shutdown", "/r"), nil
 	case MACOS:
-		return exec.Command("reboot"), nil
+		if os.Geteuid() != 0 {
+			return loginwindowCommand("aevtrrst"), nil
+		}
+		return exec.Command("shutdown", "-r", "now"), nil
 	case LINUX:
-		return exec.Command("systemctl", "reboot", "--ignore-inhibitors"), nil
+		return linuxPowerCommand(linuxReboot)
+	case FREEBSD:
+		return exec.Command("shutdown", "-r", "now"), nil
 	default:
 		return nil, errors.New(runtime.GOOS + " does not support reboot")
 	}
 }
+
+// GetSuspendCommand returns the command sleeping with the SleepMode of the power options.
+func GetSuspendCommand() (*exec.Cmd, error) {
+	mode := powerOptions.SleepMode
+	switch runtime.GOOS {
+	case WINDOWS:
+		if mode == SleepModeHibernate {
+			return exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "1,1,0"), nil
+		}
+		return exec.Command("rundll32.exe", "powrprof.dll,SetSuspendState", "0,1,0"), nil
+	case MACOS:
+		// The hibernatemode of pmset persists: 0 suspends, 25 hibernates and 3 is the hybrid default of laptops
+		switch mode {
+		case SleepModeHibernate:
+			return exec.Command("sh", "-c", "pmset -a hibernatemode 25 && pmset sleepnow"), nil
+		case SleepModeHybrid:
+			return exec.Command("sh", "-c", "pmset -a hibernatemode 3 && pmset sleepnow"), nil
+		}
+		return exec.Command("pmset", "sleepnow"), nil
+	case LINUX:
+		switch mode {
+		case SleepModeHibernate:
+			return linuxPowerCommand(linuxHibernate)
+		case SleepModeHybrid:
+			return linuxPowerCommand(linuxHybridSleep)
+		}
+		return linuxPowerCommand(linuxSuspend)
+	case FREEBSD:
+		// ACPI S3 or S4, which need root and hardware supporting them
+		if mode == SleepModeHibernate {
+			return exec.Command("acpiconf", "-s", "4"), nil
+		}
+		return exec.Command("acpiconf", "-s", "3"), nil
+	default:
+		return nil, errors.New(runtime.GOOS + " does not support suspend")
+	}
+}
+
+func GetDisplayOffCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case MACOS:
+		return exec.Command("pmset", "displaysleepnow"), nil
+	default:
+		return nil, errors.New(runtime.GOOS + " does not support turning the display off")
+	}
+}
+
+// GetScheduleWakeCommand returns the command waking the PC from sleep at the given time. It needs root.
+func GetScheduleWakeCommand(at time.Time) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case MACOS:
+		return exec.Command("pmset", "schedule", "wake", at.Format("01/02/06 15:04:05")), nil
+	default:
+		return nil, errors.New(runtime.GOOS + " does not support scheduled wake")
+	}
+}
+
+// GetAppleScriptCommand returns the command running the AppleScript source.
+func GetAppleScriptCommand(script string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case MACOS:
+		return exec.Command("osascript", "-e", script), nil
+	default:
+		return nil, errors.New(runtime.GOOS + " does not support AppleScript")
+	}
+}
+
+// loginwindowCommand sends the Apple event to the loginwindow of the logged in user, eg. aevtrsdn to shut down
+// without asking. Unlike the shutdown command it needs no root, like choosing Shut Down in the Apple menu.
+func loginwindowCommand(event string) *exec.Cmd {
+	return exec.Command("osascript", "-e", `tell application "loginwindow" to «event `+event+`»`)
+}
+
+// maxStderrLength limits how much of a failed command's stderr is kept.
+const maxStderrLength = 512
+
+// CommandError describes a command that failed to start or exited with a non-zero exit code.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s failed with exit code %d: %s", e.Command, e.ExitCode, e.Stderr)
+	}
+	return fmt.Sprintf("%s failed with exit code %d: %v", e.Command, e.ExitCode, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandWaitDelay is how long RunCommandContext waits for the output of a command after it exited or was killed
+const commandWaitDelay = time.Second
+
+// dryRun receives the commands RunCommand would run instead of running them, nil runs them.
+var dryRun io.Writer
+
+// SetDryRun makes RunCommand print the resolved commands to w instead of running them.
+// Nil runs them again. Call it before any command runs.
+func SetDryRun(w io.Writer) {
+	dryRun = w
+}
+
+// RunCommand runs cmd to completion and returns a *CommandError with its
+// exit code and an excerpt of stderr if it fails.
+func RunCommand(cmd *exec.Cmd) error {
+	return RunCommandContext(context.Background(), cmd)
+}
+
+// RunCommandContext runs cmd like RunCommand and kills it once ctx is done, eg. when an action times out.
+func RunCommandContext(ctx context.Context, cmd *exec.Cmd) error {
+	// A command that can't be found fails right away without running, also in a dry run
+	if dryRun != nil && cmd.Err == nil {
+		fmt.Fprintln(dryRun, "Would run: "+cmd.String())
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if ctx.Done() != nil {
+		// Programs started in the background may keep stderr open after the command exited
+		cmd.WaitDelay = commandWaitDelay
+	}
+
+	err := cmd.Start()
+	if err == nil {
+		stop := context.AfterFunc(ctx, func() { killCommand(cmd) })
+		err = cmd.Wait()
+		if !stop() && err != nil {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+	if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+
+	cmdErr := &CommandError{
+		Command:  cmd.String(),
+		ExitCode: -1,
+		Stderr:   strings.TrimSpace(stderr.String()),
+		Err:      err,
+	}
+	if len(cmdErr.Stderr) > maxStderrLength {
+		cmdErr.Stderr = cmdErr.Stderr[:maxStderrLength] + "..."
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmdErr.ExitCode = exitErr.ExitCode()
+	}
+
+	return cmdErr
+}
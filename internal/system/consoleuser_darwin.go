@@ -0,0 +1,37 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"syscall"
+)
+
+// consoleUid returns the user logged in at the screen, who owns /dev/console.
+func consoleUid() (int, error) {
+	info, err := os.Stat("/dev/console")
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Uid == 0 {
+		return 0, errors.New("No user is logged in")
+	}
+	return int(stat.Uid), nil
+}
+
+// commandAsUser runs cmd in the login session of account with launchctl asuser, so it reaches the apps of
+// the user, eg. for AppleScript, and drops root with sudo. sudo resets the environment to a login environment of
+// the user, so the environment set on cmd is passed with env. The directory and stdio of cmd are kept.
+func commandAsUser(cmd *exec.Cmd, account *user.User) (*exec.Cmd, error) {
+	args := []string{"asuser", account.Uid, "sudo", "-u", account.Username, "--"}
+	if cmd.Env != nil {
+		args = append(append(args, "/usr/bin/env"), cmd.Env...)
+	}
+	args = append(append(args, cmd.Path), cmd.Args[1:]...)
+	asUser := exec.Command("launchctl", args...)
+	asUser.Dir, asUser.SysProcAttr = cmd.Dir, cmd.SysProcAttr
+	asUser.Stdin, asUser.Stdout, asUser.Stderr = cmd.Stdin, cmd.Stdout, cmd.Stderr
+	return asUser, nil
+}
@@ -0,0 +1,18 @@
+package system
+
+import (
+	"context"
+	"os/exec"
+	"os/user"
+)
+
+// consoleUid returns the user logged in on seat0.
+func consoleUid() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), logindTimeout)
+	defer cancel()
+	return activeUid(ctx)
+}
+
+func commandAsUser(cmd *exec.Cmd, account *user.User) (*exec.Cmd, error) {
+	return withCredential(cmd, account)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package system
+
+import (
+	"errors"
+	"os/exec"
+	"os/user"
+	"runtime"
+)
+
+func consoleUid() (int, error) {
+	return 0, errors.New(runtime.GOOS + " does not support running as the console user")
+}
+
+func commandAsUser(cmd *exec.Cmd, account *user.User) (*exec.Cmd, error) {
+	return withCredential(cmd, account)
+}
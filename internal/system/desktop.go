@@ -0,0 +1,19 @@
+package system
+
+import "time"
+
+// Backends reading the Linux desktop
+const (
+	DesktopAuto    = "auto"
+	DesktopX11     = "x11"
+	DesktopWayland = "wayland"
+)
+
+// Desktop is the state of the graphical session shown on the screen of the PC.
+type Desktop struct {
+	Session
+	// Idle is how long the user hasn't used keyboard or mouse
+	Idle time.Duration
+	// DisplayOn is nil where the backend can't tell whether the display is on
+	DisplayOn *bool
+}
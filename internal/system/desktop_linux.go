@@ -0,0 +1,238 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/dbus"
+)
+
+const dbusProperties = "org.freedesktop.DBus.Properties"
+
+// ReadDesktop reads the active session on seat0 from logind, which desktops and screen lockers keep
+// informed about locking. Idle time and display power come from backend: DesktopX11 asks the X server
+// through xprintidle and xset, DesktopWayland uses the logind idle hint, eg. set by swayidle idlehint,
+// and asks sway for the power of its outputs. DesktopAuto picks the backend by the session type.
+func ReadDesktop(ctx context.Context, backend string) (Desktop, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return Desktop{}, err
+	}
+	defer conn.Close()
+
+	active, err := property(ctx, conn, "/org/freedesktop/login1/seat/seat0", "org.freedesktop.login1.Seat", "ActiveSession")
+	if err != nil {
+		return Desktop{}, fmt.Errorf("Reading the active session failed: %w", err)
+	}
+	// (so): session id and object path, / without an active session
+	fields, _ := active.([]any)
+	if len(fields) < 2 || fields[1] == dbus.ObjectPath("/") {
+		return Desktop{}, nil
+	}
+	props, err := properties(ctx, conn, fields[1].(dbus.ObjectPath), "org.freedesktop.login1.Session")
+	if err != nil {
+		return Desktop{}, fmt.Errorf("Reading the active session failed: %w", err)
+	}
+
+	sessionType, _ := props["Type"].(string)
+	class, _ := props["Class"].(string)
+	locked, _ := props["LockedHint"].(bool)
+	desktop := Desktop{
+		// The greeter is the login screen
+		Session: Session{LoggedIn: class == "user", Locked: locked},
+		Idle:    idleHint(props),
+	}
+	if !desktop.LoggedIn || (sessionType != DesktopX11 && sessionType != DesktopWayland) {
+		return desktop, nil
+	}
+
+	uid := sessionUid(props)
+	if backend == DesktopAuto {
+		backend = sessionType
+	}
+	switch backend {
+	case DesktopX11:
+		display, _ := props["Display"].(string)
+		env := x11Env(display, uid)
+		if idle, err := x11Idle(ctx, env); err == nil {
+			desktop.Idle = idle
+		}
+		if on, err := x11DisplayOn(ctx, env); err == nil {
+			desktop.DisplayOn = &on
+		}
+	case DesktopWayland:
+		if on, err := swayDisplayOn(ctx, uid); err == nil {
+			desktop.DisplayOn = &on
+		}
+	}
+	return desktop, nil
+}
+
+// property returns the value of a D-Bus property of the logind object at path.
+func property(ctx context.Context, conn *dbus.Conn, path dbus.ObjectPath, iface string, name string) (any, error) {
+	reply, err := conn.Call(ctx, logindName, path, dbusProperties, "Get", iface, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 {
+		return nil, errors.New("Empty reply")
+	}
+	variant, _ := reply[0].(dbus.Variant)
+	return variant.Value, nil
+}
+
+// properties returns all D-Bus properties of iface of the logind object at path.
+func properties(ctx context.Context, conn *dbus.Conn, path dbus.ObjectPath, iface string) (map[string]any, error) {
+	reply, err := conn.Call(ctx, logindName, path, dbusProperties, "GetAll", iface)
+	if err != nil {
+		return nil, err
+	}
+	props := make(map[string]any)
+	if len(reply) == 0 {
+		return props, nil
+	}
+	// a{sv}
+	entries, _ := reply[0].([]any)
+	for _, entry := range entries {
+		pair, ok := entry.([]any)
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		name, _ := pair[0].(string)
+		variant, _ := pair[1].(dbus.Variant)
+		props[name] = variant.Value
+	}
+	return props, nil
+}
+
+// idleHint returns how long logind reports the session as idle. Desktops only set the hint after their own idle
+// timeout, before that the session counts as active.
+func idleHint(props map[string]any) time.Duration {
+	idle, _ := props["IdleHint"].(bool)
+	// Microseconds since the epoch
+	since, _ := props["IdleSinceHint"].(uint64)
+	if !idle || since == 0 {
+		return 0
+	}
+	return max(time.Since(time.UnixMicro(int64(since))), 0)
+}
+
+// sessionUid returns the user of the session from its User property (uo).
+func sessionUid(props map[string]any) uint32 {
+	fields, _ := props["User"].([]any)
+	if len(fields) == 0 {
+		return 0
+	}
+	uid, _ := fields[0].(uint32)
+	return uid
+}
+
+// x11Env returns the environment for X11 tools connecting to display of the user uid.
+func x11Env(display string, uid uint32) []string {
+	return append(os.Environ(), x11Vars(display, uid)...)
+}
+
+// x11Vars returns the variables missing in the environment to connect to display of the user uid, whose X
+// authority file is looked up where display managers put it if pc2mqtt runs outside the session, eg. as a service.
+func x11Vars(display string, uid uint32) []string {
+	var env []string
+	if os.Getenv("DISPLAY") == "" && display != "" {
+		env = append(env, "DISPLAY="+display)
+	}
+	if os.Getenv("XAUTHORITY") != "" {
+		return env
+	}
+	candidates := []string{filepath.Join("/run/user", strconv.Itoa(int(uid)), "gdm/Xauthority")}
+	if account, err := user.LookupId(strconv.Itoa(int(uid))); err == nil {
+		candidates = append(candidates, filepath.Join(account.HomeDir, ".Xauthority"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return append(env, "XAUTHORITY="+path)
+		}
+	}
+	return env
+}
+
+// x11Idle reads the idle time of the XScreenSaver extension with xprintidle.
+func x11Idle(ctx context.Context, env []string) (time.Duration, error) {
+	cmd := exec.CommandContext(ctx, "xprintidle")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	millis, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid xprintidle output %q", out)
+	}
+	return time.Duration(millis) * time.Millisecond, nil
+}
+
+// x11DisplayOn reads the DPMS state of the monitor with xset.
+func x11DisplayOn(ctx context.Context, env []string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "xset", "q")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		// eg. "  Monitor is On", "Monitor is in Standby" or "Monitor is Off"
+		if state, ok := strings.CutPrefix(strings.TrimSpace(line), "Monitor is "); ok {
+			return state == "On", nil
+		}
+	}
+	// Without DPMS the monitor stays on
+	return true, nil
+}
+
+// swayDisplayOn asks sway of the user uid whether any of its outputs is powered.
+func swayDisplayOn(ctx context.Context, uid uint32) (bool, error) {
+	socket := os.Getenv("SWAYSOCK")
+	if socket == "" {
+		matches, _ := filepath.Glob(filepath.Join("/run/user", strconv.Itoa(int(uid)), "sway-ipc.*.sock"))
+		if len(matches) == 0 {
+			return false, errors.New("sway is not running")
+		}
+		socket = matches[0]
+	}
+
+	out, err := exec.CommandContext(ctx, "swaymsg", "-s", socket, "-t", "get_outputs", "-r").Output()
+	if err != nil {
+		return false, err
+	}
+	var outputs []struct {
+		Active bool `json:"active"`
+		// Power replaced dpms in sway 1.8
+		Power *bool `json:"power"`
+		Dpms  *bool `json:"dpms"`
+	}
+	if err := json.Unmarshal(out, &outputs); err != nil {
+		return false, fmt.Errorf("Invalid sway outputs: %w", err)
+	}
+	for _, output := range outputs {
+		if !output.Active {
+			continue
+		}
+		on := true
+		if output.Power != nil {
+			on = *output.Power
+		} else if output.Dpms != nil {
+			on = *output.Dpms
+		}
+		if on {
+			return true, nil
+		}
+	}
+	return false, nil
+}
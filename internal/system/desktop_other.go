@@ -0,0 +1,14 @@
+//go:build !linux
+
+package system
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+// ReadDesktop returns an error, only Linux desktops are read.
+func ReadDesktop(ctx context.Context, backend string) (Desktop, error) {
+	return Desktop{}, errors.New(runtime.GOOS + " does not support reading the desktop")
+}
@@ -0,0 +1,180 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Graphics modes of laptops with switchable graphics
+const (
+	// GpuModeIntegrated powers the discrete GPU off
+	GpuModeIntegrated = "integrated"
+	// GpuModeHybrid renders on the integrated GPU and offloads programs to the discrete one
+	GpuModeHybrid = "hybrid"
+	// GpuModeDiscrete renders everything on the discrete GPU
+	GpuModeDiscrete = "discrete"
+)
+
+// gpuTool switches the graphics mode with the tool of a vendor or distribution.
+type gpuTool struct {
+	name string
+	// modes maps the graphics modes to the names of the tool
+	modes map[string]string
+	query []string
+	set   []string
+	// supported lists the modes of this laptop, eg. "[Integrated, Hybrid]". Nil if the tool can't tell.
+	supported []string
+	// pending returns whether a switch waits for a reboot or logout. Nil if the tool can't tell, then every
+	// switch is assumed to need a reboot.
+	pending []string
+}
+
+// gpuTools in the order they are looked for
+var gpuTools = []gpuTool{
+	{
+		// asusctl and supergfxd, on ASUS and other laptops
+		name:      "supergfxctl",
+		modes:     map[string]string{GpuModeIntegrated: "Integrated", GpuModeHybrid: "Hybrid", GpuModeDiscrete: "AsusMuxDgpu"},
+		query:     []string{"supergfxctl", "-g"},
+		set:       []string{"supergfxctl", "-m"},
+		supported: []string{"supergfxctl", "-s"},
+		pending:   []string{"supergfxctl", "-p"},
+	},
+	{
+		// Pop!_OS
+		name:  "system76-power",
+		modes: map[string]string{GpuModeIntegrated: "integrated", GpuModeHybrid: "hybrid", GpuModeDiscrete: "nvidia"},
+		query: []string{"system76-power", "graphics"},
+		set:   []string{"system76-power", "graphics"},
+	},
+	{
+		// nvidia-prime of Ubuntu
+		name:  "prime-select",
+		modes: map[string]string{GpuModeIntegrated: "intel", GpuModeHybrid: "on-demand", GpuModeDiscrete: "nvidia"},
+		query: []string{"prime-select", "query"},
+		set:   []string{"prime-select"},
+	},
+}
+
+var (
+	gpuSwitchMu sync.Mutex
+	// gpuSwitched is set once the mode was switched by pc2mqtt, for tools that can't tell a pending switch
+	gpuSwitched bool
+)
+
+// installedGpuTool returns the first installed tool switching the graphics mode, nil if there is none.
+var installedGpuTool = sync.OnceValue(func() *gpuTool {
+	for i, tool := range gpuTools {
+		if _, err := exec.LookPath(tool.name); err == nil {
+			return &gpuTools[i]
+		}
+	}
+	return nil
+})
+
+// GpuModes returns the graphics modes this laptop can switch to with the installed tool, supergfxctl,
+// system76-power or prime-select, none without one. Only ASUS laptops
+// with a MUX switch render on the discrete GPU with supergfxctl.
+var GpuModes = sync.OnceValue(func() []string {
+	tool := installedGpuTool()
+	if tool == nil {
+		return nil
+	}
+	var supported string
+	if tool.supported != nil {
+		out, err := exec.Command(tool.supported[0], tool.supported[1:]...).Output()
+		if err != nil {
+			return nil
+		}
+		supported = string(out)
+	}
+
+	var modes []string
+	for _, mode := range []string{GpuModeIntegrated, GpuModeHybrid, GpuModeDiscrete} {
+		if tool.supported == nil || strings.Contains(supported, tool.modes[mode]) {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+})
+
+// GetGpuMode returns the graphics mode the tool is set to, which may only apply after a reboot.
+func GetGpuMode(ctx context.Context) (string, error) {
+	tool := installedGpuTool()
+	if tool == nil {
+		return "", errors.New("No tool switching the graphics mode is installed")
+	}
+	out, err := exec.CommandContext(ctx, tool.query[0], tool.query[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	current := strings.TrimSpace(string(out))
+	for mode, name := range tool.modes {
+		if strings.EqualFold(current, name) {
+			return mode, nil
+		}
+	}
+	// Modes without a counterpart, eg. compute of system76-power or Vfio of supergfxctl
+	return strings.ToLower(current), nil
+}
+
+// SetGpuMode switches the graphics mode, which needs root or polkit.
+func SetGpuMode(mode string) error {
+	tool := installedGpuTool()
+	if tool == nil {
+		return errors.New("No tool switching the graphics mode is installed")
+	}
+	name, ok := tool.modes[mode]
+	if !ok {
+		return errors.New("Unsupported graphics mode " + mode)
+	}
+
+	args := append(slices.Clone(tool.set[1:]), name)
+	if err := RunCommand(exec.Command(tool.set[0], args...)); err != nil {
+		return err
+	}
+	gpuSwitchMu.Lock()
+	gpuSwitched = true
+	gpuSwitchMu.Unlock()
+	return nil
+}
+
+// What a switched graphics mode waits for, see GetGpuPendingAction
+const (
+	GpuPendingNone   = "none"
+	GpuPendingLogout = "logout"
+	GpuPendingReboot = "reboot"
+)
+
+// GetGpuPendingAction returns whether the switched graphics mode applies only after a reboot or, with
+// supergfxctl, a logout.
+func GetGpuPendingAction(ctx context.Context) (string, error) {
+	tool := installedGpuTool()
+	if tool == nil || tool.pending == nil {
+		gpuSwitchMu.Lock()
+		defer gpuSwitchMu.Unlock()
+		if gpuSwitched {
+			return GpuPendingReboot, nil
+		}
+		return GpuPendingNone, nil
+	}
+
+	// eg. "No action required" or "Logout required to complete mode change"
+	out, err := exec.CommandContext(ctx, tool.pending[0], tool.pending[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	pending := strings.ToLower(string(out))
+	switch {
+	case strings.Contains(pending, "logout"):
+		return GpuPendingLogout, nil
+	case strings.Contains(pending, "reboot"):
+		return GpuPendingReboot, nil
+	default:
+		return GpuPendingNone, nil
+	}
+}
@@ -0,0 +1,171 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Vendors of the GPUs read by the GPU backends
+const (
+	GpuVendorAmd    = "amd"
+	GpuVendorIntel  = "intel"
+	GpuVendorNvidia = "nvidia"
+)
+
+// gpuReadingTtl is how long a reading is reused, the sensors of a GPU poll one after another
+const gpuReadingTtl = 5 * time.Second
+
+// gpuDetectTimeout bounds the first call of rocm-smi or nvidia-smi, which loads the driver
+const gpuDetectTimeout = 30 * time.Second
+
+// GpuReading holds what a GPU reports, nil where its driver or tool has no value.
+type GpuReading struct {
+	// Utilization in percent
+	Utilization *float64
+	// Temperature in °C
+	Temperature *float64
+	// FanSpeed in RPM, or percent with Gpu.FanPercent
+	FanSpeed *float64
+	// Power draw in W
+	Power *float64
+	// MemoryUsed is the video memory in use in bytes
+	MemoryUsed *float64
+}
+
+// Gpu is a graphics card found by one of the GPU backends.
+type Gpu struct {
+	Vendor string
+	// Name is the model if the backend knows it, eg. "NVIDIA GeForce RTX 3080", else the vendor
+	Name string
+	// FanPercent is set if FanSpeed is in percent of the maximum instead of RPM
+	FanPercent bool
+	// Reports is the first reading, its values tell what the GPU reports at all
+	Reports GpuReading
+
+	read  func(ctx context.Context) (GpuReading, error)
+	cache *gpuCache
+}
+
+type gpuCache struct {
+	mu      sync.Mutex
+	reading GpuReading
+	read    time.Time
+}
+
+// Gpus returns the GPUs reporting any value, found once with the amdgpu and Intel drivers on Linux, rocm-smi
+// and nvidia-smi.
+var Gpus = sync.OnceValue(func() []Gpu {
+	ctx, cancel := context.WithTimeout(context.Background(), gpuDetectTimeout)
+	defer cancel()
+
+	var gpus []Gpu
+	for _, gpu := range append(findGpus(), nvidiaSmiGpus()...) {
+		reading, err := gpu.read(ctx)
+		if err != nil || reading == (GpuReading{}) {
+			continue
+		}
+		gpu.Reports = reading
+		gpu.cache = &gpuCache{reading: reading, read: time.Now()}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+})
+
+// Read returns the current values of the GPU, reusing a reading for gpuReadingTtl.
+func (gpu Gpu) Read(ctx context.Context) (GpuReading, error) {
+	gpu.cache.mu.Lock()
+	defer gpu.cache.mu.Unlock()
+	if time.Since(gpu.cache.read) < gpuReadingTtl {
+		return gpu.cache.reading, nil
+	}
+	reading, err := gpu.read(ctx)
+	if err != nil {
+		return GpuReading{}, err
+	}
+	gpu.cache.reading, gpu.cache.read = reading, time.Now()
+	return reading, nil
+}
+
+// gpuValue parses value and multiplies it by scale, nil if the value is missing, eg. "N/A" or "[Not Supported]".
+func gpuValue(value string, scale float64) *float64 {
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil
+	}
+	parsed *= scale
+	return &parsed
+}
+
+// nvidiaSmiQuery are the values read from nvidia-smi, the bus id first to find a GPU again
+const nvidiaSmiQuery = "pci.bus_id,name,utilization.gpu,temperature.gpu,fan.speed,power.draw,memory.used"
+
+// nvidiaSmiGpus returns the GPUs listed by nvidia-smi of the NVIDIA driver, on Linux and Windows.
+func nvidiaSmiGpus() []Gpu {
+	if _, err := exec.LookPath("nvidia-smi"); err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gpuDetectTimeout)
+	defer cancel()
+	rows, err := nvidiaSmi(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var gpus []Gpu
+	// In the order of the bus, the same on every start
+	for _, busId := range slices.Sorted(maps.Keys(rows)) {
+		row := rows[busId]
+		gpus = append(gpus, Gpu{
+			Vendor:     GpuVendorNvidia,
+			Name:       row[1],
+			FanPercent: true,
+			read: func(ctx context.Context) (GpuReading, error) {
+				rows, err := nvidiaSmi(ctx)
+				if err != nil {
+					return GpuReading{}, err
+				}
+				row, ok := rows[busId]
+				if !ok {
+					return GpuReading{}, fmt.Errorf("nvidia-smi no longer lists the GPU %s", busId)
+				}
+				return GpuReading{
+					Utilization: gpuValue(row[2], 1),
+					Temperature: gpuValue(row[3], 1),
+					FanSpeed:    gpuValue(row[4], 1),
+					Power:       gpuValue(row[5], 1),
+					// MiB
+					MemoryUsed: gpuValue(row[6], 1024*1024),
+				}, nil
+			},
+		})
+	}
+	return gpus
+}
+
+// nvidiaSmi returns the fields of nvidiaSmiQuery by the bus id of each GPU.
+func nvidiaSmi(ctx context.Context) (map[string][]string, error) {
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu="+nvidiaSmiQuery, "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %w", err)
+	}
+	columns := len(strings.Split(nvidiaSmiQuery, ","))
+	rows := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != columns {
+			return nil, fmt.Errorf("Unexpected nvidia-smi line %q", line)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		rows[fields[0]] = fields
+	}
+	return rows, nil
+}
@@ -0,0 +1,204 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// findGpus returns the GPUs of the amdgpu, i915 and xe drivers in /sys/class/drm, and those listed by rocm-smi
+// if the amdgpu driver isn't visible there, eg. in containers.
+func findGpus() []Gpu {
+	cards, _ := filepath.Glob("/sys/class/drm/card[0-9]*")
+	var gpus []Gpu
+	for _, card := range cards {
+		// Connectors are named like card0-DP-1
+		if strings.Contains(filepath.Base(card), "-") {
+			continue
+		}
+		device := filepath.Join(card, "device")
+		driver, err := os.Readlink(filepath.Join(device, "driver"))
+		if err != nil {
+			continue
+		}
+		switch filepath.Base(driver) {
+		case "amdgpu":
+			gpus = append(gpus, amdgpuGpu(device))
+		case "i915", "xe":
+			gpus = append(gpus, intelGpu(device))
+		}
+	}
+
+	if !slices.ContainsFunc(gpus, func(gpu Gpu) bool { return gpu.Vendor == GpuVendorAmd }) {
+		gpus = append(gpus, rocmSmiGpus()...)
+	}
+	return gpus
+}
+
+// amdgpuGpu reads the sysfs files of the amdgpu driver and its hwmon sensors.
+// https://docs.kernel.org/gpu/amdgpu/thermal.html
+func amdgpuGpu(device string) Gpu {
+	name := strings.TrimSpace(readFile(filepath.Join(device, "product_name")))
+	if name == "" {
+		name = "AMD GPU"
+	}
+	return Gpu{
+		Vendor: GpuVendorAmd,
+		Name:   name,
+		read: func(ctx context.Context) (GpuReading, error) {
+			reading := GpuReading{
+				Utilization: gpuValue(readFile(filepath.Join(device, "gpu_busy_percent")), 1),
+				MemoryUsed:  gpuValue(readFile(filepath.Join(device, "mem_info_vram_used")), 1),
+			}
+			if hwmon := gpuHwmon(device); hwmon != "" {
+				// Millidegrees of the edge sensor, RPM and microwatts. Newer GPUs only report power1_input.
+				reading.Temperature = gpuValue(readFile(filepath.Join(hwmon, "temp1_input")), 0.001)
+				reading.FanSpeed = gpuValue(readFile(filepath.Join(hwmon, "fan1_input")), 1)
+				reading.Power = gpuValue(readFile(filepath.Join(hwmon, "power1_average")), 0.000001)
+				if reading.Power == nil {
+					reading.Power = gpuValue(readFile(filepath.Join(hwmon, "power1_input")), 0.000001)
+				}
+			}
+			return reading, nil
+		},
+	}
+}
+
+// intelGpu reads the hwmon sensors of the i915 and xe drivers, which only discrete GPUs have. The power draw is
+// derived from the energy counter, the first reading reports 0 W.
+// https://docs.kernel.org/gpu/i915.html
+func intelGpu(device string) Gpu {
+	var (
+		mu         sync.Mutex
+		lastEnergy float64
+		lastRead   time.Time
+	)
+	return Gpu{
+		Vendor: GpuVendorIntel,
+		Name:   "Intel GPU",
+		read: func(ctx context.Context) (GpuReading, error) {
+			hwmon := gpuHwmon(device)
+			if hwmon == "" {
+				return GpuReading{}, nil
+			}
+			reading := GpuReading{
+				Temperature: gpuValue(readFile(filepath.Join(hwmon, "temp1_input")), 0.001),
+				FanSpeed:    gpuValue(readFile(filepath.Join(hwmon, "fan1_input")), 1),
+			}
+
+			// Microjoules
+			if energy := gpuValue(readFile(filepath.Join(hwmon, "energy1_input")), 0.000001); energy != nil {
+				mu.Lock()
+				now := time.Now()
+				power := 0.0
+				if seconds := now.Sub(lastRead).Seconds(); !lastRead.IsZero() && seconds > 0 && *energy >= lastEnergy {
+					power = (*energy - lastEnergy) / seconds
+				}
+				lastEnergy, lastRead = *energy, now
+				mu.Unlock()
+				reading.Power = &power
+			}
+			return reading, nil
+		},
+	}
+}
+
+// gpuHwmon returns the hwmon directory of a GPU device, empty if it has none.
+func gpuHwmon(device string) string {
+	hwmons, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*"))
+	if len(hwmons) == 0 {
+		return ""
+	}
+	return hwmons[0]
+}
+
+func readFile(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// rocmSmiArgs select the values of rocmSmiGpus, printed as {"card0": {"GPU use (%)": "3", ...}}
+var rocmSmiArgs = []string{"--showuse", "--showtemp", "--showfan", "--showpower", "--showmeminfo", "vram", "--json"}
+
+// rocmSmiGpus returns the GPUs listed by rocm-smi of ROCm.
+func rocmSmiGpus() []Gpu {
+	if _, err := exec.LookPath("rocm-smi"); err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gpuDetectTimeout)
+	defer cancel()
+	cards, err := rocmSmi(ctx)
+	if err != nil {
+		return nil
+	}
+
+	// Next to the cards the output may have a "system" entry
+	var names []string
+	for name := range cards {
+		if strings.HasPrefix(name, "card") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var gpus []Gpu
+	for _, card := range names {
+		gpus = append(gpus, Gpu{
+			Vendor: GpuVendorAmd,
+			Name:   "AMD GPU",
+			read: func(ctx context.Context) (GpuReading, error) {
+				cards, err := rocmSmi(ctx)
+				if err != nil {
+					return GpuReading{}, err
+				}
+				values, ok := cards[card]
+				if !ok {
+					return GpuReading{}, fmt.Errorf("rocm-smi no longer lists %s", card)
+				}
+				// The names of the values differ between ROCm versions
+				find := func(match func(string) bool) string {
+					for name, value := range values {
+						if match(name) {
+							return value
+						}
+					}
+					return ""
+				}
+				return GpuReading{
+					Utilization: gpuValue(values["GPU use (%)"], 1),
+					Temperature: gpuValue(find(func(name string) bool {
+						return strings.HasPrefix(name, "Temperature (Sensor edge)")
+					}), 1),
+					FanSpeed: gpuValue(values["Fan RPM"], 1),
+					Power: gpuValue(find(func(name string) bool {
+						return strings.Contains(name, "Graphics Package Power (W)")
+					}), 1),
+					MemoryUsed: gpuValue(values["VRAM Total Used Memory (B)"], 1),
+				}, nil
+			},
+		})
+	}
+	return gpus
+}
+
+func rocmSmi(ctx context.Context) (map[string]map[string]string, error) {
+	out, err := exec.CommandContext(ctx, "rocm-smi", rocmSmiArgs...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("rocm-smi failed: %w", err)
+	}
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(out, &cards); err != nil {
+		return nil, fmt.Errorf("Unexpected rocm-smi output: %w", err)
+	}
+	return cards, nil
+}
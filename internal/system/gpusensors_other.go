@@ -0,0 +1,8 @@
+//go:build !linux
+
+package system
+
+// findGpus returns none, only the NVIDIA GPUs of nvidia-smi are read outside Linux.
+func findGpus() []Gpu {
+	return nil
+}
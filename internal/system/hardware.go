@@ -0,0 +1,50 @@
+package system
+
+import (
+	"strings"
+	"sync"
+)
+
+// HardwareInfo describes the machine pc2mqtt runs on. Fields the OS doesn't report stay empty.
+type HardwareInfo struct {
+	Manufacturer string
+	Model        string
+	Version      string
+}
+
+// Firmware placeholders vendors leave in unset DMI fields
+var hardwarePlaceholders = []string{
+	"to be filled by o.e.m.",
+	"system manufacturer",
+	"system product name",
+	"system version",
+	"default string",
+	"not applicable",
+	"not specified",
+	"none",
+	"0",
+}
+
+var detectHardware = sync.OnceValue(func() HardwareInfo {
+	info := hardware()
+	return HardwareInfo{
+		Manufacturer: cleanHardwareValue(info.Manufacturer),
+		Model:        cleanHardwareValue(info.Model),
+		Version:      cleanHardwareValue(info.Version),
+	}
+})
+
+// Hardware returns the manufacturer, model and revision of this machine. It is detected once.
+func Hardware() HardwareInfo {
+	return detectHardware()
+}
+
+func cleanHardwareValue(value string) string {
+	value = strings.TrimSpace(strings.Trim(value, "\x00"))
+	for _, placeholder := range hardwarePlaceholders {
+		if strings.EqualFold(value, placeholder) {
+			return ""
+		}
+	}
+	return value
+}
@@ -0,0 +1,13 @@
+package system
+
+import (
+	"os/exec"
+)
+
+func hardware() HardwareInfo {
+	info := HardwareInfo{Manufacturer: "Apple"}
+	if out, err := exec.Command("sysctl", "-n", "hw.model").Output(); err == nil {
+		info.Model = string(out)
+	}
+	return info
+}
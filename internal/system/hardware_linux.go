@@ -0,0 +1,28 @@
+package system
+
+import (
+	"os"
+)
+
+func readHardwareFile(path string) string {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+func hardware() HardwareInfo {
+	info := HardwareInfo{
+		Manufacturer: readHardwareFile("/sys/class/dmi/id/sys_vendor"),
+		Model:        readHardwareFile("/sys/class/dmi/id/product_name"),
+		Version:      readHardwareFile("/sys/class/dmi/id/product_version"),
+	}
+
+	// Single board computers like the Raspberry Pi have no DMI but describe themselves in the device tree
+	if info.Model == "" {
+		info.Model = readHardwareFile("/proc/device-tree/model")
+	}
+
+	return info
+}
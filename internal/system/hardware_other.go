@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !windows
+
+package system
+
+import (
+	"os/exec"
+)
+
+// kenv exposes the SMBIOS fields on the BSDs
+func kenv(name string) string {
+	out, err := exec.Command("kenv", "-q", name).Output()
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func hardware() HardwareInfo {
+	return HardwareInfo{
+		Manufacturer: kenv("smbios.system.maker"),
+		Model:        kenv("smbios.system.product"),
+		Version:      kenv("smbios.system.version"),
+	}
+}
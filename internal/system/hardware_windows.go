@@ -0,0 +1,11 @@
+package system
+
+const biosKey = `HARDWARE\DESCRIPTION\System\BIOS`
+
+func hardware() HardwareInfo {
+	var info HardwareInfo
+	info.Manufacturer, _ = readRegistryString(biosKey, "SystemManufacturer")
+	info.Model, _ = readRegistryString(biosKey, "SystemProductName")
+	info.Version, _ = readRegistryString(biosKey, "SystemVersion")
+	return info
+}
@@ -12,3 +12,9 @@ func Hostname() string {
 
 	return hostname
 }
+
+// MachineId returns the stable id the OS assigned to this installation:
+// /etc/machine-id on Linux, MachineGuid on Windows and IOPlatformUUID on macOS.
+func MachineId() (string, error) {
+	return machineId()
+}
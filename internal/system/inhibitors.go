@@ -0,0 +1,31 @@
+package system
+
+import "strings"
+
+// Operations other programs can block
+const (
+	InhibitShutdown = "shutdown"
+	InhibitSleep    = "sleep"
+)
+
+// Inhibitor is a program blocking shutdown or sleep, eg. a running backup.
+type Inhibitor struct {
+	Who string
+	Why string
+}
+
+func (inhibitor Inhibitor) String() string {
+	if inhibitor.Why == "" {
+		return inhibitor.Who
+	}
+	return inhibitor.Who + " (" + inhibitor.Why + ")"
+}
+
+// JoinInhibitors lists the programs of inhibitors, eg. "firefox, backup".
+func JoinInhibitors(inhibitors []Inhibitor) string {
+	names := make([]string, len(inhibitors))
+	for i, inhibitor := range inhibitors {
+		names[i] = inhibitor.Who
+	}
+	return strings.Join(names, ", ")
+}
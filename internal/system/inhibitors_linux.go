@@ -0,0 +1,45 @@
+package system
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/leonlatsch/pc2mqtt/internal/dbus"
+)
+
+// Inhibitors returns the programs holding a block inhibitor lock of logind on what, InhibitShutdown or InhibitSleep.
+// Delay locks only hold the operation back for a few seconds and are left out.
+func Inhibitors(ctx context.Context, what string) ([]Inhibitor, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// a(ssssuu): what, who, why, mode, uid and pid
+	reply, err := conn.Call(ctx, logindName, logindPath, logindManager, "ListInhibitors")
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 {
+		return nil, nil
+	}
+	locks, _ := reply[0].([]any)
+
+	var inhibitors []Inhibitor
+	for _, lock := range locks {
+		fields, ok := lock.([]any)
+		if !ok || len(fields) < 4 {
+			continue
+		}
+		whats, _ := fields[0].(string)
+		who, _ := fields[1].(string)
+		why, _ := fields[2].(string)
+		mode, _ := fields[3].(string)
+		if mode == "block" && slices.Contains(strings.Split(whats, ":"), what) {
+			inhibitors = append(inhibitors, Inhibitor{Who: who, Why: why})
+		}
+	}
+	return inhibitors, nil
+}
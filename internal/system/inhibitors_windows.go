@@ -0,0 +1,75 @@
+package system
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32                         = syscall.NewLazyDLL("user32.dll")
+	procEnumWindows                = user32.NewProc("EnumWindows")
+	procShutdownBlockReasonQuery   = user32.NewProc("ShutdownBlockReasonQuery")
+	procGetWindowThreadProcessId   = user32.NewProc("GetWindowThreadProcessId")
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procQueryFullProcessImageNameW = kernel32.NewProc("QueryFullProcessImageNameW")
+)
+
+const processQueryLimitedInformation = 0x1000
+
+// Inhibitors returns the programs with a shutdown block reason on InhibitShutdown. Windows has
+// no sleep locks programs could be asked for. Only windows of the session pc2mqtt runs in are
+// visible, a service doesn't see the programs of the logged in user.
+func Inhibitors(ctx context.Context, what string) ([]Inhibitor, error) {
+	if what != InhibitShutdown {
+		return nil, nil
+	}
+
+	enumMu.Lock()
+	defer enumMu.Unlock()
+	enumInhibitors = nil
+	if ret, _, err := procEnumWindows.Call(enumCallback, 0); ret == 0 {
+		return nil, err
+	}
+	return enumInhibitors, nil
+}
+
+var (
+	enumMu         sync.Mutex
+	enumInhibitors []Inhibitor
+	// Callbacks are never freed, so all enumerations share this one
+	enumCallback = syscall.NewCallback(func(hwnd uintptr, _ uintptr) uintptr {
+		var size uint32
+		if ret, _, _ := procShutdownBlockReasonQuery.Call(hwnd, 0, uintptr(unsafe.Pointer(&size))); ret == 0 || size == 0 {
+			return 1
+		}
+		buf := make([]uint16, size)
+		if ret, _, _ := procShutdownBlockReasonQuery.Call(hwnd, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); ret == 0 {
+			return 1
+		}
+		enumInhibitors = append(enumInhibitors, Inhibitor{Who: windowProcessName(hwnd), Why: syscall.UTF16ToString(buf)})
+		// Continue with the next window
+		return 1
+	})
+)
+
+// windowProcessName returns the executable name of the process owning hwnd without .exe.
+func windowProcessName(hwnd uintptr) string {
+	var pid uint32
+	procGetWindowThreadProcessId.Call(hwnd, uintptr(unsafe.Pointer(&pid)))
+	process, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return "unknown"
+	}
+	defer syscall.CloseHandle(process)
+
+	buf := make([]uint16, syscall.MAX_PATH)
+	size := uint32(len(buf))
+	if ret, _, _ := procQueryFullProcessImageNameW.Call(uintptr(process), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); ret == 0 {
+		return "unknown"
+	}
+	return strings.TrimSuffix(filepath.Base(syscall.UTF16ToString(buf[:size])), ".exe")
+}
@@ -0,0 +1,72 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var (
+	keepAwakeMu sync.Mutex
+	// keepAwake is the running caffeinate, nil if the PC may sleep
+	keepAwake *exec.Cmd
+)
+
+// KeepAwake keeps the PC and its display from sleeping while on is true, by running caffeinate on macOS.
+// caffeinate ends together with pc2mqtt.
+func KeepAwake(on bool) error {
+	if runtime.GOOS != MACOS {
+		return errors.New(runtime.GOOS + " does not support keeping awake")
+	}
+
+	keepAwakeMu.Lock()
+	defer keepAwakeMu.Unlock()
+	if !on {
+		if keepAwake == nil {
+			return nil
+		}
+		if dryRun != nil {
+			fmt.Fprintln(dryRun, "Would stop: "+keepAwake.String())
+			return nil
+		}
+		if err := keepAwake.Process.Kill(); err != nil {
+			return err
+		}
+		keepAwake = nil
+		return nil
+	}
+	if keepAwake != nil {
+		return nil
+	}
+
+	// Prevent display and idle sleep until pc2mqtt exits
+	cmd := exec.Command("caffeinate", "-d", "-i", "-w", strconv.Itoa(os.Getpid()))
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would start: "+cmd.String())
+		return nil
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Starting caffeinate failed: %w", err)
+	}
+	keepAwake = cmd
+	go func() {
+		cmd.Wait()
+		keepAwakeMu.Lock()
+		defer keepAwakeMu.Unlock()
+		if keepAwake == cmd {
+			keepAwake = nil
+		}
+	}()
+	return nil
+}
+
+// KeepingAwake reports whether KeepAwake keeps the PC from sleeping.
+func KeepingAwake() bool {
+	keepAwakeMu.Lock()
+	defer keepAwakeMu.Unlock()
+	return keepAwake != nil
+}
@@ -0,0 +1,106 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Ways to power off, reboot and suspend Linux
+const (
+	// LinuxPowerAuto asks logind over D-Bus and falls back to the first installed of systemctl, loginctl and shutdown
+	LinuxPowerAuto      = "auto"
+	LinuxPowerSystemctl = "systemctl"
+	// LinuxPowerLoginctl uses elogind, eg. on Alpine, Gentoo or Void with openrc or runit
+	LinuxPowerLoginctl = "loginctl"
+	// LinuxPowerShutdown runs shutdown of sysvinit or openrc, or poweroff and reboot of BusyBox
+	LinuxPowerShutdown = "shutdown"
+	// LinuxPowerSysrq powers off right away through the magic SysRq key, after syncing and remounting read-only
+	LinuxPowerSysrq = "sysrq"
+)
+
+// Actions of linuxPowerCommand, named like the systemctl commands
+const (
+	linuxPowerOff = "poweroff"
+	linuxReboot   = "reboot"
+	linuxSuspend  = "suspend"
+	// The sleep modes of the kernel are in /sys/power/state and /sys/power/disk
+	linuxHibernate   = "hibernate"
+	linuxHybridSleep = "hybrid-sleep"
+)
+
+// linuxPowerCommand returns the command running action with the LinuxPower of the power options.
+func linuxPowerCommand(action string) (*exec.Cmd, error) {
+	method := powerOptions.LinuxPower
+	if method == LinuxPowerAuto {
+		method = detectLinuxPower()
+	}
+
+	switch method {
+	case LinuxPowerSystemctl:
+		return exec.Command("systemctl", action, "--ignore-inhibitors"), nil
+	case LinuxPowerLoginctl:
+		return exec.Command("loginctl", action), nil
+	case LinuxPowerShutdown:
+		if isLinuxSleep(action) {
+			return sleepCommand(action), nil
+		}
+		// BusyBox has no shutdown
+		if _, err := exec.LookPath("shutdown"); err != nil {
+			return exec.Command(action), nil
+		}
+		if action == linuxReboot {
+			return exec.Command("shutdown", "-r", "now"), nil
+		}
+		return exec.Command("shutdown", "-h", "now"), nil
+	case LinuxPowerSysrq:
+		switch action {
+		case linuxPowerOff:
+			return sysrqCommand("o"), nil
+		case linuxReboot:
+			return sysrqCommand("b"), nil
+		default:
+			return sleepCommand(action), nil
+		}
+	default:
+		return nil, errors.New("Unknown Linux power method " + method)
+	}
+}
+
+// detectLinuxPower returns the way to power off this Linux, systemctl if systemd is the init system.
+func detectLinuxPower() string {
+	// Only exists while systemd runs, systemctl may be installed without it, eg. in containers or WSL
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		return LinuxPowerSystemctl
+	}
+	// The loginctl of elogind powers off, the one of systemd doesn't
+	if _, err := exec.LookPath("loginctl"); err == nil {
+		if _, err := exec.LookPath("systemctl"); err != nil {
+			return LinuxPowerLoginctl
+		}
+	}
+	return LinuxPowerShutdown
+}
+
+// sysrqCommand syncs the disks, remounts them read-only and then triggers the SysRq key, eg. o to power off.
+// https://docs.kernel.org/admin-guide/sysrq.html
+func sysrqCommand(key string) *exec.Cmd {
+	return exec.Command("sh", "-c", "echo s > /proc/sysrq-trigger && echo u > /proc/sysrq-trigger && echo "+key+" > /proc/sysrq-trigger")
+}
+
+func isLinuxSleep(action string) bool {
+	return action == linuxSuspend || action == linuxHibernate || action == linuxHybridSleep
+}
+
+// sleepCommand suspends, hibernates or sleeps hybrid through the kernel without any init system.
+// https://docs.kernel.org/admin-guide/pm/sleep-states.html
+func sleepCommand(action string) *exec.Cmd {
+	switch action {
+	case linuxHibernate:
+		return exec.Command("sh", "-c", "echo platform > /sys/power/disk && echo disk > /sys/power/state")
+	case linuxHybridSleep:
+		return exec.Command("sh", "-c", "echo suspend > /sys/power/disk && echo disk > /sys/power/state")
+	default:
+		return exec.Command("sh", "-c", "echo mem > /sys/power/state")
+	}
+}
@@ -0,0 +1,23 @@
+package system
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+)
+
+var platformUUIDPattern = regexp.MustCompile(`"IOPlatformUUID" = "([^"]+)"`)
+
+func machineId() (string, error) {
+	out, err := exec.Command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice").Output()
+	if err != nil {
+		return "", err
+	}
+
+	match := platformUUIDPattern.FindSubmatch(out)
+	if match == nil {
+		return "", errors.New("IOPlatformUUID not found in ioreg output")
+	}
+
+	return string(match[1]), nil
+}
@@ -0,0 +1,24 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+var machineIdFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+func machineId() (string, error) {
+	for _, path := range machineIdFiles {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		if id := strings.TrimSpace(string(buf)); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", errors.New("no machine id found in " + strings.Join(machineIdFiles, " or "))
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !windows
+
+package system
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+)
+
+func machineId() (string, error) {
+	// BSDs keep a generated host UUID in /etc/hostid
+	buf, err := os.ReadFile("/etc/hostid")
+	if err != nil {
+		return "", errors.New(runtime.GOOS + " does not provide a machine id")
+	}
+
+	return strings.TrimSpace(string(buf)), nil
+}
@@ -0,0 +1,5 @@
+package system
+
+func machineId() (string, error) {
+	return readRegistryString(`SOFTWARE\Microsoft\Cryptography`, "MachineGuid")
+}
@@ -0,0 +1,101 @@
+package system
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageStartup is how long a message window may take to fail, eg. without a display, before it counts as shown
+const messageStartup = time.Second
+
+// Reasons a full-screen message closed
+const (
+	MessageDismissed = "dismissed"
+	MessageTimedOut  = "timeout"
+	// MessageReplaced is a message closed for the next one
+	MessageReplaced = "replaced"
+)
+
+// FullScreenMessage is a large message covering the screen until the user dismisses it or Duration passes.
+type FullScreenMessage struct {
+	Title   string
+	Message string
+	// Duration is how long the message is shown, 0 until it is dismissed
+	Duration time.Duration
+}
+
+var (
+	shownMessageMu sync.Mutex
+	// shownMessage is the process showing the current message
+	shownMessage *os.Process
+)
+
+// ShowMessage shows message over the whole screen, replacing the one shown before, and calls onClose with
+// MessageDismissed, MessageTimedOut or MessageReplaced once it closes, in the background.
+func ShowMessage(message FullScreenMessage, onClose func(reason string)) error {
+	if dryRun != nil {
+		fmt.Fprintf(dryRun, "Would show full-screen message for %s: %s: %s\n", message.Duration, message.Title, message.Message)
+		return nil
+	}
+	cmd, timeoutCode, err := messageCommand(message)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	shownMessageMu.Lock()
+	if shownMessage != nil {
+		shownMessage.Kill()
+		shownMessage = nil
+	}
+	if err := cmd.Start(); err != nil {
+		shownMessageMu.Unlock()
+		return &CommandError{Command: cmd.Path, ExitCode: -1, Err: err}
+	}
+	shownMessage = cmd.Process
+	shownMessageMu.Unlock()
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		// Failed before showing the window, unless the next message already replaced it
+		reason := closeMessage(cmd.Process)
+		if err != nil && reason != MessageReplaced {
+			return &CommandError{Command: cmd.Path, ExitCode: cmd.ProcessState.ExitCode(), Stderr: strings.TrimSpace(stderr.String()), Err: err}
+		}
+		go onClose(reason)
+		return nil
+	case <-time.After(messageStartup):
+	}
+
+	go func() {
+		err := <-exited
+		reason := closeMessage(cmd.Process)
+		var exitErr *exec.ExitError
+		if reason == MessageDismissed && errors.As(err, &exitErr) && exitErr.ExitCode() == timeoutCode {
+			reason = MessageTimedOut
+		}
+		onClose(reason)
+	}()
+	return nil
+}
+
+// closeMessage forgets process as the shown message and returns MessageReplaced if another message replaced it,
+// MessageDismissed otherwise.
+func closeMessage(process *os.Process) string {
+	shownMessageMu.Lock()
+	defer shownMessageMu.Unlock()
+	if shownMessage != process {
+		return MessageReplaced
+	}
+	shownMessage = nil
+	return MessageDismissed
+}
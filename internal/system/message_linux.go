@@ -0,0 +1,93 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"html"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// Exit codes of yad and zenity when --timeout closed the dialog
+const (
+	yadTimeout    = 70
+	zenityTimeout = 5
+)
+
+// messageCommand returns the command showing message with yad, which covers the whole screen, or zenity, which
+// shows a large dialog, and the exit code it times out with. It runs in the graphical session on seat0, as its
+// user if pc2mqtt runs as root, eg. as a system service.
+func messageCommand(message FullScreenMessage) (*exec.Cmd, int, error) {
+	text := "<span font='48'>" + html.EscapeString(message.Message) + "</span>"
+	if message.Title != "" {
+		text = "<span font='32' weight='bold'>" + html.EscapeString(message.Title) + "</span>\n\n" + text
+	}
+	title := message.Title
+	if title == "" {
+		title = "pc2mqtt"
+	}
+
+	var cmd *exec.Cmd
+	var timeoutCode int
+	if _, err := exec.LookPath("yad"); err == nil {
+		cmd = exec.Command("yad", "--fullscreen", "--undecorated", "--on-top", "--skip-taskbar", "--center",
+			"--title", title, "--text", text, "--text-align", "center", "--button", "OK:0")
+		timeoutCode = yadTimeout
+	} else if _, err := exec.LookPath("zenity"); err == nil {
+		cmd = exec.Command("zenity", "--info", "--no-wrap", "--title", title, "--text", text)
+		timeoutCode = zenityTimeout
+	} else {
+		return nil, 0, errors.New("Showing full-screen messages needs yad or zenity")
+	}
+	if seconds := int(message.Duration.Seconds()); seconds > 0 {
+		cmd.Args = append(cmd.Args, "--timeout", strconv.Itoa(seconds))
+	}
+
+	if err := inGraphicalSession(cmd); err != nil {
+		return nil, 0, err
+	}
+	return cmd, timeoutCode, nil
+}
+
+// inGraphicalSession sets up cmd to open its window in the session logged in on seat0.
+func inGraphicalSession(cmd *exec.Cmd) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	props, err := activeSession(ctx)
+	if err != nil {
+		return err
+	}
+	uid := sessionUid(props)
+
+	if os.Getuid() == 0 {
+		account, err := user.LookupId(strconv.Itoa(int(uid)))
+		if err != nil {
+			return err
+		}
+		if _, err := withCredential(cmd, account); err != nil {
+			return err
+		}
+		// x11Vars leaves out the display variables pc2mqtt has, eg. when started with sudo, so pass those on
+		for _, name := range []string{"DISPLAY", "XAUTHORITY", "WAYLAND_DISPLAY"} {
+			if value := os.Getenv(name); value != "" {
+				cmd.Env = append(cmd.Env, name+"="+value)
+			}
+		}
+	} else {
+		cmd.Env = os.Environ()
+	}
+	display, _ := props["Display"].(string)
+	cmd.Env = append(cmd.Env, x11Vars(display, uid)...)
+	// Wayland sessions have no X display in logind, the compositor's socket is in the runtime dir
+	sessionType, _ := props["Type"].(string)
+	runtimeDir := filepath.Join("/run/user", strconv.Itoa(int(uid)))
+	if sessionType == DesktopWayland && os.Getenv("WAYLAND_DISPLAY") == "" {
+		if _, err := os.Stat(filepath.Join(runtimeDir, "wayland-0")); err == nil {
+			cmd.Env = append(cmd.Env, "WAYLAND_DISPLAY=wayland-0", "XDG_RUNTIME_DIR="+runtimeDir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package system
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// messageCommand returns an error, full-screen messages are only shown on Linux and Windows.
+func messageCommand(message FullScreenMessage) (*exec.Cmd, int, error) {
+	return nil, 0, errors.New(runtime.GOOS + " does not support full-screen messages")
+}
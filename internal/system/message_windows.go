@@ -0,0 +1,61 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// messageTimeout is the exit code of messageScript when the duration passed
+const messageTimeout = 2
+
+// messageScript shows PC2MQTT_TEXT in a borderless, topmost window covering the screen. A click or key closes
+// it, and so does the timer after PC2MQTT_DURATION seconds.
+const messageScript = `$ErrorActionPreference = 'Stop'
+Add-Type -AssemblyName System.Windows.Forms
+Add-Type -AssemblyName System.Drawing
+$form = New-Object Windows.Forms.Form
+$form.FormBorderStyle = 'None'
+$form.WindowState = 'Maximized'
+$form.TopMost = $true
+$form.ShowInTaskbar = $false
+$form.KeyPreview = $true
+$form.BackColor = [Drawing.Color]::Black
+$form.Text = $env:PC2MQTT_TITLE
+$label = New-Object Windows.Forms.Label
+$label.Dock = 'Fill'
+$label.TextAlign = 'MiddleCenter'
+$label.ForeColor = [Drawing.Color]::White
+$label.Font = New-Object Drawing.Font('Segoe UI', 48)
+$label.Text = $env:PC2MQTT_TEXT
+$form.Controls.Add($label)
+$script:timedOut = $false
+$label.Add_Click({ $form.Close() })
+$form.Add_KeyDown({ $form.Close() })
+if ([int]$env:PC2MQTT_DURATION -gt 0) {
+    $timer = New-Object Windows.Forms.Timer
+    $timer.Interval = [int]$env:PC2MQTT_DURATION * 1000
+    $timer.Add_Tick({ $script:timedOut = $true; $form.Close() })
+    $timer.Start()
+}
+$form.Add_Shown({ $form.Activate() })
+[Windows.Forms.Application]::Run($form)
+if ($script:timedOut) { exit 2 }
+`
+
+// messageCommand returns the command showing message with Windows Forms through PowerShell and the exit code it
+// times out with. Like toasts the message only shows in the session of a logged in user, not for a service in
+// session 0.
+func messageCommand(message FullScreenMessage) (*exec.Cmd, int, error) {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(messageScript))
+	text := message.Message
+	if message.Title != "" {
+		text = message.Title + "\n\n" + message.Message
+	}
+	cmd.Env = append(os.Environ(),
+		"PC2MQTT_TITLE="+message.Title,
+		"PC2MQTT_TEXT="+text,
+		fmt.Sprintf("PC2MQTT_DURATION=%d", int(message.Duration.Seconds())),
+	)
+	return cmd, messageTimeout, nil
+}
@@ -0,0 +1,36 @@
+package system
+
+import (
+	"net"
+)
+
+// NetworkInterface is a network adapter with the bytes it received and sent since boot.
+type NetworkInterface struct {
+	Name string
+	// Physical is set for network cards, unlike bridges, VPN tunnels or the virtual adapters of containers and VMs
+	Physical bool
+	Received uint64
+	Sent     uint64
+}
+
+// ReadNetworkInterfaces returns the network interfaces of the PC without the loopback interface.
+func ReadNetworkInterfaces() ([]NetworkInterface, error) {
+	interfaces, err := readNetworkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	loopback := map[string]bool{}
+	if system, err := net.Interfaces(); err == nil {
+		for _, adapter := range system {
+			loopback[adapter.Name] = adapter.Flags&net.FlagLoopback != 0
+		}
+	}
+	filtered := interfaces[:0]
+	for _, adapter := range interfaces {
+		if !loopback[adapter.Name] {
+			filtered = append(filtered, adapter)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,51 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readNetworkInterfaces parses /proc/net/dev, which has a line per interface after two header lines:
+// "  eth0: <received bytes> <7 more receive counters> <sent bytes> ..."
+func readNetworkInterfaces() ([]NetworkInterface, error) {
+	content, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []NetworkInterface
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	for _, line := range lines[min(2, len(lines)):] {
+		name, counters, found := strings.Cut(line, ":")
+		fields := strings.Fields(counters)
+		if !found || len(fields) < 9 {
+			return nil, fmt.Errorf("Unexpected /proc/net/dev line %q", line)
+		}
+		received, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unexpected /proc/net/dev line %q", line)
+		}
+		sent, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unexpected /proc/net/dev line %q", line)
+		}
+
+		name = strings.TrimSpace(name)
+		interfaces = append(interfaces, NetworkInterface{
+			Name:     name,
+			Physical: isPhysicalInterface(name),
+			Received: received,
+			Sent:     sent,
+		})
+	}
+	return interfaces, nil
+}
+
+// isPhysicalInterface reports whether name is backed by a device on a bus, like PCI or USB. Virtual
+// interfaces, eg. bridges, bonds, VLANs, tun and veth, have none.
+func isPhysicalInterface(name string) bool {
+	_, err := os.Stat("/sys/class/net/" + name + "/device")
+	return err == nil
+}
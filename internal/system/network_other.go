@@ -0,0 +1,76 @@
+//go:build !linux && !windows
+
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// virtualInterfacePrefixes are names of interfaces without a network card of their own on macOS and the BSDs
+var virtualInterfacePrefixes = []string{
+	"anpi", "ap", "awdl", "bridge", "epair", "feth", "gif", "lagg", "llw", "pflog", "pfsync", "stf", "tap", "tun",
+	"utun", "vlan", "vmenet", "vmnet", "vnet", "wg",
+}
+
+// readNetworkInterfaces parses "netstat -ibn", which lists every interface with its link address in a <Link#n>
+// row. Interfaces without a link address, eg. tunnels, leave the Address column empty, so the counters are
+// found counting from the end.
+func readNetworkInterfaces() ([]NetworkInterface, error) {
+	out, err := exec.Command("netstat", "-ibn").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	header := strings.Fields(lines[0])
+	receivedColumn := slices.Index(header, "Ibytes")
+	sentColumn := slices.Index(header, "Obytes")
+	if receivedColumn < 0 || sentColumn < 0 {
+		return nil, fmt.Errorf("Unexpected netstat header %q", lines[0])
+	}
+
+	var interfaces []NetworkInterface
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], "<Link#") {
+			continue
+		}
+		// The address is missing or present
+		if len(fields) != len(header) && len(fields) != len(header)-1 {
+			return nil, fmt.Errorf("Unexpected netstat line %q", line)
+		}
+		offset := len(header) - len(fields)
+		received, err := strconv.ParseUint(fields[receivedColumn-offset], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unexpected netstat line %q", line)
+		}
+		sent, err := strconv.ParseUint(fields[sentColumn-offset], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unexpected netstat line %q", line)
+		}
+
+		// macOS marks interfaces that are down with *
+		name := strings.TrimSuffix(fields[0], "*")
+		interfaces = append(interfaces, NetworkInterface{
+			Name:     name,
+			Physical: offset == 0 && !slices.ContainsFunc(virtualInterfacePrefixes, func(prefix string) bool { return hasInterfacePrefix(name, prefix) }),
+			Received: received,
+			Sent:     sent,
+		})
+	}
+	return interfaces, nil
+}
+
+// hasInterfacePrefix reports whether name is prefix followed by a unit number, eg. tap0 but not tape0.
+func hasInterfacePrefix(name string, prefix string) bool {
+	unit, found := strings.CutPrefix(name, prefix)
+	if !found || unit == "" {
+		return false
+	}
+	_, err := strconv.Atoi(unit)
+	return err == nil
+}
@@ -0,0 +1,97 @@
+package system
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	iphlpapi         = syscall.NewLazyDLL("iphlpapi.dll")
+	procGetIfTable2  = iphlpapi.NewProc("GetIfTable2")
+	procFreeMibTable = iphlpapi.NewProc("FreeMibTable")
+)
+
+// InterfaceAndOperStatusFlags of MIB_IF_ROW2
+const (
+	ifHardwareInterface = 0x01
+	// Filter interfaces are the layers of filter drivers, eg. WFP or QoS, above an adapter and repeat its counters
+	ifFilterInterface = 0x02
+)
+
+// mibIfRow2 is MIB_IF_ROW2, with the padding spelled out so the layout holds on 32 bit Windows too.
+// https://learn.microsoft.com/en-us/windows/win32/api/netioapi/ns-netioapi-mib_if_row2
+type mibIfRow2 struct {
+	interfaceLuid            uint64
+	interfaceIndex           uint32
+	interfaceGuid            [16]byte
+	alias                    [257]uint16
+	description              [257]uint16
+	physicalAddressLength    uint32
+	physicalAddress          [32]byte
+	permanentPhysicalAddress [32]byte
+	mtu                      uint32
+	ifType                   uint32
+	tunnelType               uint32
+	mediaType                uint32
+	physicalMediumType       uint32
+	accessType               uint32
+	directionType            uint32
+	flags                    uint8
+	_                        [3]byte
+	operStatus               uint32
+	adminStatus              uint32
+	mediaConnectState        uint32
+	networkGuid              [16]byte
+	connectionType           uint32
+	_                        [4]byte
+	transmitLinkSpeed        uint64
+	receiveLinkSpeed         uint64
+	inOctets                 uint64
+	inUcastPkts              uint64
+	inNUcastPkts             uint64
+	inDiscards               uint64
+	inErrors                 uint64
+	inUnknownProtos          uint64
+	inUcastOctets            uint64
+	inMulticastOctets        uint64
+	inBroadcastOctets        uint64
+	outOctets                uint64
+	outUcastPkts             uint64
+	outNUcastPkts            uint64
+	outDiscards              uint64
+	outErrors                uint64
+	outUcastOctets           uint64
+	outMulticastOctets       uint64
+	outBroadcastOctets       uint64
+	outQLen                  uint64
+}
+
+// mibIfTable2 is MIB_IF_TABLE2, followed by numEntries rows
+type mibIfTable2 struct {
+	numEntries uint32
+	_          [4]byte
+	table      [1]mibIfRow2
+}
+
+// readNetworkInterfaces reads the interfaces with GetIfTable2, named by their alias like "Ethernet" or "Wi-Fi".
+func readNetworkInterfaces() ([]NetworkInterface, error) {
+	var table *mibIfTable2
+	if ret, _, _ := procGetIfTable2.Call(uintptr(unsafe.Pointer(&table))); ret != 0 {
+		return nil, syscall.Errno(ret)
+	}
+	defer procFreeMibTable.Call(uintptr(unsafe.Pointer(table)))
+
+	var interfaces []NetworkInterface
+	for _, row := range unsafe.Slice(&table.table[0], table.numEntries) {
+		if row.flags&ifFilterInterface != 0 {
+			continue
+		}
+		interfaces = append(interfaces, NetworkInterface{
+			Name:     syscall.UTF16ToString(row.alias[:]),
+			Physical: row.flags&ifHardwareInterface != 0,
+			Received: row.inOctets,
+			Sent:     row.outOctets,
+		})
+	}
+	return interfaces, nil
+}
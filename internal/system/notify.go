@@ -0,0 +1,32 @@
+package system
+
+import "time"
+
+// notificationTimeout is how long a shown notification waits for a click of its actions
+const notificationTimeout = 10 * time.Minute
+
+// Urgencies of notifications
+const (
+	UrgencyLow      = "low"
+	UrgencyNormal   = "normal"
+	UrgencyCritical = "critical"
+)
+
+// Notification is a message shown to the user of the PC.
+type Notification struct {
+	Title   string
+	Message string
+	// Image is the path of a local image shown with the message
+	Image   string
+	Actions []NotificationAction
+	// Urgency is one of UrgencyLow, UrgencyNormal or UrgencyCritical, empty is normal
+	Urgency string
+	// Tag replaces the notification shown before with the same tag, if it is still shown
+	Tag string
+}
+
+// NotificationAction is a button of a notification.
+type NotificationAction struct {
+	Id    string
+	Label string
+}
@@ -0,0 +1,258 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/dbus"
+)
+
+const (
+	notificationsName  = "org.freedesktop.Notifications"
+	notificationsPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsIface = "org.freedesktop.Notifications"
+)
+
+// notifyTimeout bounds finding the user and showing the notification
+const notifyTimeout = 10 * time.Second
+
+var urgencies = map[string]byte{UrgencyLow: 0, UrgencyNormal: 1, UrgencyCritical: 2}
+
+var (
+	notificationIdsMu sync.Mutex
+	// notificationIds maps tags to the id of the notification last shown with them
+	notificationIds = make(map[string]uint32)
+)
+
+// Notify shows notification through the notification server of the desktop, eg. GNOME Shell, KDE Plasma
+// or dunst, and calls onAction with the id of the action the user clicks, in the background. As root, eg.
+// as a system service, the notification goes to the user logged in on seat0 through notifySendAs.
+// https://specifications.freedesktop.org/notification-spec/latest/
+func Notify(notification Notification, onAction func(id string)) error {
+	if dryRun != nil {
+		fmt.Fprintf(dryRun, "Would call %s.Notify: %s: %s\n", notificationsIface, notification.Title, notification.Message)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if os.Getuid() == 0 {
+		uid, err := activeUid(ctx)
+		if err != nil {
+			return err
+		}
+		if uid != 0 {
+			return notifySendAs(uid, notification, onAction)
+		}
+	}
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	// Subscribe before showing, a quick click must not get lost
+	signals := make(chan dbus.Signal, 16)
+	if len(notification.Actions) > 0 && onAction != nil {
+		rule := "type='signal',sender='" + notificationsName + "',interface='" + notificationsIface + "'"
+		if err := conn.Subscribe(ctx, rule, signals); err != nil {
+			conn.Close()
+			return fmt.Errorf("Subscribing to notification actions failed: %w", err)
+		}
+	}
+
+	// Actions are pairs of id and label
+	var actions []string
+	for _, action := range notification.Actions {
+		actions = append(actions, action.Id, action.Label)
+	}
+	hints := map[string]dbus.Variant{}
+	if urgency, ok := urgencies[notification.Urgency]; ok {
+		hints["urgency"] = dbus.Variant{Signature: "y", Value: urgency}
+	}
+	if notification.Image != "" {
+		hints["image-path"] = dbus.Variant{Signature: "s", Value: notification.Image}
+	}
+
+	notificationIdsMu.Lock()
+	replacesId := notificationIds[notification.Tag]
+	notificationIdsMu.Unlock()
+	// -1 lets the server decide when the notification expires
+	reply, err := conn.Call(ctx, notificationsName, notificationsPath, notificationsIface, "Notify",
+		"pc2mqtt", replacesId, "", notification.Title, notification.Message, actions, hints, int32(-1))
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("Showing the notification failed: %w", err)
+	}
+	if len(reply) == 0 {
+		conn.Close()
+		return errors.New("Showing the notification failed: Empty reply")
+	}
+	id, _ := reply[0].(uint32)
+	if notification.Tag != "" {
+		notificationIdsMu.Lock()
+		notificationIds[notification.Tag] = id
+		notificationIdsMu.Unlock()
+	}
+
+	if len(notification.Actions) == 0 || onAction == nil {
+		conn.Close()
+		return nil
+	}
+	go func() {
+		defer conn.Close()
+		if action, ok := waitForAction(signals, id); ok {
+			onAction(action)
+		}
+	}()
+	return nil
+}
+
+// notifySendAs shows notification on the desktop of the user uid by running notify-send as the user. The session
+// bus only accepts connections of the user itself, which root can't claim on the socket.
+func notifySendAs(uid int, notification Notification, onAction func(id string)) error {
+	account, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return err
+	}
+
+	args := []string{"--app-name=pc2mqtt", "--print-id"}
+	if _, ok := urgencies[notification.Urgency]; ok {
+		args = append(args, "--urgency="+notification.Urgency)
+	}
+	if notification.Image != "" {
+		args = append(args, "--icon="+notification.Image)
+	}
+	notificationIdsMu.Lock()
+	if replacesId, ok := notificationIds[notification.Tag]; ok {
+		args = append(args, "--replace-id="+strconv.FormatUint(uint64(replacesId), 10))
+	}
+	notificationIdsMu.Unlock()
+	wait := len(notification.Actions) > 0 && onAction != nil
+	if wait {
+		for _, action := range notification.Actions {
+			args = append(args, "--action="+action.Id+"="+action.Label)
+		}
+		args = append(args, "--wait")
+	}
+	args = append(args, "--", notification.Title, notification.Message)
+
+	timeout := notifyTimeout
+	if wait {
+		timeout = notificationTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	cmd, err := withCredential(exec.CommandContext(ctx, "notify-send", args...), account)
+	if err != nil {
+		cancel()
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("Showing the notification as %s failed, is notify-send installed? %w", account.Username, err)
+	}
+
+	// The id comes first, followed by the key of the invoked action with --wait
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() {
+		err := cmd.Wait()
+		cancel()
+		return fmt.Errorf("Showing the notification as %s failed: %w %s", account.Username, err, strings.TrimSpace(stderr.String()))
+	}
+	if id, err := strconv.ParseUint(strings.TrimSpace(lines.Text()), 10, 32); err == nil && notification.Tag != "" {
+		notificationIdsMu.Lock()
+		notificationIds[notification.Tag] = uint32(id)
+		notificationIdsMu.Unlock()
+	}
+
+	go func() {
+		defer cancel()
+		var action string
+		if lines.Scan() {
+			action = strings.TrimSpace(lines.Text())
+		}
+		cmd.Wait()
+		if wait && action != "" {
+			onAction(action)
+		}
+	}()
+	return nil
+}
+
+// waitForAction returns the action invoked on the notification id, until it is closed or times out.
+func waitForAction(signals <-chan dbus.Signal, id uint32) (string, bool) {
+	timeout := time.NewTimer(notificationTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case signal, ok := <-signals:
+			if !ok {
+				return "", false
+			}
+			// ActionInvoked(u id, s action_key) and NotificationClosed(u id, u reason)
+			if len(signal.Body) < 2 || signal.Body[0] != id {
+				continue
+			}
+			switch signal.Member {
+			case "ActionInvoked":
+				action, _ := signal.Body[1].(string)
+				return action, action != ""
+			case "NotificationClosed":
+				return "", false
+			}
+		case <-timeout.C:
+			return "", false
+		}
+	}
+}
+
+// activeUid returns the user of the session logged in on seat0.
+func activeUid(ctx context.Context) (int, error) {
+	props, err := activeSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int(sessionUid(props)), nil
+}
+
+// activeSession returns the logind properties of the session of the user logged in on seat0.
+func activeSession(ctx context.Context) (map[string]any, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	active, err := property(ctx, conn, "/org/freedesktop/login1/seat/seat0", "org.freedesktop.login1.Seat", "ActiveSession")
+	if err != nil {
+		return nil, fmt.Errorf("Reading the active session failed: %w", err)
+	}
+	fields, _ := active.([]any)
+	if len(fields) < 2 || fields[1] == dbus.ObjectPath("/") {
+		return nil, errors.New("No user is logged in")
+	}
+	props, err := properties(ctx, conn, fields[1].(dbus.ObjectPath), "org.freedesktop.login1.Session")
+	if err != nil {
+		return nil, fmt.Errorf("Reading the active session failed: %w", err)
+	}
+	if class, _ := props["Class"].(string); class != "user" {
+		return nil, errors.New("No user is logged in")
+	}
+	return props, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package system
+
+import (
+	"errors"
+	"runtime"
+)
+
+// Notify returns an error, notifications are only shown on Linux and Windows.
+func Notify(notification Notification, onAction func(id string)) error {
+	return errors.New(runtime.GOOS + " does not support notifications")
+}
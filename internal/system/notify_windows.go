@@ -0,0 +1,127 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf16"
+)
+
+// toastAppId is the app the toasts are shown for. Toasts need a registered app, which pc2mqtt isn't.
+const toastAppId = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows the toast XML of PC2MQTT_TOAST through WinRT, prints "shown" and then the arguments
+// of the clicked action, if any is clicked before the toast is dismissed or times out.
+const toastScript = `$ErrorActionPreference = 'Stop'
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml($env:PC2MQTT_TOAST)
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+if ($env:PC2MQTT_TAG) {
+    $toast.Tag = $env:PC2MQTT_TAG
+    $toast.Group = 'pc2mqtt'
+}
+Register-ObjectEvent -InputObject $toast -EventName Activated -SourceIdentifier activated | Out-Null
+Register-ObjectEvent -InputObject $toast -EventName Dismissed -SourceIdentifier dismissed | Out-Null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:PC2MQTT_APP_ID).Show($toast)
+[Console]::Out.WriteLine('shown')
+$event = Wait-Event -Timeout $env:PC2MQTT_TIMEOUT
+if ($event -and $event.SourceIdentifier -eq 'activated') {
+    [Console]::Out.WriteLine(([Windows.UI.Notifications.ToastActivatedEventArgs]$event.SourceEventArgs).Arguments)
+}
+`
+
+// Notify shows notification as toast and calls onAction with the id of the action the user clicks, in the
+// background. Toasts only show in the session of a logged in user, not for a service in session 0. Low
+// urgency toasts are silent, Windows has no other urgencies.
+func Notify(notification Notification, onAction func(id string)) error {
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(toastScript))
+	cmd.Env = append(os.Environ(),
+		"PC2MQTT_TOAST="+toastXml(notification),
+		"PC2MQTT_APP_ID="+toastAppId,
+		"PC2MQTT_TAG="+notification.Tag,
+		fmt.Sprintf("PC2MQTT_TIMEOUT=%d", int(notificationTimeout.Seconds())),
+	)
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would show toast: "+toastXml(notification))
+		return nil
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return &CommandError{Command: "powershell.exe", ExitCode: -1, Err: err}
+	}
+
+	lines := bufio.NewScanner(stdout)
+	if !lines.Scan() || lines.Text() != "shown" {
+		err := cmd.Wait()
+		if err == nil {
+			err = errors.New("The toast was not shown")
+		}
+		return &CommandError{Command: "powershell.exe", ExitCode: cmd.ProcessState.ExitCode(), Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+
+	go func() {
+		defer cmd.Wait()
+		if lines.Scan() && lines.Text() != "" && onAction != nil {
+			onAction(lines.Text())
+		}
+	}()
+	return nil
+}
+
+// toastXml returns the toast content of notification.
+// https://learn.microsoft.com/en-us/windows/apps/design/shell/tiles-and-notifications/adaptive-interactive-toasts
+func toastXml(notification Notification) string {
+	var b strings.Builder
+	b.WriteString(`<toast><visual><binding template="ToastGeneric">`)
+	for _, text := range []string{notification.Title, notification.Message} {
+		if text != "" {
+			b.WriteString("<text>" + escapeXml(text) + "</text>")
+		}
+	}
+	if notification.Image != "" {
+		b.WriteString(`<image placement="appLogoOverride" src="` + escapeXml(notification.Image) + `"/>`)
+	}
+	b.WriteString("</binding></visual>")
+	if notification.Urgency == UrgencyLow {
+		b.WriteString(`<audio silent="true"/>`)
+	}
+	if len(notification.Actions) > 0 {
+		b.WriteString("<actions>")
+		for _, action := range notification.Actions {
+			b.WriteString(`<action activationType="foreground" content="` + escapeXml(action.Label) + `" arguments="` + escapeXml(action.Id) + `"/>`)
+		}
+		b.WriteString("</actions>")
+	}
+	b.WriteString("</toast>")
+	return b.String()
+}
+
+func escapeXml(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// encodePowerShell encodes script for -EncodedCommand, which avoids quoting it on the command line.
+func encodePowerShell(script string) string {
+	var buf bytes.Buffer
+	for _, unit := range utf16.Encode([]rune(script)) {
+		binary.Write(&buf, binary.LittleEndian, unit)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
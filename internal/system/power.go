@@ -0,0 +1,51 @@
+package system
+
+// PowerOptions decide how the PC powers off, reboots and suspends.
+type PowerOptions struct {
+	// LinuxPower is one of the LinuxPower constants, eg. LinuxPowerLoginctl
+	LinuxPower string
+	// HybridShutdown shuts Windows down for Fast Startup, which hibernates the kernel instead of powering off fully
+	HybridShutdown bool
+	// SleepMode is one of the SleepMode constants, eg. SleepModeHibernate
+	SleepMode string
+}
+
+var powerOptions = PowerOptions{LinuxPower: LinuxPowerAuto, SleepMode: SleepModeSuspend}
+
+// SetPowerOptions replaces the power options. Call it before any action runs.
+func SetPowerOptions(options PowerOptions) {
+	powerOptions = options
+}
+
+// Shutdown powers the PC off.
+func Shutdown() error {
+	return powerAction(logindPowerOff, GetShutdownCommand)
+}
+
+// Reboot restarts the PC.
+func Reboot() error {
+	return powerAction(logindReboot, GetRebootCommand)
+}
+
+// Suspend puts the PC to sleep with the SleepMode of the power options.
+func Suspend() error {
+	switch powerOptions.SleepMode {
+	case SleepModeHibernate:
+		return powerAction(logindHibernate, GetSuspendCommand)
+	case SleepModeHybrid:
+		return powerAction(logindHybridSleep, GetSuspendCommand)
+	default:
+		return powerAction(logindSuspend, GetSuspendCommand)
+	}
+}
+
+// Methods of the logind manager
+// https://www.freedesktop.org/software/systemd/man/latest/org.freedesktop.login1.html
+const (
+	logindPowerOff = "PowerOff"
+	logindReboot   = "Reboot"
+	logindSuspend  = "Suspend"
+	// Need a swap of at least the used memory
+	logindHibernate   = "Hibernate"
+	logindHybridSleep = "HybridSleep"
+)
@@ -0,0 +1,68 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/leonlatsch/pc2mqtt/internal/dbus"
+)
+
+const (
+	logindName    = "org.freedesktop.login1"
+	logindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManager = "org.freedesktop.login1.Manager"
+)
+
+// logindTimeout bounds a call of logind, which answers once the action is scheduled
+const logindTimeout = 30 * time.Second
+
+var errNoLogind = errors.New("logind is not running")
+
+// powerAction calls method of logind, which honours delay inhibitors and authorizes non-root users
+// through polkit. Without a system bus or logind, eg. in containers, or with a power method other than
+// LinuxPowerAuto the command of fallback runs.
+func powerAction(method string, fallback func() (*exec.Cmd, error)) error {
+	if powerOptions.LinuxPower == LinuxPowerAuto {
+		conn, err := dbus.SystemBus()
+		if err == nil {
+			defer conn.Close()
+			if err := callLogind(conn, method); !errors.Is(err, errNoLogind) {
+				return err
+			}
+		}
+	}
+
+	cmd, err := fallback()
+	if err != nil {
+		return err
+	}
+	return RunCommand(cmd)
+}
+
+func callLogind(conn *dbus.Conn, method string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), logindTimeout)
+	defer cancel()
+
+	if dryRun != nil {
+		reply, err := conn.Call(ctx, "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "NameHasOwner", logindName)
+		if err != nil || len(reply) == 0 || reply[0] != true {
+			return errNoLogind
+		}
+		fmt.Fprintln(dryRun, "Would call "+logindManager+"."+method)
+		return nil
+	}
+
+	// Not interactive, nobody could answer a polkit password prompt
+	_, err := conn.Call(ctx, logindName, logindPath, logindManager, method, false)
+	var dbusErr *dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		return errNoLogind
+	}
+	if err != nil {
+		return fmt.Errorf("Calling logind %s failed: %w", method, err)
+	}
+	return nil
+}
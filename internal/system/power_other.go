@@ -0,0 +1,14 @@
+//go:build !linux && !windows
+
+package system
+
+import "os/exec"
+
+// powerAction runs the command of fallback. Linux asks logind and Windows calls its API instead.
+func powerAction(_ string, fallback func() (*exec.Cmd, error)) error {
+	cmd, err := fallback()
+	if err != nil {
+		return err
+	}
+	return RunCommand(cmd)
+}
@@ -0,0 +1,222 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                   = syscall.NewLazyDLL("advapi32.dll")
+	procLookupPrivilegeValueW  = advapi32.NewProc("LookupPrivilegeValueW")
+	procAdjustTokenPrivileges  = advapi32.NewProc("AdjustTokenPrivileges")
+	procInitiateShutdownW      = advapi32.NewProc("InitiateSystemShutdownExW")
+	procExitWindowsEx          = user32.NewProc("ExitWindowsEx")
+	powrprof                   = syscall.NewLazyDLL("powrprof.dll")
+	procSetSuspendState        = powrprof.NewProc("SetSuspendState")
+	procPowerGetActiveScheme   = powrprof.NewProc("PowerGetActiveScheme")
+	procPowerSetActiveScheme   = powrprof.NewProc("PowerSetActiveScheme")
+	procPowerReadACValueIndex  = powrprof.NewProc("PowerReadACValueIndex")
+	procPowerReadDCValueIndex  = powrprof.NewProc("PowerReadDCValueIndex")
+	procPowerWriteACValueIndex = powrprof.NewProc("PowerWriteACValueIndex")
+	procPowerWriteDCValueIndex = powrprof.NewProc("PowerWriteDCValueIndex")
+)
+
+// "Allow hybrid sleep" in the sleep settings of power plans, HYBRIDSLEEP in SUB_SLEEP of powercfg
+var (
+	guidSubSleep    = syscall.GUID{Data1: 0x238c9fa8, Data2: 0x0aad, Data3: 0x41ed, Data4: [8]byte{0x83, 0xf4, 0x97, 0xbe, 0x24, 0x2c, 0x8f, 0x20}}
+	guidHybridSleep = syscall.GUID{Data1: 0x94ac6d29, Data2: 0x73ce, Data3: 0x41a6, Data4: [8]byte{0x80, 0x9f, 0x63, 0x63, 0xba, 0x21, 0xb4, 0x7e}}
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-exitwindowsex
+const (
+	ewxReboot         = 0x02
+	ewxPowerOff       = 0x08
+	ewxForceIfHung    = 0x10
+	ewxHybridShutdown = 0x00400000
+
+	// Planned "Other (Planned)" shutdown, as logged in the event log
+	shutdownReasonPlanned = 0x80000000
+
+	sePrivilegeEnabled = 0x02
+	errNotAllAssigned  = syscall.Errno(1300)
+)
+
+// powerAction calls the Windows API for method instead of shutdown.exe, so the action works where
+// running programs is restricted and fails with the actual Windows error.
+func powerAction(method string, _ func() (*exec.Cmd, error)) error {
+	var call string
+	var action func() error
+	switch method {
+	case logindPowerOff:
+		// Without EWX_HYBRID_SHUTDOWN the PC powers off fully, unlike Shut Down in the start menu with Fast Startup
+		call, action = "ExitWindowsEx(EWX_POWEROFF)", func() error { return exitWindows(ewxPowerOff, false) }
+		if powerOptions.HybridShutdown {
+			call, action = "ExitWindowsEx(EWX_POWEROFF|EWX_HYBRID_SHUTDOWN)", func() error { return exitWindows(ewxPowerOff|ewxHybridShutdown, false) }
+		}
+	case logindReboot:
+		call, action = "ExitWindowsEx(EWX_REBOOT)", func() error { return exitWindows(ewxReboot, true) }
+	case logindSuspend:
+		call, action = "SetSuspendState", func() error { return suspend(false) }
+	case logindHibernate:
+		call, action = "SetSuspendState(hibernate)", func() error { return suspend(true) }
+	case logindHybridSleep:
+		call, action = "SetSuspendState with hybrid sleep allowed", hybridSleep
+	default:
+		return errors.New("Unsupported power action " + method)
+	}
+
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would call "+call)
+		return nil
+	}
+	if err := enableShutdownPrivilege(); err != nil {
+		return fmt.Errorf("Acquiring the shutdown privilege failed: %w", err)
+	}
+	if err := action(); err != nil {
+		return fmt.Errorf("Calling %s failed: %w", call, err)
+	}
+	return nil
+}
+
+// exitWindows shuts down or reboots the PC. Programs that don't respond are closed, others may still
+// cancel the shutdown. If ExitWindowsEx is refused, eg. without an interactive session,
+// InitiateSystemShutdownEx is asked instead, which always powers off fully.
+func exitWindows(flags uintptr, reboot bool) error {
+	ret, _, err := procExitWindowsEx.Call(flags|ewxForceIfHung, shutdownReasonPlanned)
+	if ret != 0 {
+		return nil
+	}
+
+	var rebootAfterShutdown uintptr
+	if reboot {
+		rebootAfterShutdown = 1
+	}
+	// Local machine, no message, no timeout and without forcing programs closed
+	if ret, _, err2 := procInitiateShutdownW.Call(0, 0, 0, 0, rebootAfterShutdown, shutdownReasonPlanned); ret == 0 {
+		return fmt.Errorf("%w, InitiateSystemShutdownEx: %w", err, err2)
+	}
+	return nil
+}
+
+// suspend sleeps or hibernates, asking programs and allowing wake events.
+func suspend(hibernate bool) error {
+	var hibernateFlag uintptr
+	if hibernate {
+		hibernateFlag = 1
+	}
+	if ret, _, err := procSetSuspendState.Call(hibernateFlag, 0, 0); ret == 0 {
+		return err
+	}
+	return nil
+}
+
+// hybridSleep turns on "Allow hybrid sleep" in the active power plan, which then decides how Windows sleeps,
+// and suspends. The previous setting is restored after resuming, so the power plan isn't changed for good.
+func hybridSleep() error {
+	scheme, err := activePowerScheme()
+	if err != nil {
+		return fmt.Errorf("Reading the active power plan failed: %w", err)
+	}
+	ac, dc, err := readHybridSleep(&scheme)
+	if err != nil {
+		return fmt.Errorf("Reading hybrid sleep of the power plan failed: %w", err)
+	}
+	if ac == 1 && dc == 1 {
+		return suspend(false)
+	}
+
+	if err := writeHybridSleep(&scheme, 1, 1); err != nil {
+		return fmt.Errorf("Allowing hybrid sleep in the power plan failed: %w", err)
+	}
+	// SetSuspendState returns once the PC resumed
+	suspendErr := suspend(false)
+	if err := writeHybridSleep(&scheme, ac, dc); err != nil {
+		return errors.Join(suspendErr, fmt.Errorf("Restoring hybrid sleep in the power plan failed: %w", err))
+	}
+	return suspendErr
+}
+
+// activePowerScheme returns the GUID of the active power plan.
+func activePowerScheme() (syscall.GUID, error) {
+	var scheme *syscall.GUID
+	if ret, _, _ := procPowerGetActiveScheme.Call(0, uintptr(unsafe.Pointer(&scheme))); ret != 0 {
+		return syscall.GUID{}, syscall.Errno(ret)
+	}
+	defer syscall.LocalFree(syscall.Handle(unsafe.Pointer(scheme)))
+	return *scheme, nil
+}
+
+// readHybridSleep returns "Allow hybrid sleep" of scheme on AC and on battery, 1 if allowed.
+func readHybridSleep(scheme *syscall.GUID) (ac uint32, dc uint32, err error) {
+	for _, read := range []struct {
+		proc  *syscall.LazyProc
+		value *uint32
+	}{{procPowerReadACValueIndex, &ac}, {procPowerReadDCValueIndex, &dc}} {
+		ret, _, _ := read.proc.Call(0, uintptr(unsafe.Pointer(scheme)), uintptr(unsafe.Pointer(&guidSubSleep)),
+			uintptr(unsafe.Pointer(&guidHybridSleep)), uintptr(unsafe.Pointer(read.value)))
+		if ret != 0 {
+			return 0, 0, syscall.Errno(ret)
+		}
+	}
+	return ac, dc, nil
+}
+
+// writeHybridSleep sets "Allow hybrid sleep" of scheme on AC and on battery and applies it.
+func writeHybridSleep(scheme *syscall.GUID, ac uint32, dc uint32) error {
+	for _, write := range []struct {
+		proc  *syscall.LazyProc
+		value uint32
+	}{{procPowerWriteACValueIndex, ac}, {procPowerWriteDCValueIndex, dc}} {
+		ret, _, _ := write.proc.Call(0, uintptr(unsafe.Pointer(scheme)), uintptr(unsafe.Pointer(&guidSubSleep)),
+			uintptr(unsafe.Pointer(&guidHybridSleep)), uintptr(write.value))
+		if ret != 0 {
+			return syscall.Errno(ret)
+		}
+	}
+	// Changes of the active plan only apply once it is activated again
+	if ret, _, _ := procPowerSetActiveScheme.Call(0, uintptr(unsafe.Pointer(scheme))); ret != 0 {
+		return syscall.Errno(ret)
+	}
+	return nil
+}
+
+// enableShutdownPrivilege enables SE_SHUTDOWN_NAME in the token of pc2mqtt, which every shutdown
+// API requires. Even administrators and services only hold it disabled.
+func enableShutdownPrivilege() error {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	var token syscall.Token
+	if err := syscall.OpenProcessToken(process, syscall.TOKEN_ADJUST_PRIVILEGES|syscall.TOKEN_QUERY, &token); err != nil {
+		return err
+	}
+	defer token.Close()
+
+	// TOKEN_PRIVILEGES with a single LUID_AND_ATTRIBUTES
+	privileges := struct {
+		count      uint32
+		luid       [2]uint32
+		attributes uint32
+	}{count: 1, attributes: sePrivilegeEnabled}
+	name, err := syscall.UTF16PtrFromString("SeShutdownPrivilege")
+	if err != nil {
+		return err
+	}
+	if ret, _, err := procLookupPrivilegeValueW.Call(0, uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&privileges.luid))); ret == 0 {
+		return err
+	}
+
+	ret, _, err := procAdjustTokenPrivileges.Call(uintptr(token), 0, uintptr(unsafe.Pointer(&privileges)), 0, 0, 0)
+	if ret == 0 {
+		return err
+	}
+	// Succeeds without enabling privileges the account doesn't hold
+	if errors.Is(err, errNotAllAssigned) {
+		return errors.New("The account of pc2mqtt may not shut down the PC")
+	}
+	return nil
+}
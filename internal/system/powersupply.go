@@ -0,0 +1,87 @@
+package system
+
+import (
+	"context"
+	"time"
+)
+
+// Power supply changes of WatchPowerSupply
+const (
+	PowerSupplyAcPlugged   = "ac_plugged"
+	PowerSupplyAcUnplugged = "ac_unplugged"
+	PowerSupplyLidClosed   = "lid_closed"
+	PowerSupplyLidOpened   = "lid_opened"
+)
+
+// powerSupplyCheck is how often WatchPowerSupply reads the power supply
+const powerSupplyCheck = 5 * time.Second
+
+// PowerSupply tells whether the PC runs on AC power and whether its lid is closed. Fields are nil where
+// the PC can't tell, eg. desktops without battery or lid.
+type PowerSupply struct {
+	OnAc      *bool
+	LidClosed *bool
+}
+
+// PowerSupplyChanges returns the changes WatchPowerSupply can send on this PC.
+func PowerSupplyChanges() []string {
+	var changes []string
+	supply := readPowerSupply()
+	if supply.OnAc != nil {
+		changes = append(changes, PowerSupplyAcPlugged, PowerSupplyAcUnplugged)
+	}
+	if supply.LidClosed != nil {
+		changes = append(changes, PowerSupplyLidClosed, PowerSupplyLidOpened)
+	}
+	return changes
+}
+
+// WatchPowerSupply sends a change to changes whenever AC power is plugged or unplugged and the lid is
+// closed or opened, until ctx is done. It returns right away if the PC can't tell either.
+func WatchPowerSupply(ctx context.Context, changes chan<- string) error {
+	previous := readPowerSupply()
+	if previous.OnAc == nil && previous.LidClosed == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(powerSupplyCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current := readPowerSupply()
+		if powerSupplyChanged(previous.OnAc, current.OnAc) {
+			if err := sendPowerSupplyChange(ctx, changes, *current.OnAc, PowerSupplyAcPlugged, PowerSupplyAcUnplugged); err != nil {
+				return err
+			}
+		}
+		if powerSupplyChanged(previous.LidClosed, current.LidClosed) {
+			if err := sendPowerSupplyChange(ctx, changes, *current.LidClosed, PowerSupplyLidClosed, PowerSupplyLidOpened); err != nil {
+				return err
+			}
+		}
+		previous = current
+	}
+}
+
+// powerSupplyChanged reports whether current is known and differs from previous.
+func powerSupplyChanged(previous *bool, current *bool) bool {
+	return previous != nil && current != nil && *previous != *current
+}
+
+func sendPowerSupplyChange(ctx context.Context, changes chan<- string, on bool, changeOn string, changeOff string) error {
+	change := changeOff
+	if on {
+		change = changeOn
+	}
+	select {
+	case changes <- change:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readPowerSupply reads the mains power supplies of the kernel and the ACPI lid button.
+// https://docs.kernel.org/power/power_supply_class.html
+func readPowerSupply() PowerSupply {
+	var supply PowerSupply
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	for _, dir := range supplies {
+		kind, err := os.ReadFile(filepath.Join(dir, "type"))
+		if err != nil || strings.TrimSpace(string(kind)) != "Mains" {
+			continue
+		}
+		online, err := os.ReadFile(filepath.Join(dir, "online"))
+		if err != nil {
+			continue
+		}
+		// Any plugged in adapter powers the PC
+		onAc := strings.TrimSpace(string(online)) == "1" || supply.OnAc != nil && *supply.OnAc
+		supply.OnAc = &onAc
+	}
+
+	// Holds eg. "state:      closed"
+	lids, _ := filepath.Glob("/proc/acpi/button/lid/*/state")
+	for _, path := range lids {
+		state, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		closed := strings.Contains(string(state), "closed")
+		supply.LidClosed = &closed
+		break
+	}
+	return supply
+}
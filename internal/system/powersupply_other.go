@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package system
+
+// readPowerSupply reports neither AC power nor lid, which pc2mqtt doesn't read on this OS.
+func readPowerSupply() PowerSupply {
+	return PowerSupply{}
+}
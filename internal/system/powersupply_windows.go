@@ -0,0 +1,33 @@
+package system
+
+import "unsafe"
+
+var procGetSystemPowerStatus = kernel32.NewProc("GetSystemPowerStatus")
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winbase/ns-winbase-system_power_status
+const (
+	acLineOnline       = 1
+	batteryFlagNone    = 128
+	batteryFlagUnknown = 255
+)
+
+// readPowerSupply asks Windows for the AC line status. It isn't known on PCs without battery, which always
+// report AC power. Windows tells about the lid only through window messages.
+func readPowerSupply() PowerSupply {
+	var status struct {
+		acLineStatus        byte
+		batteryFlag         byte
+		batteryLifePercent  byte
+		systemStatusFlag    byte
+		batteryLifeTime     uint32
+		batteryFullLifeTime uint32
+	}
+	if ret, _, _ := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); ret == 0 {
+		return PowerSupply{}
+	}
+	if status.batteryFlag == batteryFlagNone || status.batteryFlag == batteryFlagUnknown || status.acLineStatus > acLineOnline {
+		return PowerSupply{}
+	}
+	onAc := status.acLineStatus == acLineOnline
+	return PowerSupply{OnAc: &onAc}
+}
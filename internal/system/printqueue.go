@@ -0,0 +1,10 @@
+package system
+
+// PrintJob is a document waiting in the local print queue.
+type PrintJob struct {
+	Printer string `json:"printer,omitempty"`
+	// Name is the document, eg. the file name
+	Name string `json:"name"`
+	// Owner is the user who printed it
+	Owner string `json:"owner,omitempty"`
+}
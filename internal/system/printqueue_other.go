@@ -0,0 +1,44 @@
+//go:build !windows
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// HasPrintQueue reports whether CUPS or another lpd compatible print system is installed.
+func HasPrintQueue() bool {
+	_, err := exec.LookPath("lpq")
+	return err == nil
+}
+
+// PrintJobs returns the jobs of every printer from "lpq -a", which prints a header and then a line per job:
+// "active  alice  12  Report.pdf  102400 bytes". The printer is not listed.
+func PrintJobs(ctx context.Context) ([]PrintJob, error) {
+	out, err := exec.CommandContext(ctx, "lpq", "-a").Output()
+	if err != nil {
+		return nil, fmt.Errorf("lpq failed: %w", err)
+	}
+
+	var jobs []PrintJob
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		// "no entries", the header and the size at the end of every job
+		if len(fields) < 6 || fields[0] == "Rank" || fields[len(fields)-1] != "bytes" {
+			continue
+		}
+		jobs = append(jobs, PrintJob{
+			Name:  strings.Join(fields[3:len(fields)-2], " "),
+			Owner: fields[1],
+		})
+	}
+	return jobs, nil
+}
+
+// ClearPrintQueue cancels every job of every printer. Only root may cancel the jobs of other users.
+func ClearPrintQueue() error {
+	return RunCommand(exec.Command("cancel", "-a"))
+}
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// printQueueTimeout bounds restarting the print spooler
+const printQueueTimeout = 60 * time.Second
+
+// HasPrintQueue reports true, every Windows has the print spooler.
+func HasPrintQueue() bool {
+	return true
+}
+
+// PrintJobs returns the jobs of every printer from Get-PrintJob.
+func PrintJobs(ctx context.Context) ([]PrintJob, error) {
+	out, err := printCmdlet(ctx, `Get-Printer | ForEach-Object { Get-PrintJob -PrinterName $_.Name } | `+
+		`ForEach-Object { @{printer = $_.PrinterName; name = $_.DocumentName; owner = $_.UserName} } | ConvertTo-Json -Compress`)
+	if err != nil {
+		return nil, err
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil, nil
+	}
+	// ConvertTo-Json doesn't wrap a single job in an array
+	if !strings.HasPrefix(out, "[") {
+		out = "[" + out + "]"
+	}
+	var jobs []PrintJob
+	if err := json.Unmarshal([]byte(out), &jobs); err != nil {
+		return nil, fmt.Errorf("Unexpected Get-PrintJob output: %w", err)
+	}
+	return jobs, nil
+}
+
+// ClearPrintQueue stops the print spooler, deletes the spooled jobs and starts the spooler again, which also
+// removes jobs that Remove-PrintJob can't. Needs an administrator, like the pc2mqtt service.
+func ClearPrintQueue() error {
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would stop the Spooler service, delete the spooled jobs and start it again")
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), printQueueTimeout)
+	defer cancel()
+	_, err := printCmdlet(ctx, `Stop-Service Spooler -Force
+Remove-Item "$env:SystemRoot\System32\spool\PRINTERS\*" -Force -ErrorAction SilentlyContinue
+Start-Service Spooler`)
+	return err
+}
+
+// printCmdlet runs script in PowerShell, stopping at the first error, and returns its output.
+func printCmdlet(ctx context.Context, script string) (string, error) {
+	script = "$ErrorActionPreference = 'Stop'\n" + script
+	out, err := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(script)).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("PowerShell failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return string(out), err
+}
@@ -0,0 +1,43 @@
+package system
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// readRegistryString reads a string value below HKEY_LOCAL_MACHINE.
+func readRegistryString(subkey string, value string) (string, error) {
+	subkeyPtr, err := syscall.UTF16PtrFromString(subkey)
+	if err != nil {
+		return "", err
+	}
+
+	var key syscall.Handle
+	// Always read the 64 bit view, 32 bit processes would otherwise be redirected
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_LOCAL_MACHINE, subkeyPtr, 0, syscall.KEY_READ|syscall.KEY_WOW64_64KEY, &key); err != nil {
+		return "", err
+	}
+	defer syscall.RegCloseKey(key)
+
+	name, err := syscall.UTF16PtrFromString(value)
+	if err != nil {
+		return "", err
+	}
+
+	// Ask for the size first, values like the product name have no fixed length
+	var valueType, size uint32
+	if err := syscall.RegQueryValueEx(key, name, nil, &valueType, nil, &size); err != nil {
+		return "", err
+	}
+	if size == 0 {
+		return "", nil
+	}
+
+	buf := make([]uint16, size/2+1)
+	size = uint32(len(buf) * 2)
+	if err := syscall.RegQueryValueEx(key, name, nil, &valueType, (*byte)(unsafe.Pointer(&buf[0])), &size); err != nil {
+		return "", err
+	}
+
+	return syscall.UTF16ToString(buf), nil
+}
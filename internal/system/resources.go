@@ -0,0 +1,8 @@
+package system
+
+// Memory is the physical memory of the PC in bytes.
+type Memory struct {
+	Total uint64
+	// Used is the memory taken by processes and the kernel, without caches that are freed on demand
+	Used uint64
+}
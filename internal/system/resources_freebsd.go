@@ -0,0 +1,86 @@
+package system
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	cpuTimesMu sync.Mutex
+	// cpuTimes is the last read of kern.cp_time
+	cpuTimes []uint64
+)
+
+// sysctl reads the numeric values of names with the sysctl command, which prints one line per name.
+// Array values like kern.cp_time are separated by spaces.
+func sysctl(names ...string) ([][]uint64, error) {
+	out, err := exec.Command("sysctl", append([]string{"-n"}, names...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != len(names) {
+		return nil, fmt.Errorf("Unexpected sysctl output %q", out)
+	}
+	values := make([][]uint64, len(lines))
+	for i, line := range lines {
+		for _, field := range strings.Fields(line) {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid %s %q", names[i], line)
+			}
+			values[i] = append(values[i], value)
+		}
+		if len(values[i]) == 0 {
+			return nil, fmt.Errorf("Invalid %s %q", names[i], line)
+		}
+	}
+	return values, nil
+}
+
+// ReadCpuUsage returns the percentage of CPU time spent busy since the last read, or since boot on the first.
+func ReadCpuUsage() (float64, error) {
+	values, err := sysctl("kern.cp_time")
+	if err != nil {
+		return 0, err
+	}
+	// Ticks in user, nice, system, interrupt and idle
+	times := values[0]
+	if len(times) != 5 {
+		return 0, fmt.Errorf("Invalid kern.cp_time %v", times)
+	}
+
+	cpuTimesMu.Lock()
+	last := cpuTimes
+	cpuTimes = times
+	cpuTimesMu.Unlock()
+
+	var total, idle uint64
+	for i, ticks := range times {
+		if len(last) == len(times) {
+			ticks -= last[i]
+		}
+		total += ticks
+		if i == 4 {
+			idle = ticks
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(total-idle) / float64(total) * 100, nil
+}
+
+// ReadMemory reads the physical memory from sysctl. Free and inactive pages count as available.
+func ReadMemory() (Memory, error) {
+	values, err := sysctl("hw.physmem", "hw.pagesize", "vm.stats.vm.v_free_count", "vm.stats.vm.v_inactive_count")
+	if err != nil {
+		return Memory{}, err
+	}
+	total, pageSize := values[0][0], values[1][0]
+	available := (values[2][0] + values[3][0]) * pageSize
+	return Memory{Total: total, Used: total - min(available, total)}, nil
+}
@@ -0,0 +1,20 @@
+//go:build !freebsd
+
+package system
+
+import (
+	"errors"
+	"runtime"
+)
+
+var errNoResources = errors.New(runtime.GOOS + " does not support reading CPU and memory usage")
+
+// ReadCpuUsage returns an error, CPU usage is only read on FreeBSD.
+func ReadCpuUsage() (float64, error) {
+	return 0, errNoResources
+}
+
+// ReadMemory returns an error, memory is only read on FreeBSD.
+func ReadMemory() (Memory, error) {
+	return Memory{}, errNoResources
+}
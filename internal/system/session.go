@@ -0,0 +1,31 @@
+package system
+
+// Kinds of session changes
+const (
+	SessionLock   = "lock"
+	SessionUnlock = "unlock"
+	SessionLogon  = "logon"
+	SessionLogoff = "logoff"
+)
+
+// Session is the state of the user session on the console of the PC.
+type Session struct {
+	LoggedIn bool
+	Locked   bool
+}
+
+// Apply returns the session after change, one of SessionLock, SessionUnlock, SessionLogon or SessionLogoff.
+func (session Session) Apply(change string) Session {
+	switch change {
+	case SessionLock:
+		session.Locked = true
+	case SessionUnlock:
+		session.Locked = false
+	case SessionLogon:
+		session.LoggedIn = true
+	case SessionLogoff:
+		// A new logon starts unlocked
+		session.LoggedIn, session.Locked = false, false
+	}
+	return session
+}
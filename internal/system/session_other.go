@@ -0,0 +1,21 @@
+//go:build !windows
+
+package system
+
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+var errNoSessionNotifications = errors.New(runtime.GOOS + " does not support session notifications")
+
+// CurrentSession returns an error, only Windows reports its sessions.
+func CurrentSession() (Session, error) {
+	return Session{}, errNoSessionNotifications
+}
+
+// WatchSessions returns an error, only Windows reports its sessions.
+func WatchSessions(ctx context.Context, changes chan<- string) error {
+	return errNoSessionNotifications
+}
@@ -0,0 +1,201 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	wtsapi32                             = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSRegisterSessionNotification   = wtsapi32.NewProc("WTSRegisterSessionNotification")
+	procWTSUnRegisterSessionNotification = wtsapi32.NewProc("WTSUnRegisterSessionNotification")
+	procWTSQuerySessionInformationW      = wtsapi32.NewProc("WTSQuerySessionInformationW")
+	procWTSFreeMemory                    = wtsapi32.NewProc("WTSFreeMemory")
+	procWTSGetActiveConsoleSessionId     = kernel32.NewProc("WTSGetActiveConsoleSessionId")
+	procGetModuleHandleW                 = kernel32.NewProc("GetModuleHandleW")
+	procRegisterClassExW                 = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW                  = user32.NewProc("CreateWindowExW")
+	procDestroyWindow                    = user32.NewProc("DestroyWindow")
+	procDefWindowProcW                   = user32.NewProc("DefWindowProcW")
+	procGetMessageW                      = user32.NewProc("GetMessageW")
+	procDispatchMessageW                 = user32.NewProc("DispatchMessageW")
+	procPostMessageW                     = user32.NewProc("PostMessageW")
+	procPostQuitMessage                  = user32.NewProc("PostQuitMessage")
+)
+
+const (
+	wmDestroy            = 0x0002
+	wmClose              = 0x0010
+	wmWtsSessionChange   = 0x02B1
+	notifyForAllSessions = 1
+	// Parent of message-only windows, which are invisible and only receive messages
+	hwndMessage = ^uintptr(2)
+
+	errClassAlreadyExists = syscall.Errno(1410)
+
+	// WTS_INFO_CLASS values
+	wtsUserName    = 5
+	wtsSessionInfo = 25
+	// SessionFlags of WTSINFOEX_LEVEL1_W
+	wtsSessionStateLock = 0
+	noConsoleSession    = 0xFFFFFFFF
+)
+
+// Session changes of WM_WTSSESSION_CHANGE
+// https://learn.microsoft.com/en-us/windows/win32/termserv/wm-wtssession-change
+var sessionChangeKinds = map[uintptr]string{
+	5: SessionLogon,
+	6: SessionLogoff,
+	7: SessionLock,
+	8: SessionUnlock,
+}
+
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   uintptr
+	icon       uintptr
+	cursor     uintptr
+	background uintptr
+	menuName   *uint16
+	className  *uint16
+	iconSm     uintptr
+}
+
+type msg struct {
+	hwnd    uintptr
+	message uint32
+	wParam  uintptr
+	lParam  uintptr
+	time    uint32
+	pt      [2]int32
+	private uint32
+}
+
+var (
+	watchMu sync.Mutex
+	// watchCtx and watchChanges belong to the running WatchSessions, which the window procedure sends to
+	watchCtx     context.Context
+	watchChanges chan<- string
+	// Callbacks are never freed, so all watches share this one
+	sessionWndProc = syscall.NewCallback(func(hwnd uintptr, message uintptr, wParam uintptr, lParam uintptr) uintptr {
+		switch message {
+		case wmWtsSessionChange:
+			if change, ok := sessionChangeKinds[wParam]; ok {
+				select {
+				case watchChanges <- change:
+				case <-watchCtx.Done():
+				}
+			}
+			return 0
+		case wmClose:
+			procWTSUnRegisterSessionNotification.Call(hwnd)
+			procDestroyWindow.Call(hwnd)
+			return 0
+		case wmDestroy:
+			procPostQuitMessage.Call(0)
+			return 0
+		}
+		ret, _, _ := procDefWindowProcW.Call(hwnd, message, wParam, lParam)
+		return ret
+	})
+)
+
+// CurrentSession returns the state of the session on the console, not logged in if nobody is.
+func CurrentSession() (Session, error) {
+	sessionId, _, _ := procWTSGetActiveConsoleSessionId.Call()
+	if sessionId == noConsoleSession {
+		return Session{}, nil
+	}
+
+	userName, err := querySession(sessionId, wtsUserName)
+	if err != nil {
+		return Session{}, err
+	}
+	// The console session exists without anybody logged in, showing the logon screen
+	if len(userName) < 2 || *(*uint16)(unsafe.Pointer(&userName[0])) == 0 {
+		return Session{}, nil
+	}
+
+	info, err := querySession(sessionId, wtsSessionInfo)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(info) < 20 {
+		return Session{}, errors.New("Invalid session information")
+	}
+	// WTSINFOEXW is the level followed by WTSINFOEX_LEVEL1_W, whose SessionFlags are at offset 8
+	flags := *(*int32)(unsafe.Pointer(&info[16]))
+	return Session{LoggedIn: true, Locked: flags == wtsSessionStateLock}, nil
+}
+
+// querySession returns a copy of the information class of the session.
+func querySession(sessionId uintptr, infoClass uintptr) ([]byte, error) {
+	var buf *byte
+	var size uint32
+	if ret, _, err := procWTSQuerySessionInformationW.Call(0, sessionId, infoClass, uintptr(unsafe.Pointer(&buf)), uintptr(unsafe.Pointer(&size))); ret == 0 {
+		return nil, err
+	}
+	defer procWTSFreeMemory.Call(uintptr(unsafe.Pointer(buf)))
+	return append([]byte(nil), unsafe.Slice(buf, size)...), nil
+}
+
+// WatchSessions sends SessionLock, SessionUnlock, SessionLogon and SessionLogoff of all sessions
+// to changes until ctx is done. It receives them through a message-only window, which also
+// works for services without a desktop.
+func WatchSessions(ctx context.Context, changes chan<- string) error {
+	if !watchMu.TryLock() {
+		return errors.New("Sessions are already watched")
+	}
+	defer watchMu.Unlock()
+	watchCtx, watchChanges = ctx, changes
+
+	// The window belongs to this thread, which has to receive its messages
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	instance, _, _ := procGetModuleHandleW.Call(0)
+	className, err := syscall.UTF16PtrFromString("pc2mqttSessions")
+	if err != nil {
+		return err
+	}
+	class := wndClassEx{wndProc: sessionWndProc, instance: instance, className: className}
+	class.size = uint32(unsafe.Sizeof(class))
+	if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&class))); ret == 0 && !errors.Is(err, errClassAlreadyExists) {
+		return err
+	}
+
+	hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0, hwndMessage, 0, instance, 0)
+	if hwnd == 0 {
+		return err
+	}
+	if ret, _, err := procWTSRegisterSessionNotification.Call(hwnd, notifyForAllSessions); ret == 0 {
+		procDestroyWindow.Call(hwnd)
+		return err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		procPostMessageW.Call(hwnd, wmClose, 0, 0)
+	})
+	defer stop()
+
+	var m msg
+	for {
+		ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0)
+		switch int32(ret) {
+		case 0:
+			// WM_QUIT after the window was closed
+			return nil
+		case -1:
+			return err
+		}
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
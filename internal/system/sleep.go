@@ -0,0 +1,44 @@
+package system
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep changes of WatchSleep
+const (
+	SleepSuspend = "suspend"
+	SleepResume  = "resume"
+)
+
+// clockJumpCheck is how often watchClockJumps compares the clocks
+const clockJumpCheck = 5 * time.Second
+
+// minClockJump is the smallest gap between wall clock and monotonic clock taken as sleep. Smaller gaps
+// come from adjusting the time.
+const minClockJump = 30 * time.Second
+
+// watchClockJumps sends SleepResume to changes whenever the wall clock moved further than the monotonic
+// clock, which stops while the PC sleeps, until ctx is done. It can't tell when the PC goes to sleep.
+func watchClockJumps(ctx context.Context, changes chan<- string) error {
+	ticker := time.NewTicker(clockJumpCheck)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			now := time.Now()
+			// Round(0) drops the monotonic reading, so Sub compares the wall clocks
+			if now.Round(0).Sub(last.Round(0))-now.Sub(last) >= minClockJump {
+				select {
+				case changes <- SleepResume:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			last = now
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/leonlatsch/pc2mqtt/internal/dbus"
+)
+
+// WatchSleep sends SleepSuspend before the PC goes to sleep and SleepResume after it woke up to changes,
+// until ctx is done. logind reports both with its PrepareForSleep signal. Without logind resume is
+// detected from the clock jumping.
+func WatchSleep(ctx context.Context, changes chan<- string) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return watchClockJumps(ctx, changes)
+	}
+	defer conn.Close()
+
+	signals := make(chan dbus.Signal, 8)
+	rule := "type='signal',interface='" + logindManager + "',member='PrepareForSleep'"
+	if err := conn.Subscribe(ctx, rule, signals); err != nil {
+		return fmt.Errorf("Subscribing to logind sleep signals failed: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case signal, ok := <-signals:
+			if !ok {
+				return errors.New("logind sleep signals stopped")
+			}
+			if signal.Member != "PrepareForSleep" || len(signal.Body) == 0 {
+				continue
+			}
+			// true before sleeping, false after waking up
+			change := SleepResume
+			if start, _ := signal.Body[0].(bool); start {
+				change = SleepSuspend
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package system
+
+import "context"
+
+// WatchSleep sends SleepResume after the PC woke up to changes, until ctx is done. Without cgo for IOKit
+// on macOS, resume is detected from the clock jumping and going to sleep isn't reported.
+func WatchSleep(ctx context.Context, changes chan<- string) error {
+	return watchClockJumps(ctx, changes)
+}
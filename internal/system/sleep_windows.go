@@ -0,0 +1,76 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procPowerRegisterSuspendResumeNotification   = powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotification = powrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+)
+
+const deviceNotifyCallback = 2
+
+// Power events of the suspend resume notification
+// https://learn.microsoft.com/en-us/windows/win32/power/wm-powerbroadcast
+const (
+	pbtApmSuspend         = 0x4
+	pbtApmResumeAutomatic = 0x12
+)
+
+// deviceNotifySubscribeParameters is DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS.
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+var (
+	sleepWatchMu sync.Mutex
+	// sleepCtx and sleepChanges belong to the running WatchSleep, which the callback sends to
+	sleepCtx     context.Context
+	sleepChanges chan<- string
+	// Callbacks are never freed, so all watches share this one
+	sleepCallback = syscall.NewCallback(func(_ uintptr, event uintptr, _ uintptr) uintptr {
+		var change string
+		switch event {
+		case pbtApmSuspend:
+			change = SleepSuspend
+		case pbtApmResumeAutomatic:
+			// Sent for every wake, also without a user, unlike PBT_APMRESUMESUSPEND
+			change = SleepResume
+		default:
+			return 0
+		}
+		select {
+		case sleepChanges <- change:
+		case <-sleepCtx.Done():
+		}
+		return 0
+	})
+)
+
+// WatchSleep sends SleepSuspend before the PC goes to sleep and SleepResume after it woke up to changes,
+// until ctx is done. Windows reports both to a suspend resume notification, which also works for services.
+func WatchSleep(ctx context.Context, changes chan<- string) error {
+	if !sleepWatchMu.TryLock() {
+		return errors.New("Sleep is already watched")
+	}
+	defer sleepWatchMu.Unlock()
+	sleepCtx, sleepChanges = ctx, changes
+
+	params := &deviceNotifySubscribeParameters{callback: sleepCallback}
+	var handle uintptr
+	if ret, _, _ := procPowerRegisterSuspendResumeNotification.Call(deviceNotifyCallback, uintptr(unsafe.Pointer(params)), uintptr(unsafe.Pointer(&handle))); ret != 0 {
+		return syscall.Errno(ret)
+	}
+	<-ctx.Done()
+	procPowerUnregisterSuspendResumeNotification.Call(handle)
+	// Windows reads the callback from params until unregistered
+	runtime.KeepAlive(params)
+	return ctx.Err()
+}
@@ -0,0 +1,25 @@
+package system
+
+import (
+	"slices"
+	"sync"
+)
+
+// How the PC sleeps, selected with the SleepMode of the power options
+const (
+	// SleepModeSuspend keeps the memory powered, eg. ACPI S3
+	SleepModeSuspend = "suspend"
+	// SleepModeHibernate writes the memory to disk and powers off
+	SleepModeHibernate = "hibernate"
+	// SleepModeHybrid writes the memory to disk and suspends, so the PC resumes from disk after a power loss
+	SleepModeHybrid = "hybrid-sleep"
+)
+
+// supportedSleepModes are detected once, like the hardware info
+var supportedSleepModes = sync.OnceValue(sleepModes)
+
+// SleepModeSupported reports whether this PC can sleep with mode. Suspend is always assumed to work, as before
+// sleep modes could be selected.
+func SleepModeSupported(mode string) bool {
+	return mode == SleepModeSuspend || slices.Contains(supportedSleepModes(), mode)
+}
@@ -0,0 +1,11 @@
+package system
+
+import "os"
+
+// sleepModes returns suspend, and hibernate and hybrid sleep as root, who may change the hibernatemode of pmset.
+func sleepModes() []string {
+	if os.Geteuid() != 0 {
+		return []string{SleepModeSuspend}
+	}
+	return []string{SleepModeSuspend, SleepModeHibernate, SleepModeHybrid}
+}
@@ -0,0 +1,62 @@
+package system
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sleepModes reads the sleep states of the kernel. Hibernating additionally needs a swap with room for the
+// used memory.
+func sleepModes() []string {
+	states, err := os.ReadFile("/sys/power/state")
+	if err != nil {
+		return nil
+	}
+	fields := strings.Fields(string(states))
+	var modes []string
+	suspend := false
+	for _, state := range fields {
+		// freeze is suspend-to-idle, which logind uses where mem is missing
+		if state == "mem" || state == "freeze" {
+			suspend = true
+		}
+	}
+	if suspend {
+		modes = append(modes, SleepModeSuspend)
+	}
+	if !strings.Contains(string(states), "disk") || !swapFitsMemory() {
+		return modes
+	}
+	modes = append(modes, SleepModeHibernate)
+	if disk, err := os.ReadFile("/sys/power/disk"); err == nil && suspend && strings.Contains(string(disk), "suspend") {
+		modes = append(modes, SleepModeHybrid)
+	}
+	return modes
+}
+
+// swapFitsMemory reports whether the free swap holds the used memory, like logind checks before hibernating.
+func swapFitsMemory() bool {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// In kB
+	values := map[string]int64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		values[name], _ = strconv.ParseInt(fields[0], 10, 64)
+	}
+	return values["SwapFree"] > 0 && values["SwapFree"] >= values["MemTotal"]-values["MemAvailable"]
+}
@@ -0,0 +1,32 @@
+//go:build !linux && !windows && !darwin
+
+package system
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// sleepModes reads the ACPI sleep states on FreeBSD, eg. "S3 S4 S5". Hybrid sleep isn't supported.
+func sleepModes() []string {
+	if runtime.GOOS != FREEBSD {
+		return []string{SleepModeSuspend}
+	}
+	out, err := exec.Command("sysctl", "-n", "hw.acpi.supported_sleep_state").Output()
+	if err != nil {
+		return nil
+	}
+
+	var modes []string
+	states := strings.Fields(string(out))
+	for _, state := range states {
+		switch state {
+		case "S3":
+			modes = append(modes, SleepModeSuspend)
+		case "S4":
+			modes = append(modes, SleepModeHibernate)
+		}
+	}
+	return modes
+}
@@ -0,0 +1,38 @@
+package system
+
+import "unsafe"
+
+var procGetPwrCapabilities = powrprof.NewProc("GetPwrCapabilities")
+
+// Offsets of the BOOLEAN fields of SYSTEM_POWER_CAPABILITIES
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-system_power_capabilities
+const (
+	pwrSystemS3         = 5
+	pwrSystemS4         = 6
+	pwrHiberFilePresent = 8
+	pwrAoAc             = 20
+)
+
+// sleepModes asks Windows for the sleep states, like powercfg /a. Hibernating needs a hibernation file,
+// which powercfg /hibernate on creates. Modern standby PCs suspend, but can't sleep hybrid.
+func sleepModes() []string {
+	// SYSTEM_POWER_CAPABILITIES is 76 bytes
+	var capabilities [128]byte
+	if ret, _, _ := procGetPwrCapabilities.Call(uintptr(unsafe.Pointer(&capabilities[0]))); ret == 0 {
+		return nil
+	}
+
+	var modes []string
+	s3 := capabilities[pwrSystemS3] != 0
+	if s3 || capabilities[pwrAoAc] != 0 {
+		modes = append(modes, SleepModeSuspend)
+	}
+	hibernate := capabilities[pwrSystemS4] != 0 && capabilities[pwrHiberFilePresent] != 0
+	if hibernate {
+		modes = append(modes, SleepModeHibernate)
+	}
+	if hibernate && s3 {
+		modes = append(modes, SleepModeHybrid)
+	}
+	return modes
+}
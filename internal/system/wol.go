@@ -0,0 +1,42 @@
+package system
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// WakeOnLanBroadcast is the default address of Wake-on-LAN packets, the limited broadcast on the discard port
+const WakeOnLanBroadcast = "255.255.255.255"
+
+const wakeOnLanPort = "9"
+
+// WakeOnLan sends a Wake-on-LAN magic packet for the machine with the MAC address mac to
+// broadcast, an address with an optional port.
+func WakeOnLan(mac string, broadcast string) error {
+	hardwareAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return err
+	}
+	if _, _, err := net.SplitHostPort(broadcast); err != nil {
+		broadcast = net.JoinHostPort(broadcast, wakeOnLanPort)
+	}
+
+	if dryRun != nil {
+		fmt.Fprintln(dryRun, "Would send Wake-on-LAN packet for "+hardwareAddr.String()+" to "+broadcast)
+		return nil
+	}
+
+	// 6 bytes of 0xff followed by the MAC address 16 times
+	packet := append(bytes.Repeat([]byte{0xff}, 6), bytes.Repeat(hardwareAddr, 16)...)
+	conn, err := net.Dial("udp", broadcast)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("Failed to send Wake-on-LAN packet to %s: %w", broadcast, err)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package tailscale
+
+// socketPaths are the local API sockets of tailscaled on Linux, the BSDs and the open source macOS daemon
+var socketPaths = []string{
+	"/var/run/tailscale/tailscaled.sock",
+	"/run/tailscale/tailscaled.sock",
+	"/var/run/tailscaled.socket",
+}
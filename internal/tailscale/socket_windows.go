@@ -0,0 +1,4 @@
+package tailscale
+
+// tailscaled listens on a named pipe on Windows, which the tailscale CLI reads for us
+var socketPaths []string
@@ -0,0 +1,160 @@
+// Package tailscale reads the connection status of the local Tailscale node from tailscaled.
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStatusAge is how long a read status is reused, so the sensors polled together share one request
+const maxStatusAge = 5 * time.Second
+
+// Status is the part of the status of tailscaled used by pc2mqtt.
+// https://pkg.go.dev/tailscale.com/ipn/ipnstate#Status
+type Status struct {
+	// BackendState is eg. Running, Stopped or NeedsLogin
+	BackendState string   `json:"BackendState"`
+	TailscaleIPs []string `json:"TailscaleIPs"`
+	Peer         map[string]struct {
+		HostName string `json:"HostName"`
+		DNSName  string `json:"DNSName"`
+		ExitNode bool   `json:"ExitNode"`
+	} `json:"Peer"`
+}
+
+// ExitNode returns the name of the peer used as exit node, empty if none is used.
+func (status Status) ExitNode() string {
+	for _, peer := range status.Peer {
+		if !peer.ExitNode {
+			continue
+		}
+		// The DNS name is unique in the tailnet, eg. exit.example.ts.net.
+		if name, _, _ := strings.Cut(peer.DNSName, "."); name != "" {
+			return name
+		}
+		return peer.HostName
+	}
+	return ""
+}
+
+// IP returns the IPv4 address of the node in the tailnet, or its first address if it has none.
+func (status Status) IP() string {
+	for _, ip := range status.TailscaleIPs {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			return ip
+		}
+	}
+	if len(status.TailscaleIPs) > 0 {
+		return status.TailscaleIPs[0]
+	}
+	return ""
+}
+
+var (
+	mu       sync.Mutex
+	cached   Status
+	cachedAt time.Time
+)
+
+// Installed reports whether tailscaled listens on a known socket or the tailscale CLI is installed.
+func Installed() bool {
+	return socketPath() != "" || cliPath() != ""
+}
+
+func socketPath() string {
+	for _, path := range socketPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func cliPath() string {
+	path, err := exec.LookPath("tailscale")
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// GetStatus reads the status from the local API of tailscaled, or from the tailscale CLI
+// where the API isn't reachable through a socket.
+func GetStatus(ctx context.Context) (Status, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if time.Since(cachedAt) < maxStatusAge {
+		return cached, nil
+	}
+
+	var status Status
+	var err error
+	if path := socketPath(); path != "" {
+		status, err = readSocket(ctx, path)
+	} else {
+		status, err = readCli(ctx)
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	cached, cachedAt = status, time.Now()
+	return status, nil
+}
+
+func readSocket(ctx context.Context, path string) (Status, error) {
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	// tailscaled only accepts this host name on its local API
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://local-tailscaled.sock/localapi/v0/status", nil)
+	if err != nil {
+		return Status{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Status{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Status{}, fmt.Errorf("The Tailscale daemon answered %s", resp.Status)
+	}
+
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return Status{}, fmt.Errorf("Invalid Tailscale status: %w", err)
+	}
+	return status, nil
+}
+
+func readCli(ctx context.Context) (Status, error) {
+	path := cliPath()
+	if path == "" {
+		return Status{}, errors.New("Tailscale is not installed")
+	}
+	out, err := exec.CommandContext(ctx, path, "status", "--json").Output()
+	if err != nil {
+		return Status{}, fmt.Errorf("Reading the Tailscale status failed: %w", err)
+	}
+
+	var status Status
+	if err := json.Unmarshal(out, &status); err != nil {
+		return Status{}, fmt.Errorf("Invalid Tailscale status: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,139 @@
+package update
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ChecksumsAsset lists the SHA-256 sums of all release binaries in sha256sum format.
+const ChecksumsAsset = "checksums.txt"
+
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// AssetName is the name of the release binary for the running OS and architecture, eg. pc2mqtt_linux_amd64.
+func AssetName() string {
+	name := "pc2mqtt_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func (release Release) asset(name string) (Asset, bool) {
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Install downloads the release binary for this platform, verifies its checksum
+// and replaces the executable with it. The running process keeps the old binary
+// until it is restarted.
+func Install(ctx context.Context, release Release, executable string) error {
+	binary, ok := release.asset(AssetName())
+	if !ok {
+		return fmt.Errorf("Release %s has no binary %s", release.TagName, AssetName())
+	}
+	checksums, ok := release.asset(ChecksumsAsset)
+	if !ok {
+		return fmt.Errorf("Release %s has no %s", release.TagName, ChecksumsAsset)
+	}
+
+	expected, err := expectedChecksum(ctx, checksums.BrowserDownloadUrl, binary.Name)
+	if err != nil {
+		return err
+	}
+
+	// Download next to the executable, so the final rename stays on one file system
+	tmp, err := os.CreateTemp(filepath.Dir(executable), filepath.Base(executable)+".update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	err = download(ctx, binary.BrowserDownloadUrl, io.MultiWriter(tmp, hash))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("Checksum mismatch for %s: expected %s, got %s", binary.Name, expected, actual)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return replaceExecutable(executable, tmp.Name())
+}
+
+// replaceExecutable moves the running executable aside before moving the new one in,
+// since Windows refuses to overwrite a running binary but allows renaming it.
+func replaceExecutable(executable string, replacement string) error {
+	old := executable + ".old"
+	os.Remove(old)
+
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(replacement, executable); err != nil {
+		os.Rename(old, executable)
+		return err
+	}
+
+	// Fails on Windows while the old binary runs, the next update removes it
+	os.Remove(old)
+	return nil
+}
+
+func expectedChecksum(ctx context.Context, url string, name string) (string, error) {
+	var checksums strings.Builder
+	if err := download(ctx, url, &checksums); err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(checksums.String()))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks binary mode with a leading *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", ChecksumsAsset, name)
+}
+
+func download(ctx context.Context, url string, dst io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Download of %s failed: %s", url, resp.Status)
+	}
+
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
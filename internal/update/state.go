@@ -0,0 +1,78 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The bridge checks for updates periodically and keeps the result for the update entity.
+var (
+	knownMu sync.Mutex
+	known   *Release
+
+	installed     = make(chan struct{})
+	installedOnce sync.Once
+)
+
+// Executable returns the absolute path of the running binary with symlinks resolved.
+// It is resolved once, since the path changes while the binary is replaced.
+var Executable = sync.OnceValues(func() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(executable)
+})
+
+// Check fetches the latest release and remembers it for LatestKnown.
+func Check(ctx context.Context) (Release, error) {
+	release, err := Latest(ctx)
+	if err != nil {
+		return release, err
+	}
+
+	knownMu.Lock()
+	defer knownMu.Unlock()
+	known = &release
+	return release, nil
+}
+
+// LatestKnown returns the release found by the last successful Check.
+func LatestKnown() (Release, bool) {
+	knownMu.Lock()
+	defer knownMu.Unlock()
+	if known == nil {
+		return Release{}, false
+	}
+	return *known, true
+}
+
+// InstallLatest installs the release found by the last Check over the running binary
+// and closes Installed, so the bridge restarts into it.
+func InstallLatest(ctx context.Context) error {
+	release, ok := LatestKnown()
+	if !ok {
+		return errors.New("No release known yet, the update check has not finished")
+	}
+
+	executable, err := Executable()
+	if err != nil {
+		return err
+	}
+
+	if err := Install(ctx, release, executable); err != nil {
+		return err
+	}
+	installedOnce.Do(func() {
+		close(installed)
+	})
+	return nil
+}
+
+// Installed is closed once InstallLatest replaced the running binary.
+func Installed() <-chan struct{} {
+	return installed
+}
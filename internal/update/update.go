@@ -0,0 +1,103 @@
+// Package update looks up pc2mqtt releases on GitHub.
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repository hosting the pc2mqtt releases.
+const Repository = "leonlatsch/pc2mqtt"
+
+var latestReleaseUrl = "https://api.github.com/repos/" + Repository + "/releases/latest"
+
+// Release is the part of the GitHub release API response pc2mqtt uses.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	HtmlUrl string  `json:"html_url"`
+	Body    string  `json:"body"`
+	Assets  []Asset `json:"assets"`
+}
+
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Latest returns the latest published release.
+func Latest(ctx context.Context) (Release, error) {
+	var release Release
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, latestReleaseUrl, nil)
+	if err != nil {
+		return release, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return release, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return release, fmt.Errorf("GitHub answered %s for %s", resp.Status, latestReleaseUrl)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	return release, err
+}
+
+// IsRelease reports whether version is a release version like v1.2.3 and not eg. a dev build.
+func IsRelease(version string) bool {
+	_, ok := parseVersion(version)
+	return ok
+}
+
+// IsNewer reports whether the release version latest is newer than current. It is false if either
+// is no release version, like dev builds, which can't be compared to releases.
+func IsNewer(latest string, current string) bool {
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for i := range latestParts {
+		if latestParts[i] != currentParts[i] {
+			return latestParts[i] > currentParts[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses major.minor.patch of versions like v1.2.3, ignoring pre-release and build suffixes.
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	fields := strings.Split(version, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = number
+	}
+	return parts, true
+}
@@ -0,0 +1,53 @@
+package version
+
+import "runtime/debug"
+
+// Version, Commit and Date are set at build time, eg. with
+// -ldflags "-X github.com/leonlatsch/pc2mqtt/internal/version.Version=v1.2.3".
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// Get returns the pc2mqtt version, falling back to the module version for go install builds.
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "dev"
+}
+
+// GetCommit returns the git commit pc2mqtt was built from, falling back to the VCS info Go embeds.
+func GetCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	return buildSetting("vcs.revision")
+}
+
+// GetDate returns the build date, falling back to the time of the commit Go embeds.
+func GetDate() string {
+	if Date != "" {
+		return Date
+	}
+	return buildSetting("vcs.time")
+}
+
+func buildSetting(key string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == key {
+			return setting.Value
+		}
+	}
+	return ""
+}
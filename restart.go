@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+
+	"github.com/leonlatsch/pc2mqtt/internal/appconfig"
+)
+
+// restartEnv returns the environment of the restarted binary, with the passphrase taken out of it at startup.
+func restartEnv() []string {
+	env := os.Environ()
+	if passphrase := appconfig.Passphrase(); passphrase != "" {
+		env = append(env, appconfig.PassphraseEnv+"="+passphrase)
+	}
+	return env
+}
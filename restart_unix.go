@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+)
+
+// restart replaces the process with the updated binary, keeping the pid for the service manager.
+func restart() error {
+	executable, err := update.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(executable, append([]string{executable}, os.Args[1:]...), restartEnv())
+}
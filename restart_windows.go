@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/leonlatsch/pc2mqtt/internal/update"
+)
+
+// restart starts the updated binary with the same arguments and exits, since Windows cannot
+// replace a running process. Services are restarted by the service manager instead.
+func restart() error {
+	executable, err := update.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = restartEnv()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}